@@ -253,6 +253,15 @@ defmodule TuiAcp.ConnectionHandler do
     end
   end
 
+  # Unknown requests get a method-not-found error, so the client (and its
+  # heartbeat) is not left waiting; unknown notifications need no reply
+  defp process_message(%{"method" => method, "id" => id} = msg, state) when not is_nil(id) do
+    Logger.warning("Unknown method: #{method}")
+    Logger.debug("Full message: #{inspect(msg)}")
+    send_error(state.socket, id, -32601, "Method not found: #{method}")
+    state
+  end
+
   defp process_message(%{"method" => method} = msg, state) do
     Logger.warning("Unknown method: #{method}")
     Logger.warning("Full message: #{inspect(msg)}")
@@ -274,6 +283,11 @@ defmodule TuiAcp.ConnectionHandler do
     send_raw(socket, json)
   end
 
+  defp send_error(socket, id, code, message) do
+    json = JsonRpc.encode_error(id, code, message)
+    send_raw(socket, json)
+  end
+
   defp send_notification(socket, method, params) do
     # Convert to camelCase for TCP protocol
     camel_params = %{
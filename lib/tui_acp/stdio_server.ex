@@ -130,6 +130,14 @@ defmodule TuiAcp.StdioServer do
     {:noreply, state}
   end
 
+  # Unknown requests get a method-not-found error so the client isn't left
+  # waiting; unknown notifications need no reply
+  defp handle_message(%{"method" => method, "id" => id}, state) when not is_nil(id) do
+    Logger.warning("Unknown method: #{method}")
+    send_error(id, -32601, "Method not found: #{method}")
+    {:noreply, state}
+  end
+
   defp handle_message(%{"method" => method}, state) do
     Logger.warning("Unknown method: #{method}")
     {:noreply, state}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/ron/tui_acp/tui/client"
+	"github.com/ron/tui_acp/tui/clock"
 	"github.com/ron/tui_acp/tui/logger"
 )
 
@@ -22,28 +25,166 @@ const (
 	MessageError      MessageType = "error"
 	MessageDebug      MessageType = "debug"
 	MessageInfo       MessageType = "info"
+	MessageThought    MessageType = "thought"
+	MessagePlan       MessageType = "plan"
 )
 
 // Message represents a conversation message
 type Message struct {
-	Type    MessageType
-	Content string
-	Data    interface{} // Optional structured data
+	ID      int64       `json:"id,omitempty"` // Unique in the conversation, increasing in order
+	Type    MessageType `json:"type"`
+	Content string      `json:"content"`
+	Data    interface{} `json:"data,omitempty"` // Optional structured data
+
+	// Time is when the message entered the conversation; zero for messages saved
+	// before messages carried times
+	Time time.Time `json:"time,omitzero"`
+
+	// Continued marks a later part of a response that was flushed in parts
+	// (see FlushPolicy); it carries on from the message before it
+	Continued bool `json:"continued,omitempty"`
+}
+
+// UpdateKind identifies what an UpdateEvent signals
+type UpdateKind string
+
+const (
+	UpdateChunk      UpdateKind = "chunk"       // Streaming text was appended
+	UpdateComplete   UpdateKind = "complete"    // The agent finished its response
+	UpdateToolInput  UpdateKind = "tool-input"  // A tool call started
+	UpdateToolOutput UpdateKind = "tool-output" // A tool call finished
+	UpdateError      UpdateKind = "error"       // Sending a prompt failed
+	UpdatePermission UpdateKind = "permission"  // The user must answer Prompt
+	UpdatePromptDone UpdateKind = "prompt-done" // Prompt stopped waiting before it was answered
+	UpdateConnection UpdateKind = "connection"  // The connection or session changed
+	UpdateMode       UpdateKind = "mode"        // The session's modes or current mode changed
+)
+
+// UpdateEvent notifies the UI that conversation state has changed
+type UpdateEvent struct {
+	Kind    UpdateKind
+	Text    string   // Chunk text, or the formatted summary for tool events
+	Method  string   // Tool method name for tool events
+	Message *Message // Message added to the conversation for tool events
+	Err     error    // Error for UpdateError and failed tool calls
+
+	// ToolCallID identifies the call across the tool events of a call the
+	// agent reports, whose input, progress and output share it
+	ToolCallID string
+
+	Prompt *PermissionPrompt // Question to answer for UpdatePermission and UpdatePromptDone
+
+	Lifecycle *client.LifecycleEvent // Transition for UpdateConnection
+
+	Modes []client.SessionMode // The session's modes for UpdateMode; Text is the current one
+}
+
+// yesNoOptions are the answers to a plain yes/no question
+var yesNoOptions = []client.PermissionOption{
+	{Name: "Allow", Allow: true},
+	{Name: "Deny", Allow: false},
+}
+
+// PermissionPrompt is a question put to the user on behalf of the agent, answered
+// by picking one of its options. The UI must call Choose or Answer once; later
+// calls are ignored.
+type PermissionPrompt struct {
+	Question string
+	Options  []client.PermissionOption
+
+	answer chan int
+	once   sync.Once
+}
+
+// NewPermissionPrompt creates a prompt waiting for an answer. Without options it
+// is a yes/no question.
+func NewPermissionPrompt(question string, options ...client.PermissionOption) *PermissionPrompt {
+	if len(options) == 0 {
+		options = yesNoOptions
+	}
+	return &PermissionPrompt{
+		Question: question,
+		Options:  options,
+		answer:   make(chan int, 1),
+	}
+}
+
+// Choose records the option the user picked. An index outside Options dismisses
+// the prompt without an answer.
+func (p *PermissionPrompt) Choose(index int) {
+	p.once.Do(func() {
+		if index < 0 || index >= len(p.Options) {
+			index = -1
+		}
+		p.answer <- index
+	})
+}
+
+// Answer picks the first option that allows or denies, dismissing the prompt
+// when there is no such option
+func (p *PermissionPrompt) Answer(allow bool) {
+	p.Choose(p.FirstOption(allow))
+}
+
+// FirstOption returns the index of the first option that allows or denies, or -1
+func (p *PermissionPrompt) FirstOption(allow bool) int {
+	for i, opt := range p.Options {
+		if opt.Allow == allow {
+			return i
+		}
+	}
+	return -1
 }
 
 // App manages the business logic for the chat application
 type App struct {
 	mu             sync.RWMutex
+	connectMu      sync.Mutex // Serializes Connect, which runs without holding mu
 	client         *client.ACPClient
+	clientConfig   client.Config
 	conversation   *ConversationManager
 	logger         logger.Logger
-	updateCallback func(string)
+	updateCallback func(UpdateEvent)
+	cancelPrompt   context.CancelFunc // Cancels the prompt in flight, if any
+	showThoughts   bool
+	sessionFile    string
+	resume         bool
+	onLifecycle    func(client.LifecycleEvent)
+	renderMarkdown func([]Message) string
 }
 
 // Config contains configuration for creating an App
 type Config struct {
 	Logger         logger.Logger
-	UpdateCallback func(string) // Called when a message chunk is received
+	UpdateCallback func(UpdateEvent) // Called when the conversation changes
+	Clock          clock.Clock       // Time source for the conversation (nil = system clock)
+
+	// TrimResponses drops trailing whitespace from finished agent responses
+	TrimResponses bool
+	// DetectStreamedErrors shows agent responses that read as errors as errors
+	DetectStreamedErrors bool
+	// FlushPolicy decides when streamed response text becomes a message
+	// ("" = FlushOnComplete)
+	FlushPolicy FlushPolicy
+	// MaxMessages keeps at most this many messages, dropping the oldest
+	// (0 = no limit)
+	MaxMessages int
+	// ShowThoughts keeps the agent's reasoning in the conversation as MessageThought
+	ShowThoughts bool
+	// SessionFile remembers the agent session between launches ("" = not remembered)
+	SessionFile string
+	// Resume reconnects to the session remembered in SessionFile for the same address
+	Resume bool
+	// OnLifecycle is called on connect, session creation and disconnect, for
+	// embedders that keep their own connection state (nil = not called)
+	OnLifecycle func(client.LifecycleEvent)
+	// RenderMarkdown renders the conversation for Markdown exports, so they are
+	// labelled as in the UI (nil = labelled by message type)
+	RenderMarkdown func([]Message) string
+
+	// Client holds the base ACP client configuration.
+	// Address, Logger and Handler are filled in by Connect.
+	Client client.Config
 }
 
 // New creates a new App instance
@@ -52,29 +193,66 @@ func New(cfg Config) *App {
 		cfg.Logger = logger.NewNoopLogger()
 	}
 
+	conversation := NewConversationManagerWithClock(cfg.Clock)
+	conversation.SetTrimTrailingWhitespace(cfg.TrimResponses)
+	conversation.SetDetectStreamedErrors(cfg.DetectStreamedErrors)
+	if cfg.FlushPolicy != "" {
+		conversation.SetFlushPolicy(cfg.FlushPolicy)
+	}
+	conversation.SetMaxMessages(cfg.MaxMessages)
+
 	return &App{
 		logger:         cfg.Logger,
 		updateCallback: cfg.UpdateCallback,
-		conversation:   NewConversationManager(),
+		clientConfig:   cfg.Client,
+		sessionFile:    cfg.SessionFile,
+		resume:         cfg.Resume,
+		onLifecycle:    cfg.OnLifecycle,
+		renderMarkdown: cfg.RenderMarkdown,
+		showThoughts:   cfg.ShowThoughts,
+		conversation:   conversation,
 	}
 }
 
-// Connect establishes a connection to the ACP server
+// Connect establishes a connection to the ACP server. mu is not held while
+// connecting, so the UI can read the app's state while the handshake runs and
+// as lifecycle events arrive from it.
 func (a *App) Connect(ctx context.Context, address string) error {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+	a.connectMu.Lock()
+	defer a.connectMu.Unlock()
+
+	cfg := a.clientConfig
+	cfg.Address = address
+	cfg.Logger = a.logger
+	cfg.Handler = a
+
+	if a.resume && a.sessionFile != "" {
+		state, err := LoadSessionState(a.sessionFile)
+		switch {
+		case err != nil:
+			a.logger.Warn("Not resuming: %v", err)
+		case state.SessionID != "" && state.Address == address:
+			a.logger.Info("Resuming session %s", state.SessionID)
+			cfg.SessionID = state.SessionID
+		}
+	}
 
-	acpClient, err := client.NewACPClient(client.Config{
-		Address: address,
-		Logger:  a.logger,
-		Handler: a,
-	})
+	acpClient, err := client.NewACPClient(ctx, cfg)
 	if err != nil {
 		return err
 	}
 
+	a.mu.Lock()
 	a.client = acpClient
-	a.logger.Info("Connected to ACP server at %s", address)
+	a.mu.Unlock()
+	a.logger.Info("Connected to ACP server at %s (session %s)", address, acpClient.SessionID())
+
+	if a.sessionFile != "" {
+		state := SessionState{Address: address, SessionID: acpClient.SessionID()}
+		if err := SaveSessionState(a.sessionFile, state); err != nil {
+			a.logger.Warn("Session won't be resumable: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -83,6 +261,37 @@ func (a *App) AddUserMessage(text string) {
 	a.conversation.AddUserMessage(text)
 }
 
+// Search finds the messages containing query; see ConversationManager.Search
+func (a *App) Search(query string, caseSensitive bool) []SearchHit {
+	return a.conversation.Search(query, caseSensitive)
+}
+
+// EditMessage replaces the content of the message with the given ID
+func (a *App) EditMessage(id int64, content string) error {
+	return a.conversation.EditMessage(id, content)
+}
+
+// RetryLastUserMessage drops everything after the last user message and sends
+// it to the agent again, returning the message. The agent keeps its own history
+// of the session, so it sees the message as asked again rather than the earlier
+// answer undone. The prompt is sent in the background; failures arrive as
+// UpdateError events.
+func (a *App) RetryLastUserMessage(ctx context.Context) (Message, error) {
+	a.mu.RLock()
+	client := a.client
+	a.mu.RUnlock()
+	if client == nil {
+		return Message{}, fmt.Errorf("not connected")
+	}
+
+	msg, ok := a.conversation.RewindToLastUserMessage()
+	if !ok {
+		return Message{}, fmt.Errorf("no message to retry")
+	}
+	go a.sendPrompt(ctx, client, msg.Content)
+	return msg, nil
+}
+
 // SendPromptToAgent sends a prompt to the agent (without adding to messages)
 func (a *App) SendPromptToAgent(ctx context.Context, text string) error {
 	a.mu.RLock()
@@ -90,7 +299,7 @@ func (a *App) SendPromptToAgent(ctx context.Context, text string) error {
 	a.mu.RUnlock()
 
 	if client != nil {
-		return client.SendPrompt(ctx, text)
+		return a.sendPrompt(ctx, client, text)
 	}
 
 	return nil
@@ -105,72 +314,300 @@ func (a *App) SendMessage(ctx context.Context, text string) error {
 	a.mu.RUnlock()
 
 	if client != nil {
-		return client.SendPrompt(ctx, text)
+		return a.sendPrompt(ctx, client, text)
 	}
 
 	return nil
 }
 
+// sendPrompt sends a prompt that CancelPrompt can cancel. Cancellation is
+// returned as an error but not reported to the UI, which already knows.
+func (a *App) sendPrompt(ctx context.Context, acpClient *client.ACPClient, text string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	a.mu.Lock()
+	a.cancelPrompt = cancel
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		a.cancelPrompt = nil
+		a.mu.Unlock()
+		cancel()
+	}()
+
+	err := acpClient.SendPrompt(ctx, text)
+	if err != nil && ctx.Err() != nil {
+		a.logger.Info("Prompt cancelled: %v", err)
+		return err
+	}
+	return a.notifyError(err)
+}
+
+// CancelPrompt cancels the prompt in flight, which also tells the agent to stop
+// with a session/cancel notification. It reports whether there was one to cancel.
+func (a *App) CancelPrompt() bool {
+	a.mu.RLock()
+	cancel := a.cancelPrompt
+	a.mu.RUnlock()
+
+	if cancel == nil {
+		return false
+	}
+	a.logger.Info("Cancelling prompt")
+	cancel()
+	return true
+}
+
+// notifyError forwards a non-nil error to the UI as an UpdateError event and returns it
+func (a *App) notifyError(err error) error {
+	if err != nil {
+		a.notify(UpdateEvent{Kind: UpdateError, Err: err})
+	}
+	return err
+}
+
 // OnMessageChunk implements the MessageHandler interface
 func (a *App) OnMessageChunk(ctx context.Context, text string) error {
 	a.conversation.AppendToCurrentResponse(text)
+	a.notify(UpdateEvent{Kind: UpdateChunk, Text: text})
 
-	if a.updateCallback != nil {
-		a.updateCallback(text)
+	return nil
+}
+
+// OnThoughtChunk implements the ThoughtHandler interface.
+// Reasoning is kept apart from the response, or dropped when thoughts are hidden.
+func (a *App) OnThoughtChunk(ctx context.Context, text string) error {
+	if !a.showThoughts {
+		return nil
 	}
+	a.conversation.AppendToCurrentThought(text)
+	a.notify(UpdateEvent{Kind: UpdateChunk})
 
 	return nil
 }
 
+// OnAttachment implements the AttachmentHandler interface.
+// The placeholder for an image or resource is kept in order with the response.
+func (a *App) OnAttachment(ctx context.Context, description string) error {
+	a.conversation.FlushCurrentResponse()
+
+	msg := Message{
+		Type:    MessageInfo,
+		Content: description,
+	}
+	a.conversation.AddMessage(msg)
+	a.notify(UpdateEvent{Kind: UpdateChunk, Message: &msg})
+
+	return nil
+}
+
+// OnPlan implements the PlanHandler interface.
+// The plan is shown as a checklist each time the agent revises it.
+func (a *App) OnPlan(ctx context.Context, entries []client.PlanEntry) error {
+	a.conversation.FlushCurrentResponse()
+
+	msg := Message{
+		Type:    MessagePlan,
+		Content: formatPlan(entries),
+		Data:    entries,
+	}
+	a.conversation.AddMessage(msg)
+	a.notify(UpdateEvent{Kind: UpdateChunk, Message: &msg})
+
+	return nil
+}
+
+// OnToolCall implements the ToolCallHandler interface.
+// A tool call run by the agent is shown like one run through the client: its input
+// when first reported, its output with the statuses it went through once done.
+func (a *App) OnToolCall(ctx context.Context, call client.ToolCall) error {
+	if !call.Done() {
+		if call.Updates > 0 {
+			// Progress on a call already shown; only the running tool changes
+			a.notify(UpdateEvent{Kind: UpdateToolInput, Method: call.Title, ToolCallID: call.ID})
+			return nil
+		}
+		a.addToolInput(call.Title, toolCallParams(call), call.ID)
+		return nil
+	}
+
+	// A call reported already finished still shows what it was given
+	if call.Updates == 0 {
+		a.addToolInput(call.Title, toolCallParams(call), call.ID)
+	}
+
+	var err error
+	if call.Failed() {
+		err = fmt.Errorf("tool call failed")
+		if call.Output != nil {
+			err = fmt.Errorf("%v", call.Output)
+		}
+	}
+	method := fmt.Sprintf("%s (%s)", call.Title, strings.Join(call.Statuses, " → "))
+	a.addToolOutput(method, call.Output, err, call.ID)
+	return nil
+}
+
 // OnMessageComplete implements the MessageHandler interface
 // Called when the agent has finished sending a response
 func (a *App) OnMessageComplete(ctx context.Context) error {
 	a.conversation.FlushCurrentResponse()
+	a.notify(UpdateEvent{Kind: UpdateComplete})
 
+	return nil
+}
+
+// notify delivers an update event to the UI if a callback is configured
+func (a *App) notify(event UpdateEvent) {
 	if a.updateCallback != nil {
-		a.updateCallback("")
+		a.updateCallback(event)
+	}
+}
+
+// askPermission puts a yes/no question to the user and waits for the answer.
+// Without a UI to ask the answer is no.
+func (a *App) askPermission(ctx context.Context, question string) (bool, error) {
+	index, err := a.askChoice(ctx, question, yesNoOptions)
+	if err != nil || index < 0 {
+		return false, err
+	}
+	return yesNoOptions[index].Allow, nil
+}
+
+// askChoice puts a question to the user and waits for the index of the option
+// they pick, or -1 if they dismiss it. Without a UI to ask it returns -1.
+// If ctx ends first the UI is told to stop showing the question.
+func (a *App) askChoice(ctx context.Context, question string, options []client.PermissionOption) (int, error) {
+	if a.updateCallback == nil {
+		return -1, nil
 	}
 
+	prompt := NewPermissionPrompt(question, options...)
+	a.notify(UpdateEvent{Kind: UpdatePermission, Text: question, Prompt: prompt})
+
+	select {
+	case index := <-prompt.answer:
+		return index, nil
+	case <-ctx.Done():
+		prompt.Choose(-1)
+		a.notify(UpdateEvent{Kind: UpdatePromptDone, Text: question, Prompt: prompt})
+		return -1, ctx.Err()
+	}
+}
+
+// OnPermissionRequest implements the PermissionHandler interface
+// Called when the agent asks before running a tool call
+func (a *App) OnPermissionRequest(ctx context.Context, title string, options []client.PermissionOption) (int, error) {
+	a.logger.Info("Agent requests permission for %s", title)
+	return a.askChoice(ctx, fmt.Sprintf("Allow the agent to run %s?", title), options)
+}
+
+// OnLifecycleEvent implements the LifecycleHandler interface
+// Called when the connection or session changes
+func (a *App) OnLifecycleEvent(event client.LifecycleEvent) {
+	if event.Err != nil {
+		a.logger.Warn("Connection %s: %v", event.Kind, event.Err)
+	} else {
+		a.logger.Debug("Connection %s (session %s)", event.Kind, event.SessionID)
+	}
+	if a.onLifecycle != nil {
+		a.onLifecycle(event)
+	}
+	a.notify(UpdateEvent{Kind: UpdateConnection, Text: string(event.Kind), Lifecycle: &event, Err: event.Err})
+}
+
+// OnModeChange implements the ModeHandler interface.
+// Called when the session advertises its modes and when the current mode changes.
+func (a *App) OnModeChange(ctx context.Context, modes []client.SessionMode, current string) error {
+	a.logger.Debug("Session mode %s of %d", current, len(modes))
+	a.notify(UpdateEvent{Kind: UpdateMode, Text: current, Modes: modes})
 	return nil
 }
 
+// Modes returns the agent's session modes and the ID of the current one, or
+// nothing when not connected or the agent has no modes
+func (a *App) Modes() ([]client.SessionMode, string) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.client == nil {
+		return nil, ""
+	}
+	return a.client.Modes()
+}
+
+// SetMode switches the session to the mode with the given ID or name, ignoring case
+func (a *App) SetMode(ctx context.Context, name string) error {
+	a.mu.RLock()
+	c := a.client
+	a.mu.RUnlock()
+	if c == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	modes, _ := c.Modes()
+	names := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		if strings.EqualFold(mode.ID, name) || strings.EqualFold(mode.Name, name) {
+			return c.SetMode(ctx, mode.ID)
+		}
+		names = append(names, mode.Name)
+	}
+	if len(modes) == 0 {
+		return fmt.Errorf("the agent has no session modes")
+	}
+	return fmt.Errorf("unknown mode %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// OnWritePermissionRequest implements the WritePermissionHandler interface
+// Called the first time the agent writes in a directory outside the working directory
+func (a *App) OnWritePermissionRequest(ctx context.Context, dir string) (bool, error) {
+	a.logger.Info("Agent requests write access to %s", dir)
+	return a.askPermission(ctx, fmt.Sprintf("Allow the agent to write files in %s for this session?", dir))
+}
+
 // OnToolInput implements the ToolMessageHandler interface
 // Called when a tool is about to be executed
 func (a *App) OnToolInput(ctx context.Context, method string, params map[string]interface{}) error {
+	a.addToolInput(method, params, "")
+	return nil
+}
+
+// addToolInput shows a tool call about to run. callID is the agent's ID for
+// a call it reports, "" for one run through the client.
+func (a *App) addToolInput(method string, params map[string]interface{}, callID string) {
 	// Flush any pending response before showing tool call
 	a.conversation.FlushCurrentResponse()
 
 	// Format tool input message
 	content := formatToolInput(method, params)
-	a.conversation.AddMessage(Message{
+	msg := Message{
 		Type:    MessageToolInput,
 		Content: content,
 		Data:    params,
-	})
-
-	if a.updateCallback != nil {
-		a.updateCallback(content)
 	}
-
-	return nil
+	a.conversation.AddMessage(msg)
+	a.notify(UpdateEvent{Kind: UpdateToolInput, Text: content, Method: method, Message: &msg, ToolCallID: callID})
 }
 
 // OnToolOutput implements the ToolMessageHandler interface
 // Called when a tool has finished executing
 func (a *App) OnToolOutput(ctx context.Context, method string, result interface{}, err error) error {
+	a.addToolOutput(method, result, err, "")
+	return nil
+}
+
+// addToolOutput shows the result of a tool call, with callID as for addToolInput
+func (a *App) addToolOutput(method string, result interface{}, err error, callID string) {
 	// Format tool output message
 	content := formatToolOutput(method, result, err)
-	a.conversation.AddMessage(Message{
+	msg := Message{
 		Type:    MessageToolOutput,
 		Content: content,
 		Data:    result,
-	})
-
-	if a.updateCallback != nil {
-		a.updateCallback(content)
 	}
-
-	return nil
+	a.conversation.AddMessage(msg)
+	a.notify(UpdateEvent{Kind: UpdateToolOutput, Text: content, Method: method, Message: &msg, Err: err, ToolCallID: callID})
 }
 
 // formatToolInput formats tool input for display
@@ -179,18 +616,60 @@ func formatToolInput(method string, params map[string]interface{}) string {
 	switch method {
 	case "_fs/grep_search":
 		pattern, _ := params["pattern"].(string)
-		path, _ := params["path"].(string)
-		if path == "" {
-			path = "."
-		}
+		path := pathsSummary(params, ".")
 		return fmt.Sprintf("%s: pattern=%q path=%q", method, pattern, path)
 	case "_fs/list_dirs":
-		path, _ := params["path"].(string)
-		if path == "" {
-			path = "."
-		}
+		path := pathsSummary(params, ".")
 		recursive, _ := params["recursive"].(bool)
+		if maxDepth, ok := params["maxDepth"].(float64); ok && maxDepth > 0 {
+			return fmt.Sprintf("%s: path=%q recursive=%v maxDepth=%d", method, path, recursive, int(maxDepth))
+		}
 		return fmt.Sprintf("%s: path=%q recursive=%v", method, path, recursive)
+	case "_fs/find_files":
+		namePattern, _ := params["namePattern"].(string)
+		path := pathsSummary(params, ".")
+		return fmt.Sprintf("%s: namePattern=%q path=%q", method, namePattern, path)
+	case "_fs/stat":
+		path := pathsSummary(params, "")
+		return fmt.Sprintf("%s: path=%q", method, path)
+	case "_fs/read_bytes":
+		path, _ := params["path"].(string)
+		offset, _ := params["offset"].(float64)
+		if length, ok := params["length"].(float64); ok && length > 0 {
+			return fmt.Sprintf("%s: path=%q offset=%d length=%d", method, path, int64(offset), int64(length))
+		}
+		return fmt.Sprintf("%s: path=%q offset=%d", method, path, int64(offset))
+	case "_fs/tail":
+		path, _ := params["path"].(string)
+		if lines, ok := params["lines"].(float64); ok {
+			return fmt.Sprintf("%s: path=%q lines=%d", method, path, int(lines))
+		}
+		return fmt.Sprintf("%s: path=%q", method, path)
+	case "_fs/watch":
+		path, _ := params["path"].(string)
+		if mode, _ := params["mode"].(string); mode != "" {
+			return fmt.Sprintf("%s: path=%q mode=%s", method, path, mode)
+		}
+		return fmt.Sprintf("%s: path=%q", method, path)
+	case "_fs/unwatch":
+		watchID, _ := params["watchId"].(string)
+		return fmt.Sprintf("%s: watchId=%s", method, watchID)
+	case "_fs/delete":
+		path, _ := params["path"].(string)
+		if recursive, _ := params["recursive"].(bool); recursive {
+			return fmt.Sprintf("%s: path=%q recursive=true", method, path)
+		}
+		return fmt.Sprintf("%s: path=%q", method, path)
+	case "terminal":
+		command, _ := params["command"].(string)
+		if args, ok := params["args"].([]string); ok && len(args) > 0 {
+			command += " " + strings.Join(args, " ")
+		}
+		return fmt.Sprintf("%s: $ %s (in %v)", method, command, params["cwd"])
+	case "_fs/move":
+		source, _ := params["source"].(string)
+		destination, _ := params["destination"].(string)
+		return fmt.Sprintf("%s: %q -> %q", method, source, destination)
 	default:
 		// Fallback to JSON
 		paramsJSON, _ := json.Marshal(params)
@@ -198,6 +677,67 @@ func formatToolInput(method string, params map[string]interface{}) string {
 	}
 }
 
+// formatPlan renders plan entries as a checklist, one entry per line
+func formatPlan(entries []client.PlanEntry) string {
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		mark := "[ ]"
+		switch entry.Status {
+		case "completed":
+			mark = "[x]"
+		case "in_progress":
+			mark = "[~]"
+		}
+		line := mark + " " + entry.Content
+		if entry.Priority == "high" {
+			line += " (high priority)"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// toolCallParams presents a tool call's input as params for formatToolInput
+func toolCallParams(call client.ToolCall) map[string]interface{} {
+	params := map[string]interface{}{}
+	if input, ok := call.Input.(map[string]interface{}); ok {
+		for key, value := range input {
+			params[key] = value
+		}
+	} else if call.Input != nil {
+		params["input"] = call.Input
+	}
+	if call.Kind != "" {
+		params["kind"] = call.Kind
+	}
+	return params
+}
+
+// pathsSummary renders the path or paths param of an _fs/* call for display,
+// falling back to def when neither is given
+func pathsSummary(params map[string]interface{}, def string) string {
+	value, ok := params["paths"]
+	if !ok {
+		value = params["path"]
+	}
+
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return v
+		}
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		if len(parts) > 0 {
+			return strings.Join(parts, ", ")
+		}
+	}
+	return def
+}
+
 // formatToolOutput formats tool output for display
 func formatToolOutput(method string, result interface{}, err error) string {
 	if err != nil {
@@ -224,6 +764,71 @@ func formatToolOutput(method string, result interface{}, err error) string {
 			}
 			return fmt.Sprintf("%s: %d entries", method, count)
 		}
+	case "_fs/find_files":
+		if res, ok := result.(map[string]interface{}); ok {
+			files, _ := res["files"].([]string)
+			truncated, _ := res["truncated"].(bool)
+			if truncated {
+				return fmt.Sprintf("%s: %d files (truncated)", method, len(files))
+			}
+			return fmt.Sprintf("%s: %d files", method, len(files))
+		}
+	case "_fs/stat":
+		if res, ok := result.(map[string]interface{}); ok {
+			if stats, ok := res["stats"].([]map[string]interface{}); ok {
+				return fmt.Sprintf("%s: %d paths", method, len(stats))
+			}
+			if exists, _ := res["exists"].(bool); !exists {
+				return fmt.Sprintf("%s: not found", method)
+			}
+			if isDir, _ := res["isDir"].(bool); isDir {
+				return fmt.Sprintf("%s: directory (mode %v)", method, res["mode"])
+			}
+			return fmt.Sprintf("%s: file, %v bytes (mode %v)", method, res["size"], res["mode"])
+		}
+	case "_fs/read_bytes":
+		if res, ok := result.(map[string]interface{}); ok {
+			if truncated, _ := res["truncated"].(bool); truncated {
+				return fmt.Sprintf("%s: %v bytes (truncated)", method, res["length"])
+			}
+			return fmt.Sprintf("%s: %v bytes", method, res["length"])
+		}
+	case "_fs/tail":
+		if res, ok := result.(map[string]interface{}); ok {
+			return fmt.Sprintf("%s: %v lines", method, res["lines"])
+		}
+	case "_fs/watch":
+		if res, ok := result.(map[string]interface{}); ok {
+			return fmt.Sprintf("%s: %v watching %v (%v)", method, res["watchId"], res["path"], res["mode"])
+		}
+	case "_fs/watch_list":
+		if res, ok := result.(map[string]interface{}); ok {
+			return fmt.Sprintf("%s: %v active", method, res["count"])
+		}
+	case "_fs/unwatch":
+		if res, ok := result.(map[string]interface{}); ok {
+			return fmt.Sprintf("%s: stopped watching %v", method, res["path"])
+		}
+	case "_fs/delete":
+		if res, ok := result.(map[string]interface{}); ok {
+			return fmt.Sprintf("%s: deleted %v", method, res["path"])
+		}
+	case "_fs/move":
+		if res, ok := result.(map[string]interface{}); ok {
+			return fmt.Sprintf("%s: moved to %v", method, res["destination"])
+		}
+	case "terminal":
+		if res, ok := result.(map[string]interface{}); ok {
+			status := fmt.Sprintf("exit %v", res["exitCode"])
+			if signal, ok := res["signal"]; ok {
+				status = fmt.Sprintf("killed by %v", signal)
+			}
+			output, _ := res["output"].(string)
+			if tail := outputTail(output, terminalOutputLines); tail != "" {
+				return fmt.Sprintf("%s: %s\n%s", method, status, tail)
+			}
+			return fmt.Sprintf("%s: %s", method, status)
+		}
 	}
 
 	// Fallback to JSON (truncated if too long)
@@ -235,6 +840,21 @@ func formatToolOutput(method string, result interface{}, err error) string {
 	return fmt.Sprintf("%s: %s", method, summary)
 }
 
+// terminalOutputLines is how many lines of a command's output are shown with its result
+const terminalOutputLines = 10
+
+// outputTail returns the last n lines of output, noting how many were left out
+func outputTail(output string, n int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return ""
+	}
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprintf("… %d earlier lines\n%s", len(lines)-n, strings.Join(lines[len(lines)-n:], "\n"))
+}
+
 // GetMessages returns the messages slice (not a copy for efficiency).
 // Callers should not modify the returned slice.
 func (a *App) GetMessages() []Message {
@@ -253,6 +873,12 @@ func (a *App) GetState() (messages []Message, currentResponse string) {
 	return a.conversation.GetState()
 }
 
+// Snapshot returns a copy of the messages and the current response, safe to
+// use from another goroutine while the agent keeps adding to the conversation
+func (a *App) Snapshot() (messages []Message, currentResponse string) {
+	return a.conversation.Snapshot()
+}
+
 // AddMessage adds a message of a specific type to the conversation
 func (a *App) AddMessage(msgType string, content string, data ...interface{}) {
 	msg := Message{
@@ -267,13 +893,50 @@ func (a *App) AddMessage(msgType string, content string, data ...interface{}) {
 	a.conversation.AddMessage(msg)
 }
 
-// Close closes the ACP client connection
+// ConnectionHealth returns the health of the agent connection.
+// The zero value (not connected, not monitored) is returned before Connect.
+func (a *App) ConnectionHealth() client.ConnectionHealth {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.client == nil {
+		return client.ConnectionHealth{}
+	}
+	return a.client.Health()
+}
+
+// ConnectionInfo returns what was negotiated with the agent, and false when
+// not connected
+func (a *App) ConnectionInfo() (client.ConnectionInfo, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.client == nil {
+		return client.ConnectionInfo{}, false
+	}
+	return a.client.Info(), true
+}
+
+// WatchCount returns how many file watches the agent has active
+func (a *App) WatchCount() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.client == nil {
+		return 0
+	}
+	return a.client.WatchCount()
+}
+
+// Close closes the ACP client connection. The disconnect event it causes may
+// read the app's state, so mu is not held while closing.
 func (a *App) Close() error {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+	a.mu.RLock()
+	c := a.client
+	a.mu.RUnlock()
 
-	if a.client != nil {
-		return a.client.Close()
+	if c != nil {
+		return c.Close()
 	}
 	return nil
 }
@@ -0,0 +1,534 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	acp "github.com/coder/acp-go-sdk"
+	"github.com/ron/tui_acp/tui/client"
+	"github.com/ron/tui_acp/tui/client/clienttest"
+)
+
+// connectApp connects a new App built from cfg to agent, closing it when the test ends
+func connectApp(t *testing.T, agent *clienttest.Agent, cfg Config) *App {
+	t.Helper()
+	if cfg.Client.Cwd == "" {
+		cfg.Client.Cwd = t.TempDir()
+	}
+	a := New(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.Connect(ctx, agent.Listen(t)); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
+// waitFor fails the test unless cond becomes true within a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCancelPrompt(t *testing.T) {
+	// The agent answers with one chunk, then works until it is cancelled
+	stopped := make(chan struct{})
+	agent := &clienttest.Agent{
+		OnPrompt: func(ctx context.Context, conn *acp.AgentSideConnection, p acp.PromptRequest) (acp.PromptResponse, error) {
+			clienttest.Send(ctx, conn, p.SessionId, acp.UpdateAgentMessageText("Thinking"))
+			<-ctx.Done()
+			close(stopped)
+			return acp.PromptResponse{StopReason: acp.StopReasonCancelled}, nil
+		},
+	}
+
+	errorsSeen := make(chan error, 1)
+	a := connectApp(t, agent, Config{
+		UpdateCallback: func(event UpdateEvent) {
+			if event.Kind == UpdateError {
+				errorsSeen <- event.Err
+			}
+		},
+	})
+
+	if a.CancelPrompt() {
+		t.Error("CancelPrompt reported a prompt before any was sent")
+	}
+
+	sent := make(chan error, 1)
+	go func() { sent <- a.SendMessage(context.Background(), "work slowly") }()
+	waitFor(t, "the first chunk", func() bool { return a.GetCurrentResponse() == "Thinking" })
+
+	if !a.CancelPrompt() {
+		t.Fatal("CancelPrompt found no prompt in flight")
+	}
+	select {
+	case err := <-sent:
+		if err == nil {
+			t.Error("cancelled prompt returned no error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SendMessage still running after the prompt was cancelled")
+	}
+
+	// The agent is told to stop, and the cancellation isn't shown as a failure
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent never saw the cancellation")
+	}
+	waitFor(t, "session/cancel", func() bool { return len(agent.Cancels()) == 1 })
+	select {
+	case err := <-errorsSeen:
+		t.Errorf("cancellation reported as an error: %v", err)
+	default:
+	}
+	if a.CancelPrompt() {
+		t.Error("CancelPrompt reported a prompt after it finished")
+	}
+}
+
+func TestConnectResumesRememberedSession(t *testing.T) {
+	agent := &clienttest.Agent{CanLoadSession: true}
+	address := agent.Listen(t)
+	cfg := Config{
+		SessionFile: filepath.Join(t.TempDir(), "session.json"),
+		Resume:      true,
+		Client:      client.Config{Cwd: t.TempDir()},
+	}
+	ctx := context.Background()
+
+	// The first launch has nothing to resume, so it starts a session and remembers it
+	first := New(cfg)
+	if err := first.Connect(ctx, address); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	first.Close()
+	state, err := LoadSessionState(cfg.SessionFile)
+	if err != nil {
+		t.Fatalf("session not remembered: %v", err)
+	}
+	if want := (SessionState{Address: address, SessionID: "session-1"}); state != want {
+		t.Errorf("remembered %+v, want %+v", state, want)
+	}
+
+	second := New(cfg)
+	if err := second.Connect(ctx, address); err != nil {
+		t.Fatalf("reconnect: %v", err)
+	}
+	defer second.Close()
+	if loads := agent.Loads(); len(loads) != 1 || loads[0].SessionId != "session-1" {
+		t.Errorf("loads = %+v, want session-1 resumed", loads)
+	}
+	if news := agent.NewSessions(); len(news) != 1 {
+		t.Errorf("%d sessions created, want only the first launch's", len(news))
+	}
+}
+
+// feed passes updates to a as the client would receive them from the agent,
+// then ends the turn
+func feed(t *testing.T, a *App, updates ...acp.SessionUpdate) {
+	t.Helper()
+	ctx := context.Background()
+	h := client.NewCapabilityHandler(client.NewFileSystemAdapter(t.TempDir(), nil), a, nil)
+	for _, u := range updates {
+		if err := h.SessionUpdate(ctx, acp.SessionNotification{SessionId: "s", Update: u}); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	}
+	a.OnMessageComplete(ctx)
+}
+
+// messageSummary is a message's type and content
+type messageSummary struct {
+	Type    MessageType
+	Content string
+}
+
+// summarize returns the type and content of the messages of a
+func summarize(a *App) []messageSummary {
+	var summaries []messageSummary
+	for _, msg := range a.GetMessages() {
+		summaries = append(summaries, messageSummary{msg.Type, msg.Content})
+	}
+	return summaries
+}
+
+func TestThoughtsKeptApartFromResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		showThoughts bool
+		want         []messageSummary
+	}{
+		{
+			name:         "shown",
+			showThoughts: true,
+			want: []messageSummary{
+				{MessageThought, "Weighing the options"},
+				{MessageAssistant, "The answer is 42"},
+			},
+		},
+		{
+			name: "hidden",
+			want: []messageSummary{{MessageAssistant, "The answer is 42"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(Config{ShowThoughts: tt.showThoughts})
+			feed(t, a,
+				acp.UpdateAgentThoughtText("Weighing "),
+				acp.UpdateAgentThoughtText("the options\n"),
+				acp.UpdateAgentMessageText("The answer "),
+				acp.UpdateAgentMessageText("is 42"),
+			)
+
+			if got := summarize(a); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("messages = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanShownAsChecklist(t *testing.T) {
+	a := New(Config{})
+	feed(t, a,
+		acp.UpdateAgentMessageText("Let me plan."),
+		acp.UpdatePlan(
+			acp.PlanEntry{Content: "Read the code", Priority: acp.PlanEntryPriorityHigh, Status: acp.PlanEntryStatusCompleted},
+			acp.PlanEntry{Content: "Fix the bug", Priority: acp.PlanEntryPriorityMedium, Status: acp.PlanEntryStatusInProgress},
+			acp.PlanEntry{Content: "Run the tests", Priority: acp.PlanEntryPriorityLow, Status: acp.PlanEntryStatusPending},
+		),
+	)
+
+	want := []messageSummary{
+		{MessageAssistant, "Let me plan."},
+		{MessagePlan, "[x] Read the code (high priority)\n[~] Fix the bug\n[ ] Run the tests"},
+	}
+	if got := summarize(a); !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %+v, want %+v", got, want)
+	}
+}
+
+func TestToolCallLifecycle(t *testing.T) {
+	var events []UpdateKind
+	a := New(Config{UpdateCallback: func(event UpdateEvent) { events = append(events, event.Kind) }})
+	feed(t, a,
+		acp.StartToolCall("call-1", "Read config",
+			acp.WithStartKind(acp.ToolKindRead),
+			acp.WithStartRawInput(map[string]interface{}{"path": "config.json"})),
+		acp.UpdateToolCall("call-1", acp.WithUpdateStatus(acp.ToolCallStatusInProgress)),
+		acp.UpdateToolCall("call-1", acp.WithUpdateStatus(acp.ToolCallStatusInProgress)),
+		acp.UpdateToolCall("call-1",
+			acp.WithUpdateStatus(acp.ToolCallStatusCompleted),
+			acp.WithUpdateContent([]acp.ToolCallContent{acp.ToolContent(acp.TextBlock("12 lines"))})),
+	)
+
+	// Progress updates change the running tool without repeating its input
+	messages := summarize(a)
+	if len(messages) != 2 || messages[0].Type != MessageToolInput || messages[1].Type != MessageToolOutput {
+		t.Fatalf("messages = %+v, want one tool input and one tool output", messages)
+	}
+	if want := `Read config: {"kind":"read","path":"config.json"}`; messages[0].Content != want {
+		t.Errorf("input = %q, want %q", messages[0].Content, want)
+	}
+	if want := "Read config (pending → in_progress → completed)"; !strings.HasPrefix(messages[1].Content, want) || !strings.Contains(messages[1].Content, "12 lines") {
+		t.Errorf("output = %q, want the statuses and the result", messages[1].Content)
+	}
+	wantEvents := []UpdateKind{UpdateToolInput, UpdateToolInput, UpdateToolInput, UpdateToolOutput, UpdateComplete}
+	if !reflect.DeepEqual(events, wantEvents) {
+		t.Errorf("events = %v, want %v", events, wantEvents)
+	}
+}
+
+func TestToolCallFailed(t *testing.T) {
+	a := New(Config{})
+	feed(t, a,
+		acp.StartToolCall("call-1", "Run tests", acp.WithStartStatus(acp.ToolCallStatusInProgress)),
+		acp.UpdateToolCall("call-1",
+			acp.WithUpdateStatus(acp.ToolCallStatusFailed),
+			acp.WithUpdateRawOutput("exit status 1")),
+	)
+
+	messages := summarize(a)
+	if len(messages) != 2 || messages[1].Type != MessageToolOutput {
+		t.Fatalf("messages = %+v, want a tool input and its output", messages)
+	}
+	if want := "Run tests (in_progress → failed) error: exit status 1"; messages[1].Content != want {
+		t.Errorf("output = %q, want %q", messages[1].Content, want)
+	}
+}
+
+func TestToolCallReportedDone(t *testing.T) {
+	a := New(Config{})
+	feed(t, a, acp.StartToolCall("call-1", "Search", acp.WithStartStatus(acp.ToolCallStatusCompleted)))
+
+	// A call first seen finished still shows its input, once
+	messages := summarize(a)
+	if len(messages) != 2 || messages[0].Type != MessageToolInput || messages[1].Type != MessageToolOutput {
+		t.Errorf("messages = %+v, want one tool input and one tool output", messages)
+	}
+}
+
+// permissionOptions are what an agent offers when asking to run a tool call
+var permissionOptions = []acp.PermissionOption{
+	{OptionId: "allow", Name: "Allow once", Kind: acp.PermissionOptionKindAllowOnce},
+	{OptionId: "always", Name: "Always allow", Kind: acp.PermissionOptionKindAllowAlways},
+	{OptionId: "reject", Name: "Reject", Kind: acp.PermissionOptionKindRejectOnce},
+}
+
+// askingAgent returns an agent that asks permission for a tool call of the given
+// kind and title in each prompt, and a channel of its answers: the chosen
+// option's ID, or "cancelled"
+func askingAgent(kind acp.ToolKind, title string) (*clienttest.Agent, <-chan string) {
+	answers := make(chan string, 1)
+	agent := &clienttest.Agent{
+		OnPrompt: func(ctx context.Context, conn *acp.AgentSideConnection, p acp.PromptRequest) (acp.PromptResponse, error) {
+			resp, err := conn.RequestPermission(ctx, acp.RequestPermissionRequest{
+				SessionId: p.SessionId,
+				ToolCall:  acp.RequestPermissionToolCall{ToolCallId: "call-1", Title: acp.Ptr(title), Kind: acp.Ptr(kind)},
+				Options:   permissionOptions,
+			})
+			switch {
+			case err != nil:
+				answers <- "error: " + err.Error()
+			case resp.Outcome.Selected != nil:
+				answers <- string(resp.Outcome.Selected.OptionId)
+			default:
+				answers <- "cancelled"
+			}
+			return acp.PromptResponse{StopReason: acp.StopReasonEndTurn}, nil
+		},
+	}
+	return agent, answers
+}
+
+// answerPermission returns an update callback that answers permission prompts
+// by picking option index, or leaves them unanswered for -2. The prompts seen
+// are sent to asked.
+func answerPermission(index int, asked chan<- *PermissionPrompt) func(UpdateEvent) {
+	return func(event UpdateEvent) {
+		if event.Kind != UpdatePermission {
+			return
+		}
+		asked <- event.Prompt
+		if index != -2 {
+			event.Prompt.Choose(index)
+		}
+	}
+}
+
+func TestPermissionPrompt(t *testing.T) {
+	tests := []struct {
+		name    string
+		index   int // Option picked, -1 to dismiss or -2 to leave unanswered
+		timeout time.Duration
+		want    string
+	}{
+		{name: "allow", index: 0, want: "allow"},
+		{name: "always", index: 1, want: "always"},
+		{name: "reject", index: 2, want: "reject"},
+		{name: "dismissed", index: -1, want: "cancelled"},
+		{name: "timed out", index: -2, timeout: 50 * time.Millisecond, want: "reject"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent, answers := askingAgent(acp.ToolKindEdit, "Edit main.go")
+			asked := make(chan *PermissionPrompt, 1)
+			a := connectApp(t, agent, Config{
+				UpdateCallback: answerPermission(tt.index, asked),
+				Client:         client.Config{PermissionTimeout: tt.timeout},
+			})
+
+			if err := a.SendMessage(context.Background(), "edit it"); err != nil {
+				t.Fatalf("prompt: %v", err)
+			}
+			if got := <-answers; got != tt.want {
+				t.Errorf("agent got %q, want %q", got, tt.want)
+			}
+
+			// The user saw the request and every option the agent offered
+			prompt := <-asked
+			if prompt.Question != "Allow the agent to run Edit main.go?" {
+				t.Errorf("question = %q", prompt.Question)
+			}
+			want := []client.PermissionOption{{Name: "Allow once", Allow: true}, {Name: "Always allow", Allow: true}, {Name: "Reject"}}
+			if !reflect.DeepEqual(prompt.Options, want) {
+				t.Errorf("options = %+v, want %+v", prompt.Options, want)
+			}
+		})
+	}
+}
+
+func TestPermissionRejectedWithoutUI(t *testing.T) {
+	agent, answers := askingAgent(acp.ToolKindExecute, "Run make")
+	a := connectApp(t, agent, Config{})
+
+	if err := a.SendMessage(context.Background(), "build it"); err != nil {
+		t.Fatalf("prompt: %v", err)
+	}
+	// With no one to ask, the request is dismissed rather than approved
+	if got := <-answers; got != "cancelled" {
+		t.Errorf("agent got %q, want cancelled", got)
+	}
+}
+
+func TestPermissionPolicy(t *testing.T) {
+	policy := &client.PermissionPolicy{Allow: []string{"read"}, Deny: []string{"execute"}}
+
+	tests := []struct {
+		name  string
+		kind  acp.ToolKind
+		want  string
+		asked bool
+	}{
+		{name: "allow", kind: acp.ToolKindRead, want: "allow"},
+		{name: "deny", kind: acp.ToolKindExecute, want: "reject"},
+		{name: "ask", kind: acp.ToolKindEdit, want: "always", asked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent, answers := askingAgent(tt.kind, "tool")
+			asked := make(chan *PermissionPrompt, 1)
+			a := connectApp(t, agent, Config{
+				UpdateCallback: answerPermission(1, asked),
+				Client:         client.Config{PermissionPolicy: policy},
+			})
+
+			if err := a.SendMessage(context.Background(), "go"); err != nil {
+				t.Fatalf("prompt: %v", err)
+			}
+			if got := <-answers; got != tt.want {
+				t.Errorf("agent got %q, want %q", got, tt.want)
+			}
+			if got := len(asked) == 1; got != tt.asked {
+				t.Errorf("user asked = %v, want %v", got, tt.asked)
+			}
+		})
+	}
+}
+
+func TestSnapshotWhilePromptRuns(t *testing.T) {
+	agent := &clienttest.Agent{OnPrompt: func(ctx context.Context, conn *acp.AgentSideConnection, p acp.PromptRequest) (acp.PromptResponse, error) {
+		for i := 0; i < 300; i++ {
+			clienttest.Send(ctx, conn, p.SessionId, acp.UpdateAgentMessageText(fmt.Sprintf("word %d\n\n", i)))
+		}
+		return acp.PromptResponse{StopReason: acp.StopReasonEndTurn}, nil
+	}}
+	a := connectApp(t, agent, Config{})
+
+	// The log consumer adds messages while the agent streams its response
+	// and the UI reads the conversation to render it; run with -race
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+				a.AddMessage(string(MessageDebug), fmt.Sprintf("log line %d", i))
+				time.Sleep(100 * time.Microsecond)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				messages, current := a.Snapshot()
+				size := len(current)
+				for _, msg := range messages {
+					size += len(msg.Content)
+				}
+				_ = size
+			}
+		}
+	}()
+
+	err := a.SendMessage(context.Background(), "stream a long answer")
+	close(done)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("prompt: %v", err)
+	}
+
+	// A snapshot is the caller's own copy
+	messages, _ := a.Snapshot()
+	for i := range messages {
+		messages[i].Content = "changed"
+	}
+	for _, msg := range a.GetMessages() {
+		if msg.Content == "changed" {
+			t.Fatal("changing a snapshot changed the conversation")
+		}
+	}
+}
+
+func TestRetryAfterFailedResponse(t *testing.T) {
+	var attempts int
+	agent := &clienttest.Agent{OnPrompt: func(ctx context.Context, conn *acp.AgentSideConnection, p acp.PromptRequest) (acp.PromptResponse, error) {
+		attempts++
+		if attempts == 1 {
+			clienttest.Send(ctx, conn, p.SessionId, acp.UpdateAgentMessageText("Let me"))
+			return acp.PromptResponse{}, errors.New("model overloaded")
+		}
+		clienttest.Send(ctx, conn, p.SessionId, acp.UpdateAgentMessageText("Here it is."))
+		return acp.PromptResponse{StopReason: acp.StopReasonEndTurn}, nil
+	}}
+	a := connectApp(t, agent, Config{})
+
+	if err := a.SendMessage(context.Background(), "write the tests"); err == nil {
+		t.Fatal("first prompt succeeded, want it to fail")
+	}
+	msg, err := a.RetryLastUserMessage(context.Background())
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if msg.Content != "write the tests" {
+		t.Errorf("retried %q", msg.Content)
+	}
+
+	// The failed answer is replaced by the new one
+	want := []messageSummary{{MessageUser, "write the tests"}, {MessageAssistant, "Here it is."}}
+	waitFor(t, "the retried answer", func() bool { return reflect.DeepEqual(summarize(a), want) })
+	prompts := agent.Prompts()
+	if len(prompts) != 2 || prompts[1].Prompt[0].Text.Text != "write the tests" {
+		t.Errorf("agent got prompts %+v, want the message sent again", prompts)
+	}
+}
+
+func TestRetryWithNothingToRetry(t *testing.T) {
+	if _, err := New(Config{}).RetryLastUserMessage(context.Background()); err == nil {
+		t.Error("retry without a connection succeeded")
+	}
+
+	a := connectApp(t, &clienttest.Agent{}, Config{})
+	a.AddMessage(string(MessageInfo), "connected")
+	if _, err := a.RetryLastUserMessage(context.Background()); err == nil || !strings.Contains(err.Error(), "no message to retry") {
+		t.Errorf("err = %v, want nothing to retry", err)
+	}
+}
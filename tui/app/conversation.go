@@ -1,30 +1,198 @@
 package app
 
 import (
+	"fmt"
 	"strings"
 	"sync"
+	"unicode"
+
+	"github.com/ron/tui_acp/tui/clock"
+)
+
+// FlushPolicy decides when streamed response text moves into the conversation
+type FlushPolicy string
+
+const (
+	// FlushOnComplete keeps a response in one message until it is finished
+	FlushOnComplete FlushPolicy = "complete"
+	// FlushParagraphs moves each finished paragraph into a message of its own
+	// while the response streams, so it leaves the live view early. Paragraphs
+	// inside a code fence are kept together.
+	FlushParagraphs FlushPolicy = "paragraph"
 )
 
+// ParseFlushPolicy checks a flush policy name
+func ParseFlushPolicy(name string) (FlushPolicy, error) {
+	switch policy := FlushPolicy(name); policy {
+	case FlushOnComplete, FlushParagraphs:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown flush policy %q (expected %s or %s)", name, FlushOnComplete, FlushParagraphs)
+}
+
 // ConversationManager handles message storage and state for the conversation
 type ConversationManager struct {
 	mu              sync.RWMutex
 	messages        []Message
 	currentResponse *strings.Builder
+	currentThought  *strings.Builder // Reasoning streamed since the last response text
+	clock           clock.Clock      // Time source for anything time-dependent in the conversation
+	trimResponses   bool             // Drop trailing whitespace from finished responses
+	detectErrors    bool             // Mark finished responses that read as errors as MessageError
+	flushPolicy     FlushPolicy      // When streamed text becomes a message
+	responseSplit   bool             // Part of the current response was already flushed
+	lastID          int64            // ID given to the latest message
+	maxMessages     int              // Oldest messages beyond this many are dropped (0 = no limit)
+	dropped         int              // Messages dropped to stay within maxMessages
 }
 
 // NewConversationManager creates a new ConversationManager
 func NewConversationManager() *ConversationManager {
+	return NewConversationManagerWithClock(clock.NewRealClock())
+}
+
+// NewConversationManagerWithClock creates a new ConversationManager that reads time from clk
+func NewConversationManagerWithClock(clk clock.Clock) *ConversationManager {
+	if clk == nil {
+		clk = clock.NewRealClock()
+	}
 	return &ConversationManager{
 		messages:        make([]Message, 0),
 		currentResponse: &strings.Builder{},
+		currentThought:  &strings.Builder{},
+		clock:           clk,
+		flushPolicy:     FlushOnComplete,
 	}
 }
 
+// SetTrimTrailingWhitespace controls whether trailing whitespace is dropped from
+// streamed responses when they are finished. Leading and internal whitespace is kept.
+func (c *ConversationManager) SetTrimTrailingWhitespace(trim bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trimResponses = trim
+}
+
+// SetDetectStreamedErrors controls whether a finished response that looks like an
+// error, for agents that stream failures as text, is stored as a MessageError.
+// The content is kept as streamed either way.
+func (c *ConversationManager) SetDetectStreamedErrors(detect bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.detectErrors = detect
+}
+
+// SetFlushPolicy sets when streamed response text is moved into messages
+func (c *ConversationManager) SetFlushPolicy(policy FlushPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushPolicy = policy
+}
+
+// SetMaxMessages caps how many messages are kept, dropping the oldest beyond
+// it as new ones arrive. 0 or less keeps them all.
+func (c *ConversationManager) SetMaxMessages(limit int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxMessages = max(limit, 0)
+	c.trim()
+}
+
+// Dropped returns how many of the oldest messages were dropped to stay within
+// the message limit
+func (c *ConversationManager) Dropped() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.dropped
+}
+
+// trim drops the oldest messages beyond the message limit (must hold lock)
+func (c *ConversationManager) trim() {
+	n := len(c.messages) - c.maxMessages
+	if c.maxMessages == 0 || n <= 0 {
+		return
+	}
+	// Clear the dropped messages so their content can be collected before
+	// the next append moves the slice
+	clear(c.messages[:n])
+	c.messages = c.messages[n:]
+	c.dropped += n
+}
+
 // AddMessage adds a message to the conversation
 func (c *ConversationManager) AddMessage(msg Message) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.addMessage(msg)
+}
+
+// addMessage appends a message with the next ID, stamping it with the time
+// unless it has one (must hold lock)
+func (c *ConversationManager) addMessage(msg Message) {
+	c.lastID++
+	msg.ID = c.lastID
+	if msg.Time.IsZero() {
+		msg.Time = c.clock.Now()
+	}
 	c.messages = append(c.messages, msg)
+	c.trim()
+}
+
+// EditMessage replaces the content of the message with the given ID
+func (c *ConversationManager) EditMessage(id int64, content string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.messages {
+		if c.messages[i].ID == id {
+			c.messages[i].Content = content
+			return nil
+		}
+	}
+	return fmt.Errorf("no message with ID %d", id)
+}
+
+// RewindToLastUserMessage drops everything after the last user message,
+// including any response in progress, and returns that message. It reports
+// false, changing nothing, when there is no user message.
+func (c *ConversationManager) RewindToLastUserMessage() (Message, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		if c.messages[i].Type == MessageUser {
+			// A fresh slice, so snapshots of the longer conversation keep their messages
+			c.messages = append(make([]Message, 0, i+1), c.messages[:i+1]...)
+			c.currentResponse.Reset()
+			c.currentThought.Reset()
+			c.responseSplit = false
+			return c.messages[i], true
+		}
+	}
+	return Message{}, false
+}
+
+// Restore replaces the conversation with messages, such as history from an
+// earlier run, dropping any response in progress
+func (c *ConversationManager) Restore(messages []Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(make([]Message, 0, len(messages)), messages...)
+	// Keep the saved IDs and number messages saved without one after them
+	c.lastID = 0
+	for _, msg := range c.messages {
+		c.lastID = max(c.lastID, msg.ID)
+	}
+	for i := range c.messages {
+		if c.messages[i].ID == 0 {
+			c.lastID++
+			c.messages[i].ID = c.lastID
+		}
+	}
+	c.trim()
+	c.currentResponse.Reset()
+	c.currentThought.Reset()
+	c.responseSplit = false
 }
 
 // AddUserMessage adds a user message, flushing any pending response first
@@ -34,7 +202,7 @@ func (c *ConversationManager) AddUserMessage(text string) {
 
 	c.flushCurrentResponse()
 
-	c.messages = append(c.messages, Message{
+	c.addMessage(Message{
 		Type:    MessageUser,
 		Content: text,
 	})
@@ -44,7 +212,20 @@ func (c *ConversationManager) AddUserMessage(text string) {
 func (c *ConversationManager) AppendToCurrentResponse(text string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.flushCurrentThought()
 	c.currentResponse.WriteString(text)
+	if c.flushPolicy == FlushParagraphs {
+		c.flushParagraphs()
+	}
+}
+
+// AppendToCurrentThought appends reasoning text. Reasoning that follows response
+// text ends that part of the response, so the two keep their order.
+func (c *ConversationManager) AppendToCurrentThought(text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushResponseText()
+	c.currentThought.WriteString(text)
 }
 
 // FlushCurrentResponse flushes the current response to messages
@@ -54,15 +235,80 @@ func (c *ConversationManager) FlushCurrentResponse() {
 	c.flushCurrentResponse()
 }
 
-// flushCurrentResponse adds any pending response to messages (must hold lock)
+// flushCurrentThought adds any pending reasoning to messages (must hold lock)
+func (c *ConversationManager) flushCurrentThought() {
+	if c.currentThought == nil || c.currentThought.Len() == 0 {
+		return
+	}
+
+	content := strings.TrimRightFunc(c.currentThought.String(), unicode.IsSpace)
+	c.currentThought.Reset()
+	if content == "" {
+		return
+	}
+	c.addMessage(Message{
+		Type:    MessageThought,
+		Content: content,
+	})
+}
+
+// flushCurrentResponse adds any pending reasoning and response to messages (must hold lock)
 func (c *ConversationManager) flushCurrentResponse() {
-	if c.currentResponse.Len() > 0 {
-		c.messages = append(c.messages, Message{
-			Type:    MessageAssistant,
-			Content: c.currentResponse.String(),
-		})
-		c.currentResponse.Reset()
+	c.flushCurrentThought()
+	c.flushResponseText()
+}
+
+// flushResponseText adds any pending response text to messages (must hold lock)
+func (c *ConversationManager) flushResponseText() {
+	if c.currentResponse.Len() == 0 {
+		return
+	}
+
+	content := c.currentResponse.String()
+	c.currentResponse.Reset()
+	continued := c.responseSplit
+	c.responseSplit = false
+	if c.trimResponses || continued {
+		content = strings.TrimRightFunc(content, unicode.IsSpace)
+		if content == "" {
+			return
+		}
+	}
+	msgType := MessageAssistant
+	if c.detectErrors && !continued && looksLikeStreamedError(content) {
+		msgType = MessageError
 	}
+	c.addMessage(Message{
+		Type:      msgType,
+		Content:   content,
+		Continued: continued,
+	})
+}
+
+// flushParagraphs moves the finished paragraphs of the current response into a
+// message, keeping the paragraph still being streamed (must hold lock)
+func (c *ConversationManager) flushParagraphs() {
+	content := c.currentResponse.String()
+	end := strings.LastIndex(content, "\n\n")
+	// Don't split a code block: back up until every fence before the break is closed
+	for end > 0 && strings.Count(content[:end], "```")%2 == 1 {
+		end = strings.LastIndex(content[:strings.LastIndex(content[:end], "```")], "\n\n")
+	}
+
+	part := strings.TrimRightFunc(content[:max(end, 0)], unicode.IsSpace)
+	if part == "" {
+		return
+	}
+	rest := strings.TrimLeft(content[end:], "\n")
+	c.currentResponse.Reset()
+	c.currentResponse.WriteString(rest)
+
+	c.addMessage(Message{
+		Type:      MessageAssistant,
+		Content:   part,
+		Continued: c.responseSplit,
+	})
+	c.responseSplit = true
 }
 
 // GetMessages returns the messages slice (not a copy for efficiency).
@@ -95,3 +341,17 @@ func (c *ConversationManager) GetState() (messages []Message, currentResponse st
 	}
 	return c.messages, currentResponse
 }
+
+// Snapshot returns a copy of the messages and the current response, taken under
+// the lock, which callers may keep and read while the conversation grows
+func (c *ConversationManager) Snapshot() (messages []Message, currentResponse string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	messages = make([]Message, len(c.messages))
+	copy(messages, c.messages)
+	if c.currentResponse != nil {
+		currentResponse = c.currentResponse.String()
+	}
+	return messages, currentResponse
+}
@@ -0,0 +1,210 @@
+package app
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ron/tui_acp/tui/clock"
+)
+
+func TestMessagesStampedWithTime(t *testing.T) {
+	start := time.Date(2025, 3, 4, 9, 30, 0, 0, time.UTC)
+	clk := clock.NewFakeClock(start)
+	c := NewConversationManagerWithClock(clk)
+
+	c.AddUserMessage("hello")
+	clk.Advance(time.Second)
+	c.AppendToCurrentThought("thinking")
+	c.AppendToCurrentResponse("hi ")
+	clk.Advance(time.Second)
+	c.AppendToCurrentResponse("there")
+	clk.Advance(time.Second)
+	c.FlushCurrentResponse()
+	c.AddMessage(Message{Type: MessageInfo, Content: "done"})
+	saved := start.Add(-time.Hour)
+	c.AddMessage(Message{Type: MessageInfo, Content: "from before", Time: saved})
+
+	// A streamed response is stamped when it is finished, and a message that
+	// already has a time keeps it
+	want := []struct {
+		msgType MessageType
+		time    time.Time
+	}{
+		{MessageUser, start},
+		{MessageThought, start.Add(time.Second)},
+		{MessageAssistant, start.Add(3 * time.Second)},
+		{MessageInfo, start.Add(3 * time.Second)},
+		{MessageInfo, saved},
+	}
+	messages, _ := c.Snapshot()
+	if len(messages) != len(want) {
+		t.Fatalf("got %d messages, want %d: %+v", len(messages), len(want), messages)
+	}
+	for i, w := range want {
+		if messages[i].Type != w.msgType || !messages[i].Time.Equal(w.time) {
+			t.Errorf("message %d is %s at %v, want %s at %v", i, messages[i].Type, messages[i].Time, w.msgType, w.time)
+		}
+	}
+}
+
+func TestMessageTimeOptionalInJSON(t *testing.T) {
+	data, err := json.Marshal(Message{Type: MessageUser, Content: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"time"`) {
+		t.Errorf("message without a time encoded as %s", data)
+	}
+
+	// Messages saved before they carried times still load
+	messages, err := ParseMessages([]byte(`[{"type": "user", "content": "hi"}]`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !messages[0].Time.IsZero() {
+		t.Errorf("time = %v, want zero", messages[0].Time)
+	}
+
+	at := time.Date(2025, 3, 4, 9, 30, 0, 0, time.UTC)
+	data, _ = json.Marshal([]Message{{Type: MessageUser, Content: "hi", Time: at}})
+	messages, err = ParseMessages(data)
+	if err != nil || !messages[0].Time.Equal(at) {
+		t.Errorf("time read back as %v (err %v), want %v", messages[0].Time, err, at)
+	}
+}
+
+func TestMessageIDs(t *testing.T) {
+	c := NewConversationManager()
+	c.AddUserMessage("one")
+	c.AppendToCurrentResponse("two")
+	c.FlushCurrentResponse()
+	c.AddMessage(Message{Type: MessageInfo, Content: "three", ID: 99})
+
+	// IDs increase in order, and one the caller set is replaced
+	messages, _ := c.Snapshot()
+	for i, msg := range messages {
+		if msg.ID != int64(i+1) {
+			t.Errorf("message %q has ID %d, want %d", msg.Content, msg.ID, i+1)
+		}
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	c := NewConversationManager()
+	for _, text := range []string{"first", "second", "third"} {
+		c.AddUserMessage(text)
+	}
+
+	if err := c.EditMessage(2, "second, edited"); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	messages, _ := c.Snapshot()
+	got := []string{messages[0].Content, messages[1].Content, messages[2].Content}
+	if want := []string{"first", "second, edited", "third"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	if messages[1].ID != 2 {
+		t.Errorf("edited message has ID %d, want it kept", messages[1].ID)
+	}
+
+	if err := c.EditMessage(42, "nothing"); err == nil {
+		t.Error("editing a missing message succeeded")
+	}
+}
+
+func TestEditWhileAppending(t *testing.T) {
+	c := NewConversationManager()
+	c.AddUserMessage("edited")
+
+	// Edits and appends share the lock; run with -race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			c.AddMessage(Message{Type: MessageDebug, Content: "log"})
+			c.AppendToCurrentResponse("x")
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		if err := c.EditMessage(1, strings.Repeat("e", i)); err != nil {
+			t.Fatalf("edit: %v", err)
+		}
+	}
+	<-done
+
+	if messages, _ := c.Snapshot(); messages[0].Content != strings.Repeat("e", 199) {
+		t.Errorf("first message = %q after the last edit", messages[0].Content)
+	}
+}
+
+func TestRewindToLastUserMessage(t *testing.T) {
+	c := NewConversationManager()
+	c.AddUserMessage("first")
+	c.AppendToCurrentResponse("answer")
+	c.FlushCurrentResponse()
+	c.AddUserMessage("second")
+	c.AddMessage(Message{Type: MessageError, Content: "overloaded"})
+	c.AppendToCurrentResponse("partial")
+	before, _ := c.Snapshot()
+
+	msg, ok := c.RewindToLastUserMessage()
+	if !ok || msg.Content != "second" || msg.ID != 3 {
+		t.Fatalf("rewound to %+v, %v; want the second user message", msg, ok)
+	}
+	messages, current := c.Snapshot()
+	if len(messages) != 3 || current != "" {
+		t.Errorf("after rewinding: %d messages and response %q, want 3 and none", len(messages), current)
+	}
+	// A snapshot from before keeps the messages that were dropped
+	if len(before) != 4 || before[3].Content != "overloaded" {
+		t.Errorf("earlier snapshot changed: %+v", before)
+	}
+
+	if _, ok := NewConversationManager().RewindToLastUserMessage(); ok {
+		t.Error("rewound a conversation with no user message")
+	}
+}
+
+func TestMaxMessagesTrim(t *testing.T) {
+	c := NewConversationManager()
+	c.SetMaxMessages(3)
+
+	tests := []struct {
+		add         string
+		wantFirst   int64
+		wantDropped int
+	}{
+		{"one", 1, 0},
+		{"two", 1, 0},
+		{"three", 1, 0},
+		// At the limit the next message pushes out the oldest
+		{"four", 2, 1},
+		{"five", 3, 2},
+	}
+	for _, tt := range tests {
+		c.AddMessage(Message{Type: MessageInfo, Content: tt.add})
+		messages, _ := c.Snapshot()
+		if len(messages) > 3 || messages[0].ID != tt.wantFirst || c.Dropped() != tt.wantDropped {
+			t.Errorf("after %q: %d messages from ID %d, %d dropped; want from ID %d, %d dropped",
+				tt.add, len(messages), messages[0].ID, c.Dropped(), tt.wantFirst, tt.wantDropped)
+		}
+		if last := messages[len(messages)-1]; last.Content != tt.add {
+			t.Errorf("after %q: last message %q", tt.add, last.Content)
+		}
+	}
+
+	// Lowering the limit trims at once; removing it keeps everything after
+	c.SetMaxMessages(1)
+	if messages, _ := c.Snapshot(); len(messages) != 1 || messages[0].Content != "five" || c.Dropped() != 4 {
+		t.Errorf("limit 1 kept %+v, %d dropped", messages, c.Dropped())
+	}
+	c.SetMaxMessages(0)
+	for i := 0; i < 10; i++ {
+		c.AddMessage(Message{Type: MessageInfo, Content: "more"})
+	}
+	if messages, _ := c.Snapshot(); len(messages) != 11 {
+		t.Errorf("unlimited kept %d messages, want 11", len(messages))
+	}
+}
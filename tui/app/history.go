@@ -0,0 +1,100 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// historyVersion is the layout of conversation history written by SaveState.
+// Fields added later must be optional, so older files keep loading.
+const historyVersion = 1
+
+// historyLimit is how many of the latest messages SaveState keeps, so history
+// doesn't grow without bound across runs
+const historyLimit = 500
+
+// historyFile is conversation history as saved on disk
+type historyFile struct {
+	Version  int       `json:"version"`
+	Messages []Message `json:"messages"`
+}
+
+// DefaultHistoryFile is where conversation history with the agent at address,
+// in the working directory cwd ("" = the current directory), is kept between
+// runs when no file is configured. It returns "" when the system has no user
+// config directory.
+func DefaultHistoryFile(cwd, address string) string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "history", stateKey(absDir(cwd), address)+".json")
+}
+
+// SaveState writes the conversation to path so a later run can restore it with
+// LoadState. Debug messages are log output of this run and are left out, and
+// only the latest historyLimit messages are kept.
+func (a *App) SaveState(path string) error {
+	messages, _ := a.conversation.Snapshot()
+	kept := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Type != MessageDebug {
+			kept = append(kept, msg)
+		}
+	}
+	if len(kept) > historyLimit {
+		kept = kept[len(kept)-historyLimit:]
+	}
+
+	data, err := json.MarshalIndent(historyFile{Version: historyVersion, Messages: kept}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// LoadState replaces the conversation with the history saved at path. A missing
+// file leaves the conversation as it is. Messages of types this version doesn't
+// know are kept and shown under their raw type, and a plain array of messages,
+// as exported by /save, is accepted too.
+func (a *App) LoadState(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var messages []Message
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		messages, err = ParseMessages(trimmed)
+		if err != nil {
+			return err
+		}
+	} else {
+		var history historyFile
+		if err := json.Unmarshal(data, &history); err != nil {
+			return fmt.Errorf("failed to parse history: %w", err)
+		}
+		if history.Version > historyVersion {
+			a.logger.Warn("History %s is from a newer version (%d); loading what is understood", path, history.Version)
+		}
+		messages = history.Messages
+	}
+
+	a.conversation.Restore(messages)
+	a.logger.Info("Restored %d messages from %s", len(messages), path)
+	return nil
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ron/tui_acp/tui/clock"
+)
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	saved := New(Config{Clock: clock.NewFakeClock(time.Date(2025, 3, 4, 9, 30, 0, 0, time.UTC))})
+	saved.AddUserMessage("read main.go")
+	saved.AddMessage(string(MessageToolInput), "read_file", map[string]interface{}{"path": "main.go", "limit": float64(20)})
+	saved.AddMessage(string(MessageDebug), "connected in 3ms")
+	saved.AddMessage(string(MessageToolOutput), "20 lines", map[string]interface{}{"lines": []interface{}{"package main", "func main() {}"}})
+	saved.AddMessage(string(MessageAssistant), "It defines main.")
+
+	path := filepath.Join(t.TempDir(), "history", "chat.json")
+	if err := saved.SaveState(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	restored := New(Config{})
+	if err := restored.LoadState(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// Everything but this run's log output comes back as it was, Data included
+	var want []Message
+	for _, msg := range saved.GetMessages() {
+		if msg.Type != MessageDebug {
+			want = append(want, msg)
+		}
+	}
+	if got := restored.GetMessages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored %+v\nwant %+v", got, want)
+	}
+
+	// New messages are numbered after the restored ones
+	restored.AddUserMessage("and the tests?")
+	messages := restored.GetMessages()
+	if last, prev := messages[len(messages)-1].ID, messages[len(messages)-2].ID; last <= prev {
+		t.Errorf("new message got ID %d, not after %d", last, prev)
+	}
+}
+
+func TestSaveStateKeepsLatest(t *testing.T) {
+	a := New(Config{})
+	for i := 0; i < historyLimit+20; i++ {
+		a.AddMessage(string(MessageInfo), fmt.Sprintf("message %d", i))
+	}
+	path := filepath.Join(t.TempDir(), "chat.json")
+	if err := a.SaveState(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	restored := New(Config{})
+	if err := restored.LoadState(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	messages := restored.GetMessages()
+	if len(messages) != historyLimit || messages[0].Content != "message 20" {
+		t.Errorf("restored %d messages from %q, want the latest %d", len(messages), messages[0].Content, historyLimit)
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []Message
+		wantErr string
+	}{
+		{
+			name: "unknown type and field",
+			file: `{"version": 1, "messages": [{"id": 4, "type": "user", "content": "hi"}, {"type": "citation", "content": "see docs", "source": "web"}]}`,
+			want: []Message{{ID: 4, Type: MessageUser, Content: "hi"}, {ID: 5, Type: "citation", Content: "see docs"}},
+		},
+		{
+			name: "newer version",
+			file: `{"version": 9, "messages": [{"type": "assistant", "content": "hello"}], "settings": {}}`,
+			want: []Message{{ID: 1, Type: MessageAssistant, Content: "hello"}},
+		},
+		{
+			name: "exported array",
+			file: `[{"type": "user", "content": "hi"}, {"type": "assistant", "content": "hello"}]`,
+			want: []Message{{ID: 1, Type: MessageUser, Content: "hi"}, {ID: 2, Type: MessageAssistant, Content: "hello"}},
+		},
+		{name: "corrupt", file: `{"version": 1, "messages": [`, wantErr: "failed to parse history"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "chat.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			a := New(Config{})
+			err := a.LoadState(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if got := a.GetMessages(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("restored %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadStateMissingFile(t *testing.T) {
+	a := New(Config{})
+	a.AddUserMessage("kept")
+	if err := a.LoadState(filepath.Join(t.TempDir(), "none.json")); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if messages := a.GetMessages(); len(messages) != 1 || messages[0].Content != "kept" {
+		t.Errorf("messages = %+v, want the conversation left alone", messages)
+	}
+}
+
+func TestDefaultHistoryFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	config, err := os.UserConfigDir()
+	if err != nil {
+		t.Skip("no user config directory:", err)
+	}
+
+	path := DefaultHistoryFile("/work/project", "localhost:8080")
+	if dir := filepath.Join(config, "tui_acp", "history"); filepath.Dir(path) != dir {
+		t.Errorf("history file %s, want it in %s", path, dir)
+	}
+	if again := DefaultHistoryFile("/work/project", "localhost:8080"); again != path {
+		t.Errorf("history file changed between calls: %s, then %s", path, again)
+	}
+	// Each directory and agent has its own history
+	for _, other := range []string{DefaultHistoryFile("/work/other", "localhost:8080"), DefaultHistoryFile("/work/project", "localhost:9090")} {
+		if other == path {
+			t.Errorf("history file %s shared", other)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// searchContext is how many bytes of a matching line are kept on each side of
+// the match in a search snippet
+const searchContext = 40
+
+// SearchHit is a message matching a conversation search
+type SearchHit struct {
+	Index   int         // Position of the message in the conversation
+	ID      int64       // ID of the message
+	Type    MessageType // Type of the message
+	Snippet string      // The line of the first match, cut to the text around it
+	Start   int         // Byte offset of the match in Snippet
+	End     int         // Byte offset just past the match in Snippet
+}
+
+// Search finds the messages containing query as plain text, oldest first, with
+// a snippet around the first match in each. Debug messages are not searched.
+// An empty query matches nothing.
+func (c *ConversationManager) Search(query string, caseSensitive bool) []SearchHit {
+	if query == "" {
+		return nil
+	}
+	pattern := regexp.QuoteMeta(query)
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	re := regexp.MustCompile(pattern)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var hits []SearchHit
+	for i, msg := range c.messages {
+		if msg.Type == MessageDebug {
+			continue
+		}
+		loc := re.FindStringIndex(msg.Content)
+		if loc == nil {
+			continue
+		}
+		snippet, start, end := searchSnippet(msg.Content, loc[0], loc[1])
+		hits = append(hits, SearchHit{
+			Index:   i,
+			ID:      msg.ID,
+			Type:    msg.Type,
+			Snippet: snippet,
+			Start:   start,
+			End:     end,
+		})
+	}
+	return hits
+}
+
+// searchSnippet cuts the line holding content[start:end] to the match and up to
+// searchContext bytes either side, marking cuts with "…". It returns the
+// snippet and the match's offsets in it.
+func searchSnippet(content string, start, end int) (string, int, int) {
+	lineStart := strings.LastIndexByte(content[:start], '\n') + 1
+	lineEnd := len(content)
+	if i := strings.IndexByte(content[start:], '\n'); i >= 0 {
+		lineEnd = start + i
+	}
+	// A match spanning lines is shown up to the end of its first line
+	end = min(end, lineEnd)
+
+	from := max(lineStart, start-searchContext)
+	for from > lineStart && !utf8.RuneStart(content[from]) {
+		from--
+	}
+	to := min(lineEnd, end+searchContext)
+	for to < lineEnd && !utf8.RuneStart(content[to]) {
+		to++
+	}
+
+	var prefix, suffix string
+	if from > lineStart {
+		prefix = "…"
+	}
+	if to < lineEnd {
+		suffix = "…"
+	}
+	snippet := prefix + content[from:to] + suffix
+	return snippet, start - from + len(prefix), end - from + len(prefix)
+}
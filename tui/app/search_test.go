@@ -0,0 +1,102 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// searchFixture returns a short conversation to search
+func searchFixture() *ConversationManager {
+	c := NewConversationManager()
+	c.AddUserMessage("How do I parse JSON in Go?")
+	c.AddMessage(Message{Type: MessageAssistant, Content: "Use encoding/json.\n\nCall json.Unmarshal(data, &v) with a pointer."})
+	c.AddMessage(Message{Type: MessageDebug, Content: "json request took 20ms"})
+	c.AddMessage(Message{Type: MessageToolOutput, Content: "main.go:3: func parse(x) error"})
+	c.AddUserMessage("What about YAML?")
+	return c
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		caseSensitive bool
+		want          []int64
+	}{
+		{"any case", "json", false, []int64{1, 2}},
+		{"case sensitive", "JSON", true, []int64{1}},
+		{"case sensitive lower", "json", true, []int64{2}},
+		// Regexp syntax in the query is plain text
+		{"literal", "parse(x)", false, []int64{4}},
+		{"literal dot", "encoding.json", false, nil},
+		{"no match", "toml", false, nil},
+		{"empty", "", false, nil},
+		// Debug messages are left out
+		{"debug", "20ms", false, nil},
+	}
+
+	c := searchFixture()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []int64
+			for _, hit := range c.Search(tt.query, tt.caseSensitive) {
+				ids = append(ids, hit.ID)
+				if match := hit.Snippet[hit.Start:hit.End]; !strings.EqualFold(match, tt.query) {
+					t.Errorf("hit %d marks %q, want the query", hit.ID, match)
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("Search(%q) found IDs %v, want %v", tt.query, ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchHit(t *testing.T) {
+	c := searchFixture()
+	hits := c.Search("unmarshal", false)
+	if len(hits) != 1 {
+		t.Fatalf("got %d hits, want 1", len(hits))
+	}
+
+	// The snippet is the matching line alone
+	want := SearchHit{Index: 1, ID: 2, Type: MessageAssistant, Snippet: "Call json.Unmarshal(data, &v) with a pointer.", Start: 10, End: 19}
+	if hits[0] != want {
+		t.Errorf("hit = %+v, want %+v", hits[0], want)
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	long := strings.Repeat("é", 60)
+	tests := []struct {
+		name    string
+		content string
+		query   string
+		want    string
+	}{
+		{"short line", "one\ntwo needle three\nfour", "needle", "two needle three"},
+		{"cut both sides", strings.Repeat("a", 50) + "needle" + strings.Repeat("b", 50), "needle",
+			"…" + strings.Repeat("a", 40) + "needle" + strings.Repeat("b", 40) + "…"},
+		// Cuts fall between characters, never inside one
+		{"multibyte", long + "needle" + long, "needle", "…" + strings.Repeat("é", 20) + "needle" + strings.Repeat("é", 20) + "…"},
+		{"spans lines", "the needle\nand more", "needle\nand", "the needle"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := strings.Index(tt.content, tt.query)
+			snippet, from, to := searchSnippet(tt.content, start, start+len(tt.query))
+			if snippet != tt.want {
+				t.Errorf("snippet = %q, want %q", snippet, tt.want)
+			}
+			if !utf8.ValidString(snippet) {
+				t.Errorf("snippet %q is not valid UTF-8", snippet)
+			}
+			if got := snippet[from:to]; got != "needle" {
+				t.Errorf("match in snippet is %q, want needle", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DefaultSessionFile is where the last session for the working directory cwd
+// ("" = the current directory) is remembered when no file is configured. It is
+// kept in the user config directory, not in cwd, so projects aren't littered
+// with state files. It returns "" when there is no user config directory.
+func DefaultSessionFile(cwd string) string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "sessions", stateKey(absDir(cwd))+".json")
+}
+
+// stateDir returns the directory state is kept in between runs, or "" when the
+// system has no user config directory
+func stateDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "tui_acp")
+}
+
+// stateKey names a state file after what it belongs to, such as a working
+// directory, in a form that is safe as a file name
+func stateKey(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// absDir returns dir as an absolute path, the current directory for ""
+func absDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// SessionState is the session remembered between launches
+type SessionState struct {
+	Address   string `json:"address"`   // Agent the session belongs to
+	SessionID string `json:"sessionId"` // Session to resume on that agent
+}
+
+// LoadSessionState reads the remembered session. A missing file gives an empty state.
+func LoadSessionState(path string) (SessionState, error) {
+	var state SessionState
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read session state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse session state: %w", err)
+	}
+	return state, nil
+}
+
+// SaveSessionState remembers the session so the next launch can resume it
+func SaveSessionState(path string, state SessionState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create session state directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write session state: %w", err)
+	}
+	return nil
+}
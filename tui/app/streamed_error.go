@@ -0,0 +1,37 @@
+package app
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// streamedErrorPrefixes start responses that are errors rather than answers.
+// Compared case-insensitively against the trimmed response.
+var streamedErrorPrefixes = []string{"error:", "fatal:", "panic:"}
+
+// looksLikeStreamedError guesses whether an agent response is an error reported as
+// plain text: a message starting with an error prefix, or a lone JSON error object
+// such as {"error": ...} or a JSON-RPC {"code": ..., "message": ...}.
+func looksLikeStreamedError(content string) bool {
+	trimmed := strings.TrimSpace(content)
+	lower := strings.ToLower(trimmed)
+	for _, prefix := range streamedErrorPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+
+	if !strings.HasPrefix(trimmed, "{") {
+		return false
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(trimmed), &obj); err != nil {
+		return false
+	}
+	if _, ok := obj["error"]; ok {
+		return true
+	}
+	_, hasCode := obj["code"]
+	_, hasMessage := obj["message"]
+	return hasCode && hasMessage
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LoadMessages reads a saved conversation from disk.
+// Both a JSON array of messages and JSONL (one message per line) are accepted.
+func LoadMessages(path string) ([]Message, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read conversation: %w", err)
+	}
+
+	return ParseMessages(data)
+}
+
+// ParseMessages decodes a conversation from JSON or JSONL content
+func ParseMessages(data []byte) ([]Message, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return []Message{}, nil
+	}
+
+	// A JSON array holds the whole conversation
+	if trimmed[0] == '[' {
+		var messages []Message
+		if err := json.Unmarshal(trimmed, &messages); err != nil {
+			return nil, fmt.Errorf("failed to parse conversation: %w", err)
+		}
+		return messages, nil
+	}
+
+	// Otherwise treat it as JSONL
+	messages := make([]Message, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(trimmed)+1)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, fmt.Errorf("failed to parse conversation line %d: %w", lineNumber, err)
+		}
+		messages = append(messages, msg)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read conversation: %w", err)
+	}
+
+	return messages, nil
+}
+
+// Formats accepted by ExportConversation
+const (
+	ExportMarkdown = "md"
+	ExportJSON     = "json"
+)
+
+// ExportFormatForPath picks the export format from a file's extension:
+// JSON for .json, Markdown otherwise
+func ExportFormatForPath(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return ExportJSON
+	}
+	return ExportMarkdown
+}
+
+// DefaultExportPath names an export file after the time it was made
+func DefaultExportPath(format string, now time.Time) string {
+	return fmt.Sprintf("conversation-%s.%s", now.Format("20060102-150405"), format)
+}
+
+// ExportConversation writes the conversation to path as Markdown or JSON. The
+// JSON is an array of messages, Data included, which LoadMessages reads back.
+func (a *App) ExportConversation(path, format string) error {
+	messages, _ := a.conversation.Snapshot()
+
+	var data []byte
+	switch format {
+	case ExportMarkdown:
+		render := a.renderMarkdown
+		if render == nil {
+			render = markdownByType
+		}
+		data = []byte(render(messages))
+		if dropped := a.conversation.Dropped(); dropped > 0 {
+			note := fmt.Sprintf("_%d earlier messages were dropped to stay within the message limit._\n\n", dropped)
+			data = append([]byte(note), data...)
+		}
+	case ExportJSON:
+		var err error
+		data, err = json.MarshalIndent(messages, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode conversation: %w", err)
+		}
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("unknown export format %q (expected %s or %s)", format, ExportMarkdown, ExportJSON)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	a.logger.Info("Saved %d messages to %s", len(messages), path)
+	return nil
+}
+
+// markdownByType renders messages as Markdown labelled by their raw type, for
+// apps configured without a renderer
+func markdownByType(messages []Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "**%s:**\n\n%s\n\n", msg.Type, msg.Content)
+	}
+	return b.String()
+}
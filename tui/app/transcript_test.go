@@ -0,0 +1,122 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ron/tui_acp/tui/clock"
+)
+
+// exportFixture returns an app holding a short conversation with a tool result
+// carrying structured data
+func exportFixture(cfg Config) *App {
+	cfg.Clock = clock.NewFakeClock(time.Date(2025, 3, 4, 9, 30, 0, 0, time.UTC))
+	a := New(cfg)
+	a.AddUserMessage("find the handler")
+	a.AddMessage(string(MessageToolOutput), "2 matches", map[string]interface{}{"path": "main.go", "matches": float64(2)})
+	a.AddMessage(string(MessageAssistant), "It is in main.go.")
+	return a
+}
+
+func TestExportConversationJSON(t *testing.T) {
+	a := exportFixture(Config{})
+	path := filepath.Join(t.TempDir(), "chat.json")
+
+	if err := a.ExportConversation(path, ExportJSON); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	loaded, err := LoadMessages(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// The export reads back as the same messages, Data included
+	want, _ := a.Snapshot()
+	if !reflect.DeepEqual(loaded, want) {
+		t.Errorf("loaded %+v, want %+v", loaded, want)
+	}
+}
+
+func TestExportConversationMarkdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		render func([]Message) string
+		want   []string
+	}{
+		{"by type", nil, []string{"**user:**\n\nfind the handler", "**tool_output:**\n\n2 matches", "**assistant:**\n\nIt is in main.go."}},
+		{"renderer", func(messages []Message) string {
+			var b strings.Builder
+			for _, msg := range messages {
+				b.WriteString("## " + strings.ToUpper(string(msg.Type)) + "\n")
+			}
+			return b.String()
+		}, []string{"## USER\n## TOOL_OUTPUT\n## ASSISTANT\n"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := exportFixture(Config{RenderMarkdown: tt.render})
+			path := filepath.Join(t.TempDir(), "chat.md")
+			if err := a.ExportConversation(path, ExportMarkdown); err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("export %q, want it to contain %q", data, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExportConversationRejectsUnknownFormat(t *testing.T) {
+	a := exportFixture(Config{})
+	path := filepath.Join(t.TempDir(), "chat.txt")
+
+	if err := a.ExportConversation(path, "txt"); err == nil || !strings.Contains(err.Error(), "unknown export format") {
+		t.Errorf("err = %v, want the format rejected", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file written for an unknown format: %v", err)
+	}
+}
+
+func TestExportPaths(t *testing.T) {
+	formats := map[string]string{"notes.md": ExportMarkdown, "chat.JSON": ExportJSON, "notes": ExportMarkdown, "out.txt": ExportMarkdown}
+	for path, want := range formats {
+		if got := ExportFormatForPath(path); got != want {
+			t.Errorf("ExportFormatForPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	now := time.Date(2025, 3, 4, 9, 5, 7, 0, time.UTC)
+	if got, want := DefaultExportPath(ExportJSON, now), "conversation-20250304-090507.json"; got != want {
+		t.Errorf("DefaultExportPath = %q, want %q", got, want)
+	}
+}
+
+func TestExportNotesDroppedMessages(t *testing.T) {
+	a := New(Config{MaxMessages: 2})
+	for _, text := range []string{"one", "two", "three"} {
+		a.AddUserMessage(text)
+	}
+	path := filepath.Join(t.TempDir(), "chat.md")
+	if err := a.ExportConversation(path, ExportMarkdown); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "_1 earlier messages were dropped") || strings.Contains(string(data), "one") {
+		t.Errorf("export = %q, want a note in place of the dropped message", data)
+	}
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"io/fs"
+	"time"
 
 	acp "github.com/coder/acp-go-sdk"
 	"github.com/ron/tui_acp/tui/logger"
@@ -14,18 +15,51 @@ type MessageHandler interface {
 	OnMessageComplete(ctx context.Context) error
 }
 
+// ThoughtHandler is implemented by message handlers that want the agent's
+// reasoning, streamed separately from its response
+type ThoughtHandler interface {
+	OnThoughtChunk(ctx context.Context, text string) error
+}
+
+// AttachmentHandler is implemented by message handlers that show the non-text
+// content an agent streams, such as images and resources, as a placeholder
+type AttachmentHandler interface {
+	OnAttachment(ctx context.Context, description string) error
+}
+
 // ToolMessageHandler defines the interface for handling tool call notifications
 type ToolMessageHandler interface {
 	OnToolInput(ctx context.Context, method string, params map[string]interface{}) error
 	OnToolOutput(ctx context.Context, method string, result interface{}, err error) error
 }
 
+// WritePermissionHandler is asked before the agent writes outside the working directory.
+// It should block until the user grants or denies writes to dir for the session.
+type WritePermissionHandler interface {
+	OnWritePermissionRequest(ctx context.Context, dir string) (bool, error)
+}
+
 // GrepResult represents a single match from a grep search
 type GrepResult struct {
 	Path       string // File path
 	LineNumber int    // Line number (1-indexed)
 	Line       string // The matching line
 	Match      string // The matched text
+	Column     int    // Byte column of the match start within the line (1-indexed)
+	RuneColumn int    // Character column of the match start within the line (1-indexed)
+	ByteOffset int64  // Absolute byte offset of the match start in the file
+}
+
+// GrepOptions controls how GrepSearch walks and matches
+type GrepOptions struct {
+	Recursive       bool     // Descend into subdirectories
+	CaseSensitive   bool     // Match letter case exactly
+	ExcludePatterns []string // Globs for files and directories to skip
+	FilePattern     string   // Glob a file's base name must match to be searched (empty = all files)
+	Multiline       bool     // Match whole files so patterns can span lines
+	FixedString     bool     // Treat the pattern as literal text rather than a regex
+	WholeWord       bool     // Only match the pattern at word boundaries
+	MaxResults      int      // Stop after this many matches (0 = unlimited)
 }
 
 // DirectoryEntry represents a file or directory in a listing
@@ -37,11 +71,93 @@ type DirectoryEntry struct {
 	Mode  fs.FileMode // File mode and permissions
 }
 
+// SkippedPath records a path that could not be read during a walk or search
+type SkippedPath struct {
+	Path   string // Path that was skipped
+	Reason string // Why it was skipped (e.g. permission denied)
+}
+
+// ReadResult holds the content returned by a file read
+type ReadResult struct {
+	Content   string // File content, possibly truncated
+	Truncated bool   // Whether content was cut at the read limit
+	TotalSize int64  // Size of the whole file in bytes
+	NextLine  int    // Line to continue from with a windowed read when truncated
+	// NextOffset is the byte offset to continue from when a byte-range read is truncated
+	NextOffset int64
+}
+
+// FileStat describes the metadata of a path
+type FileStat struct {
+	Path    string      // Resolved path
+	Exists  bool        // Whether the path exists
+	IsDir   bool        // Whether it's a directory
+	Size    int64       // File size in bytes
+	Mode    fs.FileMode // File mode and permissions
+	ModTime time.Time   // Last modification time
+}
+
 // Config contains configuration for creating an ACPClient
 type Config struct {
 	Address string
 	Logger  logger.Logger
 	Handler MessageHandler
+
+	// Transport selects how the agent is reached (see ProtocolConfig.Transport)
+	Transport string
+	// Command and Args launch the agent for the stdio transport
+	Command string
+	Args    []string
+
+	// GrepWorkers bounds concurrent file scans in grep searches (0 = runtime.NumCPU())
+	GrepWorkers int
+	// FollowSymlinks makes recursive walks descend into symlinked directories
+	FollowSymlinks bool
+	// RestrictToCwd rejects agent file access outside the session working directory
+	RestrictToCwd bool
+	// MaxGrepFileBytes skips larger files during grep (0 = DefaultMaxGrepFileBytes)
+	MaxGrepFileBytes int64
+	// MaxGrepLineBytes skips longer lines during grep (0 = DefaultMaxGrepLineBytes)
+	MaxGrepLineBytes int64
+	// GrepFileTimeout skips a file that takes longer than this to grep (0 = no limit)
+	GrepFileTimeout time.Duration
+	// AbsolutePaths returns resolved absolute paths in file responses rather
+	// than paths relative to the working directory
+	AbsolutePaths bool
+	// MaxGrepResults caps grep matches whatever the agent asks for (0 = DefaultMaxGrepResults)
+	MaxGrepResults int
+	// MaxWriteBytes rejects agent writes with more content than this (0 = DefaultMaxWriteBytes)
+	MaxWriteBytes int64
+	// MaxReadBytes truncates agent reads returning more content than this (0 = DefaultMaxReadBytes)
+	MaxReadBytes int64
+	// HeartbeatInterval is how often the agent is pinged to check liveness (0 = disabled)
+	HeartbeatInterval time.Duration
+	// ConnectTimeout bounds each connection attempt (0 = DefaultConnectTimeout)
+	ConnectTimeout time.Duration
+	// ReadTimeout and WriteTimeout fail a connection that stalls for that long
+	// (0 = never); see ProtocolConfig
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// MaxRetries is how many times a failed connection attempt is retried (0 = none)
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubling after each (0 = DefaultRetryBackoff)
+	RetryBackoff time.Duration
+	// McpServers are offered to the agent for the session
+	McpServers []acp.McpServer
+	// JSONEncoding is how extension responses appear in the debug log (compact or indent)
+	JSONEncoding string
+	// SaveImages writes images the agent streams to temporary files
+	SaveImages bool
+	// PermissionTimeout rejects permission requests left unanswered this long (0 = wait indefinitely)
+	PermissionTimeout time.Duration
+	// PermissionPolicy allows or denies permission requests without asking (nil = always ask)
+	PermissionPolicy *PermissionPolicy
+	// EnableTerminal lets the agent run commands in the working directory
+	EnableTerminal bool
+	// Cwd is the working directory for the session and agent file access (empty = os.Getwd)
+	Cwd string
+	// SessionID resumes a prior session when the agent supports it (empty = new session)
+	SessionID string
 }
 
 // ACPClient is a facade that composes protocol, capability, and extension components
@@ -55,8 +171,9 @@ type ACPClient struct {
 	logger     logger.Logger
 }
 
-// NewACPClient creates a new ACP client and connects to the specified TCP address
-func NewACPClient(cfg Config) (*ACPClient, error) {
+// NewACPClient creates a new ACP client and connects to the agent over the configured transport.
+// ctx bounds connecting, including retries, and the protocol handshake.
+func NewACPClient(ctx context.Context, cfg Config) (*ACPClient, error) {
 	if cfg.Logger == nil {
 		cfg.Logger = logger.NewNoopLogger()
 	}
@@ -70,9 +187,31 @@ func NewACPClient(cfg Config) (*ACPClient, error) {
 	// Create filesystem adapter (will be initialized with cwd after protocol connects)
 	// For now use "." as placeholder - will be updated after connection
 	client.fs = NewFileSystemAdapter(".", cfg.Logger)
+	if cfg.GrepWorkers > 0 {
+		client.fs.SetGrepWorkers(cfg.GrepWorkers)
+	}
+	client.fs.SetFollowSymlinks(cfg.FollowSymlinks)
+	client.fs.SetRestrictToCwd(cfg.RestrictToCwd)
+	if cfg.MaxGrepFileBytes > 0 {
+		client.fs.SetMaxFileBytes(cfg.MaxGrepFileBytes)
+	}
+	if cfg.MaxGrepLineBytes > 0 {
+		client.fs.SetMaxLineBytes(cfg.MaxGrepLineBytes)
+	}
+	client.fs.SetGrepFileTimeout(cfg.GrepFileTimeout)
+	if cfg.MaxWriteBytes > 0 {
+		client.fs.SetMaxWriteBytes(cfg.MaxWriteBytes)
+	}
+	if cfg.MaxReadBytes > 0 {
+		client.fs.SetMaxReadBytes(cfg.MaxReadBytes)
+	}
 
 	// Create capability handler
 	client.capability = NewCapabilityHandler(client.fs, cfg.Handler, cfg.Logger)
+	client.capability.SetSaveImages(cfg.SaveImages)
+	client.capability.SetPermissionTimeout(cfg.PermissionTimeout)
+	client.capability.SetPermissionPolicy(cfg.PermissionPolicy)
+	client.capability.SetTerminalEnabled(cfg.EnableTerminal)
 
 	// Create extension router with optional tool message handler
 	var toolHandler ToolMessageHandler
@@ -80,18 +219,40 @@ func NewACPClient(cfg Config) (*ACPClient, error) {
 		toolHandler = th
 	}
 	client.extension = NewExtensionRouter(client.fs, cfg.Logger, toolHandler)
+	if cfg.MaxGrepResults > 0 {
+		client.extension.SetMaxGrepResults(cfg.MaxGrepResults)
+	}
+	client.extension.SetAbsolutePaths(cfg.AbsolutePaths)
+	client.extension.SetWriteAuthorizer(client.capability.authorizeWrite)
 
 	// Create protocol client (this establishes the connection)
-	protocol, err := NewProtocolClient(ProtocolConfig{
-		Address:          cfg.Address,
-		Logger:           cfg.Logger,
-		ACPClient:        client, // ACPClient implements acp.Client via delegation
-		ExtensionHandler: client.extension,
+	protocol, err := NewProtocolClient(ctx, ProtocolConfig{
+		Transport:         cfg.Transport,
+		Address:           cfg.Address,
+		Command:           cfg.Command,
+		Args:              cfg.Args,
+		Logger:            cfg.Logger,
+		ACPClient:         client, // ACPClient implements acp.Client via delegation
+		ExtensionHandler:  client.extension,
+		HeartbeatInterval: cfg.HeartbeatInterval,
+		ConnectTimeout:    cfg.ConnectTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		MaxRetries:        cfg.MaxRetries,
+		RetryBackoff:      cfg.RetryBackoff,
+		McpServers:        cfg.McpServers,
+		JSONEncoding:      cfg.JSONEncoding,
+		Cwd:               cfg.Cwd,
+		SessionID:         cfg.SessionID,
+		Terminal:          cfg.EnableTerminal,
+		OnLifecycle:       client.onLifecycle,
+		OnSessionModes:    client.capability.setSessionModes,
 	})
 	if err != nil {
 		return nil, err
 	}
 	client.protocol = protocol
+	client.extension.SetNotifier(protocol.middleware)
 
 	// Update filesystem adapter with actual working directory
 	// (same instance is shared by capability handler and extension router)
@@ -104,7 +265,8 @@ func NewACPClient(cfg Config) (*ACPClient, error) {
 func (c *ACPClient) SendPrompt(ctx context.Context, prompt string) error {
 	err := c.protocol.SendPrompt(ctx, prompt)
 
-	// Signal that the message is complete
+	// The turn is over and its chunks delivered, or it failed: either way the
+	// response is complete
 	if c.handler != nil {
 		c.handler.OnMessageComplete(ctx)
 	}
@@ -112,14 +274,45 @@ func (c *ACPClient) SendPrompt(ctx context.Context, prompt string) error {
 	return err
 }
 
-// Close closes the ACP client and TCP connection
+// Close stops any file watches and closes the ACP client and its connection to the agent
 func (c *ACPClient) Close() error {
+	c.extension.StopWatches()
+	c.capability.ReleaseTerminals()
+
 	if c.protocol != nil {
 		return c.protocol.Close()
 	}
 	return nil
 }
 
+// SessionID returns the ID of the agent session, new or resumed
+func (c *ACPClient) SessionID() string {
+	if c.protocol == nil {
+		return ""
+	}
+	return c.protocol.SessionID()
+}
+
+// Health returns the connection health reported by the heartbeat
+func (c *ACPClient) Health() ConnectionHealth {
+	if c.protocol == nil {
+		return ConnectionHealth{}
+	}
+	return c.protocol.Health()
+}
+
+// WatchCount returns how many file watches the agent has active
+func (c *ACPClient) WatchCount() int {
+	return c.extension.WatchCount()
+}
+
+// onLifecycle passes connection transitions to the handler if it follows them
+func (c *ACPClient) onLifecycle(event LifecycleEvent) {
+	if lh, ok := c.handler.(LifecycleHandler); ok {
+		lh.OnLifecycleEvent(event)
+	}
+}
+
 // acp.Client interface implementation - delegates to CapabilityHandler
 
 // SessionUpdate handles session update notifications from the agent
@@ -142,27 +335,27 @@ func (c *ACPClient) ReadTextFile(ctx context.Context, p acp.ReadTextFileRequest)
 	return c.capability.ReadTextFile(ctx, p)
 }
 
-// CreateTerminal is not supported in this client
+// CreateTerminal starts a command for the agent
 func (c *ACPClient) CreateTerminal(ctx context.Context, p acp.CreateTerminalRequest) (acp.CreateTerminalResponse, error) {
 	return c.capability.CreateTerminal(ctx, p)
 }
 
-// KillTerminalCommand is not supported in this client
+// KillTerminalCommand stops a terminal's command
 func (c *ACPClient) KillTerminalCommand(ctx context.Context, p acp.KillTerminalCommandRequest) (acp.KillTerminalCommandResponse, error) {
 	return c.capability.KillTerminalCommand(ctx, p)
 }
 
-// ReleaseTerminal is not supported in this client
+// ReleaseTerminal stops and forgets a terminal
 func (c *ACPClient) ReleaseTerminal(ctx context.Context, p acp.ReleaseTerminalRequest) (acp.ReleaseTerminalResponse, error) {
 	return c.capability.ReleaseTerminal(ctx, p)
 }
 
-// TerminalOutput is not supported in this client
+// TerminalOutput returns a terminal's output
 func (c *ACPClient) TerminalOutput(ctx context.Context, p acp.TerminalOutputRequest) (acp.TerminalOutputResponse, error) {
 	return c.capability.TerminalOutput(ctx, p)
 }
 
-// WaitForTerminalExit is not supported in this client
+// WaitForTerminalExit waits for a terminal's command to exit
 func (c *ACPClient) WaitForTerminalExit(ctx context.Context, p acp.WaitForTerminalExitRequest) (acp.WaitForTerminalExitResponse, error) {
 	return c.capability.WaitForTerminalExit(ctx, p)
 }
@@ -170,11 +363,51 @@ func (c *ACPClient) WaitForTerminalExit(ctx context.Context, p acp.WaitForTermin
 // Filesystem delegation methods for external use
 
 // GrepSearch delegates to the FileSystemAdapter
-func (c *ACPClient) GrepSearch(ctx context.Context, pattern string, paths []string, recursive bool, caseSensitive bool) ([]GrepResult, error) {
-	return c.fs.GrepSearch(ctx, pattern, paths, recursive, caseSensitive)
+func (c *ACPClient) GrepSearch(ctx context.Context, pattern string, paths []string, opts GrepOptions) ([]GrepResult, []SkippedPath, bool, error) {
+	return c.fs.GrepSearch(ctx, pattern, paths, opts)
+}
+
+// GrepSearchStream delegates to the FileSystemAdapter
+func (c *ACPClient) GrepSearchStream(ctx context.Context, pattern string, paths []string, opts GrepOptions, emit func(GrepResult) bool) ([]SkippedPath, error) {
+	return c.fs.GrepSearchStream(ctx, pattern, paths, opts, emit)
 }
 
 // ListDirectories delegates to the FileSystemAdapter
-func (c *ACPClient) ListDirectories(ctx context.Context, path string, recursive bool) ([]DirectoryEntry, error) {
-	return c.fs.ListDirectories(ctx, path, recursive)
+func (c *ACPClient) ListDirectories(ctx context.Context, path string, recursive bool, maxDepth int) ([]DirectoryEntry, []SkippedPath, error) {
+	return c.fs.ListDirectories(ctx, path, recursive, maxDepth)
+}
+
+// FindFiles delegates to the FileSystemAdapter
+func (c *ACPClient) FindFiles(ctx context.Context, path string, namePattern string, recursive bool, limit int) ([]string, error) {
+	return c.fs.FindFiles(ctx, path, namePattern, recursive, limit)
+}
+
+// ReadTextFileBytes delegates to the FileSystemAdapter
+func (c *ACPClient) ReadTextFileBytes(path string, offset int64, length int64) (ReadResult, error) {
+	return c.fs.ReadTextFileBytes(path, offset, length)
+}
+
+// TailTextFile delegates to the FileSystemAdapter
+func (c *ACPClient) TailTextFile(path string, lines int) (string, error) {
+	return c.fs.TailTextFile(path, lines)
+}
+
+// Stat delegates to the FileSystemAdapter
+func (c *ACPClient) Stat(path string) (FileStat, error) {
+	return c.fs.Stat(path)
+}
+
+// DeleteFile delegates to the FileSystemAdapter
+func (c *ACPClient) DeleteFile(path string) error {
+	return c.fs.DeleteFile(path)
+}
+
+// DeleteDirectory delegates to the FileSystemAdapter
+func (c *ACPClient) DeleteDirectory(path string) error {
+	return c.fs.DeleteDirectory(path)
+}
+
+// MoveFile delegates to the FileSystemAdapter
+func (c *ACPClient) MoveFile(src string, dst string) error {
+	return c.fs.MoveFile(src, dst)
 }
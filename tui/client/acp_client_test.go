@@ -0,0 +1,227 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	acp "github.com/coder/acp-go-sdk"
+	"github.com/ron/tui_acp/tui/client/clienttest"
+)
+
+// agentProcessEnv makes the test binary run as an agent on stdin and stdout,
+// so the stdio transport has a process to spawn
+const agentProcessEnv = "TUI_ACP_TEST_AGENT"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(agentProcessEnv) == "1" {
+		new(clienttest.Agent).Serve(os.Stdout, os.Stdin)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// recorder is a MessageHandler keeping everything the agent streamed
+type recorder struct {
+	mu          sync.Mutex
+	chunks      []string
+	attachments []string
+	// completions holds, for each OnMessageComplete, how many chunks preceded it
+	completions []int
+}
+
+func (r *recorder) OnMessageChunk(ctx context.Context, text string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chunks = append(r.chunks, text)
+	return nil
+}
+
+func (r *recorder) OnMessageComplete(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.completions = append(r.completions, len(r.chunks))
+	return nil
+}
+
+func (r *recorder) OnAttachment(ctx context.Context, description string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attachments = append(r.attachments, description)
+	return nil
+}
+
+// messages returns the chunks received so far
+func (r *recorder) messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.chunks...)
+}
+
+// completed returns the chunk count at each completion so far
+func (r *recorder) completed() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.completions...)
+}
+
+// connect connects a client with cfg to agent, closing it when the test ends
+func connect(t *testing.T, agent *clienttest.Agent, cfg Config) *ACPClient {
+	t.Helper()
+	if cfg.Address == "" && cfg.Transport != TransportStdio {
+		cfg.Address = agent.Listen(t)
+	}
+	if cfg.Cwd == "" {
+		cfg.Cwd = t.TempDir()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := NewACPClient(ctx, cfg)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestStdioTransport(t *testing.T) {
+	t.Setenv(agentProcessEnv, "1")
+	handler := &recorder{}
+	c := connect(t, nil, Config{
+		Transport: TransportStdio,
+		Command:   os.Args[0],
+		Handler:   handler,
+	})
+
+	if got := c.SessionID(); got != "session-1" {
+		t.Errorf("session = %q, want session-1", got)
+	}
+	if err := c.SendPrompt(context.Background(), "hi"); err != nil {
+		t.Fatalf("prompt: %v", err)
+	}
+	if got, want := handler.messages(), []string{"Hello"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("chunks = %q, want %q", got, want)
+	}
+
+	// Closing stops the agent process
+	if err := c.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+}
+
+func TestSessionNewAndResume(t *testing.T) {
+	tests := []struct {
+		name      string
+		canLoad   bool
+		sessionID string
+		want      string // Session the client ends up in
+		loaded    bool   // Whether it got there with session/load
+	}{
+		{name: "new", canLoad: true, want: "session-1"},
+		{name: "resume", canLoad: true, sessionID: "earlier", want: "earlier", loaded: true},
+		{name: "agent can't load", sessionID: "earlier", want: "session-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := &clienttest.Agent{CanLoadSession: tt.canLoad}
+			cwd := t.TempDir()
+			c := connect(t, agent, Config{SessionID: tt.sessionID, Cwd: cwd})
+
+			if got := c.SessionID(); got != tt.want {
+				t.Errorf("session = %q, want %q", got, tt.want)
+			}
+			loads, news := agent.Loads(), agent.NewSessions()
+			if tt.loaded {
+				if len(loads) != 1 || loads[0].SessionId != acp.SessionId(tt.sessionID) || loads[0].Cwd != cwd {
+					t.Errorf("loads = %+v, want one of %s in %s", loads, tt.sessionID, cwd)
+				}
+				if len(news) != 0 {
+					t.Errorf("created %d sessions as well as resuming", len(news))
+				}
+			} else if len(loads) != 0 || len(news) != 1 {
+				t.Errorf("%d loads and %d new sessions, want just a new session", len(loads), len(news))
+			}
+		})
+	}
+}
+
+// streamChunks answers a prompt with n chunks, then ends the turn with stop or
+// fails it with err
+func streamChunks(n int, stop acp.StopReason, err error) clienttest.PromptFunc {
+	return func(ctx context.Context, conn *acp.AgentSideConnection, p acp.PromptRequest) (acp.PromptResponse, error) {
+		for i := 0; i < n; i++ {
+			clienttest.Send(ctx, conn, p.SessionId, acp.UpdateAgentMessageText(fmt.Sprintf("chunk %d ", i)))
+		}
+		if err != nil {
+			return acp.PromptResponse{}, err
+		}
+		return acp.PromptResponse{StopReason: stop}, nil
+	}
+}
+
+func TestMessageCompleteFollowsLastChunk(t *testing.T) {
+	const n = 200
+	tests := []struct {
+		name    string
+		prompt  clienttest.PromptFunc
+		wantErr bool
+	}{
+		{name: "end turn", prompt: streamChunks(n, acp.StopReasonEndTurn, nil)},
+		{name: "max tokens", prompt: streamChunks(n, acp.StopReasonMaxTokens, nil)},
+		{name: "failed turn", prompt: streamChunks(n, "", errors.New("model overloaded")), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &recorder{}
+			c := connect(t, &clienttest.Agent{OnPrompt: tt.prompt}, Config{Handler: handler})
+
+			// The agent answers as soon as it has sent the chunks, which are
+			// handled on their own goroutines and may still be in flight
+			err := c.SendPrompt(context.Background(), "hi")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("prompt error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := handler.completed(); !reflect.DeepEqual(got, []int{n}) {
+				t.Errorf("completed after %v chunks, want once after all %d", got, n)
+			}
+		})
+	}
+}
+
+// slowRecorder is a recorder that takes a moment over each chunk, as a UI
+// rendering it would
+type slowRecorder struct {
+	recorder
+}
+
+func (r *slowRecorder) OnMessageChunk(ctx context.Context, text string) error {
+	time.Sleep(time.Millisecond)
+	return r.recorder.OnMessageChunk(ctx, text)
+}
+
+func TestChunksHandledInOrder(t *testing.T) {
+	const n = 50
+	handler := &slowRecorder{}
+	c := connect(t, &clienttest.Agent{OnPrompt: streamChunks(n, acp.StopReasonEndTurn, nil)}, Config{Handler: handler})
+
+	if err := c.SendPrompt(context.Background(), "hi"); err != nil {
+		t.Fatalf("prompt: %v", err)
+	}
+	chunks := handler.messages()
+	if len(chunks) != n {
+		t.Fatalf("got %d chunks, want %d", len(chunks), n)
+	}
+	for i, chunk := range chunks {
+		if want := fmt.Sprintf("chunk %d ", i); chunk != want {
+			t.Fatalf("chunk %d is %q, want the chunks in the order sent", i, chunk)
+		}
+	}
+}
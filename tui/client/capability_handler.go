@@ -3,6 +3,9 @@ package client
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
 
 	acp "github.com/coder/acp-go-sdk"
 	"github.com/ron/tui_acp/tui/logger"
@@ -14,6 +17,29 @@ type CapabilityHandler struct {
 	fs      *FileSystemAdapter
 	handler MessageHandler
 	logger  logger.Logger
+
+	// Session-scoped write decisions for directories outside cwd, keyed by directory.
+	// A decision also covers the directory's subdirectories.
+	grantsMu sync.Mutex
+	grants   map[string]bool
+	promptMu sync.Mutex // Serializes prompts so one directory is only asked about once
+
+	saveImages bool // Write streamed images to temporary files
+
+	permissionTimeout time.Duration     // How long permission requests wait for an answer (0 = indefinitely)
+	permissionPolicy  *PermissionPolicy // Decides permission requests before the user is asked
+
+	// Commands the agent runs through the terminal methods, by terminal ID
+	terminalEnabled bool
+	terminalsMu     sync.Mutex
+	terminals       map[string]*terminal
+	nextTerminalID  int
+
+	// Tool calls the agent reported that haven't finished, by ID
+	toolCallsMu sync.Mutex
+	toolCalls   map[string]ToolCall
+
+	modes sessionModes // Modes the session advertised and the current one
 }
 
 // NewCapabilityHandler creates a new capability handler
@@ -25,6 +51,10 @@ func NewCapabilityHandler(fs *FileSystemAdapter, handler MessageHandler, log log
 		fs:      fs,
 		handler: handler,
 		logger:  log,
+		grants:  make(map[string]bool),
+
+		terminals: make(map[string]*terminal),
+		toolCalls: make(map[string]ToolCall),
 	}
 }
 
@@ -33,6 +63,12 @@ func (c *CapabilityHandler) SetMessageHandler(handler MessageHandler) {
 	c.handler = handler
 }
 
+// SetSaveImages controls whether images the agent streams are written to
+// temporary files, whose paths are shown with their placeholders
+func (c *CapabilityHandler) SetSaveImages(save bool) {
+	c.saveImages = save
+}
+
 // SessionUpdate handles session update notifications from the agent
 func (c *CapabilityHandler) SessionUpdate(ctx context.Context, n acp.SessionNotification) error {
 	u := n.Update
@@ -49,14 +85,39 @@ func (c *CapabilityHandler) SessionUpdate(ctx context.Context, n acp.SessionNoti
 		return c.handleMessageChunk(ctx, &u.AgentMessageChunk.Content, "agent")
 	}
 
+	if u.AgentThoughtChunk != nil {
+		c.logger.Debug("AgentThoughtChunk: %+v", u.AgentThoughtChunk)
+		return c.handleThoughtChunk(ctx, &u.AgentThoughtChunk.Content)
+	}
+
+	if u.Plan != nil {
+		c.logger.Debug("Plan: %d entries", len(u.Plan.Entries))
+		return c.handlePlan(ctx, u.Plan)
+	}
+
+	if u.ToolCall != nil {
+		return c.handleToolCall(ctx, u.ToolCall)
+	}
+
+	if u.ToolCallUpdate != nil {
+		return c.handleToolCallUpdate(ctx, u.ToolCallUpdate)
+	}
+
+	if u.CurrentModeUpdate != nil {
+		return c.setCurrentMode(ctx, string(u.CurrentModeUpdate.CurrentModeId))
+	}
+
 	return nil
 }
 
 // handleMessageChunk processes message chunks and forwards them to the handler
 func (c *CapabilityHandler) handleMessageChunk(ctx context.Context, content *acp.ContentBlock, messageType string) error {
-	if content == nil || content.Text == nil {
+	if content == nil {
 		return nil
 	}
+	if content.Text == nil {
+		return c.handleAttachment(ctx, content, messageType)
+	}
 
 	textChunk := content.Text.Text
 	c.logger.Info("Received %s message chunk: %s", messageType, textChunk)
@@ -67,24 +128,49 @@ func (c *CapabilityHandler) handleMessageChunk(ctx context.Context, content *acp
 	return nil
 }
 
-// RequestPermission handles permission requests from the agent
-func (c *CapabilityHandler) RequestPermission(ctx context.Context, p acp.RequestPermissionRequest) (acp.RequestPermissionResponse, error) {
-	if len(p.Options) > 0 {
-		return acp.RequestPermissionResponse{
-			Outcome: acp.RequestPermissionOutcome{
-				Selected: &acp.RequestPermissionOutcomeSelected{
-					OptionId: p.Options[0].OptionId,
-				},
-			},
-		}, nil
-	}
-	return acp.RequestPermissionResponse{}, fmt.Errorf("no options provided")
+// handleAttachment shows a non-text block, such as an image or resource, as a
+// placeholder. Blocks of unknown kinds are dropped.
+func (c *CapabilityHandler) handleAttachment(ctx context.Context, content *acp.ContentBlock, messageType string) error {
+	description, ok := describeContent(content)
+	if !ok {
+		return nil
+	}
+
+	if content.Image != nil && c.saveImages {
+		if path, err := saveImage(content.Image); err != nil {
+			c.logger.Warn("Could not save %s image: %v", messageType, err)
+		} else {
+			description += " saved to " + path
+		}
+	}
+
+	c.logger.Info("Received %s attachment: %s", messageType, description)
+	if ah, ok := c.handler.(AttachmentHandler); ok {
+		return ah.OnAttachment(ctx, description)
+	}
+	return nil
+}
+
+// handleThoughtChunk forwards reasoning text to handlers that want it
+func (c *CapabilityHandler) handleThoughtChunk(ctx context.Context, content *acp.ContentBlock) error {
+	if content == nil || content.Text == nil {
+		return nil
+	}
+
+	if th, ok := c.handler.(ThoughtHandler); ok {
+		return th.OnThoughtChunk(ctx, content.Text.Text)
+	}
+	return nil
 }
 
 // WriteTextFile handles file write requests from the agent
 func (c *CapabilityHandler) WriteTextFile(ctx context.Context, p acp.WriteTextFileRequest) (acp.WriteTextFileResponse, error) {
 	c.logger.Info("WriteTextFile called for path: %s", p.Path)
 
+	if err := c.authorizeWrite(ctx, p.Path); err != nil {
+		return acp.WriteTextFileResponse{}, err
+	}
+
 	if err := c.fs.WriteTextFile(p.Path, p.Content); err != nil {
 		return acp.WriteTextFileResponse{}, err
 	}
@@ -92,48 +178,117 @@ func (c *CapabilityHandler) WriteTextFile(ctx context.Context, p acp.WriteTextFi
 	return acp.WriteTextFileResponse{}, nil
 }
 
-// ReadTextFile handles file read requests from the agent
-func (c *CapabilityHandler) ReadTextFile(ctx context.Context, p acp.ReadTextFileRequest) (acp.ReadTextFileResponse, error) {
-	c.logger.Info("ReadTextFile called for path: %s", p.Path)
+// authorizeWrite allows writes inside the working directory. Elsewhere it consults
+// the session grants and, when the directory hasn't been decided yet, asks the
+// handler. Without a WritePermissionHandler undecided writes are denied.
+func (c *CapabilityHandler) authorizeWrite(ctx context.Context, path string) error {
+	dir := filepath.Dir(c.fs.ResolvePath(path))
+	if c.fs.IsWithinCwd(dir) {
+		return nil
+	}
 
-	content, err := c.fs.ReadTextFile(p.Path)
-	if err != nil {
-		return acp.ReadTextFileResponse{}, err
+	allowed, decided := c.lookupGrant(dir)
+	if !decided {
+		c.promptMu.Lock()
+		defer c.promptMu.Unlock()
+
+		// Another write may have asked while we waited
+		allowed, decided = c.lookupGrant(dir)
+		if !decided {
+			var err error
+			allowed, err = c.promptWrite(ctx, dir)
+			if err != nil {
+				return err
+			}
+			c.grantsMu.Lock()
+			c.grants[dir] = allowed
+			c.grantsMu.Unlock()
+		}
 	}
 
-	return acp.ReadTextFileResponse{
-		Content: content,
-	}, nil
+	if !allowed {
+		c.logger.Warn("Denied write to %s: no permission for %s", path, dir)
+		return fmt.Errorf("write to %s denied: user did not grant access to %s", path, dir)
+	}
+	return nil
 }
 
-// unsupportedMethodError creates an error for unsupported client methods
-func unsupportedMethodError(methodName string) error {
-	return fmt.Errorf("%s not supported in this client", methodName)
+// lookupGrant finds the decision for dir or its nearest decided ancestor
+func (c *CapabilityHandler) lookupGrant(dir string) (allowed, decided bool) {
+	c.grantsMu.Lock()
+	defer c.grantsMu.Unlock()
+
+	for {
+		if allowed, ok := c.grants[dir]; ok {
+			return allowed, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false, false
+		}
+		dir = parent
+	}
 }
 
-// Terminal stubs - these methods return errors as terminal operations are not supported
+// promptWrite asks the handler whether the agent may write in dir
+func (c *CapabilityHandler) promptWrite(ctx context.Context, dir string) (bool, error) {
+	prompter, ok := c.handler.(WritePermissionHandler)
+	if !ok {
+		return false, nil
+	}
 
-// CreateTerminal is not supported in this client
-func (c *CapabilityHandler) CreateTerminal(ctx context.Context, p acp.CreateTerminalRequest) (acp.CreateTerminalResponse, error) {
-	return acp.CreateTerminalResponse{}, unsupportedMethodError("CreateTerminal")
+	c.logger.Info("Asking for write permission in %s", dir)
+	allowed, err := prompter.OnWritePermissionRequest(ctx, dir)
+	if err != nil {
+		return false, fmt.Errorf("write permission prompt failed: %w", err)
+	}
+	c.logger.Info("Write permission for %s: %v", dir, allowed)
+	return allowed, nil
 }
 
-// KillTerminalCommand is not supported in this client
-func (c *CapabilityHandler) KillTerminalCommand(ctx context.Context, p acp.KillTerminalCommandRequest) (acp.KillTerminalCommandResponse, error) {
-	return acp.KillTerminalCommandResponse{}, unsupportedMethodError("KillTerminalCommand")
-}
+// ReadTextFile handles file read requests from the agent.
+// When Line or Limit is set only that window of lines is read.
+// Content over the read limit is truncated and described in the response _meta:
+// truncated, totalSize, nextLine (where to resume with Line/Limit) and a message.
+func (c *CapabilityHandler) ReadTextFile(ctx context.Context, p acp.ReadTextFileRequest) (acp.ReadTextFileResponse, error) {
+	c.logger.Info("ReadTextFile called for path: %s", p.Path)
 
-// ReleaseTerminal is not supported in this client
-func (c *CapabilityHandler) ReleaseTerminal(ctx context.Context, p acp.ReleaseTerminalRequest) (acp.ReleaseTerminalResponse, error) {
-	return acp.ReleaseTerminalResponse{}, unsupportedMethodError("ReleaseTerminal")
-}
+	var result ReadResult
+	var err error
+	if p.Line != nil || p.Limit != nil {
+		line, limit := 1, 0
+		if p.Line != nil {
+			line = *p.Line
+		}
+		if p.Limit != nil {
+			limit = *p.Limit
+		}
+		result, err = c.fs.ReadTextFileRange(p.Path, line, limit)
+	} else {
+		result, err = c.fs.ReadTextFile(p.Path)
+	}
+	if err != nil {
+		return acp.ReadTextFileResponse{}, err
+	}
+
+	response := acp.ReadTextFileResponse{
+		Content: result.Content,
+	}
+
+	if result.Truncated {
+		response.Meta = map[string]interface{}{
+			"truncated": true,
+			"totalSize": result.TotalSize,
+			"nextLine":  result.NextLine,
+			"message": fmt.Sprintf("Content truncated to %d of %d bytes. Read the rest with line=%d and a limit.",
+				len(result.Content), result.TotalSize, result.NextLine),
+		}
+	}
 
-// TerminalOutput is not supported in this client
-func (c *CapabilityHandler) TerminalOutput(ctx context.Context, p acp.TerminalOutputRequest) (acp.TerminalOutputResponse, error) {
-	return acp.TerminalOutputResponse{}, unsupportedMethodError("TerminalOutput")
+	return response, nil
 }
 
-// WaitForTerminalExit is not supported in this client
-func (c *CapabilityHandler) WaitForTerminalExit(ctx context.Context, p acp.WaitForTerminalExitRequest) (acp.WaitForTerminalExitResponse, error) {
-	return acp.WaitForTerminalExitResponse{}, unsupportedMethodError("WaitForTerminalExit")
+// unsupportedMethodError creates an error for unsupported client methods
+func unsupportedMethodError(methodName string) error {
+	return fmt.Errorf("%s not supported in this client", methodName)
 }
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	acp "github.com/coder/acp-go-sdk"
+)
+
+// writePrompter answers write permission requests with allow and records the
+// directories it was asked about. When release is set, each answer waits for it.
+type writePrompter struct {
+	allow   bool
+	release chan struct{}
+
+	mu    sync.Mutex
+	asked []string
+}
+
+func (p *writePrompter) OnMessageChunk(ctx context.Context, text string) error { return nil }
+func (p *writePrompter) OnMessageComplete(ctx context.Context) error           { return nil }
+
+func (p *writePrompter) OnWritePermissionRequest(ctx context.Context, dir string) (bool, error) {
+	p.mu.Lock()
+	p.asked = append(p.asked, dir)
+	p.mu.Unlock()
+	if p.release != nil {
+		<-p.release
+	}
+	return p.allow, nil
+}
+
+// prompts returns the directories asked about so far
+func (p *writePrompter) prompts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.asked...)
+}
+
+// newWriteSandbox returns a capability handler working in cwd, next to an
+// outside directory the agent has no access to until it is granted
+func newWriteSandbox(t *testing.T, handler MessageHandler) (c *CapabilityHandler, cwd, outside string) {
+	t.Helper()
+	root := t.TempDir()
+	cwd, outside = filepath.Join(root, "work"), filepath.Join(root, "outside")
+	writeFiles(t, root, map[string]string{"work/keep": "", "outside/sub/keep": ""})
+	return NewCapabilityHandler(NewFileSystemAdapter(cwd, nil), handler, nil), cwd, outside
+}
+
+// writeFile asks c to write content to path
+func writeFile(c *CapabilityHandler, path, content string) error {
+	_, err := c.WriteTextFile(context.Background(), acp.WriteTextFileRequest{Path: path, Content: content})
+	return err
+}
+
+func TestWriteInsideCwdDoesNotPrompt(t *testing.T) {
+	prompter := &writePrompter{}
+	c, cwd, _ := newWriteSandbox(t, prompter)
+
+	for _, path := range []string{"notes.txt", filepath.Join(cwd, "new", "deep.txt")} {
+		if err := writeFile(c, path, "hi"); err != nil {
+			t.Errorf("write %s: %v", path, err)
+		}
+	}
+	if asked := prompter.prompts(); len(asked) != 0 {
+		t.Errorf("asked about %v, want no prompts inside the working directory", asked)
+	}
+}
+
+func TestWriteGrantRemembered(t *testing.T) {
+	prompter := &writePrompter{allow: true}
+	c, _, outside := newWriteSandbox(t, prompter)
+
+	// The grant for outside covers it and its subdirectories
+	for _, path := range []string{
+		filepath.Join(outside, "a.txt"),
+		filepath.Join(outside, "b.txt"),
+		filepath.Join(outside, "sub", "c.txt"),
+	} {
+		if err := writeFile(c, path, "hi"); err != nil {
+			t.Errorf("write %s: %v", path, err)
+		}
+	}
+	if asked := prompter.prompts(); len(asked) != 1 || asked[0] != outside {
+		t.Errorf("asked about %v, want %s once", asked, outside)
+	}
+	if got, err := os.ReadFile(filepath.Join(outside, "sub", "c.txt")); err != nil || string(got) != "hi" {
+		t.Errorf("sub/c.txt = %q, %v; want it written", got, err)
+	}
+}
+
+func TestWriteDenialRemembered(t *testing.T) {
+	prompter := &writePrompter{allow: false}
+	c, _, outside := newWriteSandbox(t, prompter)
+
+	for _, path := range []string{filepath.Join(outside, "a.txt"), filepath.Join(outside, "sub", "b.txt")} {
+		err := writeFile(c, path, "hi")
+		if err == nil || !strings.Contains(err.Error(), "denied") {
+			t.Errorf("write %s: err = %v, want it denied", path, err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s exists after a denied write", path)
+		}
+	}
+	if asked := prompter.prompts(); len(asked) != 1 {
+		t.Errorf("asked about %v, want the denial remembered after one prompt", asked)
+	}
+}
+
+func TestWriteWithoutPrompterDenied(t *testing.T) {
+	c, _, outside := newWriteSandbox(t, &recorder{})
+
+	err := writeFile(c, filepath.Join(outside, "a.txt"), "hi")
+	if err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("err = %v, want undecided writes denied", err)
+	}
+}
+
+func TestConcurrentWritesPromptOnce(t *testing.T) {
+	prompter := &writePrompter{allow: true, release: make(chan struct{})}
+	c, _, outside := newWriteSandbox(t, prompter)
+
+	errs := make(chan error, 2)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		go func() { errs <- writeFile(c, filepath.Join(outside, name), "hi") }()
+	}
+
+	// Hold the first prompt open while the second write reaches the lock
+	deadline := time.Now().Add(5 * time.Second)
+	for len(prompter.prompts()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(prompter.release)
+
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Errorf("write: %v", err)
+		}
+	}
+	if asked := prompter.prompts(); len(asked) != 1 {
+		t.Errorf("asked about %v, want one prompt for both writes", asked)
+	}
+}
+
+// newGuardedRouter returns a router sharing c's filesystem and write grants,
+// as the client wires them
+func newGuardedRouter(c *CapabilityHandler) *ExtensionRouter {
+	r := NewExtensionRouter(c.fs, nil, nil)
+	r.SetWriteAuthorizer(c.authorizeWrite)
+	return r
+}
+
+func TestDeleteAndMoveOutsideCwdAuthorized(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		params func(cwd, outside string) map[string]interface{}
+		// kept is a path, relative to the sandbox root, that a denied call leaves alone
+		kept string
+	}{
+		{
+			name:   "delete",
+			method: "_fs/delete",
+			params: func(cwd, outside string) map[string]interface{} {
+				return map[string]interface{}{"path": filepath.Join(outside, "sub"), "recursive": true}
+			},
+			kept: "outside/sub/keep",
+		},
+		{
+			name:   "move out of cwd",
+			method: "_fs/move",
+			params: func(cwd, outside string) map[string]interface{} {
+				return map[string]interface{}{"source": "keep", "destination": filepath.Join(outside, "moved")}
+			},
+			kept: "work/keep",
+		},
+		{
+			name:   "move into cwd",
+			method: "_fs/move",
+			params: func(cwd, outside string) map[string]interface{} {
+				return map[string]interface{}{"source": filepath.Join(outside, "sub", "keep"), "destination": "moved"}
+			},
+			kept: "outside/sub/keep",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" denied", func(t *testing.T) {
+			prompter := &writePrompter{allow: false}
+			c, cwd, outside := newWriteSandbox(t, prompter)
+
+			_, err := newGuardedRouter(c).HandleExtensionMethod(context.Background(), tt.method, tt.params(cwd, outside))
+			if err == nil || !strings.Contains(err.Error(), "denied") {
+				t.Errorf("err = %v, want it denied", err)
+			}
+			if asked := prompter.prompts(); len(asked) != 1 {
+				t.Errorf("asked about %v, want one prompt", asked)
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(cwd), tt.kept)); err != nil {
+				t.Errorf("%s touched by a denied call: %v", tt.kept, err)
+			}
+		})
+
+		t.Run(tt.name+" granted", func(t *testing.T) {
+			prompter := &writePrompter{allow: true}
+			c, cwd, outside := newWriteSandbox(t, prompter)
+
+			call(t, newGuardedRouter(c), tt.method, tt.params(cwd, outside))
+			if _, err := os.Stat(filepath.Join(filepath.Dir(cwd), tt.kept)); !os.IsNotExist(err) {
+				t.Errorf("%s still there after a granted call", tt.kept)
+			}
+		})
+	}
+}
+
+func TestDeleteWithoutAuthorizerStaysInCwd(t *testing.T) {
+	c, cwd, outside := newWriteSandbox(t, nil)
+	r := NewExtensionRouter(c.fs, nil, nil)
+
+	_, err := r.HandleExtensionMethod(context.Background(), "_fs/delete", map[string]interface{}{"path": filepath.Join(outside, "sub", "keep")})
+	if err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("err = %v, want a delete outside the working directory denied", err)
+	}
+	call(t, r, "_fs/delete", map[string]interface{}{"path": "keep"})
+	if _, err := os.Stat(filepath.Join(cwd, "keep")); !os.IsNotExist(err) {
+		t.Error("keep still there after deleting it inside the working directory")
+	}
+}
@@ -0,0 +1,161 @@
+// Package clienttest provides an in-process ACP agent for testing clients
+// against a real connection.
+package clienttest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+
+	acp "github.com/coder/acp-go-sdk"
+)
+
+// PromptFunc answers a prompt turn, streaming updates through conn
+type PromptFunc func(ctx context.Context, conn *acp.AgentSideConnection, p acp.PromptRequest) (acp.PromptResponse, error)
+
+// Agent is a scripted ACP agent. It creates numbered sessions and records the
+// requests it receives so tests can check what the client sent.
+type Agent struct {
+	// OnPrompt answers prompts (nil = reply "Hello" and end the turn)
+	OnPrompt PromptFunc
+	// CanLoadSession advertises session/load and accepts it for any session
+	CanLoadSession bool
+
+	mu          sync.Mutex
+	conn        *acp.AgentSideConnection
+	newSessions []acp.NewSessionRequest
+	loads       []acp.LoadSessionRequest
+	prompts     []acp.PromptRequest
+	cancels     []acp.CancelNotification
+}
+
+// Serve speaks ACP over r and w until the client disconnects
+func (a *Agent) Serve(w io.Writer, r io.Reader) {
+	conn := acp.NewAgentSideConnection(a, w, r)
+	conn.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	a.mu.Lock()
+	a.conn = conn
+	a.mu.Unlock()
+	<-conn.Done()
+}
+
+// Listen serves clients one at a time on a local TCP address, which it
+// returns. The listener is closed when the test ends.
+func (a *Agent) Listen(t testing.TB) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			a.Serve(c, c)
+			c.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// NewSessions returns the session/new requests received so far
+func (a *Agent) NewSessions() []acp.NewSessionRequest {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]acp.NewSessionRequest(nil), a.newSessions...)
+}
+
+// Loads returns the session/load requests received so far
+func (a *Agent) Loads() []acp.LoadSessionRequest {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]acp.LoadSessionRequest(nil), a.loads...)
+}
+
+// Prompts returns the session/prompt requests received so far
+func (a *Agent) Prompts() []acp.PromptRequest {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]acp.PromptRequest(nil), a.prompts...)
+}
+
+// Cancels returns the session/cancel notifications received so far
+func (a *Agent) Cancels() []acp.CancelNotification {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]acp.CancelNotification(nil), a.cancels...)
+}
+
+// Authenticate implements acp.Agent; no authentication is required
+func (a *Agent) Authenticate(ctx context.Context, p acp.AuthenticateRequest) (acp.AuthenticateResponse, error) {
+	return acp.AuthenticateResponse{}, nil
+}
+
+// Initialize implements acp.Agent
+func (a *Agent) Initialize(ctx context.Context, p acp.InitializeRequest) (acp.InitializeResponse, error) {
+	return acp.InitializeResponse{
+		ProtocolVersion:   acp.ProtocolVersionNumber,
+		AgentCapabilities: acp.AgentCapabilities{LoadSession: a.CanLoadSession},
+	}, nil
+}
+
+// Cancel implements acp.Agent. The SDK has already cancelled the turn's context.
+func (a *Agent) Cancel(ctx context.Context, p acp.CancelNotification) error {
+	a.mu.Lock()
+	a.cancels = append(a.cancels, p)
+	a.mu.Unlock()
+	return nil
+}
+
+// NewSession implements acp.Agent, naming sessions session-1, session-2, ...
+func (a *Agent) NewSession(ctx context.Context, p acp.NewSessionRequest) (acp.NewSessionResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.newSessions = append(a.newSessions, p)
+	return acp.NewSessionResponse{SessionId: acp.SessionId(fmt.Sprintf("session-%d", len(a.newSessions)))}, nil
+}
+
+// LoadSession implements acp.AgentLoader
+func (a *Agent) LoadSession(ctx context.Context, p acp.LoadSessionRequest) (acp.LoadSessionResponse, error) {
+	if !a.CanLoadSession {
+		return acp.LoadSessionResponse{}, acp.NewMethodNotFound(acp.AgentMethodSessionLoad)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.loads = append(a.loads, p)
+	return acp.LoadSessionResponse{}, nil
+}
+
+// Prompt implements acp.Agent by calling OnPrompt
+func (a *Agent) Prompt(ctx context.Context, p acp.PromptRequest) (acp.PromptResponse, error) {
+	a.mu.Lock()
+	a.prompts = append(a.prompts, p)
+	conn := a.conn
+	a.mu.Unlock()
+
+	if a.OnPrompt != nil {
+		return a.OnPrompt(ctx, conn, p)
+	}
+	if err := Send(ctx, conn, p.SessionId, acp.UpdateAgentMessageText("Hello")); err != nil {
+		return acp.PromptResponse{}, err
+	}
+	return acp.PromptResponse{StopReason: acp.StopReasonEndTurn}, nil
+}
+
+// SetSessionMode implements acp.Agent; any mode is accepted
+func (a *Agent) SetSessionMode(ctx context.Context, p acp.SetSessionModeRequest) (acp.SetSessionModeResponse, error) {
+	return acp.SetSessionModeResponse{}, nil
+}
+
+// Send streams one update for the session to the client
+func Send(ctx context.Context, conn *acp.AgentSideConnection, sessionID acp.SessionId, update acp.SessionUpdate) error {
+	return conn.SessionUpdate(ctx, acp.SessionNotification{SessionId: sessionID, Update: update})
+}
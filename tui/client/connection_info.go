@@ -0,0 +1,92 @@
+package client
+
+import (
+	"fmt"
+
+	acp "github.com/coder/acp-go-sdk"
+)
+
+// ConnectionInfo describes what was negotiated with the agent, for diagnostics
+type ConnectionInfo struct {
+	Address            string // Agent address, or the command line for the stdio transport
+	Transport          string
+	SessionID          string
+	Cwd                string
+	ProtocolVersion    int
+	Agent              string   // Name and version the agent reported, if any
+	AgentCapabilities  []string // What the agent advertised it supports
+	ClientCapabilities []string // What this client advertised
+	AuthMethods        []string // Authentication methods the agent offers
+}
+
+// Info returns what was negotiated when the connection was set up
+func (p *ProtocolClient) Info() ConnectionInfo {
+	info := ConnectionInfo{
+		Address:         p.address,
+		Transport:       p.transportName,
+		SessionID:       p.SessionID(),
+		Cwd:             p.cwd,
+		ProtocolVersion: int(p.initResp.ProtocolVersion),
+	}
+	if agent := p.initResp.AgentInfo; agent != nil {
+		info.Agent = agent.Name
+		if agent.Title != nil && *agent.Title != "" {
+			info.Agent = *agent.Title
+		}
+		if agent.Version != "" {
+			info.Agent += " " + agent.Version
+		}
+	}
+
+	caps := p.initResp.AgentCapabilities
+	info.AgentCapabilities = capabilityNames([]capability{
+		{"session/load", caps.LoadSession},
+		{"image prompts", caps.PromptCapabilities.Image},
+		{"audio prompts", caps.PromptCapabilities.Audio},
+		{"embedded context", caps.PromptCapabilities.EmbeddedContext},
+		{"MCP over HTTP", caps.McpCapabilities.Http},
+		{"MCP over SSE", caps.McpCapabilities.Sse},
+	})
+	info.ClientCapabilities = capabilityNames([]capability{
+		{"fs/read_text_file", p.clientCaps.Fs.ReadTextFile},
+		{"fs/write_text_file", p.clientCaps.Fs.WriteTextFile},
+		{"terminal", p.clientCaps.Terminal},
+	})
+	for _, method := range p.initResp.AuthMethods {
+		info.AuthMethods = append(info.AuthMethods, fmt.Sprintf("%s (%s)", method.Name, method.Id))
+	}
+	return info
+}
+
+// capability is a named capability and whether it is supported
+type capability struct {
+	name      string
+	supported bool
+}
+
+// capabilityNames lists the names of the supported capabilities
+func capabilityNames(caps []capability) []string {
+	names := make([]string, 0, len(caps))
+	for _, c := range caps {
+		if c.supported {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
+
+// Info returns what was negotiated with the agent
+func (c *ACPClient) Info() ConnectionInfo {
+	if c.protocol == nil {
+		return ConnectionInfo{}
+	}
+	return c.protocol.Info()
+}
+
+// clientCapabilities is what the client advertises in the initialize request
+func clientCapabilities(terminal bool) acp.ClientCapabilities {
+	return acp.ClientCapabilities{
+		Fs:       acp.FileSystemCapability{ReadTextFile: true, WriteTextFile: true},
+		Terminal: terminal,
+	}
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"strings"
+
+	acp "github.com/coder/acp-go-sdk"
+)
+
+// describeContent returns a placeholder for a non-text content block, such as
+// "[image: image/png, 12KB]" or "[resource: main.go]". It reports false for text
+// and for blocks it doesn't know.
+func describeContent(content *acp.ContentBlock) (string, bool) {
+	switch {
+	case content.Image != nil:
+		return fmt.Sprintf("[image: %s, %s]", content.Image.MimeType, formatByteSize(base64DecodedLen(content.Image.Data))), true
+	case content.Audio != nil:
+		return fmt.Sprintf("[audio: %s, %s]", content.Audio.MimeType, formatByteSize(base64DecodedLen(content.Audio.Data))), true
+	case content.ResourceLink != nil:
+		link := content.ResourceLink
+		desc := link.Name
+		if desc == "" {
+			desc = link.Uri
+		}
+		if link.Size != nil {
+			desc += ", " + formatByteSize(int64(*link.Size))
+		}
+		return fmt.Sprintf("[resource link: %s]", desc), true
+	case content.Resource != nil:
+		res := content.Resource.Resource
+		switch {
+		case res.TextResourceContents != nil:
+			text := res.TextResourceContents
+			return fmt.Sprintf("[resource: %s, %s]", resourceName(text.Uri), formatByteSize(int64(len(text.Text)))), true
+		case res.BlobResourceContents != nil:
+			blob := res.BlobResourceContents
+			return fmt.Sprintf("[resource: %s, %s]", resourceName(blob.Uri), formatByteSize(base64DecodedLen(blob.Blob))), true
+		}
+		return "[resource]", true
+	}
+	return "", false
+}
+
+// saveImage writes an image block's bytes to a temporary file and returns its path
+func saveImage(image *acp.ContentBlockImage) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(image.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	ext := ".bin"
+	if exts, err := mime.ExtensionsByType(image.MimeType); err == nil && len(exts) > 0 {
+		ext = exts[0]
+	}
+
+	file, err := os.CreateTemp("", "acp-image-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("failed to save image: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return "", fmt.Errorf("failed to save image: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// resourceName shortens a resource URI to its last path element
+func resourceName(uri string) string {
+	if name := path.Base(uri); name != "." && name != "/" {
+		return name
+	}
+	return uri
+}
+
+// base64DecodedLen returns the size of the data a base64 string encodes
+func base64DecodedLen(data string) int64 {
+	padding := len(data) - len(strings.TrimRight(data, "="))
+	return int64(len(data)/4*3 - padding)
+}
+
+// formatByteSize renders a byte count compactly, e.g. 512B, 12KB or 3.4MB
+func formatByteSize(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%dB", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%dKB", n/1024)
+	default:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1024*1024))
+	}
+}
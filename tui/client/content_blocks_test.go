@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	acp "github.com/coder/acp-go-sdk"
+)
+
+func TestDescribeContent(t *testing.T) {
+	png := base64.StdEncoding.EncodeToString(make([]byte, 12*1024))
+
+	tests := []struct {
+		name  string
+		block acp.ContentBlock
+		want  string
+	}{
+		{"image", acp.ImageBlock(png, "image/png"), "[image: image/png, 12KB]"},
+		{"audio", acp.AudioBlock(base64.StdEncoding.EncodeToString([]byte("wave")), "audio/wav"), "[audio: audio/wav, 4B]"},
+		{"resource link", acp.ResourceLinkBlock("main.go", "file:///src/main.go"), "[resource link: main.go]"},
+		{"resource link by uri", acp.ResourceLinkBlock("", "file:///src/main.go"), "[resource link: file:///src/main.go]"},
+		{
+			"resource link with size",
+			acp.ContentBlock{ResourceLink: &acp.ContentBlockResourceLink{Name: "big.bin", Uri: "file:///big.bin", Size: acp.Ptr(3 * 1024 * 1024)}},
+			"[resource link: big.bin, 3.0MB]",
+		},
+		{
+			"text resource",
+			acp.ResourceBlock(acp.EmbeddedResourceResource{TextResourceContents: &acp.TextResourceContents{Uri: "file:///src/file.go", Text: "package main\n"}}),
+			"[resource: file.go, 13B]",
+		},
+		{
+			"blob resource",
+			acp.ResourceBlock(acp.EmbeddedResourceResource{BlobResourceContents: &acp.BlobResourceContents{Uri: "file:///data.bin", Blob: "AAAA"}}),
+			"[resource: data.bin, 3B]",
+		},
+		{"empty resource", acp.ResourceBlock(acp.EmbeddedResourceResource{}), "[resource]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := describeContent(&tt.block)
+			if !ok || got != tt.want {
+				t.Errorf("describeContent = %q, %v; want %q", got, ok, tt.want)
+			}
+		})
+	}
+
+	if _, ok := describeContent(&acp.ContentBlock{}); ok {
+		t.Error("an unknown block was described")
+	}
+}
+
+func TestAttachmentsReachHandler(t *testing.T) {
+	handler := &recorder{}
+	c := NewCapabilityHandler(NewFileSystemAdapter(t.TempDir(), nil), handler, nil)
+	ctx := context.Background()
+
+	updates := []acp.SessionUpdate{
+		acp.UpdateAgentMessageText("Here it is: "),
+		acp.UpdateAgentMessage(acp.ResourceLinkBlock("main.go", "file:///src/main.go")),
+		acp.UpdateUserMessage(acp.ImageBlock("AAAA", "image/gif")),
+	}
+	for _, u := range updates {
+		if err := c.SessionUpdate(ctx, acp.SessionNotification{SessionId: "s", Update: u}); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	}
+
+	if got, want := handler.messages(), []string{"Here it is: "}; !reflect.DeepEqual(got, want) {
+		t.Errorf("chunks = %q, want %q", got, want)
+	}
+	if got, want := handler.attachments, []string{"[resource link: main.go]", "[image: image/gif, 3B]"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("attachments = %q, want %q", got, want)
+	}
+}
+
+func TestSaveImages(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	data := []byte("\x89PNG\r\n\x1a\n")
+
+	handler := &recorder{}
+	c := NewCapabilityHandler(NewFileSystemAdapter(t.TempDir(), nil), handler, nil)
+	c.SetSaveImages(true)
+	update := acp.UpdateAgentMessage(acp.ImageBlock(base64.StdEncoding.EncodeToString(data), "image/png"))
+	if err := c.SessionUpdate(context.Background(), acp.SessionNotification{SessionId: "s", Update: update}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	if len(handler.attachments) != 1 {
+		t.Fatalf("attachments = %q, want one", handler.attachments)
+	}
+	description, path, ok := strings.Cut(handler.attachments[0], " saved to ")
+	if !ok || description != "[image: image/png, 8B]" || !strings.HasSuffix(path, ".png") {
+		t.Fatalf("attachment = %q, want the image and the .png it was saved to", handler.attachments[0])
+	}
+	if saved, err := os.ReadFile(path); err != nil || !reflect.DeepEqual(saved, data) {
+		t.Errorf("saved %q (%v), want the decoded image", saved, err)
+	}
+}
@@ -4,17 +4,65 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ron/tui_acp/tui/logger"
 )
 
+// Grep response limits. Agents may lower or raise the defaults per request,
+// but never beyond the ceilings, to keep JSON responses bounded.
+// The grep result ceiling can be lowered or raised by the host via SetMaxGrepResults.
+const (
+	defaultGrepMaxResults    = 20
+	DefaultMaxGrepResults    = 1000
+	defaultGrepMaxLineLength = 200
+	maxGrepLineLengthCeiling = 10000
+
+	defaultFindMaxResults = 100
+	maxFindResultsCeiling = 1000
+
+	// defaultTailLines is how many lines _fs/tail returns when none are requested
+	defaultTailLines = 10
+
+	// watchEventMethod is the notification sent to the agent for each watched change
+	watchEventMethod = "_fs/watch_event"
+
+	// maxSkippedPathsListed caps how many unreadable paths are listed in a response
+	maxSkippedPathsListed = 20
+)
+
 // ExtensionRouter handles custom extension methods that start with underscore.
 // According to the ACP extensibility spec, method names starting with _ are reserved
 // for custom extensions.
+// Methods that operate on paths (grep, find, stat, list) accept either a single
+// path or a list of them; see pathsParam for the accepted shapes.
 type ExtensionRouter struct {
-	fs          *FileSystemAdapter
-	logger      logger.Logger
-	toolHandler ToolMessageHandler
+	fs             *FileSystemAdapter
+	logger         logger.Logger
+	toolHandler    ToolMessageHandler
+	maxGrepResults int  // Hard cap on grep matches, regardless of what the agent requests
+	absolutePaths  bool // Return paths as resolved rather than relative to the working directory
+
+	// Checks deletes and moves against the session's write grants; see SetWriteAuthorizer
+	authorizeWrite func(ctx context.Context, path string) error
+
+	// Watches started by _fs/watch. They outlive the request, so each has its own context.
+	notifier Notifier
+	watchMu  sync.Mutex
+	watchSeq int
+	watches  map[string]*activeWatch
+}
+
+// activeWatch is a watch started by the agent
+type activeWatch struct {
+	seq    int // Start order, for stable listings
+	id     string
+	path   string
+	mode   string
+	cancel context.CancelFunc
 }
 
 // NewExtensionRouter creates a new extension method router
@@ -23,10 +71,73 @@ func NewExtensionRouter(fs *FileSystemAdapter, log logger.Logger, toolHandler To
 		log = logger.NewNoopLogger()
 	}
 	return &ExtensionRouter{
-		fs:          fs,
-		logger:      log,
-		toolHandler: toolHandler,
+		fs:             fs,
+		logger:         log,
+		toolHandler:    toolHandler,
+		maxGrepResults: DefaultMaxGrepResults,
+		watches:        make(map[string]*activeWatch),
+	}
+}
+
+// SetNotifier sets where watch events are sent. Without one, _fs/watch is refused.
+func (r *ExtensionRouter) SetNotifier(notifier Notifier) {
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+	r.notifier = notifier
+}
+
+// SetWriteAuthorizer sets the check deletes and moves make before changing a
+// path, the one the capability handler makes for writes. Without one, only
+// paths inside the working directory may be changed.
+func (r *ExtensionRouter) SetWriteAuthorizer(authorize func(ctx context.Context, path string) error) {
+	r.authorizeWrite = authorize
+}
+
+// checkWrite reports whether the agent may change path
+func (r *ExtensionRouter) checkWrite(ctx context.Context, path string) error {
+	if r.authorizeWrite != nil {
+		return r.authorizeWrite(ctx, path)
+	}
+	if dir := filepath.Dir(r.fs.ResolvePath(path)); !r.fs.IsWithinCwd(dir) {
+		return fmt.Errorf("write to %s denied: user did not grant access to %s", path, dir)
+	}
+	return nil
+}
+
+// SetMaxGrepResults sets the hard cap on grep matches returned to the agent.
+// Values below 1 reset it to DefaultMaxGrepResults.
+func (r *ExtensionRouter) SetMaxGrepResults(limit int) {
+	if limit < 1 {
+		limit = DefaultMaxGrepResults
 	}
+	r.maxGrepResults = limit
+	r.logger.Debug("ExtensionRouter grep result cap set to: %d", limit)
+}
+
+// SetAbsolutePaths controls whether paths in grep, list, find, stat and delete
+// responses are absolute, as resolved, or relative to the working directory.
+// Relative is the default: shorter, and it doesn't reveal the host's layout.
+func (r *ExtensionRouter) SetAbsolutePaths(absolute bool) {
+	r.absolutePaths = absolute
+	r.logger.Debug("ExtensionRouter absolute paths set to: %v", absolute)
+}
+
+// responsePath returns path as it is given to the agent: relative to the working
+// directory unless absolute paths are on. Paths outside the working directory
+// stay absolute, as a relative path climbing out of it reads poorly.
+func (r *ExtensionRouter) responsePath(path string) string {
+	if r.absolutePaths || !filepath.IsAbs(path) || r.fs.cwd == "" {
+		return path
+	}
+	cwd, err := filepath.Abs(r.fs.cwd)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(cwd, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
 }
 
 // HandleExtensionMethod routes extension methods to their handlers
@@ -44,8 +155,26 @@ func (r *ExtensionRouter) HandleExtensionMethod(ctx context.Context, method stri
 		result, err = r.handleGrepSearch(ctx, params)
 	case "_fs/list_dirs":
 		result, err = r.handleListDirs(ctx, params)
+	case "_fs/find_files":
+		result, err = r.handleFindFiles(ctx, params)
+	case "_fs/stat":
+		result, err = r.handleStat(ctx, params)
+	case "_fs/read_bytes":
+		result, err = r.handleReadBytes(ctx, params)
+	case "_fs/tail":
+		result, err = r.handleTail(ctx, params)
+	case "_fs/watch":
+		result, err = r.handleWatch(ctx, params)
+	case "_fs/watch_list":
+		result, err = r.handleWatchList(ctx, params)
+	case "_fs/unwatch":
+		result, err = r.handleUnwatch(ctx, params)
+	case "_fs/delete":
+		result, err = r.handleDelete(ctx, params)
+	case "_fs/move":
+		result, err = r.handleMove(ctx, params)
 	default:
-		err = fmt.Errorf("extension method not supported: %s", method)
+		err = &RPCError{Code: CodeMethodNotFound, Message: fmt.Sprintf("extension method not supported: %s", method)}
 	}
 
 	// Broadcast tool output
@@ -63,58 +192,58 @@ func (r *ExtensionRouter) handleGrepSearch(ctx context.Context, params map[strin
 	// Extract parameters
 	pattern, _ := params["pattern"].(string)
 	if pattern == "" {
-		return nil, fmt.Errorf("pattern is required")
+		return nil, invalidParamsError("pattern is required")
 	}
 
-	path, _ := params["path"].(string)
-	if path == "" {
-		path = "."
+	paths, err := r.pathsParam(params, ".")
+	if err != nil {
+		return nil, err
 	}
 
 	caseSensitive, _ := params["caseSensitive"].(bool)
+	multiline, _ := params["multiline"].(bool)
+	fixedString, _ := params["fixedString"].(bool)
+	wholeWord, _ := params["wholeWord"].(bool)
 	filePattern, _ := params["filePattern"].(string)
-
-	// Resolve the path relative to working directory
-	resolvedPath := r.fs.ResolvePath(path)
-
-	r.logger.Debug("Grep search: pattern=%s, path=%s, caseSensitive=%v, filePattern=%s",
-		pattern, resolvedPath, caseSensitive, filePattern)
-
-	// Perform the grep search (recursive by default)
-	results, err := r.fs.GrepSearch(ctx, pattern, []string{resolvedPath}, true, caseSensitive)
+	excludePatterns := stringSliceParam(params, "excludePatterns")
+	requestedResults := intParam(params, "maxResults", defaultGrepMaxResults)
+	maxResults := clampInt(requestedResults, 1, r.maxGrepResults)
+	if requestedResults > maxResults {
+		r.logger.Warn("Grep maxResults %d clamped to host limit %d", requestedResults, maxResults)
+	}
+	maxLineLength := clampInt(intParam(params, "maxLineLength", defaultGrepMaxLineLength), 1, maxGrepLineLengthCeiling)
+
+	r.logger.Debug("Grep search: pattern=%s, paths=%v, caseSensitive=%v, multiline=%v, fixedString=%v, wholeWord=%v, filePattern=%s, excludePatterns=%v",
+		pattern, paths, caseSensitive, multiline, fixedString, wholeWord, filePattern, excludePatterns)
+
+	// Perform the grep search (recursive by default). The search stops as soon
+	// as a match beyond the limit shows the results are truncated.
+	results, skipped, truncated, err := r.fs.GrepSearch(ctx, pattern, paths, GrepOptions{
+		Recursive:       true,
+		CaseSensitive:   caseSensitive,
+		ExcludePatterns: excludePatterns,
+		FilePattern:     filePattern,
+		Multiline:       multiline,
+		FixedString:     fixedString,
+		WholeWord:       wholeWord,
+		MaxResults:      maxResults,
+	})
 	if err != nil {
 		r.logger.Error("GrepSearch failed: %v", err)
 		return nil, err
 	}
 
-	// Convert results to the expected format and limit to 20 results
-	return r.formatGrepResults(results, filePattern)
+	r.logger.Debug("Grep search found %d matches (truncated: %v)", len(results), truncated)
+	response := r.grepResponse(results, truncated, maxResults, maxLineLength, requestedResults > maxResults)
+	r.addSkippedPaths(response, skipped)
+	return response, nil
 }
 
-// formatGrepResults converts GrepResult slice to the expected response format
-func (r *ExtensionRouter) formatGrepResults(results []GrepResult, filePattern string) (map[string]interface{}, error) {
-	const maxResults = 20
-	const maxLineLength = 200
-
+// grepResponse converts grep results to the response format. limitClamped
+// reports that maxResults is lower than the agent asked for.
+func (r *ExtensionRouter) grepResponse(results []GrepResult, truncated bool, maxResults int, maxLineLength int, limitClamped bool) map[string]interface{} {
 	matches := make([]map[string]interface{}, 0, len(results))
-	truncated := false
-	hasFilePattern := filePattern != ""
-
 	for _, result := range results {
-		// Stop if we've reached the limit
-		if len(matches) >= maxResults {
-			truncated = true
-			break
-		}
-
-		// Apply file pattern filter if specified
-		if hasFilePattern {
-			matched, err := filepath.Match(filePattern, filepath.Base(result.Path))
-			if err != nil || !matched {
-				continue
-			}
-		}
-
 		// Truncate long lines to avoid huge JSON responses
 		line := result.Line
 		if len(line) > maxLineLength {
@@ -122,25 +251,33 @@ func (r *ExtensionRouter) formatGrepResults(results []GrepResult, filePattern st
 		}
 
 		matches = append(matches, map[string]interface{}{
-			"path":       result.Path,
+			"path":       r.responsePath(result.Path),
 			"lineNumber": result.LineNumber,
+			"column":     result.Column,
+			"runeColumn": result.RuneColumn,
+			"byteOffset": result.ByteOffset,
 			"line":       line,
 			"match":      result.Match,
 		})
 	}
 
-	r.logger.Debug("Grep search found %d matches (truncated: %v)", len(matches), truncated)
-
 	response := map[string]interface{}{
 		"matches":   matches,
 		"truncated": truncated,
+		"limit":     maxResults,
 	}
 
+	if limitClamped {
+		response["limitClamped"] = true
+	}
 	if truncated {
 		response["message"] = fmt.Sprintf("Results limited to %d matches. Refine your search for more specific results.", maxResults)
+		if limitClamped {
+			response["message"] = fmt.Sprintf("Results limited to %d matches, the host's maximum (lower than the maxResults requested). Refine your search for more specific results.", maxResults)
+		}
 	}
 
-	return response, nil
+	return response
 }
 
 // handleListDirs handles the _fs/list_dirs extension method
@@ -148,27 +285,39 @@ func (r *ExtensionRouter) handleListDirs(ctx context.Context, params map[string]
 	r.logger.Info("HandleListDirs called with params: %+v", params)
 
 	// Extract parameters
-	path, _ := params["path"].(string)
-	if path == "" {
-		path = "."
+	paths, err := r.pathsParam(params, ".")
+	if err != nil {
+		return nil, err
 	}
 
 	recursive, _ := params["recursive"].(bool)
+	maxDepth := intParam(params, "maxDepth", 0)
+	if maxDepth < 0 {
+		maxDepth = 0
+	}
 
-	// Resolve the path relative to working directory
-	resolvedPath := r.fs.ResolvePath(path)
+	r.logger.Debug("List dirs: paths=%v, recursive=%v, maxDepth=%d", paths, recursive, maxDepth)
 
-	r.logger.Debug("List dirs: path=%s, recursive=%v", resolvedPath, recursive)
+	// Perform the directory listing for each path in turn
+	var results []DirectoryEntry
+	var skipped []SkippedPath
+	for _, path := range paths {
+		entries, pathSkipped, err := r.fs.ListDirectories(ctx, path, recursive, maxDepth)
+		if err != nil {
+			r.logger.Error("ListDirectories failed: %v", err)
+			return nil, err
+		}
+		results = append(results, entries...)
+		skipped = append(skipped, pathSkipped...)
+	}
 
-	// Perform the directory listing
-	results, err := r.fs.ListDirectories(ctx, resolvedPath, recursive)
+	// Convert results to the expected format
+	response, err := r.formatListDirsResults(results)
 	if err != nil {
-		r.logger.Error("ListDirectories failed: %v", err)
 		return nil, err
 	}
-
-	// Convert results to the expected format
-	return r.formatListDirsResults(results)
+	r.addSkippedPaths(response, skipped)
+	return response, nil
 }
 
 // formatListDirsResults converts DirectoryEntry slice to the expected response format
@@ -185,7 +334,7 @@ func (r *ExtensionRouter) formatListDirsResults(entries []DirectoryEntry) (map[s
 		}
 
 		formattedEntries = append(formattedEntries, map[string]interface{}{
-			"path":  entry.Path,
+			"path":  r.responsePath(entry.Path),
 			"name":  entry.Name,
 			"isDir": entry.IsDir,
 			"size":  entry.Size,
@@ -207,3 +356,521 @@ func (r *ExtensionRouter) formatListDirsResults(entries []DirectoryEntry) (map[s
 
 	return response, nil
 }
+
+// addSkippedPaths records unreadable paths on a response so the agent knows the results are partial.
+// Only the first few paths are listed; skippedCount always holds the total.
+func (r *ExtensionRouter) addSkippedPaths(response map[string]interface{}, skipped []SkippedPath) {
+	if len(skipped) == 0 {
+		return
+	}
+
+	listed := skipped
+	if len(listed) > maxSkippedPathsListed {
+		listed = listed[:maxSkippedPathsListed]
+	}
+
+	formatted := make([]map[string]interface{}, 0, len(listed))
+	for _, s := range listed {
+		formatted = append(formatted, map[string]interface{}{
+			"path":   r.responsePath(s.Path),
+			"reason": s.Reason,
+		})
+	}
+
+	response["skipped"] = formatted
+	response["skippedCount"] = len(skipped)
+}
+
+// handleFindFiles handles the _fs/find_files extension method
+func (r *ExtensionRouter) handleFindFiles(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	r.logger.Info("HandleFindFiles called with params: %+v", params)
+
+	// Extract parameters
+	namePattern, _ := params["namePattern"].(string)
+	if namePattern == "" {
+		return nil, invalidParamsError("namePattern is required")
+	}
+
+	paths, err := r.pathsParam(params, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	// Recursive by default, like grep
+	recursive, ok := params["recursive"].(bool)
+	if !ok {
+		recursive = true
+	}
+
+	maxResults := clampInt(intParam(params, "maxResults", defaultFindMaxResults), 1, maxFindResultsCeiling)
+
+	r.logger.Debug("Find files: pattern=%s, paths=%v, recursive=%v", namePattern, paths, recursive)
+
+	// Ask for one extra result to detect truncation without walking the whole tree
+	var files []string
+	for _, path := range paths {
+		found, err := r.fs.FindFiles(ctx, path, namePattern, recursive, maxResults+1-len(files))
+		if err != nil {
+			r.logger.Error("FindFiles failed: %v", err)
+			return nil, err
+		}
+		files = append(files, found...)
+		if len(files) > maxResults {
+			break
+		}
+	}
+
+	truncated := len(files) > maxResults
+	if truncated {
+		files = files[:maxResults]
+	}
+	for i, file := range files {
+		files[i] = r.responsePath(file)
+	}
+
+	r.logger.Debug("Find files found %d files (truncated: %v)", len(files), truncated)
+
+	response := map[string]interface{}{
+		"files":     files,
+		"truncated": truncated,
+	}
+
+	if truncated {
+		response["message"] = fmt.Sprintf("Results limited to %d files. Refine your pattern for more specific results.", maxResults)
+	}
+
+	return response, nil
+}
+
+// handleStat handles the _fs/stat extension method
+func (r *ExtensionRouter) handleStat(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	r.logger.Info("HandleStat called with params: %+v", params)
+
+	paths, err := r.pathsParam(params, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// A single path keeps the flat response; several are returned as a list
+	if len(paths) == 1 {
+		return r.statPath(paths[0])
+	}
+
+	stats := make([]map[string]interface{}, 0, len(paths))
+	for _, path := range paths {
+		stat, err := r.statPath(path)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	return map[string]interface{}{
+		"stats": stats,
+	}, nil
+}
+
+// statPath builds the _fs/stat response for one path
+func (r *ExtensionRouter) statPath(path string) (map[string]interface{}, error) {
+	stat, err := r.fs.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	response := map[string]interface{}{
+		"path":   r.responsePath(stat.Path),
+		"exists": stat.Exists,
+	}
+
+	if stat.Exists {
+		response["isDir"] = stat.IsDir
+		response["size"] = stat.Size
+		response["mode"] = fmt.Sprintf("%o", stat.Mode.Perm())
+		response["modTime"] = stat.ModTime.Format(time.RFC3339)
+	}
+
+	return response, nil
+}
+
+// handleReadBytes handles the _fs/read_bytes extension method, reading a byte range
+// such as the region around a grep match's byteOffset
+func (r *ExtensionRouter) handleReadBytes(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	r.logger.Info("HandleReadBytes called with params: %+v", params)
+
+	path, _ := params["path"].(string)
+	if path == "" {
+		return nil, invalidParamsError("path is required")
+	}
+
+	offset := intParam(params, "offset", 0)
+	length := intParam(params, "length", 0)
+	if offset < 0 || length < 0 {
+		return nil, invalidParamsError("offset and length must not be negative")
+	}
+
+	result, err := r.fs.ReadTextFileBytes(path, int64(offset), int64(length))
+	if err != nil {
+		return nil, err
+	}
+
+	response := map[string]interface{}{
+		"path":      path,
+		"content":   result.Content,
+		"offset":    offset,
+		"length":    len(result.Content),
+		"totalSize": result.TotalSize,
+		"truncated": result.Truncated,
+	}
+	if result.Truncated {
+		response["nextOffset"] = result.NextOffset
+		response["message"] = fmt.Sprintf("Content truncated to %d bytes. Read the rest from offset=%d.",
+			len(result.Content), result.NextOffset)
+	}
+	return response, nil
+}
+
+// handleTail handles the _fs/tail extension method, returning the last lines of a file
+func (r *ExtensionRouter) handleTail(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	r.logger.Info("HandleTail called with params: %+v", params)
+
+	path, _ := params["path"].(string)
+	if path == "" {
+		return nil, invalidParamsError("path is required")
+	}
+
+	lines := intParam(params, "lines", defaultTailLines)
+	if lines < 1 {
+		return nil, invalidParamsError("lines must be at least 1")
+	}
+
+	content, err := r.fs.TailTextFile(path, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"path":    path,
+		"content": content,
+		"lines":   countLines(content),
+	}, nil
+}
+
+// handleWatch handles the _fs/watch extension method. Changes are sent to the agent
+// as _fs/watch_event notifications carrying the watch ID, path and operation.
+// mode is notify (the default, polling when OS events are unavailable) or poll,
+// and intervalMs sets the poll interval.
+func (r *ExtensionRouter) handleWatch(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	r.logger.Info("HandleWatch called with params: %+v", params)
+
+	path, _ := params["path"].(string)
+	if path == "" {
+		return nil, invalidParamsError("path is required")
+	}
+	recursive, _ := params["recursive"].(bool)
+	mode, _ := params["mode"].(string)
+	intervalMs := intParam(params, "intervalMs", 0)
+	if intervalMs < 0 {
+		return nil, invalidParamsError("intervalMs must not be negative")
+	}
+
+	r.watchMu.Lock()
+	notifier := r.notifier
+	r.watchSeq++
+	seq := r.watchSeq
+	r.watchMu.Unlock()
+	id := fmt.Sprintf("watch-%d", seq)
+
+	if notifier == nil {
+		return nil, fmt.Errorf("watching is not available: no connection to send events on")
+	}
+
+	// The watch runs until unwatched, so it must not use the request's context
+	watchCtx, cancel := context.WithCancel(context.Background())
+	emit := func(event WatchEvent) {
+		err := notifier.Notify(watchEventMethod, map[string]interface{}{
+			"watchId": id,
+			"path":    r.responsePath(event.Path),
+			"op":      event.Op,
+		})
+		if err != nil {
+			r.logger.Error("Failed to send watch event for %s: %v", event.Path, err)
+		}
+	}
+
+	usedMode, err := r.fs.Watch(watchCtx, path, WatchOptions{
+		Recursive: recursive,
+		Mode:      mode,
+		Interval:  time.Duration(intervalMs) * time.Millisecond,
+	}, emit)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	r.watchMu.Lock()
+	r.watches[id] = &activeWatch{seq: seq, id: id, path: path, mode: usedMode, cancel: cancel}
+	r.watchMu.Unlock()
+
+	return map[string]interface{}{
+		"watchId": id,
+		"path":    path,
+		"mode":    usedMode,
+	}, nil
+}
+
+// handleWatchList handles the _fs/watch_list extension method, listing active watches
+func (r *ExtensionRouter) handleWatchList(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	r.logger.Info("HandleWatchList called")
+
+	r.watchMu.Lock()
+	active := make([]*activeWatch, 0, len(r.watches))
+	for _, w := range r.watches {
+		active = append(active, w)
+	}
+	r.watchMu.Unlock()
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].seq < active[j].seq
+	})
+
+	watches := make([]map[string]interface{}, 0, len(active))
+	for _, w := range active {
+		watches = append(watches, map[string]interface{}{
+			"watchId": w.id,
+			"path":    w.path,
+			"mode":    w.mode,
+		})
+	}
+
+	return map[string]interface{}{
+		"watches": watches,
+		"count":   len(watches),
+	}, nil
+}
+
+// handleUnwatch handles the _fs/unwatch extension method, stopping a watch by ID
+func (r *ExtensionRouter) handleUnwatch(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	r.logger.Info("HandleUnwatch called with params: %+v", params)
+
+	id, _ := params["watchId"].(string)
+	if id == "" {
+		return nil, invalidParamsError("watchId is required")
+	}
+
+	r.watchMu.Lock()
+	w, ok := r.watches[id]
+	delete(r.watches, id)
+	r.watchMu.Unlock()
+
+	if !ok {
+		return nil, invalidParamsError("no active watch with ID %s", id)
+	}
+	w.cancel()
+
+	return map[string]interface{}{
+		"watchId": id,
+		"path":    w.path,
+		"stopped": true,
+	}, nil
+}
+
+// WatchCount returns how many watches are active
+func (r *ExtensionRouter) WatchCount() int {
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+	return len(r.watches)
+}
+
+// StopWatches stops every active watch, e.g. when the client closes
+func (r *ExtensionRouter) StopWatches() {
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+
+	for id, w := range r.watches {
+		w.cancel()
+		delete(r.watches, id)
+	}
+}
+
+// handleDelete handles the _fs/delete extension method.
+// Directories are only deleted when recursive is set.
+func (r *ExtensionRouter) handleDelete(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	r.logger.Info("HandleDelete called with params: %+v", params)
+
+	path, _ := params["path"].(string)
+	if path == "" {
+		return nil, invalidParamsError("path is required")
+	}
+
+	recursive, _ := params["recursive"].(bool)
+
+	if err := r.checkWrite(ctx, path); err != nil {
+		return nil, err
+	}
+
+	stat, err := r.fs.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.Exists {
+		return nil, fmt.Errorf("path does not exist: %s", stat.Path)
+	}
+
+	if stat.IsDir && recursive {
+		err = r.fs.DeleteDirectory(path)
+	} else {
+		err = r.fs.DeleteFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"path":    r.responsePath(stat.Path),
+		"isDir":   stat.IsDir,
+		"deleted": true,
+	}, nil
+}
+
+// handleMove handles the _fs/move extension method
+func (r *ExtensionRouter) handleMove(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	r.logger.Info("HandleMove called with params: %+v", params)
+
+	source, _ := params["source"].(string)
+	if source == "" {
+		return nil, invalidParamsError("source is required")
+	}
+
+	destination, _ := params["destination"].(string)
+	if destination == "" {
+		return nil, invalidParamsError("destination is required")
+	}
+
+	// The source is removed, so both ends need access
+	for _, path := range []string{source, destination} {
+		if err := r.checkWrite(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.fs.MoveFile(source, destination); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"source":      r.responsePath(r.fs.ResolvePath(source)),
+		"destination": r.responsePath(r.fs.ResolvePath(destination)),
+		"moved":       true,
+	}, nil
+}
+
+// pathsParam decodes the target paths of an _fs/* request and resolves them
+// against the working directory. Accepted shapes, all normalized to a list:
+//
+//	"path": "src"             a single path
+//	"path": ["src", "docs"]   a list under the singular key
+//	"paths": ["src", "docs"]  a list
+//	"paths": "src"            a single path under the plural key
+//
+// Giving both keys is an error. When neither is present, def is used;
+// an empty def makes the path required.
+func (r *ExtensionRouter) pathsParam(params map[string]interface{}, def string) ([]string, error) {
+	_, hasPath := params["path"]
+	_, hasPaths := params["paths"]
+	if hasPath && hasPaths {
+		return nil, invalidParamsError("pass either path or paths, not both")
+	}
+
+	key := "path"
+	if hasPaths {
+		key = "paths"
+	}
+
+	var paths []string
+	switch value := params[key].(type) {
+	case nil:
+	case string:
+		if value != "" {
+			paths = []string{value}
+		}
+	case []interface{}:
+		for i, item := range value {
+			str, ok := item.(string)
+			if !ok || str == "" {
+				return nil, invalidParamsError("%s[%d] must be a non-empty string", key, i)
+			}
+			paths = append(paths, str)
+		}
+	default:
+		return nil, invalidParamsError("%s must be a string or a list of strings", key)
+	}
+
+	if len(paths) == 0 {
+		if def == "" {
+			return nil, invalidParamsError("path is required")
+		}
+		paths = []string{def}
+	}
+
+	resolved := make([]string, 0, len(paths))
+	for _, path := range paths {
+		resolvedPath, err := r.fs.ResolveWithinRoot(path)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, resolvedPath)
+	}
+	return resolved, nil
+}
+
+// stringSliceParam extracts a list of strings from params.
+// A single string is accepted as a one-element list; non-string items are ignored.
+func stringSliceParam(params map[string]interface{}, key string) []string {
+	switch value := params[key].(type) {
+	case string:
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if str, ok := item.(string); ok && str != "" {
+				values = append(values, str)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// intParam extracts an integer from params, returning def when absent or not a number.
+// JSON numbers decode as float64, so fractional values are truncated.
+func intParam(params map[string]interface{}, key string, def int) int {
+	switch value := params[key].(type) {
+	case float64:
+		return int(value)
+	case int:
+		return value
+	}
+	return def
+}
+
+// countLines counts the lines in content; a trailing newline doesn't start another
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+}
+
+// clampInt restricts value to the range [lo, hi]
+func clampInt(value, lo, hi int) int {
+	if value < lo {
+		return lo
+	}
+	if value > hi {
+		return hi
+	}
+	return value
+}
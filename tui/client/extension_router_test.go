@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRouter returns a router over a fresh working directory holding files
+func newTestRouter(t *testing.T, files map[string]string) (*ExtensionRouter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	return NewExtensionRouter(NewFileSystemAdapter(dir, nil), nil, nil), dir
+}
+
+// call runs an extension method, failing the test on error
+func call(t *testing.T, r *ExtensionRouter, method string, params map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	result, err := r.HandleExtensionMethod(context.Background(), method, params)
+	if err != nil {
+		t.Fatalf("%s: %v", method, err)
+	}
+	response, ok := result.(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s returned %T, want a map", method, result)
+	}
+	return response
+}
+
+func TestGrepSearchLimits(t *testing.T) {
+	r, _ := newTestRouter(t, map[string]string{
+		"a.txt": strings.Repeat("match "+strings.Repeat("x", 50)+"\n", 5),
+	})
+
+	response := call(t, r, "_fs/grep_search", map[string]interface{}{
+		"pattern":       "match",
+		"maxResults":    float64(2),
+		"maxLineLength": float64(10),
+	})
+
+	matches := response["matches"].([]map[string]interface{})
+	if len(matches) != 2 {
+		t.Fatalf("got %d matches, want 2", len(matches))
+	}
+	if response["truncated"] != true || response["limit"] != 2 {
+		t.Errorf("truncated = %v, limit = %v; want true and 2", response["truncated"], response["limit"])
+	}
+	if line := matches[0]["line"]; line != "match xxxx..." {
+		t.Errorf("line = %q, want it cut to 10 bytes", line)
+	}
+	if match := matches[0]["match"]; match != "match" {
+		t.Errorf("match = %q, want the whole match", match)
+	}
+}
+
+func TestGrepSearchDefaultsAndCeiling(t *testing.T) {
+	r, _ := newTestRouter(t, map[string]string{
+		"a.txt": strings.Repeat("match\n", 30),
+	})
+
+	response := call(t, r, "_fs/grep_search", map[string]interface{}{"pattern": "match"})
+	if got := len(response["matches"].([]map[string]interface{})); got != defaultGrepMaxResults {
+		t.Errorf("got %d matches by default, want %d", got, defaultGrepMaxResults)
+	}
+
+	// Out of range values are clamped rather than rejected
+	response = call(t, r, "_fs/grep_search", map[string]interface{}{"pattern": "match", "maxResults": float64(0)})
+	if got := len(response["matches"].([]map[string]interface{})); got != 1 {
+		t.Errorf("got %d matches for maxResults 0, want 1", got)
+	}
+}
+
+func TestFindFiles(t *testing.T) {
+	r, _ := newTestRouter(t, map[string]string{
+		"main.go":         "",
+		"README.md":       "",
+		"cmd/root.go":     "",
+		"cmd/root.go.bak": "",
+	})
+
+	response := call(t, r, "_fs/find_files", map[string]interface{}{"namePattern": "*.go"})
+	if got, want := response["files"], []string{"cmd/root.go", "main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+	if response["truncated"] != false {
+		t.Errorf("truncated = %v, want false", response["truncated"])
+	}
+
+	response = call(t, r, "_fs/find_files", map[string]interface{}{"namePattern": "*.go", "recursive": false})
+	if got, want := response["files"], []string{"main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("non-recursive files = %v, want %v", got, want)
+	}
+
+	response = call(t, r, "_fs/find_files", map[string]interface{}{"namePattern": "*", "maxResults": float64(2)})
+	if files := response["files"].([]string); len(files) != 2 || response["truncated"] != true {
+		t.Errorf("files = %v, truncated = %v; want 2 files, truncated", files, response["truncated"])
+	}
+}
+
+func TestFindFilesRequiresPattern(t *testing.T) {
+	r, _ := newTestRouter(t, nil)
+	_, err := r.HandleExtensionMethod(context.Background(), "_fs/find_files", map[string]interface{}{})
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeInvalidParams {
+		t.Errorf("err = %v, want invalid params", err)
+	}
+}
+
+func TestStat(t *testing.T) {
+	r, _ := newTestRouter(t, map[string]string{"dir/a.txt": "hello"})
+
+	response := call(t, r, "_fs/stat", map[string]interface{}{"path": "dir/a.txt"})
+	if response["path"] != "dir/a.txt" || response["exists"] != true || response["isDir"] != false || response["size"] != int64(5) {
+		t.Errorf("stat of a file = %v", response)
+	}
+	if _, err := time.Parse(time.RFC3339, response["modTime"].(string)); err != nil {
+		t.Errorf("modTime: %v", err)
+	}
+
+	response = call(t, r, "_fs/stat", map[string]interface{}{"path": "dir"})
+	if response["exists"] != true || response["isDir"] != true {
+		t.Errorf("stat of a directory = %v", response)
+	}
+
+	// A missing path is a result, not an error
+	response = call(t, r, "_fs/stat", map[string]interface{}{"path": "missing"})
+	if response["exists"] != false {
+		t.Errorf("stat of a missing path = %v", response)
+	}
+	if _, ok := response["size"]; ok {
+		t.Errorf("stat of a missing path has metadata: %v", response)
+	}
+
+	response = call(t, r, "_fs/stat", map[string]interface{}{"paths": []interface{}{"dir", "missing"}})
+	if stats := response["stats"].([]map[string]interface{}); len(stats) != 2 || stats[1]["exists"] != false {
+		t.Errorf("stat of two paths = %v", response)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package client
+
+import "path/filepath"
+
+// fileIdentity returns the fully resolved path identifying path's target,
+// since device and inode numbers are not available on this platform
+func fileIdentity(path string) (fileID, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fileID{}, err
+	}
+	return fileID{path: resolved}, nil
+}
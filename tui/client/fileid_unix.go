@@ -0,0 +1,21 @@
+//go:build unix
+
+package client
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode pair identifying path's target
+func fileIdentity(path string) (fileID, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileID{}, err
+	}
+
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, nil
+	}
+	return fileID{path: path}, nil
+}
@@ -2,20 +2,59 @@ package client
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/ron/tui_acp/tui/logger"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
 )
 
 // FileSystemAdapter handles file system operations with logging and path resolution
 type FileSystemAdapter struct {
-	cwd    string
-	logger logger.Logger
+	cwd            string
+	logger         logger.Logger
+	grepWorkers    int           // Number of files grepped concurrently
+	followSymlinks bool          // Descend into symlinked directories during recursive walks
+	maxFileBytes   int64         // Files larger than this are skipped by grep
+	maxLineBytes   int64         // Longer lines are skipped by grep
+	grepTimeout    time.Duration // Grep gives up on a file after this long (0 = never)
+	maxWriteBytes  int64         // Writes with more content than this are rejected
+	maxReadBytes   int64         // Reads return at most this many bytes of content
+	restrictToCwd  bool          // Reject paths that resolve outside cwd
+}
+
+// DefaultMaxGrepFileBytes is the largest file grep will scan by default
+const DefaultMaxGrepFileBytes int64 = 10 << 20
+
+// DefaultMaxGrepLineBytes is the longest line grep will scan by default
+const DefaultMaxGrepLineBytes int64 = 1 << 20
+
+// DefaultMaxWriteBytes is the largest content an agent may write by default
+const DefaultMaxWriteBytes int64 = 50 << 20
+
+// DefaultMaxReadBytes is the most content a single read returns by default
+const DefaultMaxReadBytes int64 = 1 << 20
+
+// fileID uniquely identifies a file or directory for symlink loop detection
+type fileID struct {
+	dev  uint64
+	ino  uint64
+	path string // Used where device/inode numbers are unavailable
 }
 
 // NewFileSystemAdapter creates a new FileSystemAdapter
@@ -24,8 +63,13 @@ func NewFileSystemAdapter(cwd string, log logger.Logger) *FileSystemAdapter {
 		log = logger.NewNoopLogger()
 	}
 	return &FileSystemAdapter{
-		cwd:    cwd,
-		logger: log,
+		cwd:           cwd,
+		logger:        log,
+		grepWorkers:   runtime.NumCPU(),
+		maxFileBytes:  DefaultMaxGrepFileBytes,
+		maxLineBytes:  DefaultMaxGrepLineBytes,
+		maxWriteBytes: DefaultMaxWriteBytes,
+		maxReadBytes:  DefaultMaxReadBytes,
 	}
 }
 
@@ -35,6 +79,78 @@ func (f *FileSystemAdapter) SetCwd(cwd string) {
 	f.logger.Debug("FileSystemAdapter cwd updated to: %s", cwd)
 }
 
+// SetGrepWorkers sets how many files GrepSearch scans concurrently.
+// Values below 1 reset it to runtime.NumCPU().
+func (f *FileSystemAdapter) SetGrepWorkers(workers int) {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	f.grepWorkers = workers
+	f.logger.Debug("FileSystemAdapter grep workers set to: %d", workers)
+}
+
+// SetMaxFileBytes sets the largest file GrepSearch will scan.
+// Values below 1 reset it to DefaultMaxGrepFileBytes.
+func (f *FileSystemAdapter) SetMaxFileBytes(maxBytes int64) {
+	if maxBytes < 1 {
+		maxBytes = DefaultMaxGrepFileBytes
+	}
+	f.maxFileBytes = maxBytes
+	f.logger.Debug("FileSystemAdapter max grep file size set to: %d bytes", maxBytes)
+}
+
+// SetMaxLineBytes sets the longest line GrepSearch will scan; longer lines are
+// skipped and reported. Values below 1 reset it to DefaultMaxGrepLineBytes.
+func (f *FileSystemAdapter) SetMaxLineBytes(maxBytes int64) {
+	if maxBytes < 1 {
+		maxBytes = DefaultMaxGrepLineBytes
+	}
+	f.maxLineBytes = maxBytes
+	f.logger.Debug("FileSystemAdapter max grep line length set to: %d bytes", maxBytes)
+}
+
+// SetGrepFileTimeout sets how long GrepSearch spends on a single file before
+// skipping it, so one pathological file can't hold up the search. 0 or less
+// means no limit.
+func (f *FileSystemAdapter) SetGrepFileTimeout(timeout time.Duration) {
+	f.grepTimeout = max(timeout, 0)
+	f.logger.Debug("FileSystemAdapter grep file timeout set to: %v", f.grepTimeout)
+}
+
+// SetMaxWriteBytes sets the largest content WriteTextFile will accept.
+// Values below 1 reset it to DefaultMaxWriteBytes.
+func (f *FileSystemAdapter) SetMaxWriteBytes(maxBytes int64) {
+	if maxBytes < 1 {
+		maxBytes = DefaultMaxWriteBytes
+	}
+	f.maxWriteBytes = maxBytes
+	f.logger.Debug("FileSystemAdapter max write size set to: %d bytes", maxBytes)
+}
+
+// SetMaxReadBytes sets the most content a single read returns before truncating.
+// Values below 1 reset it to DefaultMaxReadBytes.
+func (f *FileSystemAdapter) SetMaxReadBytes(maxBytes int64) {
+	if maxBytes < 1 {
+		maxBytes = DefaultMaxReadBytes
+	}
+	f.maxReadBytes = maxBytes
+	f.logger.Debug("FileSystemAdapter max read size set to: %d bytes", maxBytes)
+}
+
+// SetRestrictToCwd confines agent-supplied paths to the working directory.
+// Paths escaping it, whether absolute, via "..", or through symlinks, are rejected.
+func (f *FileSystemAdapter) SetRestrictToCwd(restrict bool) {
+	f.restrictToCwd = restrict
+	f.logger.Debug("FileSystemAdapter restrict to cwd set to: %v", restrict)
+}
+
+// SetFollowSymlinks controls whether recursive walks descend into symlinked directories.
+// Loops are detected by tracking visited directories, so circular links are safe.
+func (f *FileSystemAdapter) SetFollowSymlinks(follow bool) {
+	f.followSymlinks = follow
+	f.logger.Debug("FileSystemAdapter follow symlinks set to: %v", follow)
+}
+
 // ResolvePath resolves a path relative to the working directory
 // If the path is already absolute, it returns it unchanged
 func (f *FileSystemAdapter) ResolvePath(path string) string {
@@ -44,6 +160,95 @@ func (f *FileSystemAdapter) ResolvePath(path string) string {
 	return filepath.Join(f.cwd, path)
 }
 
+// ResolveWithinRoot resolves a path like ResolvePath and, when restricted to the
+// working directory, rejects it if it escapes cwd after symlink resolution
+func (f *FileSystemAdapter) ResolveWithinRoot(path string) (string, error) {
+	resolved := f.ResolvePath(path)
+	if !f.restrictToCwd {
+		return resolved, nil
+	}
+	if err := f.checkWithinRoot(resolved); err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
+// checkWithinRoot returns an error if path, with symlinks resolved, lies outside cwd
+func (f *FileSystemAdapter) checkWithinRoot(path string) error {
+	within, err := f.withinCwd(path)
+	if err != nil {
+		return err
+	}
+	if !within {
+		f.logger.Warn("Rejected path %s: outside working directory %s", path, f.cwd)
+		return fmt.Errorf("path %s is outside the working directory", path)
+	}
+	return nil
+}
+
+// IsWithinCwd reports whether a resolved path lies inside the working directory
+// after symlink resolution. Paths that can't be resolved count as outside.
+func (f *FileSystemAdapter) IsWithinCwd(path string) bool {
+	within, err := f.withinCwd(path)
+	return err == nil && within
+}
+
+// withinCwd compares path against cwd with symlinks resolved on both sides
+func (f *FileSystemAdapter) withinCwd(path string) (bool, error) {
+	root, err := evalSymlinksExisting(f.cwd)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+
+	target, err := evalSymlinksExisting(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// escapesRoot reports whether a symlink found during a walk points outside cwd.
+// It is always false when paths aren't restricted.
+func (f *FileSystemAdapter) escapesRoot(filePath string, d fs.DirEntry, opts walkOptions) bool {
+	if !f.restrictToCwd || d.Type()&fs.ModeSymlink == 0 {
+		return false
+	}
+	if err := f.checkWithinRoot(filePath); err != nil {
+		opts.reportError(f, filePath, err)
+		return true
+	}
+	return false
+}
+
+// evalSymlinksExisting resolves symlinks in the longest existing prefix of path,
+// so paths that don't exist yet (like write targets) resolve to where they would land
+func evalSymlinksExisting(path string) (string, error) {
+	path = filepath.Clean(path)
+	var rest []string
+
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, rest...)...), nil
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
 // ResolveAndValidatePath resolves a path and validates it exists
 func (f *FileSystemAdapter) ResolveAndValidatePath(path string) (string, error) {
 	resolved := f.ResolvePath(path)
@@ -53,9 +258,45 @@ func (f *FileSystemAdapter) ResolveAndValidatePath(path string) (string, error)
 	return resolved, nil
 }
 
-// WriteTextFile writes content to a file, creating directories as needed
+// Stat returns metadata for a path. A missing path is not an error;
+// it is reported with Exists set to false.
+func (f *FileSystemAdapter) Stat(path string) (FileStat, error) {
+	resolvedPath, err := f.ResolveWithinRoot(path)
+	if err != nil {
+		return FileStat{}, err
+	}
+
+	info, err := os.Stat(resolvedPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		f.logger.Debug("Stat: %s does not exist", resolvedPath)
+		return FileStat{Path: resolvedPath}, nil
+	}
+	if err != nil {
+		f.logger.Error("Failed to stat path %s: %v", resolvedPath, err)
+		return FileStat{}, fmt.Errorf("failed to stat path: %w", err)
+	}
+
+	return FileStat{
+		Path:    resolvedPath,
+		Exists:  true,
+		IsDir:   info.IsDir(),
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+// WriteTextFile writes content to a file, creating directories as needed.
+// Content larger than the write limit is rejected before anything is touched on disk.
 func (f *FileSystemAdapter) WriteTextFile(path string, content string) error {
-	resolvedPath := f.ResolvePath(path)
+	resolvedPath, err := f.ResolveWithinRoot(path)
+	if err != nil {
+		return err
+	}
+
+	if err := f.checkWriteSize(resolvedPath, len(content)); err != nil {
+		return err
+	}
 
 	// Create parent directories if they don't exist
 	dir := filepath.Dir(resolvedPath)
@@ -65,7 +306,7 @@ func (f *FileSystemAdapter) WriteTextFile(path string, content string) error {
 	}
 
 	// Write the file content
-	err := os.WriteFile(resolvedPath, []byte(content), 0644)
+	err = writeFileAtomic(resolvedPath, []byte(content))
 	f.logFileOperation("write", resolvedPath, len(content), err)
 	if err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
@@ -74,79 +315,704 @@ func (f *FileSystemAdapter) WriteTextFile(path string, content string) error {
 	return nil
 }
 
-// ReadTextFile reads content from a file
-func (f *FileSystemAdapter) ReadTextFile(path string) (string, error) {
-	resolvedPath := f.ResolvePath(path)
+// writeFileAtomic writes data to a temp file in the target's directory, syncs it
+// and renames it over the target, so a crash never leaves a partially written file.
+// An existing file keeps its mode (new files get 0644), and a symlinked target is
+// written through so the link itself is preserved.
+func writeFileAtomic(path string, data []byte) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	perm := fs.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// Remove the temp file on any failure; after a successful rename it no longer exists
+	success := false
+	defer func() {
+		if !success {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	success = true
+	return nil
+}
+
+// checkWriteSize rejects writes whose content exceeds the configured limit
+func (f *FileSystemAdapter) checkWriteSize(path string, size int) error {
+	if int64(size) <= f.maxWriteBytes {
+		return nil
+	}
+
+	f.logger.Warn("Rejected write to %s: %d bytes exceeds limit of %d bytes", path, size, f.maxWriteBytes)
+	return fmt.Errorf("write to %s rejected: content is %d bytes, limit is %d bytes", path, size, f.maxWriteBytes)
+}
+
+// ReadTextFile reads content from a file.
+// Files larger than the read limit are truncated; see ReadTextFileRange.
+func (f *FileSystemAdapter) ReadTextFile(path string) (ReadResult, error) {
+	return f.ReadTextFileRange(path, 1, 0)
+}
+
+// ReadTextFileRange reads at most limit lines starting at line (1-indexed).
+// A line below 1 starts at the beginning and a limit below 1 reads to the end of the file.
+// The file is streamed, so only the requested lines are held in memory.
+//
+// At most the read limit in bytes is returned. When more would have been read, the
+// result is cut at the last whole line that fits and marked truncated, with NextLine
+// set to where a follow-up windowed read should start. A single line larger than
+// the limit is cut at a character boundary and the rest of it is skipped.
+func (f *FileSystemAdapter) ReadTextFileRange(path string, line int, limit int) (ReadResult, error) {
+	resolvedPath, err := f.ResolveWithinRoot(path)
+	if err != nil {
+		return ReadResult{}, err
+	}
+
+	if line < 1 {
+		line = 1
+	}
+
+	file, err := os.Open(resolvedPath)
+	if err != nil {
+		f.logFileOperation("read", resolvedPath, 0, err)
+		return ReadResult{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	result := ReadResult{}
+	if info, err := file.Stat(); err == nil {
+		result.TotalSize = info.Size()
+	}
+
+	maxBytes := int(f.maxReadBytes)
+	reader := bufio.NewReader(file)
+	var content strings.Builder
+	lineNumber := 0
+	read := 0
+
+	for limit < 1 || read < limit {
+		// Lines before the window are consumed without being kept.
+		// Within it, keep one byte past the limit so overflow can be detected.
+		keep := 0
+		if lineNumber+1 >= line {
+			keep = maxBytes - content.Len() + 1
+		}
+
+		text, n, err := readLine(reader, keep)
+		if n > 0 {
+			lineNumber++
+			if lineNumber >= line {
+				if content.Len()+n > maxBytes {
+					result.Truncated = true
+					if content.Len() == 0 {
+						content.WriteString(truncateUTF8(text, maxBytes))
+						result.NextLine = lineNumber + 1
+					} else {
+						result.NextLine = lineNumber
+					}
+					break
+				}
+				content.Write(text)
+				read++
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.logFileOperation("read", resolvedPath, content.Len(), err)
+			return ReadResult{}, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	result.Content = content.String()
+	f.logFileOperation("read", resolvedPath, content.Len(), nil)
+	if result.Truncated {
+		f.logger.Warn("Read of %s truncated at %d bytes (file is %d bytes)", resolvedPath, maxBytes, result.TotalSize)
+	}
+	f.logger.Debug("ReadTextFileRange returned %d lines from line %d of %s", read, line, resolvedPath)
+	return result, nil
+}
+
+// ReadTextFileBytes reads length bytes starting at byte offset, e.g. around a grep
+// match's ByteOffset. A length below 1 reads to the end of the file, and a range
+// running past the end is cut short. The offset must lie within the file.
+// Ranges are byte-exact, so they may start or end inside a multi-byte character.
+//
+// At most the read limit in bytes is returned; a longer range is truncated with
+// NextOffset set to where a follow-up read should start.
+func (f *FileSystemAdapter) ReadTextFileBytes(path string, offset int64, length int64) (ReadResult, error) {
+	resolvedPath, err := f.ResolveWithinRoot(path)
+	if err != nil {
+		return ReadResult{}, err
+	}
+
+	if offset < 0 {
+		return ReadResult{}, fmt.Errorf("offset must not be negative: %d", offset)
+	}
+
+	file, err := os.Open(resolvedPath)
+	if err != nil {
+		f.logFileOperation("read", resolvedPath, 0, err)
+		return ReadResult{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return ReadResult{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+	result := ReadResult{TotalSize: info.Size()}
+
+	if offset > result.TotalSize {
+		return ReadResult{}, fmt.Errorf("offset %d is past the end of %s (%d bytes)", offset, resolvedPath, result.TotalSize)
+	}
+	if remaining := result.TotalSize - offset; length < 1 || length > remaining {
+		length = remaining
+	}
+	if length > f.maxReadBytes {
+		result.Truncated = true
+		result.NextOffset = offset + f.maxReadBytes
+		length = f.maxReadBytes
+	}
+
+	buf := make([]byte, length)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		f.logFileOperation("read", resolvedPath, n, err)
+		return ReadResult{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	result.Content = string(buf[:n])
+	f.logFileOperation("read", resolvedPath, n, nil)
+	if result.Truncated {
+		f.logger.Warn("Read of %s truncated at %d bytes (file is %d bytes)", resolvedPath, f.maxReadBytes, result.TotalSize)
+	}
+	f.logger.Debug("ReadTextFileBytes returned %d bytes from offset %d of %s", n, offset, resolvedPath)
+	return result, nil
+}
+
+// tailChunkSize is how much TailTextFile reads at a time while scanning backwards
+const tailChunkSize = 4096
+
+// TailTextFile returns the last lines lines of a file. The file is scanned backwards
+// from the end, so only the tail is read however large the file is. A trailing
+// newline ends the last line rather than starting an empty one; files with fewer
+// lines are returned whole.
+//
+// At most the read limit in bytes is returned. When the lines don't fit, only the
+// whole lines that do are returned, or the end of a single over-long line.
+func (f *FileSystemAdapter) TailTextFile(path string, lines int) (string, error) {
+	resolvedPath, err := f.ResolveWithinRoot(path)
+	if err != nil {
+		return "", err
+	}
+
+	if lines < 1 {
+		return "", fmt.Errorf("lines must be at least 1: %d", lines)
+	}
+
+	file, err := os.Open(resolvedPath)
+	if err != nil {
+		f.logFileOperation("read", resolvedPath, 0, err)
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
 
-	content, err := os.ReadFile(resolvedPath)
-	f.logFileOperation("read", resolvedPath, len(content), err)
+	info, err := file.Stat()
 	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	size := info.Size()
+	floor := max(size-f.maxReadBytes, 0) // Lowest offset within the read limit
+
+	// Walk backwards counting newlines until the one before the first wanted line
+	start := int64(-1)
+	earliest := int64(-1) // Start of the earliest whole line seen
+	newlines := 0
+	buf := make([]byte, tailChunkSize)
+	for pos := size; pos > floor && start < 0; {
+		n := min(int64(tailChunkSize), pos-floor)
+		pos -= n
+		if _, err := file.ReadAt(buf[:n], pos); err != nil && err != io.EOF {
+			f.logFileOperation("read", resolvedPath, 0, err)
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+
+		for i := n - 1; i >= 0; i-- {
+			offset := pos + i
+			if buf[i] != '\n' || offset == size-1 {
+				continue
+			}
+			newlines++
+			earliest = offset + 1
+			if newlines == lines {
+				start = offset + 1
+				break
+			}
+		}
+	}
+
+	switch {
+	case start >= 0:
+	case floor == 0:
+		// Fewer lines than asked for: the whole file
+		start = 0
+	case earliest >= 0:
+		f.logger.Warn("Tail of %s cut to the last %d lines to fit the %d byte read limit", resolvedPath, newlines, f.maxReadBytes)
+		start = earliest
+	default:
+		// A single line longer than the read limit: keep its end, on a character boundary
+		f.logger.Warn("Tail of %s cut to the last %d bytes of an over-long line", resolvedPath, f.maxReadBytes)
+		start = floor
+	}
+
+	content := make([]byte, size-start)
+	n, err := file.ReadAt(content, start)
+	if err != nil && err != io.EOF {
+		f.logFileOperation("read", resolvedPath, n, err)
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
+	content = content[:n]
+	for len(content) > 0 && !utf8.RuneStart(content[0]) {
+		content = content[1:]
+	}
 
+	f.logFileOperation("read", resolvedPath, len(content), nil)
+	f.logger.Debug("TailTextFile returned %d bytes from offset %d of %s", len(content), start, resolvedPath)
 	return string(content), nil
 }
 
-// GrepSearch searches for a pattern in files with context cancellation support
-func (f *FileSystemAdapter) GrepSearch(ctx context.Context, pattern string, paths []string, recursive bool, caseSensitive bool) ([]GrepResult, error) {
-	f.logger.Info("GrepSearch called with pattern: %s, paths: %v", pattern, paths)
+// readLine reads the next line including its newline, keeping at most keep bytes of it.
+// The rest of an over-long line is still consumed; n reports the full line length.
+func readLine(reader *bufio.Reader, keep int) (line []byte, n int, err error) {
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if room := keep - len(line); room > 0 {
+			line = append(line, chunk[:min(room, len(chunk))]...)
+		}
+		n += len(chunk)
+		if err != bufio.ErrBufferFull {
+			return line, n, err
+		}
+	}
+}
+
+// truncateUTF8 cuts b to at most n bytes without splitting a multi-byte character
+func truncateUTF8(b []byte, n int) string {
+	if len(b) <= n {
+		return string(b)
+	}
+	for n > 0 && !utf8.RuneStart(b[n]) {
+		n--
+	}
+	return string(b[:n])
+}
+
+// DeleteFile removes a single file. Directories are refused; use DeleteDirectory for those.
+func (f *FileSystemAdapter) DeleteFile(path string) error {
+	resolvedPath, err := f.ResolveWithinRoot(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(resolvedPath)
+	if err != nil {
+		f.logger.Error("Failed to delete file %s: %v", resolvedPath, err)
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory; pass recursive to delete it", resolvedPath)
+	}
+
+	if err := os.Remove(resolvedPath); err != nil {
+		f.logger.Error("Failed to delete file %s: %v", resolvedPath, err)
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	f.logger.Debug("Successfully deleted file %s", resolvedPath)
+	return nil
+}
+
+// DeleteDirectory removes a directory and everything below it.
+// The working directory and filesystem roots are refused.
+func (f *FileSystemAdapter) DeleteDirectory(path string) error {
+	resolvedPath, err := f.ResolveWithinRoot(path)
+	if err != nil {
+		return err
+	}
+	resolvedPath = filepath.Clean(resolvedPath)
+
+	info, err := os.Lstat(resolvedPath)
+	if err != nil {
+		f.logger.Error("Failed to delete directory %s: %v", resolvedPath, err)
+		return fmt.Errorf("failed to delete directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", resolvedPath)
+	}
+	if resolvedPath == filepath.Clean(f.cwd) || filepath.Dir(resolvedPath) == resolvedPath {
+		return fmt.Errorf("refusing to delete %s", resolvedPath)
+	}
+
+	if err := os.RemoveAll(resolvedPath); err != nil {
+		f.logger.Error("Failed to delete directory %s: %v", resolvedPath, err)
+		return fmt.Errorf("failed to delete directory: %w", err)
+	}
+
+	f.logger.Debug("Successfully deleted directory %s", resolvedPath)
+	return nil
+}
+
+// MoveFile renames src to dst, creating dst's parent directories as needed
+func (f *FileSystemAdapter) MoveFile(src string, dst string) error {
+	resolvedSrc, err := f.ResolveWithinRoot(src)
+	if err != nil {
+		return err
+	}
+	resolvedDst, err := f.ResolveWithinRoot(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(resolvedSrc); err != nil {
+		f.logger.Error("Failed to move %s: %v", resolvedSrc, err)
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	// Create parent directories if they don't exist
+	dir := filepath.Dir(resolvedDst)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		f.logger.Error("Failed to create directory %s: %v", dir, err)
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.Rename(resolvedSrc, resolvedDst); err != nil {
+		f.logger.Error("Failed to move %s to %s: %v", resolvedSrc, resolvedDst, err)
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	f.logger.Debug("Successfully moved %s to %s", resolvedSrc, resolvedDst)
+	return nil
+}
+
+// GrepSearch searches for a pattern in files with context cancellation support.
+// Files or directories matching any of excludePatterns (relative to the search root
+// or by base name) are skipped during the walk and never opened.
+// Paths that could not be read are returned in skipped so callers know results are partial.
+// With opts.Multiline, each file is matched as a whole so patterns can span lines.
+// Only files whose base name matches opts.FilePattern, when set, are opened.
+// Matches are buffered up to opts.MaxResults; once another match shows there are more,
+// the search stops early and truncated is set. Use GrepSearchStream for custom limits.
+func (f *FileSystemAdapter) GrepSearch(ctx context.Context, pattern string, paths []string, opts GrepOptions) (results []GrepResult, skipped []SkippedPath, truncated bool, err error) {
+	skipped, err = f.GrepSearchStream(ctx, pattern, paths, opts, func(result GrepResult) bool {
+		if opts.MaxResults > 0 && len(results) >= opts.MaxResults {
+			truncated = true
+			return false
+		}
+		results = append(results, result)
+		return true
+	})
+	return results, skipped, truncated, err
+}
+
+// GrepSearchStream is GrepSearch delivering matches to emit as they are found instead
+// of buffering them. Matches arrive in the same deterministic order as GrepSearch.
+// Returning false from emit stops the search: the walk ends and no further files are
+// opened. Stopping early is not an error.
+func (f *FileSystemAdapter) GrepSearchStream(ctx context.Context, pattern string, paths []string, opts GrepOptions, emit func(GrepResult) bool) ([]SkippedPath, error) {
+	f.logger.Info("GrepSearch called with pattern: %s, paths: %v, exclude: %v, multiline: %v, fixed: %v, word: %v",
+		pattern, paths, opts.ExcludePatterns, opts.Multiline, opts.FixedString, opts.WholeWord)
+
+	// Validate exclude patterns up front so a typo doesn't silently exclude nothing
+	for _, exclude := range opts.ExcludePatterns {
+		if _, err := filepath.Match(exclude, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", exclude, err)
+		}
+	}
+	if _, err := filepath.Match(opts.FilePattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid file pattern %q: %w", opts.FilePattern, err)
+	}
 
 	// Check for cancellation before starting
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
 
-	// Compile the regex pattern
-	var re *regexp.Regexp
-	var err error
-	if caseSensitive {
-		re, err = regexp.Compile(pattern)
-	} else {
-		re, err = regexp.Compile("(?i)" + pattern)
+	re, err := f.compileGrepPattern(pattern, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// searchCtx is cancelled when emit asks to stop, ending the walk and the scans
+	searchCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	// The walk and the scans run concurrently and both report unreadable paths
+	var skippedMu sync.Mutex
+	var skipped []SkippedPath
+	onError := func(path string, err error) {
+		skippedMu.Lock()
+		skipped = append(skipped, SkippedPath{Path: path, Reason: err.Error()})
+		skippedMu.Unlock()
+	}
+
+	files := make(chan string)
+	walkDone := make(chan struct{})
+	go func() {
+		defer close(walkDone)
+		defer close(files)
+		f.collectGrepFiles(searchCtx, paths, opts, onError, files)
+	}()
+
+	matches, stopped := f.grepFiles(searchCtx, files, re, opts.Multiline, onError, func(result GrepResult) bool {
+		if emit(result) {
+			return true
+		}
+		stop()
+		return false
+	})
+	<-walkDone
+
+	if err := ctx.Err(); err != nil {
+		f.logger.Debug("GrepSearch cancelled after %d results", matches)
+		return skipped, err
 	}
+
+	f.logger.Debug("GrepSearch found %d matches (stopped early: %v, %d skipped)", matches, stopped, len(skipped))
+	return skipped, nil
+}
+
+// compileGrepPattern builds the search regex. Multiline matching lets . cross line breaks.
+func (f *FileSystemAdapter) compileGrepPattern(pattern string, opts GrepOptions) (*regexp.Regexp, error) {
+	if opts.FixedString {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.WholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	flags := ""
+	if !opts.CaseSensitive {
+		flags += "i"
+	}
+	if opts.Multiline {
+		flags += "s"
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
 	if err != nil {
 		f.logger.Error("Invalid regex pattern %s: %v", pattern, err)
 		return nil, fmt.Errorf("invalid regex pattern: %w", err)
 	}
+	return re, nil
+}
 
-	var results []GrepResult
+// collectGrepFiles walks paths and sends every candidate file to files.
+// It returns early when ctx is cancelled.
+func (f *FileSystemAdapter) collectGrepFiles(ctx context.Context, paths []string, opts GrepOptions, onError func(string, error), files chan<- string) {
+	send := func(filePath string) error {
+		if opts.FilePattern != "" {
+			if matched, _ := filepath.Match(opts.FilePattern, filepath.Base(filePath)); !matched {
+				return nil
+			}
+		}
+		select {
+		case files <- filePath:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 
 	for _, path := range paths {
 		// Check for cancellation between paths
-		if err := ctx.Err(); err != nil {
-			f.logger.Debug("GrepSearch cancelled after %d results", len(results))
-			return results, err
+		if ctx.Err() != nil {
+			f.logger.Debug("GrepSearch cancelled while collecting files")
+			return
 		}
 
 		info, err := os.Stat(path)
 		if err != nil {
 			f.logger.Error("Failed to stat path %s: %v", path, err)
+			onError(path, err)
 			continue
 		}
 
 		if info.IsDir() {
-			err := f.walkDirectory(ctx, path, recursive, false, func(filePath string, d fs.DirEntry) error {
-				matches, _ := f.grepFile(filePath, re)
-				results = append(results, matches...)
-				return nil
+			root := path
+			skip := func(filePath string, d fs.DirEntry) bool {
+				return matchesAnyPattern(opts.ExcludePatterns, root, filePath)
+			}
+			walkOpts := walkOptions{recursive: opts.Recursive, skip: skip, onError: onError}
+			err := f.walkDirectory(ctx, path, walkOpts, func(filePath string, d fs.DirEntry) error {
+				return send(filePath)
 			})
 			if err != nil {
 				// Context cancelled during walk
-				return results, err
+				return
 			}
 		} else {
-			matches, _ := f.grepFile(path, re)
-			results = append(results, matches...)
+			if matchesAnyPattern(opts.ExcludePatterns, filepath.Dir(path), path) {
+				continue
+			}
+			if send(path) != nil {
+				return
+			}
 		}
 	}
+}
 
-	f.logger.Debug("GrepSearch found %d matches", len(results))
-	return results, nil
+// grepFileResult holds the outcome of scanning one file
+type grepFileResult struct {
+	idx     int // Position of the file in walk order
+	path    string
+	matches []GrepResult
+	err     error
+}
+
+// grepSkipError is a file grep gave up on. It is reported as skipped, with a
+// warning rather than as a failure to read the file.
+type grepSkipError struct {
+	reason string
 }
 
-// ListDirectories lists files and directories at the specified path
-func (f *FileSystemAdapter) ListDirectories(ctx context.Context, path string, recursive bool) ([]DirectoryEntry, error) {
-	f.logger.Info("ListDirectories called for path: %s, recursive: %v", path, recursive)
+func (e grepSkipError) Error() string {
+	return e.reason
+}
+
+// grepFiles scans files from the walk with a bounded worker pool. Matches are passed
+// to emit in the order of files (then by line), so output is deterministic regardless
+// of scheduling; only a small window of scanned-but-unemitted files is held in memory.
+// Files that fail to scan are reported to onError. It returns the number of matches
+// emitted and whether emit stopped the search.
+func (f *FileSystemAdapter) grepFiles(ctx context.Context, files <-chan string, re *regexp.Regexp, multiline bool, onError func(string, error), emit func(GrepResult) bool) (int, bool) {
+	workers := f.grepWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	type grepJob struct {
+		idx  int
+		path string
+	}
+	jobs := make(chan grepJob)
+	done := make(chan grepFileResult)
+	// window bounds files handed out but not yet emitted, so a slow file
+	// can't make the others pile up behind it
+	window := make(chan struct{}, workers*2)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				matches, err := f.grepFileWithTimeout(ctx, job.path, re, multiline)
+				done <- grepFileResult{idx: job.idx, path: job.path, matches: matches, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		idx := 0
+		for path := range files {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- grepJob{idx: idx, path: path}:
+				idx++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	pending := make(map[int]grepFileResult)
+	next, emitted, stopped := 0, 0, false
+	for result := range done {
+		pending[result.idx] = result
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-window
+
+			var skip grepSkipError
+			switch {
+			case result.err == nil || ctx.Err() != nil:
+			case errors.As(result.err, &skip):
+				f.logger.Warn("Skipped %s in grep: %v", result.path, result.err)
+				onError(result.path, result.err)
+			default:
+				f.logger.Error("Failed to grep %s: %v", result.path, result.err)
+				onError(result.path, result.err)
+			}
+			for _, match := range result.matches {
+				if stopped {
+					break
+				}
+				if !emit(match) {
+					stopped = true
+				}
+				emitted++
+			}
+		}
+	}
+
+	return emitted, stopped
+}
+
+// errStopWalk is returned from walk callbacks to end a walk early without error
+var errStopWalk = errors.New("stop walk")
+
+// FindFiles returns files under path whose base name matches the glob namePattern.
+// At most limit paths are returned (0 = unlimited); the walk stops as soon as the limit is hit.
+func (f *FileSystemAdapter) FindFiles(ctx context.Context, path string, namePattern string, recursive bool, limit int) ([]string, error) {
+	f.logger.Info("FindFiles called for path: %s, pattern: %s, recursive: %v", path, namePattern, recursive)
+
+	if _, err := filepath.Match(namePattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid name pattern %q: %w", namePattern, err)
+	}
 
 	info, err := os.Stat(path)
 	if err != nil {
@@ -158,12 +1024,55 @@ func (f *FileSystemAdapter) ListDirectories(ctx context.Context, path string, re
 		return nil, fmt.Errorf("path %s is not a directory", path)
 	}
 
-	var entries []DirectoryEntry
+	var files []string
+
+	opts := walkOptions{recursive: recursive}
+	err = f.walkDirectory(ctx, path, opts, func(filePath string, d fs.DirEntry) error {
+		if matched, _ := filepath.Match(namePattern, d.Name()); !matched {
+			return nil
+		}
+
+		files = append(files, filePath)
+		if limit > 0 && len(files) >= limit {
+			return errStopWalk
+		}
+		return nil
+	})
+
+	if err != nil && err != errStopWalk {
+		return files, err
+	}
+
+	f.logger.Debug("FindFiles found %d files", len(files))
+	return files, nil
+}
+
+// ListDirectories lists files and directories at the specified path.
+// For recursive listings, maxDepth limits how far below path to descend (0 = unlimited).
+// Paths that could not be read are returned in skipped so callers know the listing is partial.
+func (f *FileSystemAdapter) ListDirectories(ctx context.Context, path string, recursive bool, maxDepth int) (entries []DirectoryEntry, skipped []SkippedPath, err error) {
+	f.logger.Info("ListDirectories called for path: %s, recursive: %v, maxDepth: %d", path, recursive, maxDepth)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		f.logger.Error("Failed to stat path %s: %v", path, err)
+		return nil, nil, fmt.Errorf("failed to stat path: %w", err)
+	}
+
+	if !info.IsDir() {
+		return nil, nil, fmt.Errorf("path %s is not a directory", path)
+	}
+
+	onError := func(path string, err error) {
+		skipped = append(skipped, SkippedPath{Path: path, Reason: err.Error()})
+	}
 
-	err = f.walkDirectory(ctx, path, recursive, true, func(filePath string, d fs.DirEntry) error {
+	opts := walkOptions{recursive: recursive, includeDirs: true, maxDepth: maxDepth, onError: onError}
+	err = f.walkDirectory(ctx, path, opts, func(filePath string, d fs.DirEntry) error {
 		info, err := d.Info()
 		if err != nil {
 			f.logger.Error("Failed to get info for %s: %v", filePath, err)
+			onError(filePath, err)
 			return nil // Continue on error
 		}
 
@@ -178,17 +1087,45 @@ func (f *FileSystemAdapter) ListDirectories(ctx context.Context, path string, re
 	})
 
 	if err != nil && err != context.Canceled && err != context.DeadlineExceeded {
-		return nil, err
+		return nil, skipped, err
 	}
 
-	f.logger.Debug("ListDirectories found %d entries", len(entries))
-	return entries, nil
+	f.logger.Debug("ListDirectories found %d entries (%d skipped)", len(entries), len(skipped))
+	return entries, skipped, nil
+}
+
+// walkOptions controls how walkDirectory traverses a directory tree
+type walkOptions struct {
+	recursive   bool
+	includeDirs bool // Pass directories to the callback (not just files)
+	maxDepth    int  // Deepest level visited relative to the root; 0 = unlimited
+	// skip, if set, prunes entries (and whole subtrees for directories) it returns true for
+	skip func(filePath string, d fs.DirEntry) bool
+	// onError, if set, is told about paths that could not be read; the walk continues past them
+	onError func(filePath string, err error)
+}
+
+// reportError logs a walk error and passes it to the onError hook if one is set
+func (o walkOptions) reportError(f *FileSystemAdapter, filePath string, err error) {
+	f.logger.Error("Error walking path %s: %v", filePath, err)
+	if o.onError != nil {
+		o.onError(filePath, err)
+	}
 }
 
 // walkDirectory is a unified directory walker that supports both recursive and non-recursive modes.
-// It handles context cancellation and can include or exclude directories based on includeDirs.
-func (f *FileSystemAdapter) walkDirectory(ctx context.Context, dirPath string, recursive bool, includeDirs bool, callback func(filePath string, d fs.DirEntry) error) error {
-	if recursive {
+// It handles context cancellation and can include or exclude directories based on opts.includeDirs.
+// Immediate children of dirPath are at depth 1.
+func (f *FileSystemAdapter) walkDirectory(ctx context.Context, dirPath string, opts walkOptions, callback func(filePath string, d fs.DirEntry) error) error {
+	if opts.recursive && f.followSymlinks {
+		visited := make(map[fileID]bool)
+		if id, err := fileIdentity(dirPath); err == nil {
+			visited[id] = true
+		}
+		return f.walkFollowingSymlinks(ctx, dirPath, 1, opts, callback, visited)
+	}
+
+	if opts.recursive {
 		return filepath.WalkDir(dirPath, func(filePath string, d fs.DirEntry, err error) error {
 			// Check for cancellation
 			if ctxErr := ctx.Err(); ctxErr != nil {
@@ -196,7 +1133,7 @@ func (f *FileSystemAdapter) walkDirectory(ctx context.Context, dirPath string, r
 			}
 
 			if err != nil {
-				f.logger.Error("Error walking path %s: %v", filePath, err)
+				opts.reportError(f, filePath, err)
 				return nil // Continue on error
 			}
 
@@ -205,12 +1142,32 @@ func (f *FileSystemAdapter) walkDirectory(ctx context.Context, dirPath string, r
 				return nil
 			}
 
+			// Skip excluded entries, pruning whole subtrees for directories
+			if (opts.skip != nil && opts.skip(filePath, d)) || f.escapesRoot(filePath, d, opts) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// Stop descending once the depth limit is reached
+			atMaxDepth := opts.maxDepth > 0 && walkDepth(dirPath, filePath) >= opts.maxDepth
+
 			// Skip directories if not including them (for file-only operations like grep)
-			if d.IsDir() && !includeDirs {
+			if d.IsDir() && !opts.includeDirs {
+				if atMaxDepth {
+					return filepath.SkipDir
+				}
 				return nil
 			}
 
-			return callback(filePath, d)
+			if err := callback(filePath, d); err != nil {
+				return err
+			}
+			if d.IsDir() && atMaxDepth {
+				return filepath.SkipDir
+			}
+			return nil
 		})
 	}
 
@@ -228,11 +1185,17 @@ func (f *FileSystemAdapter) walkDirectory(ctx context.Context, dirPath string, r
 		}
 
 		// Skip directories if not including them
-		if entry.IsDir() && !includeDirs {
+		if entry.IsDir() && !opts.includeDirs {
 			continue
 		}
 
 		fullPath := filepath.Join(dirPath, entry.Name())
+		if opts.skip != nil && opts.skip(fullPath, entry) {
+			continue
+		}
+		if f.escapesRoot(fullPath, entry, opts) {
+			continue
+		}
 		if err := callback(fullPath, entry); err != nil {
 			return err
 		}
@@ -241,36 +1204,217 @@ func (f *FileSystemAdapter) walkDirectory(ctx context.Context, dirPath string, r
 	return nil
 }
 
-// grepFile searches for pattern matches in a single file
-func (f *FileSystemAdapter) grepFile(filePath string, re *regexp.Regexp) ([]GrepResult, error) {
+// walkFollowingSymlinks recursively walks dirPath, descending into symlinked directories.
+// Every directory entered is recorded in visited so circular links are skipped.
+// depth is the depth of dirPath's children relative to the walk root.
+func (f *FileSystemAdapter) walkFollowingSymlinks(ctx context.Context, dirPath string, depth int, opts walkOptions, callback func(filePath string, d fs.DirEntry) error, visited map[fileID]bool) error {
+	dirEntries, err := os.ReadDir(dirPath)
+	if err != nil {
+		opts.reportError(f, dirPath, err)
+		return nil // Continue on error
+	}
+
+	for _, entry := range dirEntries {
+		// Check for cancellation
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		fullPath := filepath.Join(dirPath, entry.Name())
+		if opts.skip != nil && opts.skip(fullPath, entry) {
+			continue
+		}
+		if f.escapesRoot(fullPath, entry, opts) {
+			continue
+		}
+
+		// Resolve symlinks so linked directories are walked like real ones
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
+				entry = fs.FileInfoToDirEntry(info)
+			}
+		}
+
+		if !entry.IsDir() {
+			if err := callback(fullPath, entry); err != nil {
+				return err
+			}
+			continue
+		}
+
+		id, err := fileIdentity(fullPath)
+		if err != nil {
+			opts.reportError(f, fullPath, err)
+			continue
+		}
+		if visited[id] {
+			f.logger.Warn("Skipping %s: symlink loop or directory already visited", fullPath)
+			continue
+		}
+		visited[id] = true
+
+		if opts.includeDirs {
+			if err := callback(fullPath, entry); err != nil {
+				return err
+			}
+		}
+
+		// Stop descending once the depth limit is reached
+		if opts.maxDepth > 0 && depth >= opts.maxDepth {
+			continue
+		}
+
+		if err := f.walkFollowingSymlinks(ctx, fullPath, depth+1, opts, callback, visited); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walkDepth returns how many levels below root filePath is (immediate children are depth 1)
+func walkDepth(root, filePath string) int {
+	relPath, err := filepath.Rel(root, filePath)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(relPath, string(filepath.Separator)) + 1
+}
+
+// grepFileWithTimeout greps a file, skipping it if that takes longer than the
+// grep file timeout
+func (f *FileSystemAdapter) grepFileWithTimeout(ctx context.Context, filePath string, re *regexp.Regexp, multiline bool) ([]GrepResult, error) {
+	if f.grepTimeout <= 0 {
+		return f.grepFile(ctx, filePath, re, multiline)
+	}
+
+	fileCtx, cancel := context.WithTimeout(ctx, f.grepTimeout)
+	defer cancel()
+	matches, err := f.grepFile(fileCtx, filePath, re, multiline)
+	if err != nil && ctx.Err() == nil && errors.Is(fileCtx.Err(), context.DeadlineExceeded) {
+		return nil, grepSkipError{fmt.Sprintf("gave up after %v", f.grepTimeout)}
+	}
+	return matches, err
+}
+
+// grepFile searches for pattern matches in a single file.
+// It stops early if ctx is cancelled while scanning.
+// In multiline mode the whole file is matched at once; see grepContent.
+func (f *FileSystemAdapter) grepFile(ctx context.Context, filePath string, re *regexp.Regexp, multiline bool) ([]GrepResult, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	if !isTextFileFromHandle(file) {
+	// Skip oversized files up front rather than blocking on them
+	if info, err := file.Stat(); err == nil && info.Size() > f.maxFileBytes {
+		f.logger.Debug("Skipping %s: %d bytes exceeds grep limit of %d", filePath, info.Size(), f.maxFileBytes)
+		return nil, nil
+	}
+
+	enc, bomLen, isText := sniffTextEncoding(file)
+	if !isText {
 		return nil, nil
 	}
 
-	if _, err := file.Seek(0, 0); err != nil {
+	// Start after any byte order mark so it never shows up in the first line
+	if _, err := file.Seek(int64(bomLen), io.SeekStart); err != nil {
 		return nil, err
 	}
 
+	// Cap bytes read as a backstop for files whose reported size is misleading
+	// (e.g. pipes or files still being written)
+	var reader io.Reader = io.LimitReader(file, f.maxFileBytes)
+
+	// UTF-16 is decoded to UTF-8 so patterns match as usual.
+	// Offsets for such files count bytes of the decoded text.
+	offsetBase := int64(bomLen)
+	if enc != encodingUTF8 {
+		reader = transform.NewReader(reader, utf16Decoder(enc))
+		offsetBase = 0
+	}
+
+	if multiline {
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		return grepContent(filePath, string(content), offsetBase, re), nil
+	}
+
 	var results []GrepResult
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(reader)
+	// Grow the buffer as needed for long lines, up to the line limit. The
+	// limit leaves room for a \r\n, which the scanner needs to see.
+	limit := int(f.maxLineBytes + 2)
+	scanner.Buffer(make([]byte, 0, min(bufio.MaxScanTokenSize, limit)), limit)
 	lineNumber := 0
 
+	// Track where each line starts in the file. The split function sees the raw
+	// advance (including \r\n terminators), which the returned token does not.
+	//
+	// A line longer than the limit would stop the scanner with ErrTooLong, so it
+	// is consumed as it arrives instead and stands in as an empty token with
+	// skippedLine set. Only that line goes unsearched.
+	offset, lineStart := offsetBase, offsetBase
+	skipping, skippedLine := false, false
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		skippedLine = false
+		if skipping {
+			advance, done := len(data), atEOF
+			if i := bytes.IndexByte(data, '\n'); i >= 0 {
+				advance, done = i+1, true
+			}
+			offset += int64(advance)
+			if !done {
+				return advance, nil, nil
+			}
+			skipping, skippedLine = false, true
+			return advance, data[:0], nil
+		}
+
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if advance == 0 && token == nil && err == nil && len(data) >= limit {
+			skipping, lineStart = true, offset
+			offset += int64(len(data))
+			return len(data), nil, nil
+		}
+		if token != nil {
+			lineStart = offset
+		}
+		offset += int64(advance)
+		return advance, token, err
+	})
+
+	var longLines []int
 	for scanner.Scan() {
 		lineNumber++
+
+		// Check for cancellation periodically so large files don't hold up shutdown
+		if lineNumber%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return results, err
+			}
+		}
+
+		if skippedLine {
+			longLines = append(longLines, lineNumber)
+			continue
+		}
+
 		line := scanner.Text()
 
-		if match := re.FindString(line); match != "" {
+		// Empty matches (e.g. a bare ^) are not reported
+		if loc := re.FindStringIndex(line); loc != nil && loc[1] > loc[0] {
 			results = append(results, GrepResult{
 				Path:       filePath,
 				LineNumber: lineNumber,
 				Line:       line,
-				Match:      match,
+				Match:      line[loc[0]:loc[1]],
+				Column:     loc[0] + 1,
+				RuneColumn: utf8.RuneCountInString(line[:loc[0]]) + 1,
+				ByteOffset: lineStart + int64(loc[0]),
 			})
 		}
 	}
@@ -279,9 +1423,94 @@ func (f *FileSystemAdapter) grepFile(filePath string, re *regexp.Regexp) ([]Grep
 		return results, err
 	}
 
+	if len(longLines) > 0 {
+		// The matches found elsewhere in the file are kept; the skip is reported beside them
+		if len(longLines) == 1 {
+			return results, grepSkipError{fmt.Sprintf("line %d is longer than %d bytes and was not searched", longLines[0], f.maxLineBytes)}
+		}
+		return results, grepSkipError{fmt.Sprintf("%d lines longer than %d bytes were not searched (first: line %d)", len(longLines), f.maxLineBytes, longLines[0])}
+	}
+
+	if offset >= f.maxFileBytes {
+		f.logger.Debug("Stopped scanning %s after %d bytes (grep limit)", filePath, offset)
+	}
+
 	return results, nil
 }
 
+// grepContent matches re against a whole file's content, so matches may span lines.
+// Each match is reported at the line where it starts; Line holds that first line
+// and Match the full matched text. offsetBase is added to byte offsets.
+func grepContent(filePath string, content string, offsetBase int64, re *regexp.Regexp) []GrepResult {
+	var results []GrepResult
+	lineNumber := 1
+	lineStart := 0 // Byte index where lineNumber starts
+	scanned := 0   // Content before this index has been counted into lineNumber
+
+	for _, loc := range re.FindAllStringIndex(content, -1) {
+		// Empty matches (e.g. a bare ^) are not reported
+		if loc[1] <= loc[0] {
+			continue
+		}
+
+		// Advance the line count up to the match start
+		for {
+			nl := strings.IndexByte(content[scanned:loc[0]], '\n')
+			if nl < 0 {
+				break
+			}
+			scanned += nl + 1
+			lineNumber++
+			lineStart = scanned
+		}
+		scanned = loc[0]
+
+		lineEnd := strings.IndexByte(content[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += lineStart
+		}
+		line := strings.TrimSuffix(content[lineStart:lineEnd], "\r")
+
+		results = append(results, GrepResult{
+			Path:       filePath,
+			LineNumber: lineNumber,
+			Line:       line,
+			Match:      content[loc[0]:loc[1]],
+			Column:     loc[0] - lineStart + 1,
+			RuneColumn: utf8.RuneCountInString(content[lineStart:loc[0]]) + 1,
+			ByteOffset: offsetBase + int64(loc[0]),
+		})
+	}
+
+	return results
+}
+
+// matchesAnyPattern reports whether filePath matches any of the glob patterns.
+// Each pattern is tried against the path relative to root and against the base name.
+func matchesAnyPattern(patterns []string, root string, filePath string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+
+	relPath, err := filepath.Rel(root, filePath)
+	if err != nil {
+		relPath = filePath
+	}
+	baseName := filepath.Base(filePath)
+
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, relPath); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, baseName); matched {
+			return true
+		}
+	}
+	return false
+}
+
 // logFileOperation logs file operations consistently
 func (f *FileSystemAdapter) logFileOperation(op string, path string, size int, err error) {
 	if err != nil {
@@ -303,18 +1532,53 @@ func getPreposition(op string) string {
 	}
 }
 
-// isTextFileFromHandle checks if an already-opened file is likely a text file
-// by reading the first 512 bytes and checking for null bytes or excessive non-printable characters.
+// textEncoding identifies how a text file's bytes map to characters
+type textEncoding int
+
+const (
+	encodingUTF8 textEncoding = iota
+	encodingUTF16LE
+	encodingUTF16BE
+)
+
+// sniffTextEncoding checks whether an already-opened file is likely a text file
+// by reading the first 512 bytes. A byte order mark (UTF-8, UTF-16LE/BE) is detected
+// up front, and BOM-less UTF-16 is recognized by its pattern of zero bytes.
+// UTF-16 samples are decoded to runes before checking for null characters, so their
+// zero bytes don't count as binary. Otherwise a null byte or more than 30%
+// non-printable characters marks the file as binary.
+// bomLen is the length of the byte order mark, if any.
 // The file position will be advanced by up to 512 bytes after this call.
-func isTextFileFromHandle(file *os.File) bool {
+func sniffTextEncoding(file *os.File) (enc textEncoding, bomLen int, isText bool) {
 	// Read first 512 bytes
 	buf := make([]byte, 512)
 	n, err := file.Read(buf)
 	if err != nil && n == 0 {
-		return false
+		return encodingUTF8, 0, false
 	}
 	buf = buf[:n]
 
+	switch {
+	case bytes.HasPrefix(buf, []byte{0xEF, 0xBB, 0xBF}):
+		return encodingUTF8, 3, isTextUTF8(buf[3:])
+	case bytes.HasPrefix(buf, []byte{0xFF, 0xFE}):
+		return encodingUTF16LE, 2, isTextUTF16(buf[2:], binary.LittleEndian)
+	case bytes.HasPrefix(buf, []byte{0xFE, 0xFF}):
+		return encodingUTF16BE, 2, isTextUTF16(buf[2:], binary.BigEndian)
+	}
+
+	if enc, ok := guessUTF16(buf); ok {
+		if enc == encodingUTF16LE {
+			return enc, 0, isTextUTF16(buf, binary.LittleEndian)
+		}
+		return enc, 0, isTextUTF16(buf, binary.BigEndian)
+	}
+
+	return encodingUTF8, 0, isTextUTF8(buf)
+}
+
+// isTextUTF8 applies the binary heuristic to a sample of single-byte or UTF-8 text
+func isTextUTF8(buf []byte) bool {
 	// Single pass: check for null bytes and count non-printable characters
 	var nonPrintable int
 	for _, b := range buf {
@@ -332,3 +1596,62 @@ func isTextFileFromHandle(file *os.File) bool {
 	threshold := len(buf) * 30 / 100
 	return nonPrintable < threshold
 }
+
+// isTextUTF16 decodes a UTF-16 sample and applies the binary heuristic to its characters
+func isTextUTF16(buf []byte, order binary.ByteOrder) bool {
+	units := make([]uint16, len(buf)/2)
+	for i := range units {
+		units[i] = order.Uint16(buf[2*i:])
+	}
+	runes := utf16.Decode(units)
+
+	var nonPrintable int
+	for _, r := range runes {
+		if r == 0 {
+			return false
+		}
+		// Unpaired surrogates decode to the replacement character
+		if (r < 32 && r != '\t' && r != '\n' && r != '\r') || r == utf8.RuneError {
+			nonPrintable++
+		}
+	}
+
+	threshold := len(runes) * 30 / 100
+	return nonPrintable < threshold
+}
+
+// guessUTF16 recognizes BOM-less UTF-16 from mostly-ASCII text, where every
+// other byte is zero. Genuine binaries rarely have such a regular pattern.
+func guessUTF16(buf []byte) (textEncoding, bool) {
+	pairs := len(buf) / 2
+	if pairs < 2 {
+		return encodingUTF8, false
+	}
+
+	var evenZeros, oddZeros int
+	for i := 0; i+1 < len(buf); i += 2 {
+		if buf[i] == 0 {
+			evenZeros++
+		}
+		if buf[i+1] == 0 {
+			oddZeros++
+		}
+	}
+
+	switch {
+	case oddZeros*10 >= pairs*9 && evenZeros*10 < pairs:
+		return encodingUTF16LE, true
+	case evenZeros*10 >= pairs*9 && oddZeros*10 < pairs:
+		return encodingUTF16BE, true
+	}
+	return encodingUTF8, false
+}
+
+// utf16Decoder returns a transformer converting UTF-16 in the given byte order to UTF-8
+func utf16Decoder(enc textEncoding) transform.Transformer {
+	order := unicode.LittleEndian
+	if enc == encodingUTF16BE {
+		order = unicode.BigEndian
+	}
+	return unicode.UTF16(order, unicode.IgnoreBOM).NewDecoder()
+}
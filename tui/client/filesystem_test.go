@@ -0,0 +1,572 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// writeFiles creates files under dir, keyed by slash-separated relative path
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// grepLocations returns the path:line of each result, relative to dir
+func grepLocations(t *testing.T, dir string, results []GrepResult) []string {
+	t.Helper()
+	locations := make([]string, 0, len(results))
+	for _, result := range results {
+		rel, err := filepath.Rel(dir, result.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		locations = append(locations, fmt.Sprintf("%s:%d", filepath.ToSlash(rel), result.LineNumber))
+	}
+	return locations
+}
+
+func TestGrepSearchWorkersKeepWalkOrder(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	for i := 0; i < 40; i++ {
+		// Uneven sizes so the workers finish out of order
+		content := ""
+		for j := 0; j < (i%7+1)*200; j++ {
+			content += fmt.Sprintf("line %d\n", j)
+		}
+		content += "needle\n"
+		files[fmt.Sprintf("d%d/f%02d.txt", i%3, i)] = content
+	}
+	writeFiles(t, dir, files)
+
+	search := func(workers int) []string {
+		f := NewFileSystemAdapter(dir, nil)
+		f.SetGrepWorkers(workers)
+		results, _, _, err := f.GrepSearch(context.Background(), "needle", []string{dir}, GrepOptions{Recursive: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return grepLocations(t, dir, results)
+	}
+
+	want := search(1)
+	if len(want) != 40 {
+		t.Fatalf("found %d matches with one worker, want 40", len(want))
+	}
+	for run := 0; run < 5; run++ {
+		if got := search(8); !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: matches with 8 workers are in a different order:\n%v\nwant:\n%v", run, got, want)
+		}
+	}
+}
+
+func TestWalkFollowingSymlinksSkipsLoops(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt":     "needle\n",
+		"sub/b.txt": "needle\n",
+	})
+	// sub/up leads back to the root, and sub/self to sub itself
+	if err := os.Symlink(dir, filepath.Join(dir, "sub", "up")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "sub"), filepath.Join(dir, "sub", "self")); err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFileSystemAdapter(dir, nil)
+	f.SetFollowSymlinks(true)
+	results, _, _, err := f.GrepSearch(context.Background(), "needle", []string{dir}, GrepOptions{Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"a.txt:1", "sub/b.txt:1"}
+	if got := grepLocations(t, dir, results); !reflect.DeepEqual(got, want) {
+		t.Errorf("matches = %v, want each file once: %v", got, want)
+	}
+}
+
+func TestWalkFollowingSymlinksEntersLinkedDirectories(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	writeFiles(t, outside, map[string]string{"c.txt": "needle\n"})
+	if err := os.Symlink(outside, filepath.Join(dir, "linked")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	for _, follow := range []bool{false, true} {
+		f := NewFileSystemAdapter(dir, nil)
+		f.SetFollowSymlinks(follow)
+		results, _, _, err := f.GrepSearch(context.Background(), "needle", []string{dir}, GrepOptions{Recursive: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found := len(results) == 1; found != follow {
+			t.Errorf("follow symlinks %v: got %d matches", follow, len(results))
+		}
+	}
+}
+
+func TestListDirectoriesMaxDepth(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt":         "",
+		"one/b.txt":     "",
+		"one/two/c.txt": "",
+	})
+
+	names := func(entries []DirectoryEntry) []string {
+		var names []string
+		for _, entry := range entries {
+			rel, _ := filepath.Rel(dir, entry.Path)
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return names
+	}
+
+	tests := []struct {
+		maxDepth int
+		want     []string
+	}{
+		{1, []string{"a.txt", "one"}},
+		{2, []string{"a.txt", "one", "one/b.txt", "one/two"}},
+		{0, []string{"a.txt", "one", "one/b.txt", "one/two", "one/two/c.txt"}},
+	}
+	for _, follow := range []bool{false, true} {
+		for _, tt := range tests {
+			f := NewFileSystemAdapter(dir, nil)
+			f.SetFollowSymlinks(follow)
+			entries, _, err := f.ListDirectories(context.Background(), dir, true, tt.maxDepth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(entries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("follow symlinks %v, maxDepth %d: got %v, want %v", follow, tt.maxDepth, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestGrepSkipsFilesOverSizeLimit(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"small.txt": "needle\n",
+		"large.txt": "needle\n" + strings.Repeat("padding\n", 100),
+	})
+
+	f := NewFileSystemAdapter(dir, nil)
+	f.SetMaxFileBytes(64)
+	results, _, _, err := f.GrepSearch(context.Background(), "needle", []string{dir}, GrepOptions{Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := grepLocations(t, dir, results), []string{"small.txt:1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("matches = %v, want only the small file: %v", got, want)
+	}
+}
+
+func TestReadTextFileRange(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "one\ntwo\nthree\nfour\n"})
+	f := NewFileSystemAdapter(dir, nil)
+
+	tests := []struct {
+		line, limit int
+		want        string
+	}{
+		{1, 0, "one\ntwo\nthree\nfour\n"},
+		{2, 2, "two\nthree\n"},
+		{0, 1, "one\n"},
+		{4, 10, "four\n"},
+		{9, 0, ""},
+	}
+	for _, tt := range tests {
+		result, err := f.ReadTextFileRange("a.txt", tt.line, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Content != tt.want || result.Truncated {
+			t.Errorf("line %d, limit %d: got %q (truncated %v), want %q", tt.line, tt.limit, result.Content, result.Truncated, tt.want)
+		}
+	}
+}
+
+func TestReadTextFileRangeTruncatesAtReadLimit(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "aaaa\nbbbb\ncccc\n"})
+	f := NewFileSystemAdapter(dir, nil)
+	f.SetMaxReadBytes(12)
+
+	result, err := f.ReadTextFileRange("a.txt", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Content != "aaaa\nbbbb\n" || !result.Truncated || result.NextLine != 3 || result.TotalSize != 15 {
+		t.Errorf("got %+v, want two whole lines, truncated, next line 3", result)
+	}
+}
+
+func TestDeleteAndMove(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt":     "a",
+		"sub/b.txt": "b",
+	})
+	f := NewFileSystemAdapter(dir, nil)
+
+	if err := f.MoveFile("a.txt", "moved/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "moved", "a.txt")); err != nil || string(data) != "a" {
+		t.Errorf("moved file = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("source still there after move: %v", err)
+	}
+
+	if err := f.DeleteFile("sub"); err == nil {
+		t.Error("DeleteFile removed a directory")
+	}
+	if err := f.DeleteFile("sub/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DeleteDirectory("moved"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DeleteDirectory("."); err == nil {
+		t.Error("DeleteDirectory removed the working directory")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "sub" {
+		t.Errorf("left in the working directory: %v, want only the empty sub", entries)
+	}
+}
+
+func TestWriteTextFileIsAtomic(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"target.txt": "old"})
+	target := filepath.Join(dir, "target.txt")
+	if err := os.Chmod(target, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(dir, "link.txt")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	f := NewFileSystemAdapter(dir, nil)
+
+	// Writing through the link replaces the target and keeps the link and mode
+	if err := f.WriteTextFile("link.txt", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new" {
+		t.Errorf("target = %q, want new", data)
+	}
+	if info, err := os.Lstat(filepath.Join(dir, "link.txt")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("link.txt is no longer a symlink: %v", err)
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %o, want 600", info.Mode().Perm())
+	}
+
+	// A rejected write leaves the file as it was
+	f.SetMaxWriteBytes(2)
+	if err := f.WriteTextFile("target.txt", "too long"); err == nil {
+		t.Error("write over the size limit succeeded")
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new" {
+		t.Errorf("target = %q after a rejected write, want new", data)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+}
+
+func TestRestrictToCwd(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	writeFiles(t, dir, map[string]string{"inside.txt": "in"})
+	writeFiles(t, outside, map[string]string{"secret.txt": "needle"})
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	f := NewFileSystemAdapter(dir, nil)
+	f.SetRestrictToCwd(true)
+
+	for _, path := range []string{
+		"../" + filepath.Base(outside) + "/secret.txt",
+		filepath.Join(outside, "secret.txt"),
+		"escape/secret.txt",
+		"escape/new.txt",
+	} {
+		if _, err := f.ResolveWithinRoot(path); err == nil {
+			t.Errorf("%s: resolved, want it rejected", path)
+		}
+	}
+	if _, err := f.ReadTextFile("escape/secret.txt"); err == nil {
+		t.Error("read through a symlink out of the working directory")
+	}
+	if err := f.WriteTextFile("escape/new.txt", "x"); err == nil {
+		t.Error("wrote through a symlink out of the working directory")
+	}
+
+	for _, path := range []string{"inside.txt", "new/file.txt", "sub/../inside.txt"} {
+		if _, err := f.ResolveWithinRoot(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+
+	// Walks leave out links leading outside, even when following symlinks
+	f.SetFollowSymlinks(true)
+	results, _, _, err := f.GrepSearch(context.Background(), "needle", []string{dir}, GrepOptions{Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("grep found %v outside the working directory", grepLocations(t, dir, results))
+	}
+}
+
+// utf16Bytes encodes s as UTF-16 in the given byte order, with a byte order mark if bom is set
+func utf16Bytes(s string, bigEndian, bom bool) []byte {
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	out := make([]byte, 0, 2*len(units))
+	for _, u := range units {
+		if bigEndian {
+			out = append(out, byte(u>>8), byte(u))
+		} else {
+			out = append(out, byte(u), byte(u>>8))
+		}
+	}
+	return out
+}
+
+func TestGrepDetectsEncodings(t *testing.T) {
+	text := "first line\nsecond héllo needle\n"
+	files := map[string]string{
+		"utf8.txt":       text,
+		"utf8bom.txt":    "\xEF\xBB\xBF" + text,
+		"utf16le.txt":    string(utf16Bytes(text, false, true)),
+		"utf16be.txt":    string(utf16Bytes(text, true, true)),
+		"utf16nobom.txt": string(utf16Bytes(text, false, false)),
+		"binary.bin":     "needle\x00\x01\x02\x03",
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+
+	f := NewFileSystemAdapter(dir, nil)
+	results, _, _, err := f.GrepSearch(context.Background(), "needle", []string{dir}, GrepOptions{Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"utf16be.txt:2", "utf16le.txt:2", "utf16nobom.txt:2", "utf8.txt:2", "utf8bom.txt:2"}
+	if got := grepLocations(t, dir, results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("matches = %v, want %v", got, want)
+	}
+	for _, result := range results {
+		if result.Line != "second héllo needle" || result.RuneColumn != 14 {
+			t.Errorf("%s: line %q, rune column %d", filepath.Base(result.Path), result.Line, result.RuneColumn)
+		}
+	}
+
+	// The byte order mark is not part of the first line
+	results, _, _, err = f.GrepSearch(context.Background(), "^first", []string{dir}, GrepOptions{Recursive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 5 {
+		t.Errorf("^first matched in %v, want every text file", grepLocations(t, dir, results))
+	}
+}
+
+func TestGrepMultiline(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.go": "package a\n\nfunc Run() {\r\n\treturn\n}\n"})
+	f := NewFileSystemAdapter(dir, nil)
+
+	results, _, _, err := f.GrepSearch(context.Background(), `func Run\(\) \{.*?\}`, []string{dir}, GrepOptions{Multiline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d matches, want 1", len(results))
+	}
+	got := results[0]
+	if got.LineNumber != 3 || got.Line != "func Run() {" || got.Match != "func Run() {\r\n\treturn\n}" || got.Column != 1 || got.ByteOffset != 11 {
+		t.Errorf("match = %+v", got)
+	}
+
+	// Without multiline the pattern can't cross the line break
+	results, _, _, err = f.GrepSearch(context.Background(), `func Run\(\) \{.*?\}`, []string{dir}, GrepOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("line by line, got %+v", results)
+	}
+}
+
+func TestGrepWholeWordAndFixedString(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "cat\nconcat\ncat.go\ncatalog\nc.t\n"})
+	f := NewFileSystemAdapter(dir, nil)
+
+	tests := []struct {
+		pattern string
+		opts    GrepOptions
+		want    []string
+	}{
+		{"cat", GrepOptions{}, []string{"a.txt:1", "a.txt:2", "a.txt:3", "a.txt:4"}},
+		{"cat", GrepOptions{WholeWord: true}, []string{"a.txt:1", "a.txt:3"}},
+		{"c.t", GrepOptions{}, []string{"a.txt:1", "a.txt:2", "a.txt:3", "a.txt:4", "a.txt:5"}},
+		{"c.t", GrepOptions{FixedString: true}, []string{"a.txt:5"}},
+		{"cat.go", GrepOptions{FixedString: true, WholeWord: true}, []string{"a.txt:3"}},
+		{"cat|dog", GrepOptions{WholeWord: true}, []string{"a.txt:1", "a.txt:3"}},
+	}
+	for _, tt := range tests {
+		results, _, _, err := f.GrepSearch(context.Background(), tt.pattern, []string{dir}, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := grepLocations(t, dir, results); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q with %+v: matches = %v, want %v", tt.pattern, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestGrepSearchStream(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("f%02d.txt", i)] = "needle\nneedle\n"
+	}
+	writeFiles(t, dir, files)
+	f := NewFileSystemAdapter(dir, nil)
+	f.SetGrepWorkers(4)
+
+	var streamed []GrepResult
+	_, err := f.GrepSearchStream(context.Background(), "needle", []string{dir}, GrepOptions{}, func(result GrepResult) bool {
+		streamed = append(streamed, result)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffered, _, _, err := f.GrepSearch(context.Background(), "needle", []string{dir}, GrepOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(streamed, buffered) {
+		t.Errorf("streamed matches differ from buffered ones")
+	}
+
+	// Returning false stops the search without an error
+	count := 0
+	_, err = f.GrepSearchStream(context.Background(), "needle", []string{dir}, GrepOptions{}, func(GrepResult) bool {
+		count++
+		return count < 3
+	})
+	if err != nil || count != 3 {
+		t.Errorf("stopped search: %d matches, err %v; want 3 and no error", count, err)
+	}
+
+	// GrepSearch stops once a match past MaxResults shows it is truncated
+	results, _, truncated, err := f.GrepSearch(context.Background(), "needle", []string{dir}, GrepOptions{MaxResults: 5})
+	if err != nil || len(results) != 5 || !truncated {
+		t.Errorf("got %d matches, truncated %v, err %v; want 5, truncated", len(results), truncated, err)
+	}
+}
+
+func TestGrepSearchStreamCancelled(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "needle\n"})
+	f := NewFileSystemAdapter(dir, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := f.GrepSearchStream(ctx, "needle", []string{dir}, GrepOptions{}, func(GrepResult) bool {
+		t.Error("match emitted after cancellation")
+		return true
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestGrepLongLines(t *testing.T) {
+	// A minified bundle: one long line between ordinary ones
+	minified := "var a=1;" + strings.Repeat("function f(){return 0};", 9000) + "needle();" + strings.Repeat("x=1;", 100)
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.min.js": "// needle header\n" + minified + "\n" + minified + "\nneedle footer\n",
+		"exact.js":   strings.Repeat("a", 1018) + "needle\r\nneedle\r\n",
+	})
+
+	tests := []struct {
+		name    string
+		maxLine int64
+		want    []string
+		skipped string
+	}{
+		{"searched", 0, []string{"app.min.js:1", "app.min.js:2", "app.min.js:3", "app.min.js:4", "exact.js:1", "exact.js:2"}, ""},
+		// Long lines are skipped, not the rest of the file; a line of exactly the
+		// limit is searched even with its \r\n
+		{"skipped", 1024, []string{"app.min.js:1", "app.min.js:4", "exact.js:1", "exact.js:2"}, "2 lines longer than 1024 bytes were not searched (first: line 2)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFileSystemAdapter(dir, nil)
+			f.SetMaxLineBytes(tt.maxLine)
+			results, skipped, _, err := f.GrepSearch(context.Background(), "needle", []string{dir}, GrepOptions{Recursive: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := grepLocations(t, dir, results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+			var reasons []string
+			for _, skip := range skipped {
+				reasons = append(reasons, skip.Reason)
+			}
+			if got := strings.Join(reasons, "; "); got != tt.skipped {
+				t.Errorf("skipped %q, want %q", got, tt.skipped)
+			}
+
+			// Offsets after a long line still count its bytes
+			for _, result := range results {
+				if filepath.Base(result.Path) == "app.min.js" && result.LineNumber == 4 {
+					if want := int64(17 + 2*(len(minified)+1)); result.ByteOffset != want {
+						t.Errorf("footer match at byte %d, want %d", result.ByteOffset, want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -22,10 +22,13 @@
 //  1. Read incoming JSON-RPC request from the TCP connection
 //  2. Parse the request to check if method starts with underscore (_)
 //  3. If it's an extension method:
-//     - Call our custom ExtensionMethodHandler.HandleExtensionMethod()
-//     - Send the response directly back through the writer
+//     - Call our custom ExtensionMethodHandler.HandleExtensionMethod() in a goroutine
+//     - Give it a per-request context that a cancel notification can cancel
+//     - Send the response directly back through the writer when it finishes
 //     - Continue reading (effectively "consuming" the request)
-//  4. If it's a standard method:
+//  4. If it's a cancel notification ($/cancel_request or $/cancelRequest) naming an
+//     in-flight extension request, cancel that request's context
+//  5. If it's a standard method:
 //     - Pass the request through to the SDK's normal handling
 //
 // ## ACP Extensibility Protocol
@@ -42,7 +45,7 @@
 //
 //	baseReader := bufio.NewReader(conn)
 //	writer := &flushingWriter{bufio.NewWriter(conn)}
-//	reader := NewJSONRPCMiddleware(ctx, baseReader, writer, client)
+//	reader := NewJSONRPCMiddleware(client.ctx, baseReader, writer, client)
 //	client.conn = acp.NewClientSideConnection(client, writer, reader)
 //
 // The ACPClient implements ExtensionMethodHandler to process custom methods like _fs/grep_search.
@@ -52,8 +55,35 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
+
+	acp "github.com/coder/acp-go-sdk"
+	"github.com/ron/tui_acp/tui/logger"
+)
+
+// heartbeatMethod is sent by Ping. Any response, including a method-not-found
+// error, shows the agent is alive and reading from the connection.
+const heartbeatMethod = "_heartbeat/ping"
+
+// heartbeatIDPrefix marks request IDs owned by the middleware rather than the SDK
+const heartbeatIDPrefix = "heartbeat-"
+
+// Cancel notifications for an in-flight request: the ACP spelling, and the LSP one
+// many JSON-RPC peers use. Params carry the request ID as requestId or id.
+const (
+	cancelRequestMethod    = "$/cancel_request"
+	lspCancelRequestMethod = "$/cancelRequest"
+)
+
+// Encodings for the traffic dump, selected by ProtocolConfig.JSONEncoding. The wire
+// is always compact, since agents read one message per line.
+const (
+	JSONEncodingCompact = "compact" // One line per message
+	JSONEncodingIndent  = "indent"  // Indented, for reading by eye
 )
 
 // JSONRPCRequest represents a JSON-RPC 2.0 request
@@ -72,11 +102,41 @@ type JSONRPCResponse struct {
 	Error   interface{} `json:"error,omitempty"`
 }
 
+// JSON-RPC error codes returned for extension methods
+const (
+	CodeMethodNotFound   = -32601
+	CodeInvalidParams    = -32602
+	CodeInternalError    = -32603
+	CodeServerError      = -32000 // Generic failure while handling the method
+	CodeRequestCancelled = -32800 // The request was cancelled before it finished
+)
+
+// RPCError is a handler error that carries a specific JSON-RPC error code.
+// Errors of other types are reported with CodeServerError.
+type RPCError struct {
+	Code    int
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// invalidParamsError reports a request whose params are malformed or missing required fields
+func invalidParamsError(format string, args ...interface{}) error {
+	return &RPCError{Code: CodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
 // ExtensionMethodHandler handles extension methods
 type ExtensionMethodHandler interface {
 	HandleExtensionMethod(ctx context.Context, method string, params map[string]interface{}) (interface{}, error)
 }
 
+// Notifier sends JSON-RPC notifications to the agent
+type Notifier interface {
+	Notify(method string, params interface{}) error
+}
+
 // JSONRPCMiddleware wraps io.Reader to intercept and handle extension method requests
 type JSONRPCMiddleware struct {
 	underlying io.Reader
@@ -85,6 +145,18 @@ type JSONRPCMiddleware struct {
 	buffer     []byte
 	ctx        context.Context
 	scanner    *bufio.Scanner // Persistent scanner to avoid recreation on each Read
+
+	pingMu  sync.Mutex
+	pingSeq int
+	pings   map[string]chan struct{} // Outstanding heartbeat pings by request ID
+
+	opsMu sync.Mutex
+	ops   map[string]*extensionOp // In-flight extension requests by request ID
+
+	dump       logger.Logger // Receives what the middleware sends, at debug level
+	dumpIndent bool
+
+	updates *updateTracker // Session updates passed on to the SDK
 }
 
 // NewJSONRPCMiddleware creates a new JSON-RPC middleware
@@ -96,11 +168,228 @@ func NewJSONRPCMiddleware(ctx context.Context, reader io.Reader, writer io.Write
 		ctx:        ctx,
 		buffer:     make([]byte, 0),
 		scanner:    bufio.NewScanner(reader), // Initialize scanner once
+		pings:      make(map[string]chan struct{}),
+		ops:        make(map[string]*extensionOp),
+		dump:       logger.NewNoopLogger(),
+		updates:    newUpdateTracker(),
+	}
+}
+
+// SetTrafficDump logs the responses and notifications the middleware sends to log,
+// indented if encoding is JSONEncodingIndent. Call it before the first Read.
+func (m *JSONRPCMiddleware) SetTrafficDump(log logger.Logger, encoding string) {
+	m.dump = log
+	m.dumpIndent = encoding == JSONEncodingIndent
+}
+
+// dumpMessage logs an outgoing message, reusing its wire encoding when compact will do
+func (m *JSONRPCMiddleware) dumpMessage(msg interface{}, wire []byte) {
+	if !m.dumpIndent {
+		m.dump.Debug("-> %s", wire)
+		return
+	}
+	indented, err := json.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		indented = wire
+	}
+	m.dump.Debug("-> %s", indented)
+}
+
+// Notify sends a JSON-RPC notification to the agent, e.g. a watch event
+func (m *JSONRPCMiddleware) Notify(method string, params interface{}) error {
+	notification := struct {
+		JSONRPC string      `json:"jsonrpc"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params,omitempty"`
+	}{JSONRPC: "2.0", Method: method, Params: params}
+	reqBytes, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s notification: %w", method, err)
+	}
+	m.dumpMessage(notification, reqBytes)
+	if _, err := m.writer.Write(append(reqBytes, '\n')); err != nil {
+		return fmt.Errorf("failed to send %s notification: %w", method, err)
+	}
+	return nil
+}
+
+// Ping sends a heartbeat request to the agent and waits for any response.
+// The response is consumed by Read and never reaches the SDK.
+func (m *JSONRPCMiddleware) Ping(ctx context.Context) error {
+	m.pingMu.Lock()
+	m.pingSeq++
+	id := fmt.Sprintf("%s%d", heartbeatIDPrefix, m.pingSeq)
+	done := make(chan struct{})
+	m.pings[id] = done
+	m.pingMu.Unlock()
+
+	reqBytes, err := json.Marshal(JSONRPCRequest{JSONRPC: "2.0", ID: id, Method: heartbeatMethod})
+	if err == nil {
+		_, err = m.writer.Write(append(reqBytes, '\n'))
+	}
+	if err != nil {
+		m.cancelPing(id)
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		m.cancelPing(id)
+		return ctx.Err()
+	}
+}
+
+// cancelPing forgets an outstanding ping; a late response is dropped by completePing
+func (m *JSONRPCMiddleware) cancelPing(id string) {
+	m.pingMu.Lock()
+	defer m.pingMu.Unlock()
+	delete(m.pings, id)
+}
+
+// completePing resolves the ping with the given ID, reporting whether it was one of ours
+func (m *JSONRPCMiddleware) completePing(id interface{}) bool {
+	key, ok := id.(string)
+	if !ok || !strings.HasPrefix(key, heartbeatIDPrefix) {
+		return false
+	}
+
+	m.pingMu.Lock()
+	defer m.pingMu.Unlock()
+
+	done, ok := m.pings[key]
+	if !ok {
+		// Timed out already; still ours, so keep it away from the SDK
+		return true
+	}
+	close(done)
+	delete(m.pings, key)
+	return true
+}
+
+// requestKey turns a JSON-RPC ID into a map key. IDs are compared by their JSON
+// encoding so 1 and "1" stay distinct.
+func requestKey(id interface{}) string {
+	key, _ := json.Marshal(id)
+	return string(key)
+}
+
+// extensionOp is an extension request being handled in the background
+type extensionOp struct {
+	key    string
+	cancel context.CancelFunc
+}
+
+// startOp registers a cancelable context for an extension request
+func (m *JSONRPCMiddleware) startOp(id interface{}) (context.Context, *extensionOp) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	op := &extensionOp{key: requestKey(id), cancel: cancel}
+
+	m.opsMu.Lock()
+	m.ops[op.key] = op
+	m.opsMu.Unlock()
+	return ctx, op
+}
+
+// finishOp releases an extension request's context once its response is sent
+func (m *JSONRPCMiddleware) finishOp(op *extensionOp) {
+	m.opsMu.Lock()
+	// A request reusing the ID may have replaced this one
+	if m.ops[op.key] == op {
+		delete(m.ops, op.key)
 	}
+	m.opsMu.Unlock()
+
+	op.cancel()
+}
+
+// CancelRequest cancels the in-flight extension request with the given ID,
+// reporting whether there was one
+func (m *JSONRPCMiddleware) CancelRequest(id interface{}) bool {
+	m.opsMu.Lock()
+	op, ok := m.ops[requestKey(id)]
+	m.opsMu.Unlock()
+
+	if ok {
+		op.cancel()
+	}
+	return ok
 }
 
-// Read implements io.Reader
+// CancelAllRequests cancels every in-flight extension request,
+// e.g. when the prompt they were made for is cancelled
+func (m *JSONRPCMiddleware) CancelAllRequests() {
+	m.opsMu.Lock()
+	defer m.opsMu.Unlock()
+
+	for _, op := range m.ops {
+		op.cancel()
+	}
+}
+
+// handleCancel handles a cancel notification, reporting whether it named one of
+// our extension requests. Cancels for other requests are left for the SDK.
+func (m *JSONRPCMiddleware) handleCancel(req JSONRPCRequest) bool {
+	var params map[string]interface{}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return false
+	}
+
+	id, ok := params["requestId"]
+	if !ok {
+		id = params["id"]
+	}
+	return m.CancelRequest(id)
+}
+
+// writeResponse sends the result or error of an extension request
+func (m *JSONRPCMiddleware) writeResponse(id interface{}, result interface{}, handlerErr error) error {
+	resp := JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+	}
+
+	if handlerErr != nil {
+		code := CodeServerError
+		var rpcErr *RPCError
+		switch {
+		case errors.As(handlerErr, &rpcErr):
+			code = rpcErr.Code
+		case errors.Is(handlerErr, context.Canceled):
+			code = CodeRequestCancelled
+		}
+		resp.Error = map[string]interface{}{
+			"code":    code,
+			"message": handlerErr.Error(),
+		}
+	} else {
+		resp.Result = result
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		// If we can't marshal the response, send an error response
+		resp.Result = nil
+		resp.Error = map[string]interface{}{
+			"code":    CodeInternalError,
+			"message": "Internal error: failed to marshal response",
+		}
+		respBytes, _ = json.Marshal(resp)
+	}
+	m.dumpMessage(resp, respBytes)
+	respBytes = append(respBytes, '\n')
+	_, err = m.writer.Write(respBytes)
+	return err
+}
+
+// Read implements io.Reader. Once the middleware's context is cancelled it
+// reports the cancellation instead of reading further.
 func (m *JSONRPCMiddleware) Read(p []byte) (n int, err error) {
+	if err := m.ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	// If we have buffered data from a previous interception, return it first
 	if len(m.buffer) > 0 {
 		n = copy(p, m.buffer)
@@ -127,54 +416,50 @@ func (m *JSONRPCMiddleware) Read(p []byte) (n int, err error) {
 		return n, nil
 	}
 
+	// Responses to our own heartbeat pings are consumed here
+	if req.Method == "" && m.completePing(req.ID) {
+		return m.Read(p)
+	}
+
+	// Cancel notifications for our own extension requests are consumed here
+	if (req.Method == cancelRequestMethod || req.Method == lspCancelRequestMethod) && m.handleCancel(req) {
+		return m.Read(p)
+	}
+
 	// Check if this is an extension method (starts with underscore)
 	if strings.HasPrefix(req.Method, "_") && m.handler != nil {
-		// Handle extension method
+		// Absent or null params mean "no params"; anything that isn't a JSON
+		// object is rejected before reaching the handler.
 		var params map[string]interface{}
 		if len(req.Params) > 0 {
 			if err := json.Unmarshal(req.Params, &params); err != nil {
-				// Log error but continue with nil params
-				params = nil
-			}
-		}
-
-		result, handlerErr := m.handler.HandleExtensionMethod(m.ctx, req.Method, params)
-
-		// Create response
-		var resp JSONRPCResponse
-		resp.JSONRPC = "2.0"
-		resp.ID = req.ID
-
-		if handlerErr != nil {
-			resp.Error = map[string]interface{}{
-				"code":    -32000,
-				"message": handlerErr.Error(),
+				err = invalidParamsError("invalid params for %s: expected a JSON object: %v", req.Method, err)
+				if err := m.writeResponse(req.ID, nil, err); err != nil {
+					return 0, err
+				}
+				return m.Read(p)
 			}
-		} else {
-			resp.Result = result
 		}
 
-		// Send response directly to writer
-		respBytes, err := json.Marshal(resp)
-		if err != nil {
-			// If we can't marshal the response, send an error response
-			resp.Result = nil
-			resp.Error = map[string]interface{}{
-				"code":    -32603,
-				"message": "Internal error: failed to marshal response",
-			}
-			respBytes, _ = json.Marshal(resp)
-		}
-		respBytes = append(respBytes, '\n')
-		if _, err := m.writer.Write(respBytes); err != nil {
-			return 0, err
-		}
+		// Handle the method in the background so reading continues and a cancel
+		// notification for it can be received while it runs
+		ctx, op := m.startOp(req.ID)
+		go func() {
+			defer m.finishOp(op)
+			result, handlerErr := m.handler.HandleExtensionMethod(ctx, req.Method, params)
+			// A failed write means the connection is gone; the read loop sees that too
+			_ = m.writeResponse(req.ID, result, handlerErr)
+		}()
 
 		// Return empty to continue reading
 		return m.Read(p)
 	}
 
 	// Not an extension method, pass through
+	if req.Method == acp.ClientMethodSessionUpdate {
+		m.updates.awaitTurn(m.ctx, updateDrainTimeout)
+		m.updates.markRead()
+	}
 	n = copy(p, line)
 	n += copy(p[n:], []byte("\n"))
 	return n, nil
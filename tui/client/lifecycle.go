@@ -0,0 +1,59 @@
+package client
+
+import (
+	"errors"
+)
+
+// LifecycleKind identifies a connection lifecycle transition
+type LifecycleKind string
+
+const (
+	LifecycleConnected      LifecycleKind = "connected"       // The agent answered the handshake
+	LifecycleSessionCreated LifecycleKind = "session-created" // A session was created or resumed
+	LifecycleDisconnected   LifecycleKind = "disconnected"    // The connection closed
+)
+
+// errConnectionLost is the error of a disconnect the client didn't ask for
+var errConnectionLost = errors.New("connection to agent lost")
+
+// LifecycleEvent describes a connection lifecycle transition
+type LifecycleEvent struct {
+	Kind      LifecycleKind
+	Address   string // Agent address, or the command line for the stdio transport
+	SessionID string // Session the event concerns, once there is one
+	Resumed   bool   // For LifecycleSessionCreated: a prior session was loaded
+	Err       error  // For LifecycleDisconnected: why, or nil when the client closed it
+}
+
+// LifecycleHandler is implemented by message handlers that follow the connection,
+// such as embedders keeping their own status display
+type LifecycleHandler interface {
+	OnLifecycleEvent(event LifecycleEvent)
+}
+
+// emitLifecycle reports a transition to the configured callback, if any
+func (p *ProtocolClient) emitLifecycle(event LifecycleEvent) {
+	if p.onLifecycle == nil {
+		return
+	}
+	event.Address = p.address
+	if event.SessionID == "" {
+		event.SessionID = p.SessionID()
+	}
+	p.onLifecycle(event)
+}
+
+// watchConnection reports the disconnect when the connection closes, whether
+// the agent went away or Close was called
+func (p *ProtocolClient) watchConnection() {
+	var err error
+	select {
+	case <-p.conn.Done():
+		err = errConnectionLost
+		p.mu.Lock()
+		p.health.Connected = false
+		p.mu.Unlock()
+	case <-p.ctx.Done():
+	}
+	p.emitLifecycle(LifecycleEvent{Kind: LifecycleDisconnected, Err: err})
+}
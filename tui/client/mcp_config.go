@@ -0,0 +1,111 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	acp "github.com/coder/acp-go-sdk"
+)
+
+// mcpServerConfig is one MCP server as written in an MCP config file
+type mcpServerConfig struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type"` // "stdio" (the default), "http" or "sse"
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+// LoadMcpServers reads the MCP servers to offer the agent from a JSON file.
+// The file holds either an array of servers with a name each, or an object
+// whose "mcpServers" field maps names to servers.
+func LoadMcpServers(path string) ([]acp.McpServer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MCP config: %w", err)
+	}
+
+	var configs []mcpServerConfig
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &configs); err != nil {
+			return nil, fmt.Errorf("failed to parse MCP config: %w", err)
+		}
+	} else {
+		var file struct {
+			McpServers map[string]mcpServerConfig `json:"mcpServers"`
+		}
+		if err := json.Unmarshal(trimmed, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse MCP config: %w", err)
+		}
+		for name, cfg := range file.McpServers {
+			cfg.Name = name
+			configs = append(configs, cfg)
+		}
+		sort.Slice(configs, func(i, j int) bool {
+			return configs[i].Name < configs[j].Name
+		})
+	}
+
+	servers := make([]acp.McpServer, 0, len(configs))
+	for i, cfg := range configs {
+		server, err := cfg.toMcpServer()
+		if err != nil {
+			return nil, fmt.Errorf("MCP server %d: %w", i+1, err)
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+// toMcpServer converts a config entry to the session request form
+func (c mcpServerConfig) toMcpServer() (acp.McpServer, error) {
+	if c.Name == "" {
+		return acp.McpServer{}, fmt.Errorf("missing name")
+	}
+
+	switch c.Type {
+	case "", "stdio":
+		if c.Command == "" {
+			return acp.McpServer{}, fmt.Errorf("%s: stdio server needs a command", c.Name)
+		}
+		env := make([]acp.EnvVariable, 0, len(c.Env))
+		for _, name := range sortedKeys(c.Env) {
+			env = append(env, acp.EnvVariable{Name: name, Value: c.Env[name]})
+		}
+		args := c.Args
+		if args == nil {
+			args = []string{}
+		}
+		return acp.McpServer{Stdio: &acp.McpServerStdio{Name: c.Name, Command: c.Command, Args: args, Env: env}}, nil
+	case "http", "sse":
+		if c.URL == "" {
+			return acp.McpServer{}, fmt.Errorf("%s: %s server needs a url", c.Name, c.Type)
+		}
+		headers := make([]acp.HttpHeader, 0, len(c.Headers))
+		for _, name := range sortedKeys(c.Headers) {
+			headers = append(headers, acp.HttpHeader{Name: name, Value: c.Headers[name]})
+		}
+		if c.Type == "sse" {
+			return acp.McpServer{Sse: &acp.McpServerSse{Name: c.Name, Type: c.Type, Url: c.URL, Headers: headers}}, nil
+		}
+		return acp.McpServer{Http: &acp.McpServerHttp{Name: c.Name, Type: c.Type, Url: c.URL, Headers: headers}}, nil
+	default:
+		return acp.McpServer{}, fmt.Errorf("%s: unknown server type %q (want stdio, http or sse)", c.Name, c.Type)
+	}
+}
+
+// sortedKeys returns the keys of m in order, so requests are reproducible
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
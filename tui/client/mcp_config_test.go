@@ -0,0 +1,109 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	acp "github.com/coder/acp-go-sdk"
+	"github.com/ron/tui_acp/tui/client/clienttest"
+)
+
+// writeMcpConfig writes an MCP config file and returns its path
+func writeMcpConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mcp.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadMcpServers(t *testing.T) {
+	want := []acp.McpServer{
+		{Http: &acp.McpServerHttp{Name: "docs", Type: "http", Url: "https://example.com/mcp",
+			Headers: []acp.HttpHeader{{Name: "Authorization", Value: "Bearer x"}}}},
+		{Stdio: &acp.McpServerStdio{Name: "files", Command: "mcp-files", Args: []string{"--root", "."},
+			Env: []acp.EnvVariable{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}}}},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"array", `[
+			{"name": "docs", "type": "http", "url": "https://example.com/mcp", "headers": {"Authorization": "Bearer x"}},
+			{"name": "files", "command": "mcp-files", "args": ["--root", "."], "env": {"B": "2", "A": "1"}}
+		]`},
+		{"mcpServers object", `{"mcpServers": {
+			"files": {"command": "mcp-files", "args": ["--root", "."], "env": {"B": "2", "A": "1"}},
+			"docs": {"type": "http", "url": "https://example.com/mcp", "headers": {"Authorization": "Bearer x"}}
+		}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servers, err := LoadMcpServers(writeMcpConfig(t, tt.content))
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if !reflect.DeepEqual(servers, want) {
+				t.Errorf("servers = %+v, want %+v", servers, want)
+			}
+		})
+	}
+}
+
+func TestLoadMcpServersRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"not JSON", `{`, "failed to parse"},
+		{"no name", `[{"command": "x"}]`, "missing name"},
+		{"no command", `[{"name": "a"}]`, "needs a command"},
+		{"no url", `[{"name": "a", "type": "sse"}]`, "needs a url"},
+		{"unknown type", `[{"name": "a", "type": "ws"}]`, "unknown server type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadMcpServers(writeMcpConfig(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMcpServersReachSession(t *testing.T) {
+	servers, err := LoadMcpServers(writeMcpConfig(t, `[{"name": "files", "command": "mcp-files"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Offered both to a new session and to a resumed one
+	agent := &clienttest.Agent{CanLoadSession: true}
+	connect(t, agent, Config{McpServers: servers})
+	connect(t, agent, Config{McpServers: servers, SessionID: "session-1"})
+
+	if news := agent.NewSessions(); len(news) != 1 || !reflect.DeepEqual(news[0].McpServers, servers) {
+		t.Errorf("new sessions = %+v, want one offered %+v", news, servers)
+	}
+	if loads := agent.Loads(); len(loads) != 1 || !reflect.DeepEqual(loads[0].McpServers, servers) {
+		t.Errorf("loads = %+v, want one offered %+v", loads, servers)
+	}
+}
+
+func TestNoMcpServersSendsEmptyList(t *testing.T) {
+	agent := &clienttest.Agent{}
+	connect(t, agent, Config{})
+
+	// The field is required, so it is sent empty rather than left out
+	if news := agent.NewSessions(); len(news) != 1 || news[0].McpServers == nil || len(news[0].McpServers) != 0 {
+		t.Errorf("new sessions = %+v, want an empty server list", news)
+	}
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// PermissionDecision is what a PermissionPolicy says to do with a permission request
+type PermissionDecision string
+
+const (
+	PermissionAsk   PermissionDecision = "ask"   // Put the request to the user
+	PermissionAllow PermissionDecision = "allow" // Pick an allow option without asking
+	PermissionDeny  PermissionDecision = "deny"  // Pick a reject option without asking
+)
+
+// PermissionPolicy decides permission requests ahead of time by name. Patterns
+// are globs (as in path.Match). Deny patterns match the tool call's kind, such
+// as "read" or "execute", or its title, such as "fs/read_text_file". The title
+// is chosen by the agent, so allow patterns match the kind only, unless written
+// as "kind:title" to require both, as in "execute:git status*".
+// Deny wins when a request matches both lists.
+type PermissionPolicy struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// LoadPermissionPolicy reads a permission policy from a JSON file of the form
+// {"allow": [...], "deny": [...]}
+func LoadPermissionPolicy(file string) (*PermissionPolicy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read permission policy: %w", err)
+	}
+
+	var policy PermissionPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse permission policy: %w", err)
+	}
+	for _, pattern := range append(policy.Allow, policy.Deny...) {
+		kind, title, _ := strings.Cut(pattern, ":")
+		for _, glob := range []string{pattern, kind, title} {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("invalid permission policy pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+// Decide returns the decision for a tool call of the given kind and title.
+// A nil policy asks.
+func (p *PermissionPolicy) Decide(kind, title string) PermissionDecision {
+	if p == nil {
+		return PermissionAsk
+	}
+	for _, pattern := range p.Deny {
+		if matchName(pattern, kind) || matchName(pattern, title) || matchKindTitle(pattern, kind, title) {
+			return PermissionDeny
+		}
+	}
+	for _, pattern := range p.Allow {
+		if matchKindTitle(pattern, kind, title) || (!strings.Contains(pattern, ":") && matchName(pattern, kind)) {
+			return PermissionAllow
+		}
+	}
+	return PermissionAsk
+}
+
+// matchKindTitle reports whether a "kind:title" pattern matches both names
+func matchKindTitle(pattern, kind, title string) bool {
+	kindPattern, titlePattern, ok := strings.Cut(pattern, ":")
+	return ok && matchName(kindPattern, kind) && matchName(titlePattern, title)
+}
+
+// matchName reports whether pattern matches a non-empty name
+func matchName(pattern, name string) bool {
+	if name == "" {
+		return false
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
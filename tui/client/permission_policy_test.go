@@ -0,0 +1,83 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPermissionPolicyDecide(t *testing.T) {
+	policy := &PermissionPolicy{
+		Allow: []string{"read", "execute:git status*", "fs/write_text_file"},
+		Deny:  []string{"delete", "*rm -rf*", "execute:git push*"},
+	}
+
+	tests := []struct {
+		name  string
+		kind  string
+		title string
+		want  PermissionDecision
+	}{
+		{"allowed kind", "read", "fs/read_text_file", PermissionAllow},
+		{"allowed kind and title", "execute", "git status --short", PermissionAllow},
+		{"other title of the kind", "execute", "make", PermissionAsk},
+		// An agent picks its titles, so a title alone never allows
+		{"allowed title alone", "edit", "fs/write_text_file", PermissionAsk},
+		{"denied kind", "delete", "remove old files", PermissionDeny},
+		{"denied title", "execute", "sudo rm -rf build", PermissionDeny},
+		{"deny wins", "read", "cat x; rm -rf y", PermissionDeny},
+		{"denied kind and title", "execute", "git push --force", PermissionDeny},
+		{"no kind", "", "something", PermissionAsk},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Decide(tt.kind, tt.title); got != tt.want {
+				t.Errorf("Decide(%q, %q) = %s, want %s", tt.kind, tt.title, got, tt.want)
+			}
+		})
+	}
+
+	var none *PermissionPolicy
+	if got := none.Decide("read", "anything"); got != PermissionAsk {
+		t.Errorf("nil policy = %s, want ask", got)
+	}
+}
+
+func TestLoadPermissionPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *PermissionPolicy
+		wantErr string
+	}{
+		{name: "valid", content: `{"allow": ["read"], "deny": ["execute:rm *"]}`,
+			want: &PermissionPolicy{Allow: []string{"read"}, Deny: []string{"execute:rm *"}}},
+		{name: "not JSON", content: `allow read`, wantErr: "failed to parse"},
+		{name: "bad pattern", content: `{"deny": ["execute:[rm"]}`, wantErr: "invalid permission policy pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "policy.json")
+			if err := os.WriteFile(file, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			policy, err := LoadPermissionPolicy(file)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if !reflect.DeepEqual(policy, tt.want) {
+				t.Errorf("policy = %+v, want %+v", policy, tt.want)
+			}
+		})
+	}
+}
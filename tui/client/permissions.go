@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	acp "github.com/coder/acp-go-sdk"
+)
+
+// PermissionOption is one of the answers an agent offers to a permission request
+type PermissionOption struct {
+	Name  string
+	Allow bool // Picking it lets the operation go ahead
+}
+
+// PermissionHandler is implemented by message handlers that put the agent's
+// permission requests to the user. It should block until an option is picked and
+// return its index, or -1 when the request was dismissed without an answer.
+type PermissionHandler interface {
+	OnPermissionRequest(ctx context.Context, title string, options []PermissionOption) (int, error)
+}
+
+// SetPermissionPolicy sets the policy that decides permission requests before
+// the user is asked (nil = always ask)
+func (c *CapabilityHandler) SetPermissionPolicy(policy *PermissionPolicy) {
+	c.permissionPolicy = policy
+}
+
+// SetPermissionTimeout sets how long a permission request waits for an answer
+// before the agent's reject option is picked (0 = wait indefinitely)
+func (c *CapabilityHandler) SetPermissionTimeout(timeout time.Duration) {
+	c.permissionTimeout = timeout
+}
+
+// RequestPermission handles permission requests from the agent. The permission
+// policy is consulted first; requests it leaves open are put to the handler.
+// Without a PermissionHandler those are rejected.
+func (c *CapabilityHandler) RequestPermission(ctx context.Context, p acp.RequestPermissionRequest) (acp.RequestPermissionResponse, error) {
+	if len(p.Options) == 0 {
+		return acp.RequestPermissionResponse{}, fmt.Errorf("no options provided")
+	}
+
+	title := permissionTitle(p.ToolCall)
+	var kind string
+	if p.ToolCall.Kind != nil {
+		kind = string(*p.ToolCall.Kind)
+	}
+	switch c.permissionPolicy.Decide(kind, title) {
+	case PermissionDeny:
+		c.logger.Info("Permission policy denies %s", title)
+		return rejectPermission(p.Options), nil
+	case PermissionAllow:
+		if resp, ok := allowPermission(p.Options); ok {
+			c.logger.Info("Permission policy allows %s", title)
+			return resp, nil
+		}
+	}
+
+	asker, ok := c.handler.(PermissionHandler)
+	if !ok {
+		c.logger.Warn("No permission handler, rejecting: %s", title)
+		return rejectPermission(p.Options), nil
+	}
+
+	options := make([]PermissionOption, 0, len(p.Options))
+	for _, opt := range p.Options {
+		options = append(options, PermissionOption{
+			Name:  opt.Name,
+			Allow: opt.Kind == acp.PermissionOptionKindAllowOnce || opt.Kind == acp.PermissionOptionKindAllowAlways,
+		})
+	}
+
+	askCtx := ctx
+	if c.permissionTimeout > 0 {
+		var cancel context.CancelFunc
+		askCtx, cancel = context.WithTimeout(ctx, c.permissionTimeout)
+		defer cancel()
+	}
+
+	c.logger.Info("Asking for permission: %s", title)
+	index, err := asker.OnPermissionRequest(askCtx, title, options)
+	switch {
+	case ctx.Err() != nil:
+		// The request itself went away, e.g. the connection closed
+		return cancelPermission(), nil
+	case errors.Is(err, context.DeadlineExceeded):
+		c.logger.Warn("No answer to permission request within %v, rejecting: %s", c.permissionTimeout, title)
+		return rejectPermission(p.Options), nil
+	case err != nil:
+		return acp.RequestPermissionResponse{}, fmt.Errorf("permission prompt failed: %w", err)
+	case index < 0 || index >= len(p.Options):
+		c.logger.Info("Permission request dismissed: %s", title)
+		return cancelPermission(), nil
+	}
+
+	c.logger.Info("Permission for %s: %s", title, p.Options[index].Name)
+	return selectPermission(p.Options[index].OptionId), nil
+}
+
+// permissionTitle describes the tool call a permission request is about
+func permissionTitle(call acp.RequestPermissionToolCall) string {
+	if call.Title != nil && *call.Title != "" {
+		return *call.Title
+	}
+	return fmt.Sprintf("tool call %s", call.ToolCallId)
+}
+
+// allowPermission picks the allow-once option, or failing that any allow option.
+// It returns false when the agent offered no way to say yes.
+func allowPermission(options []acp.PermissionOption) (acp.RequestPermissionResponse, bool) {
+	for _, kind := range []acp.PermissionOptionKind{acp.PermissionOptionKindAllowOnce, acp.PermissionOptionKindAllowAlways} {
+		for _, opt := range options {
+			if opt.Kind == kind {
+				return selectPermission(opt.OptionId), true
+			}
+		}
+	}
+	return acp.RequestPermissionResponse{}, false
+}
+
+// rejectPermission picks the first reject option, or cancels the request when
+// the agent offered no way to say no
+func rejectPermission(options []acp.PermissionOption) acp.RequestPermissionResponse {
+	for _, opt := range options {
+		if opt.Kind == acp.PermissionOptionKindRejectOnce || opt.Kind == acp.PermissionOptionKindRejectAlways {
+			return selectPermission(opt.OptionId)
+		}
+	}
+	return cancelPermission()
+}
+
+// selectPermission answers a permission request with the given option
+func selectPermission(id acp.PermissionOptionId) acp.RequestPermissionResponse {
+	return acp.RequestPermissionResponse{
+		Outcome: acp.RequestPermissionOutcome{
+			Selected: &acp.RequestPermissionOutcomeSelected{OptionId: id},
+		},
+	}
+}
+
+// cancelPermission answers a permission request without picking an option
+func cancelPermission() acp.RequestPermissionResponse {
+	return acp.RequestPermissionResponse{
+		Outcome: acp.RequestPermissionOutcome{
+			Cancelled: &acp.RequestPermissionOutcomeCancelled{},
+		},
+	}
+}
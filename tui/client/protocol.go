@@ -4,21 +4,27 @@ import (
 	"bufio"
 	"context"
 	"fmt"
-	"net"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	acp "github.com/coder/acp-go-sdk"
 	"github.com/ron/tui_acp/tui/logger"
 )
 
-// flushingWriter wraps a bufio.Writer and flushes after every write
+// flushingWriter wraps a bufio.Writer and flushes after every write.
+// Writes are serialized since the SDK, middleware and heartbeat all send on it.
 type flushingWriter struct {
 	*bufio.Writer
+	mu sync.Mutex
 }
 
 func (fw *flushingWriter) Write(p []byte) (n int, err error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
 	n, err = fw.Writer.Write(p)
 	if err != nil {
 		return n, err
@@ -32,108 +38,389 @@ func (fw *flushingWriter) Write(p []byte) (n int, err error) {
 type ProtocolClient struct {
 	mu sync.Mutex
 
-	sessionID  acp.SessionId
-	conn       *acp.ClientSideConnection
-	tcpConn    net.Conn
-	tcpAddress string
-	cwd        string
-	logger     logger.Logger
+	sessionID acp.SessionId
+	conn      *acp.ClientSideConnection
+	transport io.ReadWriteCloser // TCP or Unix socket connection, or the agent process
+	address   string
+	cwd       string
+	logger    logger.Logger
+
+	mcpServers []acp.McpServer // Offered to the agent with each new or loaded session
+
+	transportName string                 // How the agent is reached, as ProtocolConfig.Transport
+	initResp      acp.InitializeResponse // What the agent answered to initialize
+	clientCaps    acp.ClientCapabilities // What the client advertised in initialize
+
+	onLifecycle    func(LifecycleEvent)       // Told about connection transitions (nil = nobody)
+	onSessionModes func(acp.SessionModeState) // Told the modes a session advertises (nil = nobody)
+
+	// ctx lives as long as the client; Close cancels it to abort in-flight
+	// extension requests
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Heartbeat state
+	middleware        *JSONRPCMiddleware
+	health            ConnectionHealth
+	stopHeartbeat     context.CancelFunc
+	heartbeatDone     chan struct{}
+	stopHeartbeatOnce sync.Once
+}
+
+// ConnectionHealth describes the liveness of the agent connection as seen by the heartbeat
+type ConnectionHealth struct {
+	Connected           bool          // False once the connection has closed
+	Monitored           bool          // Whether a heartbeat is running at all
+	LastPing            time.Time     // When the last successful ping completed
+	Latency             time.Duration // Round-trip time of the last successful ping
+	ConsecutiveFailures int           // Pings failed in a row since the last success
 }
 
 // ProtocolConfig contains configuration for creating a ProtocolClient
 type ProtocolConfig struct {
+	// Transport is one of TransportTCP (the default), TransportUnix or TransportStdio
+	Transport string
+	// Address is host:port for TCP or the socket path for Unix transports
 	Address string
+	// Command and Args launch the agent for the stdio transport
+	Command string
+	Args    []string
 	Logger  logger.Logger
 	// ACPClient is the acp.Client implementation that handles agent requests
 	ACPClient acp.Client
 	// ExtensionHandler handles custom extension methods (methods starting with _)
 	ExtensionHandler ExtensionMethodHandler
+	// HeartbeatInterval is how often the agent is pinged to check liveness (0 = disabled)
+	HeartbeatInterval time.Duration
+	// ConnectTimeout bounds each dial attempt (0 = DefaultConnectTimeout)
+	ConnectTimeout time.Duration
+	// ReadTimeout fails the connection when nothing arrives from the agent for
+	// this long while a reply to a request is awaited (0 = never). An idle
+	// connection never times out. During a long prompt turn the agent must send
+	// something, such as heartbeat responses, within it. TCP and Unix
+	// transports only.
+	ReadTimeout time.Duration
+	// WriteTimeout fails the connection when a write to the agent doesn't
+	// complete within this long (0 = never). TCP and Unix transports only.
+	WriteTimeout time.Duration
+	// MaxRetries is how many times a failed dial is retried (0 = a single attempt)
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled after each failure
+	// up to maxRetryBackoff (0 = DefaultRetryBackoff)
+	RetryBackoff time.Duration
+	// McpServers are the MCP servers the agent may use in the session
+	McpServers []acp.McpServer
+	// JSONEncoding is how sent extension responses appear in the debug log:
+	// JSONEncodingCompact (the default) or JSONEncodingIndent
+	JSONEncoding string
+	// Cwd is the session's working directory, also where a spawned agent runs.
+	// It must be an existing directory (empty = the process working directory).
+	Cwd string
+	// SessionID resumes a prior session with session/load when the agent supports it.
+	// Empty, or a failed load, starts a new session.
+	SessionID string
+	// Terminal advertises that the agent may run commands through the terminal methods
+	Terminal bool
+	// OnLifecycle is called on connect, session creation and disconnect (nil = not called)
+	OnLifecycle func(LifecycleEvent)
+	// OnSessionModes is called with the modes a new or loaded session advertises,
+	// if the agent has any (nil = not called)
+	OnSessionModes func(acp.SessionModeState)
 }
 
-// NewProtocolClient creates a new protocol client and establishes connection
-func NewProtocolClient(cfg ProtocolConfig) (*ProtocolClient, error) {
+// NewProtocolClient creates a new protocol client and establishes connection.
+// Dialing is retried per cfg.MaxRetries; ctx bounds the dial and the handshake.
+// With the stdio transport the agent is spawned instead and runs until Close.
+func NewProtocolClient(ctx context.Context, cfg ProtocolConfig) (*ProtocolClient, error) {
 	if cfg.Logger == nil {
 		cfg.Logger = logger.NewNoopLogger()
 	}
 
+	switch cfg.JSONEncoding {
+	case "", JSONEncodingCompact, JSONEncodingIndent:
+	default:
+		return nil, fmt.Errorf("unknown JSON encoding %q (want %s or %s)", cfg.JSONEncoding, JSONEncodingCompact, JSONEncodingIndent)
+	}
+
+	cwd, err := resolveCwd(cfg.Cwd)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Cwd = cwd
+
 	client := &ProtocolClient{
-		logger:     cfg.Logger,
-		tcpAddress: cfg.Address,
+		cwd:            cwd,
+		logger:         cfg.Logger,
+		address:        cfg.Address,
+		mcpServers:     cfg.McpServers,
+		onLifecycle:    cfg.OnLifecycle,
+		onSessionModes: cfg.OnSessionModes,
+		transportName:  cfg.Transport,
+		clientCaps:     clientCapabilities(cfg.Terminal),
+	}
+	if client.transportName == "" {
+		client.transportName = TransportTCP
 	}
+	if client.mcpServers == nil {
+		client.mcpServers = []acp.McpServer{}
+	}
+	client.ctx, client.cancel = context.WithCancel(context.Background())
 
-	cfg.Logger.Debug("Connecting to %s...", cfg.Address)
-	conn, err := net.Dial("tcp", cfg.Address)
+	conn, err := openTransport(ctx, client.ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to %s: %w", cfg.Address, err)
+		client.cancel()
+		return nil, err
 	}
-	cfg.Logger.Debug("TCP connected")
+	cfg.Logger.Debug("Transport connected")
 
-	client.tcpConn = conn
+	client.transport = conn
 
-	// Wrap TCP connection with buffered I/O for proper line-based communication
+	// Wrap the connection with buffered I/O for proper line-based communication
 	// Use auto-flushing writer to ensure messages are sent immediately
 	baseReader := bufio.NewReader(conn)
-	writer := &flushingWriter{bufio.NewWriter(conn)}
+	writer := &flushingWriter{Writer: bufio.NewWriter(conn)}
 
 	// Wrap reader with middleware to intercept extension method requests
-	ctx := context.Background()
-	reader := NewJSONRPCMiddleware(ctx, baseReader, writer, cfg.ExtensionHandler)
+	reader := NewJSONRPCMiddleware(client.ctx, baseReader, writer, cfg.ExtensionHandler)
+	reader.SetTrafficDump(cfg.Logger, cfg.JSONEncoding)
+	client.middleware = reader
 
-	client.conn = acp.NewClientSideConnection(cfg.ACPClient, writer, reader)
+	// Session updates are counted so a prompt can wait for its last chunks
+	acpClient := trackingClient{Client: cfg.ACPClient, updates: reader.updates}
+	client.conn = acp.NewClientSideConnection(acpClient, writer, reader)
 
 	cfg.Logger.Debug("Initializing ACP connection...")
-	_, err = client.conn.Initialize(ctx, acp.InitializeRequest{
-		ProtocolVersion: acp.ProtocolVersionNumber,
-		ClientCapabilities: acp.ClientCapabilities{
-			Fs:       acp.FileSystemCapability{ReadTextFile: true, WriteTextFile: true},
-			Terminal: false,
-		},
+	replied := client.awaitReply()
+	initResp, err := client.conn.Initialize(ctx, acp.InitializeRequest{
+		ProtocolVersion:    acp.ProtocolVersionNumber,
+		ClientCapabilities: client.clientCaps,
 	})
+	replied()
 	if err != nil {
 		client.Close()
 		return nil, fmt.Errorf("failed to initialize: %w", err)
 	}
+	client.initResp = initResp
 	cfg.Logger.Debug("ACP initialized")
+	client.emitLifecycle(LifecycleEvent{Kind: LifecycleConnected})
+
+	cfg.Logger.Debug("Working directory: %s", cwd)
 
-	// Determine working directory
-	cwd, err := os.Getwd()
+	// Resume the requested session if the agent can load it, otherwise start a new one
+	if cfg.SessionID != "" {
+		if !initResp.AgentCapabilities.LoadSession {
+			cfg.Logger.Warn("Agent can't load sessions, starting a new one instead of %s", cfg.SessionID)
+		} else if err := client.loadSession(ctx, acp.SessionId(cfg.SessionID)); err != nil {
+			cfg.Logger.Warn("Failed to resume session %s, starting a new one: %v", cfg.SessionID, err)
+		}
+	}
+	if client.sessionID == "" {
+		if err := client.newSession(ctx); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	client.health.Connected = true
+	go client.watchConnection()
+	if cfg.HeartbeatInterval > 0 {
+		client.startHeartbeat(cfg.HeartbeatInterval)
+	}
+
+	return client, nil
+}
+
+// resolveCwd makes dir absolute after checking it is an existing directory.
+// An empty dir means the process working directory.
+func resolveCwd(dir string) (string, error) {
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			cwd = "."
+		}
+		if absCwd, err := filepath.Abs(cwd); err == nil {
+			cwd = absCwd
+		}
+		return cwd, nil
+	}
+
+	absDir, err := filepath.Abs(dir)
 	if err != nil {
-		cwd = "."
+		return "", fmt.Errorf("invalid working directory %s: %w", dir, err)
 	}
-	if absCwd, err := filepath.Abs(cwd); err == nil {
-		cwd = absCwd
+	info, err := os.Stat(absDir)
+	if err != nil {
+		return "", fmt.Errorf("invalid working directory: %w", err)
 	}
-	client.cwd = cwd
-	cfg.Logger.Debug("Working directory: %s", cwd)
+	if !info.IsDir() {
+		return "", fmt.Errorf("invalid working directory %s: not a directory", absDir)
+	}
+	return absDir, nil
+}
 
-	// Create new session
-	cfg.Logger.Debug("Creating new session...")
-	sessionResp, err := client.conn.NewSession(ctx, acp.NewSessionRequest{
-		Cwd:        cwd,
-		McpServers: []acp.McpServer{},
+// newSession creates a new session in the working directory
+func (p *ProtocolClient) newSession(ctx context.Context) error {
+	p.logger.Debug("Creating new session with %d MCP servers...", len(p.mcpServers))
+	replied := p.awaitReply()
+	sessionResp, err := p.conn.NewSession(ctx, acp.NewSessionRequest{
+		Cwd:        p.cwd,
+		McpServers: p.mcpServers,
 	})
+	replied()
 	if err != nil {
-		client.Close()
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	p.mu.Lock()
+	p.sessionID = sessionResp.SessionId
+	p.mu.Unlock()
+	p.logger.Debug("Session created: %s", sessionResp.SessionId)
+	p.reportModes(sessionResp.Modes)
+	p.emitLifecycle(LifecycleEvent{Kind: LifecycleSessionCreated})
+	return nil
+}
+
+// loadSession resumes a prior session. The agent replays its history as
+// session updates before responding.
+func (p *ProtocolClient) loadSession(ctx context.Context, sessionID acp.SessionId) error {
+	p.logger.Debug("Loading session %s...", sessionID)
+	replied := p.awaitReply()
+	loadResp, err := p.conn.LoadSession(ctx, acp.LoadSessionRequest{
+		SessionId:  sessionID,
+		Cwd:        p.cwd,
+		McpServers: p.mcpServers,
+	})
+	replied()
+	if err != nil {
+		return fmt.Errorf("failed to load session: %w", err)
 	}
-	client.sessionID = sessionResp.SessionId
-	cfg.Logger.Debug("Session created: %s", sessionResp.SessionId)
+	p.mu.Lock()
+	p.sessionID = sessionID
+	p.mu.Unlock()
+	p.logger.Debug("Session resumed: %s", sessionID)
+	p.reportModes(loadResp.Modes)
+	p.emitLifecycle(LifecycleEvent{Kind: LifecycleSessionCreated, Resumed: true})
+	return nil
+}
 
-	return client, nil
+// reportModes passes the modes a session advertised to the configured callback
+func (p *ProtocolClient) reportModes(modes *acp.SessionModeState) {
+	if modes != nil && len(modes.AvailableModes) > 0 && p.onSessionModes != nil {
+		p.onSessionModes(*modes)
+	}
 }
 
-// SendPrompt sends a prompt to the agent
+// SessionID returns the ID of the current session
+func (p *ProtocolClient) SessionID() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return string(p.sessionID)
+}
+
+// startHeartbeat pings the agent every interval until Close or the connection drops
+func (p *ProtocolClient) startHeartbeat(interval time.Duration) {
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.stopHeartbeat = cancel
+	p.heartbeatDone = make(chan struct{})
+	p.health.Monitored = true
+
+	p.logger.Debug("Starting heartbeat every %v", interval)
+	go p.runHeartbeat(ctx, interval)
+}
+
+// runHeartbeat is the heartbeat loop; each ping may take at most one interval
+func (p *ProtocolClient) runHeartbeat(ctx context.Context, interval time.Duration) {
+	defer close(p.heartbeatDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.conn.Done():
+			p.mu.Lock()
+			p.health.Connected = false
+			p.mu.Unlock()
+			p.logger.Warn("Connection to agent closed")
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, interval)
+			start := time.Now()
+			replied := p.awaitReply()
+			err := p.middleware.Ping(pingCtx)
+			replied()
+			cancel()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			p.mu.Lock()
+			if err != nil {
+				p.health.ConsecutiveFailures++
+			} else {
+				p.health.LastPing = time.Now()
+				p.health.Latency = time.Since(start)
+				p.health.ConsecutiveFailures = 0
+			}
+			failures := p.health.ConsecutiveFailures
+			p.mu.Unlock()
+
+			if err != nil {
+				p.logger.Warn("Heartbeat failed (%d in a row): %v", failures, err)
+			}
+		}
+	}
+}
+
+// awaitReply starts the read timeout, if there is one, for a request to the
+// agent; the returned function stops it once the reply is in
+func (p *ProtocolClient) awaitReply() func() {
+	if conn, ok := p.transport.(*deadlineConn); ok {
+		return conn.awaitReply()
+	}
+	return func() {}
+}
+
+// Health returns the current connection health
+func (p *ProtocolClient) Health() ConnectionHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.health
+}
+
+// SendPrompt sends a prompt to the agent. It returns once the turn has ended and
+// the updates streamed during it have been handled, so the response is complete.
 func (p *ProtocolClient) SendPrompt(ctx context.Context, prompt string) error {
 	p.mu.Lock()
 	sessionID := p.sessionID
 	p.mu.Unlock()
 
 	p.logger.Info("Sending prompt: %s", prompt)
-	_, err := p.conn.Prompt(ctx, acp.PromptRequest{
+	replied := p.awaitReply()
+	resp, err := p.conn.Prompt(ctx, acp.PromptRequest{
 		SessionId: sessionID,
 		Prompt:    []acp.ContentBlock{acp.TextBlock(prompt)},
 	})
+	replied()
 
+	// File operations the agent started for a cancelled prompt are no longer wanted
+	if ctx.Err() != nil {
+		p.logger.Info("Prompt cancelled, cancelling in-flight extension requests")
+		p.middleware.CancelAllRequests()
+		return err
+	}
+
+	// The agent streamed the turn before answering, but those updates may still be
+	// on their way to the handler; the turn is over only once they have arrived.
+	// A failed turn waits too, so what the agent wrote before failing is kept.
+	if err == nil && resp.StopReason != acp.StopReasonEndTurn {
+		p.logger.Info("Agent stopped early: %s", resp.StopReason)
+	}
+	if !p.middleware.updates.waitHandled(ctx, p.middleware.updates.readCount(), updateDrainTimeout) {
+		p.logger.Warn("Gave up waiting for the rest of the response after %v", updateDrainTimeout)
+	}
 	return err
 }
 
@@ -142,10 +429,20 @@ func (p *ProtocolClient) GetCwd() string {
 	return p.cwd
 }
 
-// Close closes the protocol client and TCP connection
+// Close cancels in-flight extension requests, stops the heartbeat and closes
+// the connection, stopping the agent process for the stdio transport
 func (p *ProtocolClient) Close() error {
-	if p.tcpConn != nil {
-		return p.tcpConn.Close()
+	p.cancel()
+
+	if p.stopHeartbeat != nil {
+		p.stopHeartbeatOnce.Do(func() {
+			p.stopHeartbeat()
+			<-p.heartbeatDone
+		})
+	}
+
+	if p.transport != nil {
+		return p.transport.Close()
 	}
 	return nil
 }
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ron/tui_acp/tui/client/clienttest"
+)
+
+func TestResolveCwd(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"file.txt": "", "sub/keep": ""})
+	t.Chdir(dir)
+
+	tests := []struct {
+		name    string
+		dir     string
+		want    string
+		wantErr string
+	}{
+		{name: "unset", dir: "", want: dir},
+		{name: "absolute", dir: filepath.Join(dir, "sub"), want: filepath.Join(dir, "sub")},
+		{name: "relative", dir: "sub", want: filepath.Join(dir, "sub")},
+		{name: "missing", dir: "missing", wantErr: "invalid working directory"},
+		{name: "file", dir: "file.txt", wantErr: "not a directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveCwd(tt.dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveCwd(%q): %v", tt.dir, err)
+			}
+			// The temporary directory may be reached through a symlink
+			if wantInfo, err := os.Stat(tt.want); err != nil || !sameDir(t, got, wantInfo) {
+				t.Errorf("resolveCwd(%q) = %q, want %q", tt.dir, got, tt.want)
+			}
+			if !filepath.IsAbs(got) {
+				t.Errorf("resolveCwd(%q) = %q, not absolute", tt.dir, got)
+			}
+		})
+	}
+}
+
+// sameDir reports whether path is the directory described by want
+func sameDir(t *testing.T, path string, want os.FileInfo) bool {
+	t.Helper()
+	info, err := os.Stat(path)
+	return err == nil && os.SameFile(info, want)
+}
+
+func TestCwdOverride(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"project/notes.txt": "from the project"})
+	t.Chdir(dir)
+
+	agent := &clienttest.Agent{}
+	c := connect(t, agent, Config{Cwd: "project"})
+
+	// The session and the agent's file access both use the absolute directory
+	want := filepath.Join(dir, "project")
+	if news := agent.NewSessions(); len(news) != 1 || news[0].Cwd != want {
+		t.Errorf("new sessions = %+v, want one in %s", news, want)
+	}
+	if got := c.fs.ResolvePath("notes.txt"); got != filepath.Join(want, "notes.txt") {
+		t.Errorf("notes.txt resolves to %s, want it in %s", got, want)
+	}
+}
+
+func TestCwdOverrideRejectsMissingDirectory(t *testing.T) {
+	_, err := NewACPClient(context.Background(), Config{
+		Address: freeAddress(t),
+		Cwd:     filepath.Join(t.TempDir(), "missing"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid working directory") {
+		t.Errorf("err = %v, want an invalid working directory", err)
+	}
+}
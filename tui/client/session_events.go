@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	acp "github.com/coder/acp-go-sdk"
+)
+
+// PlanEntry is one task in the plan an agent reports
+type PlanEntry struct {
+	Content  string
+	Priority string // high, medium or low
+	Status   string // pending, in_progress or completed
+}
+
+// ToolCall is the state of a tool call an agent reports, merged across its updates
+type ToolCall struct {
+	ID       string
+	Title    string
+	Kind     string
+	Status   string   // pending, in_progress, completed or failed
+	Statuses []string // Every status the call has had, in order
+	Updates  int      // Updates merged in since the call was first reported (0 = first report)
+	Input    interface{}
+	Output   interface{} // Raw output, or the text of the call's content when there is none
+}
+
+// Done reports whether the tool call has finished, successfully or not
+func (t ToolCall) Done() bool {
+	return t.Status == string(acp.ToolCallStatusCompleted) || t.Status == string(acp.ToolCallStatusFailed)
+}
+
+// Failed reports whether the tool call finished with an error
+func (t ToolCall) Failed() bool {
+	return t.Status == string(acp.ToolCallStatusFailed)
+}
+
+// PlanHandler is implemented by message handlers that show the agent's plan.
+// Each call carries the whole plan, replacing the previous one.
+type PlanHandler interface {
+	OnPlan(ctx context.Context, entries []PlanEntry) error
+}
+
+// ToolCallHandler is implemented by message handlers that follow the tool calls
+// an agent runs itself, called when a call starts and whenever it changes
+type ToolCallHandler interface {
+	OnToolCall(ctx context.Context, call ToolCall) error
+}
+
+// handlePlan forwards a plan update
+func (c *CapabilityHandler) handlePlan(ctx context.Context, plan *acp.SessionUpdatePlan) error {
+	entries := make([]PlanEntry, 0, len(plan.Entries))
+	for _, entry := range plan.Entries {
+		entries = append(entries, PlanEntry{
+			Content:  entry.Content,
+			Priority: string(entry.Priority),
+			Status:   string(entry.Status),
+		})
+	}
+
+	if ph, ok := c.handler.(PlanHandler); ok {
+		return ph.OnPlan(ctx, entries)
+	}
+	return nil
+}
+
+// handleToolCall records a newly reported tool call and forwards it
+func (c *CapabilityHandler) handleToolCall(ctx context.Context, update *acp.SessionUpdateToolCall) error {
+	status := string(update.Status)
+	if status == "" {
+		status = string(acp.ToolCallStatusPending)
+	}
+	call := ToolCall{
+		ID:       string(update.ToolCallId),
+		Title:    update.Title,
+		Kind:     string(update.Kind),
+		Status:   status,
+		Statuses: []string{status},
+		Input:    update.RawInput,
+		Output:   toolCallOutput(update.RawOutput, update.Content),
+	}
+
+	c.toolCallsMu.Lock()
+	if !call.Done() {
+		c.toolCalls[call.ID] = call
+	}
+	c.toolCallsMu.Unlock()
+
+	return c.forwardToolCall(ctx, call)
+}
+
+// handleToolCallUpdate merges an update into its tool call and forwards the result.
+// The call is forgotten once it is done.
+func (c *CapabilityHandler) handleToolCallUpdate(ctx context.Context, update *acp.SessionToolCallUpdate) error {
+	id := string(update.ToolCallId)
+
+	c.toolCallsMu.Lock()
+	call, ok := c.toolCalls[id]
+	if ok {
+		call.Updates++
+	} else {
+		// An update for a call we never saw starts its record
+		call = ToolCall{ID: id, Title: id}
+	}
+	if update.Title != nil {
+		call.Title = *update.Title
+	}
+	if update.Kind != nil {
+		call.Kind = string(*update.Kind)
+	}
+	if update.RawInput != nil {
+		call.Input = update.RawInput
+	}
+	if output := toolCallOutput(update.RawOutput, update.Content); output != nil {
+		call.Output = output
+	}
+	if update.Status != nil && string(*update.Status) != call.Status {
+		call.Status = string(*update.Status)
+		call.Statuses = append(append([]string(nil), call.Statuses...), call.Status)
+	}
+	if call.Done() {
+		delete(c.toolCalls, id)
+	} else {
+		c.toolCalls[id] = call
+	}
+	c.toolCallsMu.Unlock()
+
+	return c.forwardToolCall(ctx, call)
+}
+
+// forwardToolCall passes a tool call to the handler if it follows them
+func (c *CapabilityHandler) forwardToolCall(ctx context.Context, call ToolCall) error {
+	c.logger.Debug("Tool call %s (%s): %s", call.ID, call.Title, call.Status)
+	if th, ok := c.handler.(ToolCallHandler); ok {
+		return th.OnToolCall(ctx, call)
+	}
+	return nil
+}
+
+// toolCallOutput picks what to show as a tool call's result: its raw output, or
+// else the text of its content, with diffs and terminals named
+func toolCallOutput(rawOutput interface{}, content []acp.ToolCallContent) interface{} {
+	if rawOutput != nil {
+		return rawOutput
+	}
+	if len(content) == 0 {
+		return nil
+	}
+
+	parts := make([]string, 0, len(content))
+	for _, item := range content {
+		switch {
+		case item.Content != nil && item.Content.Content.Text != nil:
+			parts = append(parts, item.Content.Content.Text.Text)
+		case item.Content != nil:
+			if description, ok := describeContent(&item.Content.Content); ok {
+				parts = append(parts, description)
+			}
+		case item.Diff != nil:
+			parts = append(parts, fmt.Sprintf("[diff: %s]", item.Diff.Path))
+		case item.Terminal != nil:
+			parts = append(parts, fmt.Sprintf("[terminal: %s]", item.Terminal.TerminalId))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
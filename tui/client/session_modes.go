@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	acp "github.com/coder/acp-go-sdk"
+)
+
+// SessionMode is a mode the agent can work in, such as "ask" or "code"
+type SessionMode struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+// ModeHandler is implemented by message handlers that show the agent's session
+// modes. It is called when a session advertises its modes and whenever the
+// current mode changes. Agents without modes never call it.
+type ModeHandler interface {
+	OnModeChange(ctx context.Context, modes []SessionMode, current string) error
+}
+
+// sessionModes is the mode state of the current session
+type sessionModes struct {
+	mu        sync.Mutex
+	available []SessionMode
+	current   string // ID of the current mode
+}
+
+// setSessionModes records the modes a new or loaded session advertises
+func (c *CapabilityHandler) setSessionModes(state acp.SessionModeState) {
+	modes := make([]SessionMode, 0, len(state.AvailableModes))
+	for _, mode := range state.AvailableModes {
+		m := SessionMode{ID: string(mode.Id), Name: mode.Name}
+		if mode.Description != nil {
+			m.Description = *mode.Description
+		}
+		modes = append(modes, m)
+	}
+
+	c.modes.mu.Lock()
+	c.modes.available = modes
+	c.modes.current = string(state.CurrentModeId)
+	c.modes.mu.Unlock()
+
+	c.logger.Debug("Session has %d modes, current %s", len(modes), state.CurrentModeId)
+	c.forwardModes(context.Background())
+}
+
+// setCurrentMode records a mode change, by the agent or on request, and forwards it
+func (c *CapabilityHandler) setCurrentMode(ctx context.Context, id string) error {
+	c.modes.mu.Lock()
+	c.modes.current = id
+	c.modes.mu.Unlock()
+
+	c.logger.Info("Session mode is now %s", id)
+	return c.forwardModes(ctx)
+}
+
+// Modes returns the session's modes and the ID of the current one, or nothing
+// when the agent has no modes
+func (c *CapabilityHandler) Modes() ([]SessionMode, string) {
+	c.modes.mu.Lock()
+	defer c.modes.mu.Unlock()
+	return append([]SessionMode(nil), c.modes.available...), c.modes.current
+}
+
+// forwardModes passes the mode state to the handler if it follows modes
+func (c *CapabilityHandler) forwardModes(ctx context.Context) error {
+	mh, ok := c.handler.(ModeHandler)
+	if !ok {
+		return nil
+	}
+	modes, current := c.Modes()
+	return mh.OnModeChange(ctx, modes, current)
+}
+
+// SetSessionMode asks the agent to switch the current session to a mode
+func (p *ProtocolClient) SetSessionMode(ctx context.Context, id string) error {
+	replied := p.awaitReply()
+	_, err := p.conn.SetSessionMode(ctx, acp.SetSessionModeRequest{
+		SessionId: acp.SessionId(p.SessionID()),
+		ModeId:    acp.SessionModeId(id),
+	})
+	replied()
+	if err != nil {
+		return fmt.Errorf("failed to set session mode: %w", err)
+	}
+	return nil
+}
+
+// Modes returns the session's modes and the ID of the current one
+func (c *ACPClient) Modes() ([]SessionMode, string) {
+	return c.capability.Modes()
+}
+
+// SetMode switches the session to the mode with the given ID, which must be one
+// the agent advertised
+func (c *ACPClient) SetMode(ctx context.Context, id string) error {
+	modes, _ := c.capability.Modes()
+	if len(modes) == 0 {
+		return fmt.Errorf("the agent has no session modes")
+	}
+	known := false
+	for _, mode := range modes {
+		known = known || mode.ID == id
+	}
+	if !known {
+		return fmt.Errorf("unknown session mode %q", id)
+	}
+
+	if err := c.protocol.SetSessionMode(ctx, id); err != nil {
+		return err
+	}
+	return c.capability.setCurrentMode(ctx, id)
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	acp "github.com/coder/acp-go-sdk"
+)
+
+// updateDrainTimeout bounds the wait for session updates still being handled when
+// a prompt returns, in case one never reaches the client (e.g. it failed to decode)
+const updateDrainTimeout = 2 * time.Second
+
+// updateTracker counts session updates read off the connection and handled by the
+// client. The SDK handles each notification on its own goroutine, so the response
+// to a prompt can overtake the updates the agent streamed before it, and one
+// update can overtake another unless each waits its turn (see awaitTurn).
+type updateTracker struct {
+	mu      sync.Mutex
+	read    int64
+	handled int64
+	changed chan struct{} // Closed and replaced whenever handled grows
+}
+
+func newUpdateTracker() *updateTracker {
+	return &updateTracker{changed: make(chan struct{})}
+}
+
+// markRead records an update passed on to the SDK
+func (t *updateTracker) markRead() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.read++
+}
+
+// markHandled records an update the client finished handling
+func (t *updateTracker) markHandled() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handled++
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// readCount returns how many updates have been read so far
+func (t *updateTracker) readCount() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.read
+}
+
+// awaitTurn waits until every update read so far has been handled, so the next
+// one can't be handled alongside them and streamed text keeps its order. An
+// update that never reaches the client, such as one that failed to decode, is
+// given up on after timeout rather than holding back the rest.
+func (t *updateTracker) awaitTurn(ctx context.Context, timeout time.Duration) {
+	if t.waitHandled(ctx, t.readCount(), timeout) || ctx.Err() != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handled = max(t.handled, t.read)
+}
+
+// waitHandled waits until target updates have been handled. It reports false if
+// ctx ends or timeout passes first.
+func (t *updateTracker) waitHandled(ctx context.Context, target int64, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		t.mu.Lock()
+		handled, changed := t.handled, t.changed
+		t.mu.Unlock()
+		if handled >= target {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// trackingClient counts the session updates its acp.Client has handled
+type trackingClient struct {
+	acp.Client
+	updates *updateTracker
+}
+
+// SessionUpdate handles the update, then marks it handled
+func (c trackingClient) SessionUpdate(ctx context.Context, n acp.SessionNotification) error {
+	defer c.updates.markHandled()
+	return c.Client.SessionUpdate(ctx, n)
+}
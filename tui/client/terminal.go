@@ -0,0 +1,247 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	acp "github.com/coder/acp-go-sdk"
+)
+
+// DefaultTerminalOutputBytes is how much output a terminal keeps when the agent
+// sets no limit
+const DefaultTerminalOutputBytes = 1024 * 1024
+
+// terminalMethod is the method name terminal commands are shown under
+const terminalMethod = "terminal"
+
+// terminalWaitDelay is how long a command's output is still read after it
+// exits. Something it left running in the background may hold the output
+// open; the command is reported as exited after this long regardless.
+const terminalWaitDelay = 2 * time.Second
+
+// terminal is a command the agent started, with its buffered output
+type terminal struct {
+	cmd  *exec.Cmd
+	done chan struct{} // Closed once the command has exited and exit is set
+
+	mu        sync.Mutex
+	output    []byte
+	limit     int
+	truncated bool
+	exit      *acp.TerminalExitStatus
+}
+
+// Write buffers command output, dropping the oldest bytes beyond the limit.
+// The cut is moved forward to a character boundary so the output stays valid UTF-8.
+func (t *terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.output = append(t.output, p...)
+	if over := len(t.output) - t.limit; over > 0 {
+		for over < len(t.output) && !utf8.RuneStart(t.output[over]) {
+			over++
+		}
+		t.output = append(t.output[:0], t.output[over:]...)
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+// snapshot returns the output so far and the exit status, nil while running
+func (t *terminal) snapshot() (output string, truncated bool, exit *acp.TerminalExitStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.output), t.truncated, t.exit
+}
+
+// wait records the command's exit status once it finishes
+func (t *terminal) wait() {
+	err := t.cmd.Wait()
+
+	status := &acp.TerminalExitStatus{}
+	if state := t.cmd.ProcessState; state != nil {
+		if signal := exitSignal(state); signal != "" {
+			status.Signal = &signal
+		} else {
+			code := state.ExitCode()
+			status.ExitCode = &code
+		}
+	} else if err != nil {
+		code := -1
+		status.ExitCode = &code
+	}
+
+	t.mu.Lock()
+	t.exit = status
+	t.mu.Unlock()
+	close(t.done)
+}
+
+// kill stops the command, and anything it started, if it is still running
+func (t *terminal) kill() {
+	select {
+	case <-t.done:
+	default:
+		killProcessGroup(t.cmd)
+	}
+}
+
+// SetTerminalEnabled controls whether the agent may run commands through the
+// terminal methods. When disabled they fail as unsupported.
+func (c *CapabilityHandler) SetTerminalEnabled(enabled bool) {
+	c.terminalEnabled = enabled
+}
+
+// CreateTerminal starts a command for the agent. Its working directory must lie
+// inside the session's working directory.
+func (c *CapabilityHandler) CreateTerminal(ctx context.Context, p acp.CreateTerminalRequest) (acp.CreateTerminalResponse, error) {
+	if !c.terminalEnabled {
+		return acp.CreateTerminalResponse{}, unsupportedMethodError("CreateTerminal")
+	}
+
+	dir := c.fs.ResolvePath(".")
+	if p.Cwd != nil && *p.Cwd != "" {
+		dir = c.fs.ResolvePath(*p.Cwd)
+	}
+	if !c.fs.IsWithinCwd(dir) {
+		c.logger.Warn("Rejected terminal in %s: outside the working directory", dir)
+		return acp.CreateTerminalResponse{}, fmt.Errorf("terminal directory %s is outside the working directory", dir)
+	}
+
+	limit := DefaultTerminalOutputBytes
+	if p.OutputByteLimit != nil && *p.OutputByteLimit > 0 {
+		limit = *p.OutputByteLimit
+	}
+
+	cmd := exec.Command(p.Command, p.Args...)
+	cmd.Dir = dir
+	cmd.WaitDelay = terminalWaitDelay
+	startOwnProcessGroup(cmd)
+	cmd.Env = os.Environ()
+	for _, env := range p.Env {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+	t := &terminal{cmd: cmd, done: make(chan struct{}), limit: limit}
+	cmd.Stdout = t
+	cmd.Stderr = t
+
+	c.logger.Info("Starting terminal command %s %v in %s", p.Command, p.Args, dir)
+	if err := cmd.Start(); err != nil {
+		return acp.CreateTerminalResponse{}, fmt.Errorf("failed to start %s: %w", p.Command, err)
+	}
+
+	c.terminalsMu.Lock()
+	c.nextTerminalID++
+	id := fmt.Sprintf("term-%d", c.nextTerminalID)
+	c.terminals[id] = t
+	c.terminalsMu.Unlock()
+
+	params := map[string]interface{}{"command": p.Command, "args": p.Args, "cwd": dir}
+	if th, ok := c.handler.(ToolMessageHandler); ok {
+		th.OnToolInput(ctx, terminalMethod, params)
+	}
+	go func() {
+		t.wait()
+		c.reportTerminalExit(t)
+	}()
+
+	return acp.CreateTerminalResponse{TerminalId: id}, nil
+}
+
+// reportTerminalExit shows a finished command's output
+func (c *CapabilityHandler) reportTerminalExit(t *terminal) {
+	th, ok := c.handler.(ToolMessageHandler)
+	if !ok {
+		return
+	}
+	output, truncated, exit := t.snapshot()
+	result := map[string]interface{}{"output": output, "truncated": truncated}
+	if exit.ExitCode != nil {
+		result["exitCode"] = *exit.ExitCode
+	}
+	if exit.Signal != nil {
+		result["signal"] = *exit.Signal
+	}
+	th.OnToolOutput(context.Background(), terminalMethod, result, nil)
+}
+
+// terminal looks up a terminal the agent created
+func (c *CapabilityHandler) terminal(id string) (*terminal, error) {
+	if !c.terminalEnabled {
+		return nil, fmt.Errorf("terminal not supported in this client")
+	}
+	c.terminalsMu.Lock()
+	defer c.terminalsMu.Unlock()
+	t, ok := c.terminals[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown terminal %s", id)
+	}
+	return t, nil
+}
+
+// TerminalOutput returns a terminal's output so far, and its exit status once it has exited
+func (c *CapabilityHandler) TerminalOutput(ctx context.Context, p acp.TerminalOutputRequest) (acp.TerminalOutputResponse, error) {
+	t, err := c.terminal(p.TerminalId)
+	if err != nil {
+		return acp.TerminalOutputResponse{}, err
+	}
+	output, truncated, exit := t.snapshot()
+	return acp.TerminalOutputResponse{Output: output, Truncated: truncated, ExitStatus: exit}, nil
+}
+
+// WaitForTerminalExit blocks until a terminal's command exits
+func (c *CapabilityHandler) WaitForTerminalExit(ctx context.Context, p acp.WaitForTerminalExitRequest) (acp.WaitForTerminalExitResponse, error) {
+	t, err := c.terminal(p.TerminalId)
+	if err != nil {
+		return acp.WaitForTerminalExitResponse{}, err
+	}
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return acp.WaitForTerminalExitResponse{}, ctx.Err()
+	}
+	_, _, exit := t.snapshot()
+	return acp.WaitForTerminalExitResponse{ExitCode: exit.ExitCode, Signal: exit.Signal}, nil
+}
+
+// KillTerminalCommand stops a terminal's command, keeping the terminal so its
+// output can still be read
+func (c *CapabilityHandler) KillTerminalCommand(ctx context.Context, p acp.KillTerminalCommandRequest) (acp.KillTerminalCommandResponse, error) {
+	t, err := c.terminal(p.TerminalId)
+	if err != nil {
+		return acp.KillTerminalCommandResponse{}, err
+	}
+	c.logger.Info("Killing terminal %s", p.TerminalId)
+	t.kill()
+	return acp.KillTerminalCommandResponse{}, nil
+}
+
+// ReleaseTerminal stops a terminal's command if it is running and forgets the terminal
+func (c *CapabilityHandler) ReleaseTerminal(ctx context.Context, p acp.ReleaseTerminalRequest) (acp.ReleaseTerminalResponse, error) {
+	t, err := c.terminal(p.TerminalId)
+	if err != nil {
+		return acp.ReleaseTerminalResponse{}, err
+	}
+	t.kill()
+
+	c.terminalsMu.Lock()
+	delete(c.terminals, p.TerminalId)
+	c.terminalsMu.Unlock()
+	return acp.ReleaseTerminalResponse{}, nil
+}
+
+// ReleaseTerminals stops every command the agent left running
+func (c *CapabilityHandler) ReleaseTerminals() {
+	c.terminalsMu.Lock()
+	defer c.terminalsMu.Unlock()
+	for id, t := range c.terminals {
+		t.kill()
+		delete(c.terminals, id)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package client
+
+import (
+	"os"
+	"os/exec"
+)
+
+// exitSignal returns "", since processes aren't ended by signals on this platform
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
+
+// startOwnProcessGroup does nothing, since process groups are a Unix feature
+func startOwnProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills cmd; processes it started are left running
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
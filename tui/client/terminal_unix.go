@@ -0,0 +1,31 @@
+//go:build unix
+
+package client
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// exitSignal returns the name of the signal that ended a process, or "" if it exited
+func exitSignal(state *os.ProcessState) string {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal().String()
+	}
+	return ""
+}
+
+// startOwnProcessGroup makes cmd lead a process group of its own, so whatever
+// it starts can be killed along with it
+func startOwnProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills cmd and every process left in its group
+func killProcessGroup(cmd *exec.Cmd) error {
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+//go:build unix
+
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	acp "github.com/coder/acp-go-sdk"
+	"github.com/ron/tui_acp/tui/client/clienttest"
+)
+
+// runInTerminal returns a prompt handler that runs command in a terminal,
+// waits for it and reports what it printed, or the error, through result
+func runInTerminal(command string, args []string, result chan<- string) clienttest.PromptFunc {
+	return func(ctx context.Context, conn *acp.AgentSideConnection, p acp.PromptRequest) (acp.PromptResponse, error) {
+		end := acp.PromptResponse{StopReason: acp.StopReasonEndTurn}
+		created, err := conn.CreateTerminal(ctx, acp.CreateTerminalRequest{SessionId: p.SessionId, Command: command, Args: args})
+		if err != nil {
+			result <- "error: " + err.Error()
+			return end, nil
+		}
+		id := created.TerminalId
+		exit, err := conn.WaitForTerminalExit(ctx, acp.WaitForTerminalExitRequest{SessionId: p.SessionId, TerminalId: id})
+		if err != nil {
+			result <- "error: " + err.Error()
+			return end, nil
+		}
+		output, err := conn.TerminalOutput(ctx, acp.TerminalOutputRequest{SessionId: p.SessionId, TerminalId: id})
+		if err != nil {
+			result <- "error: " + err.Error()
+			return end, nil
+		}
+		if _, err := conn.ReleaseTerminal(ctx, acp.ReleaseTerminalRequest{SessionId: p.SessionId, TerminalId: id}); err != nil {
+			result <- "error: " + err.Error()
+			return end, nil
+		}
+		if exit.ExitCode == nil || output.ExitStatus == nil || *output.ExitStatus.ExitCode != *exit.ExitCode {
+			result <- "error: exit status missing"
+			return end, nil
+		}
+		result <- output.Output
+		return end, nil
+	}
+}
+
+func TestTerminalEchoEndToEnd(t *testing.T) {
+	result := make(chan string, 1)
+	agent := &clienttest.Agent{OnPrompt: runInTerminal("echo", []string{"hello"}, result)}
+	c := connect(t, agent, Config{EnableTerminal: true})
+
+	if err := c.SendPrompt(context.Background(), "say hello"); err != nil {
+		t.Fatalf("prompt: %v", err)
+	}
+	if got := <-result; got != "hello\n" {
+		t.Errorf("agent read %q, want %q", got, "hello\n")
+	}
+	c.capability.terminalsMu.Lock()
+	defer c.capability.terminalsMu.Unlock()
+	if left := len(c.capability.terminals); left != 0 {
+		t.Errorf("%d terminals left after release", left)
+	}
+}
+
+func TestTerminalDisabled(t *testing.T) {
+	result := make(chan string, 1)
+	agent := &clienttest.Agent{OnPrompt: runInTerminal("echo", []string{"hello"}, result)}
+	c := connect(t, agent, Config{})
+
+	if err := c.SendPrompt(context.Background(), "say hello"); err != nil {
+		t.Fatalf("prompt: %v", err)
+	}
+	if got := <-result; !strings.HasPrefix(got, "error:") {
+		t.Errorf("agent read %q, want an error", got)
+	}
+}
+
+// newTerminalHandler returns a capability handler with terminals enabled in a
+// fresh working directory
+func newTerminalHandler(t *testing.T) *CapabilityHandler {
+	t.Helper()
+	c := NewCapabilityHandler(NewFileSystemAdapter(t.TempDir(), nil), nil, nil)
+	c.SetTerminalEnabled(true)
+	t.Cleanup(c.ReleaseTerminals)
+	return c
+}
+
+func TestTerminalStaysInWorkingDirectory(t *testing.T) {
+	c := newTerminalHandler(t)
+	ctx := context.Background()
+
+	_, err := c.CreateTerminal(ctx, acp.CreateTerminalRequest{Command: "pwd", Cwd: acp.Ptr("..")})
+	if err == nil || !strings.Contains(err.Error(), "outside the working directory") {
+		t.Errorf("err = %v, want the directory rejected", err)
+	}
+}
+
+func TestTerminalKill(t *testing.T) {
+	c := newTerminalHandler(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	created, err := c.CreateTerminal(ctx, acp.CreateTerminalRequest{Command: "sleep", Args: []string{"30"}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	id := created.TerminalId
+	if _, err := c.KillTerminalCommand(ctx, acp.KillTerminalCommandRequest{TerminalId: id}); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	exit, err := c.WaitForTerminalExit(ctx, acp.WaitForTerminalExitRequest{TerminalId: id})
+	if err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if exit.Signal == nil {
+		t.Errorf("exit = %+v, want the command killed by a signal", exit)
+	}
+
+	// A killed terminal can still be read until it is released
+	if _, err := c.TerminalOutput(ctx, acp.TerminalOutputRequest{TerminalId: id}); err != nil {
+		t.Errorf("output after kill: %v", err)
+	}
+	c.ReleaseTerminal(ctx, acp.ReleaseTerminalRequest{TerminalId: id})
+	if _, err := c.TerminalOutput(ctx, acp.TerminalOutputRequest{TerminalId: id}); err == nil {
+		t.Error("released terminal still readable")
+	}
+}
+
+func TestTerminalOutputLimit(t *testing.T) {
+	term := &terminal{limit: 7}
+	term.Write([]byte("abc"))
+	term.Write([]byte("défgh€"))
+
+	// The oldest bytes go, and the cut moves past the é it would split
+	output, truncated, _ := term.snapshot()
+	if output != "fgh€" || !truncated {
+		t.Errorf("output = %q, truncated = %v; want %q, true", output, truncated, "fgh€")
+	}
+}
@@ -0,0 +1,260 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/ron/tui_acp/tui/logger"
+)
+
+// Transports for reaching the agent, selected by ProtocolConfig.Transport
+const (
+	TransportTCP   = "tcp"   // Dial Address as host:port
+	TransportUnix  = "unix"  // Dial Address as a Unix socket path
+	TransportStdio = "stdio" // Spawn Command and talk over its stdin and stdout
+)
+
+// DefaultConnectTimeout bounds each dial attempt when no timeout is configured,
+// so an unreachable address fails instead of waiting on the OS timeout
+const DefaultConnectTimeout = 10 * time.Second
+
+// DefaultRetryBackoff is the delay before the first dial retry when none is configured
+const DefaultRetryBackoff = 250 * time.Millisecond
+
+// maxRetryBackoff caps the exponential delay between dial attempts
+const maxRetryBackoff = 5 * time.Second
+
+// agentStopTimeout is how long a spawned agent has to exit after being interrupted
+// before it is killed
+const agentStopTimeout = 2 * time.Second
+
+// openTransport connects to the agent as configured. Dials are bounded by ctx;
+// a spawned agent lives until lifetime is cancelled.
+func openTransport(ctx context.Context, lifetime context.Context, cfg ProtocolConfig) (io.ReadWriteCloser, error) {
+	switch cfg.Transport {
+	case "", TransportTCP, TransportUnix:
+		network := "tcp"
+		if cfg.Transport == TransportUnix {
+			network = "unix"
+		}
+		conn, err := dialWithRetry(ctx, network, cfg.Address, cfg.ConnectTimeout, cfg.MaxRetries, cfg.RetryBackoff, cfg.Logger)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.ReadTimeout > 0 || cfg.WriteTimeout > 0 {
+			return &deadlineConn{Conn: conn, readTimeout: cfg.ReadTimeout, writeTimeout: cfg.WriteTimeout}, nil
+		}
+		return conn, nil
+	case TransportStdio:
+		if cfg.Command == "" {
+			return nil, fmt.Errorf("stdio transport requires a command")
+		}
+		return startAgentProcess(lifetime, cfg.Command, cfg.Args, cfg.Cwd, cfg.Logger)
+	default:
+		return nil, fmt.Errorf("unknown transport %q (want %s, %s or %s)", cfg.Transport, TransportTCP, TransportUnix, TransportStdio)
+	}
+}
+
+// dialWithRetry dials address, retrying failures with exponential backoff so the
+// client can be started alongside an agent that isn't listening yet. Each attempt
+// gives up after timeout.
+func dialWithRetry(ctx context.Context, network string, address string, timeout time.Duration, maxRetries int, backoff time.Duration, log logger.Logger) (net.Conn, error) {
+	if timeout <= 0 {
+		timeout = DefaultConnectTimeout
+	}
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	for attempt := 1; ; attempt++ {
+		log.Debug("Connecting to %s (attempt %d of %d)...", address, attempt, maxRetries+1)
+		conn, err := dialer.DialContext(ctx, network, address)
+		if err == nil {
+			return conn, nil
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() && ctx.Err() == nil {
+			err = fmt.Errorf("no answer within %v: %w", timeout, err)
+		}
+		if attempt > maxRetries || ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+		}
+
+		log.Debug("Connect attempt %d failed: %v; retrying in %v", attempt, err, backoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("failed to connect to %s: %w", address, ctx.Err())
+		}
+
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// deadlineConn gives reads and writes on a connection deadlines, so a stalled
+// or half-open connection fails the call instead of blocking it forever.
+// Writes each get writeTimeout. Reads only time out while a reply is awaited
+// (see awaitReply), since an idle agent has nothing to send; data arriving
+// starts the clock again, so only silence counts.
+type deadlineConn struct {
+	net.Conn
+	readTimeout  time.Duration // 0 = reads wait indefinitely
+	writeTimeout time.Duration // 0 = writes wait indefinitely
+
+	mu       sync.Mutex
+	awaiting int // Replies awaited; the read deadline is set while above 0
+}
+
+// awaitReply starts the read timeout until the returned function is called,
+// once the reply it waits for has arrived or been given up on
+func (c *deadlineConn) awaitReply() func() {
+	if c.readTimeout <= 0 {
+		return func() {}
+	}
+
+	c.mu.Lock()
+	c.awaiting++
+	if c.awaiting == 1 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+	c.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			c.awaiting--
+			if c.awaiting == 0 {
+				c.Conn.SetReadDeadline(time.Time{})
+			}
+			c.mu.Unlock()
+		})
+	}
+}
+
+// Read reads from the connection, failing after readTimeout without data
+// while a reply is awaited
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && c.readTimeout > 0 {
+		c.mu.Lock()
+		if c.awaiting > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+		}
+		c.mu.Unlock()
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = fmt.Errorf("nothing received from the agent for %v: %w", c.readTimeout, err)
+	}
+	return n, err
+}
+
+// Write writes to the connection, failing if it can't complete within writeTimeout
+func (c *deadlineConn) Write(p []byte) (int, error) {
+	if c.writeTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	n, err := c.Conn.Write(p)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = fmt.Errorf("agent did not accept data for %v: %w", c.writeTimeout, err)
+	}
+	return n, err
+}
+
+// agentProcess is an agent subprocess speaking ACP over its stdin and stdout
+type agentProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	logger logger.Logger
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// startAgentProcess launches the agent in dir. When ctx is cancelled the agent is
+// interrupted, then killed if it hasn't exited after agentStopTimeout.
+func startAgentProcess(ctx context.Context, command string, args []string, dir string, log logger.Logger) (*agentProcess, error) {
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = dir
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = agentStopTimeout
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open agent stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open agent stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open agent stderr: %w", err)
+	}
+
+	log.Debug("Starting agent: %s %v", command, args)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start agent %s: %w", command, err)
+	}
+	log.Debug("Agent started (pid %d)", cmd.Process.Pid)
+
+	// The agent's stderr would corrupt the terminal, so it goes to the log instead
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			log.Debug("agent: %s", scanner.Text())
+		}
+	}()
+
+	return &agentProcess{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: stdout,
+		logger: log,
+	}, nil
+}
+
+// Read reads from the agent's stdout
+func (a *agentProcess) Read(p []byte) (int, error) {
+	return a.stdout.Read(p)
+}
+
+// Write writes to the agent's stdin
+func (a *agentProcess) Write(p []byte) (int, error) {
+	return a.stdin.Write(p)
+}
+
+// Close closes the agent's stdin and waits for it to exit. An exit status, or
+// the interrupt sent when the client's lifetime ends, is not an error here,
+// since the agent is being stopped on purpose.
+func (a *agentProcess) Close() error {
+	a.closeOnce.Do(func() {
+		a.stdin.Close()
+
+		err := a.cmd.Wait()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) || errors.Is(err, context.Canceled) {
+			a.logger.Debug("Agent exited: %v", err)
+			err = nil
+		}
+		a.closeErr = err
+	})
+	return a.closeErr
+}
@@ -0,0 +1,88 @@
+//go:build linux
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ron/tui_acp/tui/logger"
+)
+
+// blackholeAddress returns a local TCP address that never answers a connection
+// attempt. It listens with no backlog and never accepts, so once one connection
+// fills the queue Linux drops further SYNs as an unreachable host would.
+func blackholeAddress(t *testing.T) string {
+	t.Helper()
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Fatal(err)
+	}
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := fmt.Sprintf("127.0.0.1:%d", sa.(*syscall.SockaddrInet4).Port)
+
+	for i := 0; i < 8; i++ {
+		conn, err := net.DialTimeout("tcp", address, 100*time.Millisecond)
+		if err != nil {
+			return address
+		}
+		t.Cleanup(func() { conn.Close() })
+	}
+	t.Skip("connections to a full listen queue are not dropped here")
+	return ""
+}
+
+func TestDialWithRetryTimesOut(t *testing.T) {
+	address := blackholeAddress(t)
+
+	start := time.Now()
+	_, err := dialWithRetry(context.Background(), "tcp", address, 200*time.Millisecond, 0, 0, logger.NewNoopLogger())
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("dial succeeded to an address that never answers")
+	}
+	if !strings.Contains(err.Error(), "no answer within 200ms") {
+		t.Errorf("err = %v, want it to say the dial timed out", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("gave up after %v, want about 200ms", elapsed)
+	}
+}
+
+func TestNewACPClientConnectTimeout(t *testing.T) {
+	address := blackholeAddress(t)
+
+	// Each attempt is bounded, retries included
+	start := time.Now()
+	_, err := NewACPClient(context.Background(), Config{
+		Address:        address,
+		Cwd:            t.TempDir(),
+		ConnectTimeout: 100 * time.Millisecond,
+		MaxRetries:     1,
+		RetryBackoff:   10 * time.Millisecond,
+	})
+	elapsed := time.Since(start)
+
+	if err == nil || !strings.Contains(err.Error(), "no answer within 100ms") {
+		t.Errorf("err = %v, want a connect timeout", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("gave up after %v, want about two 100ms attempts", elapsed)
+	}
+}
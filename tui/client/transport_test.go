@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ron/tui_acp/tui/logger"
+)
+
+// freeAddress returns a local TCP address nothing is listening on
+func freeAddress(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+	return address
+}
+
+func TestDialWithRetryWaitsForListener(t *testing.T) {
+	address := freeAddress(t)
+
+	// The agent starts listening after the first attempts have failed
+	listening := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		ln, err := net.Listen("tcp", address)
+		if err != nil {
+			t.Error(err)
+			close(listening)
+			return
+		}
+		listening <- ln
+	}()
+
+	conn, err := dialWithRetry(context.Background(), "tcp", address, time.Second, 10, 20*time.Millisecond, logger.NewNoopLogger())
+	if ln, ok := <-listening; ok {
+		defer ln.Close()
+	}
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	conn.Close()
+}
+
+func TestDialWithRetryGivesUp(t *testing.T) {
+	address := freeAddress(t)
+
+	start := time.Now()
+	_, err := dialWithRetry(context.Background(), "tcp", address, time.Second, 2, 20*time.Millisecond, logger.NewNoopLogger())
+	if err == nil {
+		t.Fatal("dial succeeded with nothing listening")
+	}
+	// Two retries wait 20ms and then 40ms
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("gave up after %v, before backing off twice", elapsed)
+	}
+}
+
+func TestDialWithRetryStopsWhenCancelled(t *testing.T) {
+	address := freeAddress(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := dialWithRetry(ctx, "tcp", address, time.Second, 100, time.Second, logger.NewNoopLogger())
+	if err == nil {
+		t.Fatal("dial succeeded with nothing listening")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("kept backing off for %v after the context ended", elapsed)
+	}
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+)
+
+// Watch modes, selected by WatchOptions.Mode
+const (
+	WatchModeNotify = "notify" // OS file events (inotify on Linux), falling back to polling when unavailable
+	WatchModePoll   = "poll"   // Periodic stat of the watched files
+)
+
+// DefaultWatchPollInterval is how often poll-mode watches stat their files when no
+// interval is given. Changes are seen with up to this much delay.
+const DefaultWatchPollInterval = 2 * time.Second
+
+// minWatchPollInterval keeps agents from asking for a CPU-hungry poll loop
+const minWatchPollInterval = 250 * time.Millisecond
+
+// Watch event operations
+const (
+	WatchOpCreate = "create"
+	WatchOpModify = "modify"
+	WatchOpRemove = "remove"
+)
+
+// WatchOptions controls how Watch detects changes
+type WatchOptions struct {
+	Recursive bool          // Watch subdirectories too, not just a directory's entries
+	Mode      string        // WatchModeNotify (the default) or WatchModePoll
+	Interval  time.Duration // How often to poll (0 = DefaultWatchPollInterval)
+}
+
+// WatchEvent describes a change seen by a watch
+type WatchEvent struct {
+	Path string // Path that changed
+	Op   string // WatchOpCreate, WatchOpModify or WatchOpRemove
+}
+
+// watchState is what a poll compares between snapshots
+type watchState struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+// Watch reports changes to path, or to the entries of a directory, to emit until
+// ctx is cancelled. It returns the mode in use once the initial state is captured,
+// so changes made after it returns are reported. Notify mode falls back to polling
+// when OS file events can't be used.
+func (f *FileSystemAdapter) Watch(ctx context.Context, path string, opts WatchOptions, emit func(WatchEvent)) (string, error) {
+	resolvedPath, err := f.ResolveWithinRoot(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(resolvedPath); err != nil {
+		return "", fmt.Errorf("failed to stat path: %w", err)
+	}
+
+	switch opts.Mode {
+	case "", WatchModeNotify:
+		err := f.startNotifyWatch(ctx, resolvedPath, opts, emit)
+		if err == nil {
+			return WatchModeNotify, nil
+		}
+		f.logger.Warn("Watching %s by polling instead: %v", resolvedPath, err)
+	case WatchModePoll:
+	default:
+		return "", fmt.Errorf("unknown watch mode %q (want %s or %s)", opts.Mode, WatchModeNotify, WatchModePoll)
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultWatchPollInterval
+	}
+	interval = max(interval, minWatchPollInterval)
+
+	snapshot, err := f.watchSnapshot(ctx, resolvedPath, opts.Recursive)
+	if err != nil {
+		return "", err
+	}
+
+	f.logger.Info("Watching %s by polling every %v (recursive: %v)", resolvedPath, interval, opts.Recursive)
+	go f.pollWatch(ctx, resolvedPath, opts.Recursive, interval, snapshot, emit)
+	return WatchModePoll, nil
+}
+
+// pollWatch stats the watched files every interval and emits the differences
+func (f *FileSystemAdapter) pollWatch(ctx context.Context, path string, recursive bool, interval time.Duration, snapshot map[string]watchState, emit func(WatchEvent)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.logger.Debug("Stopped watching %s", path)
+			return
+		case <-ticker.C:
+		}
+
+		next, err := f.watchSnapshot(ctx, path, recursive)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			f.logger.Debug("Watch poll of %s failed: %v", path, err)
+			continue
+		}
+
+		for _, event := range diffWatchSnapshots(snapshot, next) {
+			emit(event)
+		}
+		snapshot = next
+	}
+}
+
+// watchSnapshot records the state of path and, for a directory, its entries.
+// A missing path gives an empty snapshot so its removal and re-creation are seen.
+func (f *FileSystemAdapter) watchSnapshot(ctx context.Context, path string, recursive bool) (map[string]watchState, error) {
+	snapshot := make(map[string]watchState)
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return snapshot, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot[path] = watchState{size: info.Size(), modTime: info.ModTime(), isDir: info.IsDir()}
+	if !info.IsDir() {
+		return snapshot, nil
+	}
+
+	opts := walkOptions{recursive: recursive, includeDirs: true}
+	err = f.walkDirectory(ctx, path, opts, func(filePath string, d fs.DirEntry) error {
+		info, err := d.Info()
+		if err != nil {
+			// Removed between listing and stat; the next poll settles it
+			return nil
+		}
+		snapshot[filePath] = watchState{size: info.Size(), modTime: info.ModTime(), isDir: d.IsDir()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// diffWatchSnapshots lists the changes from before to after, sorted by path.
+// Directories are reported when created or removed, not when their entries change.
+func diffWatchSnapshots(before, after map[string]watchState) []WatchEvent {
+	var events []WatchEvent
+	for path, state := range after {
+		old, ok := before[path]
+		switch {
+		case !ok:
+			events = append(events, WatchEvent{Path: path, Op: WatchOpCreate})
+		case !state.isDir && (state.size != old.size || !state.modTime.Equal(old.modTime)):
+			events = append(events, WatchEvent{Path: path, Op: WatchOpModify})
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			events = append(events, WatchEvent{Path: path, Op: WatchOpRemove})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Path < events[j].Path
+	})
+	return events
+}
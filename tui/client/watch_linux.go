@@ -0,0 +1,177 @@
+//go:build linux
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask is what notify watches listen for on each watched directory
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF
+
+// inotifyWatch backs a notify-mode watch with inotify. Directories are watched
+// rather than files, so a watched file that is replaced or re-created is still seen.
+type inotifyWatch struct {
+	f         *FileSystemAdapter
+	fd        int              // The inotify instance
+	file      *os.File         // fd, read through the runtime poller
+	dirs      map[int32]string // Watch descriptor to watched directory
+	path      string           // Path being watched
+	only      string           // For a file watch, the one name reported from its directory
+	recursive bool
+	emit      func(WatchEvent)
+}
+
+// startNotifyWatch reports changes under path with inotify until ctx is cancelled
+func (f *FileSystemAdapter) startNotifyWatch(ctx context.Context, path string, opts WatchOptions, emit func(WatchEvent)) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("inotify unavailable: %w", err)
+	}
+	// A non-blocking descriptor goes through the runtime poller, so closing the
+	// file ends a pending read. File.Fd would make it blocking again, so the
+	// descriptor is kept for adding watches.
+	w := &inotifyWatch{
+		f:         f,
+		fd:        fd,
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		dirs:      make(map[int32]string),
+		path:      path,
+		recursive: opts.Recursive,
+		emit:      emit,
+	}
+
+	if info.IsDir() {
+		err = w.addTree(ctx, path, false)
+	} else {
+		w.only = filepath.Base(path)
+		err = w.add(filepath.Dir(path))
+	}
+	if err != nil {
+		w.file.Close()
+		return err
+	}
+
+	f.logger.Info("Watching %s with inotify (recursive: %v)", path, opts.Recursive)
+	go func() {
+		<-ctx.Done()
+		w.file.Close()
+	}()
+	go w.run()
+	return nil
+}
+
+// add starts watching dir
+func (w *inotifyWatch) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	w.dirs[int32(wd)] = dir
+	return nil
+}
+
+// addTree watches dir and, for recursive watches, the directories below it.
+// With report set, the entries found are emitted as created, since they may
+// have appeared before their directory was watched.
+func (w *inotifyWatch) addTree(ctx context.Context, dir string, report bool) error {
+	if err := w.add(dir); err != nil {
+		return err
+	}
+	if !w.recursive {
+		return nil
+	}
+
+	opts := walkOptions{recursive: true, includeDirs: true}
+	return w.f.walkDirectory(ctx, dir, opts, func(filePath string, d fs.DirEntry) error {
+		if report {
+			w.emit(WatchEvent{Path: filePath, Op: WatchOpCreate})
+		}
+		if d.IsDir() {
+			return w.add(filePath)
+		}
+		return nil
+	})
+}
+
+// run reads inotify events until the watch is closed
+func (w *inotifyWatch) run() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.f.logger.Warn("Watch of %s stopped: %v", w.path, err)
+			}
+			w.f.logger.Debug("Stopped watching %s", w.path)
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			name := string(nameBytes)
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+			w.handle(event.Wd, event.Mask, name)
+		}
+	}
+}
+
+// handle converts one inotify event to watch events
+func (w *inotifyWatch) handle(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		w.f.logger.Warn("Watch of %s missed events: the event queue overflowed", w.path)
+		return
+	}
+	dir, ok := w.dirs[wd]
+	if !ok {
+		return
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.dirs, wd)
+		return
+	}
+	if mask&syscall.IN_DELETE_SELF != 0 {
+		// Entries of a removed subdirectory are reported by its parent
+		if dir == w.path {
+			w.emit(WatchEvent{Path: dir, Op: WatchOpRemove})
+		}
+		return
+	}
+	if w.only != "" && name != w.only {
+		return
+	}
+
+	path := filepath.Join(dir, name)
+	isDir := mask&syscall.IN_ISDIR != 0
+	switch {
+	case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		w.emit(WatchEvent{Path: path, Op: WatchOpCreate})
+		if isDir && w.recursive {
+			if err := w.addTree(context.Background(), path, true); err != nil {
+				w.f.logger.Debug("Not watching new directory %s: %v", path, err)
+			}
+		}
+	case mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+		w.emit(WatchEvent{Path: path, Op: WatchOpRemove})
+	case mask&(syscall.IN_MODIFY|syscall.IN_ATTRIB) != 0 && !isDir:
+		w.emit(WatchEvent{Path: path, Op: WatchOpModify})
+	}
+}
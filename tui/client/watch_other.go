@@ -0,0 +1,17 @@
+//go:build !linux
+
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// errNotifyUnavailable is returned when OS file events can't back a watch
+var errNotifyUnavailable = errors.New("native file notifications are only supported on Linux")
+
+// startNotifyWatch fails on platforms without an OS event backend, so notify
+// watches poll there
+func (f *FileSystemAdapter) startNotifyWatch(ctx context.Context, path string, opts WatchOptions, emit func(WatchEvent)) error {
+	return errNotifyUnavailable
+}
@@ -0,0 +1,21 @@
+package clock
+
+import "time"
+
+// Clock reads the current time. Code that depends on time takes a Clock
+// instead of calling time.Now directly, so it can be driven by a FakeClock.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+}
+
+// RealClock reads the system clock
+type RealClock struct{}
+
+// NewRealClock creates a clock backed by the system time
+func NewRealClock() Clock {
+	return RealClock{}
+}
+
+func (RealClock) Now() time.Time                  { return time.Now() }
+func (RealClock) Since(t time.Time) time.Duration { return time.Since(t) }
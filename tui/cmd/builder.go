@@ -1,52 +1,164 @@
 package cmd
 
 import (
+	"os"
+	"time"
+
 	tea "github.com/charmbracelet/bubbletea"
+	acp "github.com/coder/acp-go-sdk"
 	"github.com/ron/tui_acp/tui/app"
+	"github.com/ron/tui_acp/tui/client"
 	"github.com/ron/tui_acp/tui/logger"
 	"github.com/ron/tui_acp/tui/ui"
 )
 
 // ApplicationBuilder handles the construction of the chat application components
 type ApplicationBuilder struct {
-	serverAddress string
-	debug         bool
-	trace         bool
-	logFile       string
+	serverAddress  string
+	transport      string
+	agentCommand   string   // Agent program launched for the stdio transport
+	agentArgs      []string // Arguments for agentCommand
+	debug          bool
+	trace          bool
+	logFile        string
+	logCompress    bool
+	themePreset    string
+	configFile     string // Re-read by ReloadConfig
+	fileLogLevel   string
+	tuiLogLevel    string
+	stderrLogLevel string
+	pinned         map[string]bool // Flags given on the command line, which the config file can't override
+	grepWorkers    int
+	followSymlinks bool
+	absolutePaths  bool
+	restrictToCwd  bool
+	maxGrepFile    int64
+	maxGrepLine    int64
+	grepTimeout    time.Duration
+	maxGrepResults int
+	maxWriteSize   int64
+	maxReadSize    int64
+	heartbeat      time.Duration
+	connectTimeout time.Duration
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	connectRetries int
+	connectBackoff time.Duration
+	jsonEncoding   string
+	mcpServers     []acp.McpServer
+	cwd            string
+	sessionFile    string
+	resume         bool
+	historyFile    string
+	trimResponses  bool
+	detectErrors   bool
+	flushPolicy    app.FlushPolicy
+	maxMessages    int
+	showThoughts   bool
+	saveImages     bool
+	permTimeout    time.Duration
+	permPolicy     *client.PermissionPolicy
+	enableTerminal bool
+	simpleSpinner  bool
+	spinnerDelay   time.Duration
+	spinnerSeed    int64
+	confirmPaste   bool
+	sendOnPaste    bool
+	confirmTokens  int
+	numberMessages bool
+	maxRender      int
+	grepLineWidth  int
+	highlight      bool
+	timestamps     bool
+
+	// onUpdate receives updates instead of updateChan, for running without the TUI
+	onUpdate func(app.UpdateEvent)
 
 	// Channels
-	updateChan chan string
+	updateChan chan app.UpdateEvent
 	logChan    chan logger.LogMessage
 
 	// Components
 	log         logger.Logger
 	application *app.App
+	recorder    *ui.CastRecorder // Records the session when set
 }
 
 // NewApplicationBuilder creates a new ApplicationBuilder with configuration
 func NewApplicationBuilder(serverAddress string) *ApplicationBuilder {
+	fileLevel, tuiLevel, stderrLevel := GetLogLevels()
 	return &ApplicationBuilder{
-		serverAddress: serverAddress,
-		debug:         GetDebug(),
-		trace:         GetTrace(),
-		logFile:       GetLogFile(),
-		updateChan:    make(chan string, 100),
-		logChan:       make(chan logger.LogMessage, 100),
+		serverAddress:  serverAddress,
+		debug:          GetDebug(),
+		trace:          GetTrace(),
+		logFile:        GetLogFile(),
+		logCompress:    GetLogCompress(),
+		themePreset:    GetThemePreset(),
+		configFile:     GetConfigFile(),
+		fileLogLevel:   fileLevel,
+		tuiLogLevel:    tuiLevel,
+		stderrLogLevel: stderrLevel,
+		grepWorkers:    grepWorkers,
+		followSymlinks: followSymlinks,
+		absolutePaths:  absolutePaths,
+		restrictToCwd:  restrictToCwd,
+		maxGrepFile:    maxGrepFile,
+		maxGrepLine:    maxGrepLine,
+		grepTimeout:    grepFileTimeout,
+		maxGrepResults: maxGrepResults,
+		maxWriteSize:   maxWriteSize,
+		maxReadSize:    maxReadSize,
+		heartbeat:      heartbeatInterval,
+		connectTimeout: connectTimeout,
+		readTimeout:    readTimeout,
+		writeTimeout:   writeTimeout,
+		connectRetries: connectRetries,
+		connectBackoff: connectBackoff,
+		jsonEncoding:   jsonEncoding,
+		sessionFile:    sessionFile,
+		resume:         resumeSession,
+		historyFile:    historyFile,
+		trimResponses:  trimResponses,
+		detectErrors:   detectErrors,
+		flushPolicy:    app.FlushPolicy(flushPolicy),
+		maxMessages:    maxMessages,
+		showThoughts:   showThoughts,
+		saveImages:     saveImages,
+		permTimeout:    permissionTimeout,
+		enableTerminal: enableTerminal,
+		cwd:            workDir,
+		simpleSpinner:  simpleSpinner,
+		spinnerDelay:   spinnerDelay,
+		spinnerSeed:    spinnerSeed,
+		confirmPaste:   confirmPaste,
+		sendOnPaste:    sendOnPaste,
+		confirmTokens:  confirmTokens,
+		numberMessages: numberMessages,
+		maxRender:      maxRenderLength,
+		grepLineWidth:  grepLineWidth,
+		highlight:      syntaxHighlight,
+		timestamps:     timestamps,
+		updateChan:     make(chan app.UpdateEvent, 100),
+		logChan:        make(chan logger.LogMessage, 100),
 	}
 }
 
 // BuildLogger creates and returns the logger
 func (b *ApplicationBuilder) BuildLogger() logger.Logger {
 	var tuiLogChan chan<- logger.LogMessage
-	if b.debug || b.trace {
+	if b.debug || b.trace || b.tuiLogLevel != "" {
 		tuiLogChan = b.logChan
 	}
 
 	b.log = logger.NewZerologLogger(logger.Config{
-		Debug:      b.debug,
-		Trace:      b.trace,
-		LogFile:    b.logFile,
-		TUILogChan: tuiLogChan,
+		Debug:       b.debug,
+		Trace:       b.trace,
+		LogFile:     b.logFile,
+		Compress:    b.logCompress,
+		TUILogChan:  tuiLogChan,
+		FileLevel:   b.fileLogLevel,
+		TUILevel:    b.tuiLogLevel,
+		StderrLevel: b.stderrLogLevel,
 	})
 
 	return b.log
@@ -58,17 +170,72 @@ func (b *ApplicationBuilder) BuildApp() *app.App {
 		b.BuildLogger()
 	}
 
+	updateCallback := func(event app.UpdateEvent) {
+		// Completion, errors and prompts must always reach the UI, otherwise it
+		// keeps loading forever or the agent waits on an unanswered prompt
+		switch event.Kind {
+		case app.UpdateComplete, app.UpdateError, app.UpdatePermission, app.UpdatePromptDone:
+			b.updateChan <- event
+			return
+		}
+
+		select {
+		case b.updateChan <- event:
+		default:
+			// Channel full, skip update
+		}
+	}
+	if b.onUpdate != nil {
+		updateCallback = b.onUpdate
+	}
+
 	b.application = app.New(app.Config{
-		Logger: b.log,
-		UpdateCallback: func(text string) {
-			select {
-			case b.updateChan <- text:
-			default:
-				// Channel full, skip update
-			}
+		Logger:               b.log,
+		SessionFile:          b.sessionFile,
+		Resume:               b.resume,
+		TrimResponses:        b.trimResponses,
+		DetectStreamedErrors: b.detectErrors,
+		FlushPolicy:          b.flushPolicy,
+		MaxMessages:          b.maxMessages,
+		ShowThoughts:         b.showThoughts,
+		RenderMarkdown:       ui.NewMessageRenderer(0).RenderConversationMarkdown,
+		UpdateCallback:       updateCallback,
+		Client: client.Config{
+			Transport:         b.transport,
+			Command:           b.agentCommand,
+			Args:              b.agentArgs,
+			GrepWorkers:       b.grepWorkers,
+			FollowSymlinks:    b.followSymlinks,
+			AbsolutePaths:     b.absolutePaths,
+			RestrictToCwd:     b.restrictToCwd,
+			MaxGrepFileBytes:  b.maxGrepFile,
+			MaxGrepLineBytes:  b.maxGrepLine,
+			GrepFileTimeout:   b.grepTimeout,
+			MaxGrepResults:    b.maxGrepResults,
+			MaxWriteBytes:     b.maxWriteSize,
+			MaxReadBytes:      b.maxReadSize,
+			HeartbeatInterval: b.heartbeat,
+			ConnectTimeout:    b.connectTimeout,
+			ReadTimeout:       b.readTimeout,
+			WriteTimeout:      b.writeTimeout,
+			MaxRetries:        b.connectRetries,
+			RetryBackoff:      b.connectBackoff,
+			JSONEncoding:      b.jsonEncoding,
+			McpServers:        b.mcpServers,
+			Cwd:               b.cwd,
+			SaveImages:        b.saveImages,
+			PermissionTimeout: b.permTimeout,
+			PermissionPolicy:  b.permPolicy,
+			EnableTerminal:    b.enableTerminal,
 		},
 	})
 
+	if b.resume && b.historyFile != "" {
+		if err := b.application.LoadState(b.historyFile); err != nil {
+			b.log.Warn("Starting without history: %v", err)
+		}
+	}
+
 	return b.application
 }
 
@@ -78,12 +245,50 @@ func (b *ApplicationBuilder) BuildModel() ui.Model {
 		b.BuildApp()
 	}
 
-	return ui.NewModel(b.application, b.updateChan, b.serverAddress)
+	palette, err := ui.PaletteForPreset(b.themePreset)
+	if err != nil {
+		b.log.Error("Falling back to default theme: %v", err)
+		palette = ui.DarkPalette()
+	}
+
+	opts := ui.DefaultOptions()
+	opts.Palette = palette
+	opts.SimpleSpinner = b.simpleSpinner
+	opts.SpinnerDelay = b.spinnerDelay
+	opts.SpinnerSeed = b.spinnerSeed
+	opts.HeartbeatInterval = b.heartbeat
+	opts.ConfirmMultilinePaste = b.confirmPaste
+	opts.SendSingleLinePaste = b.sendOnPaste
+	opts.ConfirmPromptTokens = b.confirmTokens
+	opts.NumberMessages = b.numberMessages
+	opts.MaxMessageRenderLength = b.maxRender
+	opts.GrepLineWidth = b.grepLineWidth
+	opts.SyntaxHighlight = b.highlight
+	opts.Timestamps = b.timestamps
+	if b.configFile != "" {
+		opts.Reload = b.ReloadConfig
+	}
+
+	return ui.NewModel(b.application, b.updateChan, b.serverAddress, opts)
+}
+
+// StartRecording records the session to an asciicast file at path, from the
+// program built next until Cleanup
+func (b *ApplicationBuilder) StartRecording(path string) error {
+	recorder, err := ui.NewCastRecorder(path, os.Stdout)
+	if err != nil {
+		return err
+	}
+	b.recorder = recorder
+	return nil
 }
 
 // BuildProgram creates and returns the Bubble Tea program
 func (b *ApplicationBuilder) BuildProgram() *tea.Program {
 	model := b.BuildModel()
+	if b.recorder != nil {
+		return tea.NewProgram(model, tea.WithOutput(b.recorder))
+	}
 	return tea.NewProgram(model)
 }
 
@@ -105,8 +310,18 @@ func (b *ApplicationBuilder) StartLogConsumer() {
 
 // Cleanup closes all resources
 func (b *ApplicationBuilder) Cleanup() {
+	if b.recorder != nil {
+		if err := b.recorder.Close(); err != nil {
+			b.log.Warn("Recording incomplete: %v", err)
+		}
+	}
 	if b.application != nil {
 		b.application.Close()
+		if b.historyFile != "" {
+			if err := b.application.SaveState(b.historyFile); err != nil {
+				b.log.Warn("History not saved: %v", err)
+			}
+		}
 	}
 }
 
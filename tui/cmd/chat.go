@@ -3,12 +3,64 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	acp "github.com/coder/acp-go-sdk"
+	"github.com/ron/tui_acp/tui/app"
+	"github.com/ron/tui_acp/tui/client"
+	"github.com/ron/tui_acp/tui/logger"
+	"github.com/ron/tui_acp/tui/ui"
 	"github.com/spf13/cobra"
 )
 
 var (
-	address string
+	address           string
+	transport         string
+	agentCommand      string
+	heartbeatInterval time.Duration
+	connectTimeout    time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	connectRetries    int
+	connectBackoff    time.Duration
+	jsonEncoding      string
+	mcpConfig         string
+	workDir           string
+	sessionFile       string
+	resumeSession     bool
+	historyFile       string
+	trimResponses     bool
+	detectErrors      bool
+	flushPolicy       string
+	maxMessages       int
+	showThoughts      bool
+	saveImages        bool
+	permissionTimeout time.Duration
+	permissionPolicy  string
+	enableTerminal    bool
+	grepWorkers       int
+	followSymlinks    bool
+	absolutePaths     bool
+	restrictToCwd     bool
+	maxGrepFile       int64
+	maxGrepLine       int64
+	grepFileTimeout   time.Duration
+	maxGrepResults    int
+	maxWriteSize      int64
+	maxReadSize       int64
+	simpleSpinner     bool
+	spinnerDelay      time.Duration
+	spinnerSeed       int64
+	confirmPaste      bool
+	sendOnPaste       bool
+	confirmTokens     int
+	numberMessages    bool
+	maxRenderLength   int
+	grepLineWidth     int
+	syntaxHighlight   bool
+	timestamps        bool
+	recordFile        string
 )
 
 // chatCmd represents the chat command
@@ -16,8 +68,10 @@ var chatCmd = &cobra.Command{
 	Use:   "chat [address]",
 	Short: "Start the chat interface with an ACP agent",
 	Long: `Start an interactive chat session with an ACP agent.
-The address should be in the format host:port (e.g., localhost:9090).
-If no address is provided, it defaults to localhost:9090.`,
+The address should be in the format host:port (e.g., localhost:9090),
+or a socket path with --transport unix.
+If no address is provided, it defaults to localhost:9090.
+With --command the agent is started as a subprocess and spoken to over stdio.`,
 	Args: cobra.MaximumNArgs(1),
 	Run:  runChat,
 }
@@ -27,17 +81,155 @@ func init() {
 
 	// Local flags for the chat command
 	chatCmd.Flags().StringVarP(&address, "address", "a", "localhost:9090", "ACP server address (host:port)")
+	chatCmd.Flags().StringVar(&transport, "transport", client.TransportTCP, "How to reach the agent: tcp or unix (--command implies stdio)")
+	chatCmd.Flags().StringVar(&agentCommand, "command", "", "Launch the agent with this command line and talk to it over stdio, instead of connecting to --address")
+	chatCmd.Flags().DurationVar(&connectTimeout, "connect-timeout", client.DefaultConnectTimeout, "How long each connection attempt waits for the agent to answer")
+	chatCmd.Flags().IntVar(&connectRetries, "connect-retries", 5, "How many times to retry connecting if the agent isn't listening yet")
+	chatCmd.Flags().DurationVar(&connectBackoff, "connect-backoff", client.DefaultRetryBackoff, "Delay before the first connection retry, doubled after each")
+	chatCmd.Flags().StringVar(&workDir, "cwd", "", "Working directory for the agent's session and file access (default: the current directory)")
+	chatCmd.Flags().StringVar(&mcpConfig, "mcp-config", "", "JSON file of MCP servers to offer the agent for the session")
+	chatCmd.Flags().StringVar(&jsonEncoding, "json-encoding", client.JSONEncodingCompact, "How extension responses sent to the agent are shown in the debug log: compact or indent")
+	chatCmd.Flags().BoolVar(&resumeSession, "resume", false, "Resume the last session with this agent, if the agent supports loading sessions, and show the conversation kept in --history-file")
+	chatCmd.Flags().StringVar(&historyFile, "history-file", "", "Where the conversation is kept between runs, shown again with --resume (default: a file per working directory and agent in the user config directory; --history-file= to not keep it)")
+	chatCmd.Flags().StringVar(&sessionFile, "session-file", "", "Where the last session is remembered for --resume (default: a file per working directory in the user config directory; --session-file= to not remember)")
+	chatCmd.Flags().DurationVar(&readTimeout, "read-timeout", 0, "Drop the connection when the agent sends nothing for this long while a reply is awaited; an idle connection is kept (0 = never)")
+	chatCmd.Flags().DurationVar(&writeTimeout, "write-timeout", 0, "Drop the connection when a write to the agent stalls for this long (0 = never)")
+	chatCmd.Flags().DurationVar(&heartbeatInterval, "heartbeat-interval", 30*time.Second, "How often to ping the agent and refresh the connection indicator (0 = disabled)")
+	chatCmd.Flags().StringVar(&flushPolicy, "flush-policy", string(app.FlushOnComplete), "When streamed responses move to the transcript: complete (when finished) or paragraph (each finished paragraph)")
+	chatCmd.Flags().IntVar(&maxMessages, "max-messages", 0, "Keep at most this many messages in memory, dropping the oldest; the terminal keeps them in its scrollback (0 = no limit)")
+	chatCmd.Flags().BoolVar(&trimResponses, "trim-responses", true, "Drop trailing whitespace from agent responses (--trim-responses=false keeps them verbatim)")
+	chatCmd.Flags().BoolVar(&detectErrors, "detect-errors", false, "Show agent responses that look like errors (\"Error: ...\" or a JSON error) as errors")
+	chatCmd.Flags().BoolVar(&showThoughts, "show-thoughts", true, "Show the agent's reasoning, for agents that stream it (--show-thoughts=false hides it)")
+	chatCmd.Flags().DurationVar(&permissionTimeout, "permission-timeout", 0, "Deny an agent's permission request left unanswered this long (0 = wait for an answer)")
+	chatCmd.Flags().StringVar(&permissionPolicy, "permission-policy", "", "JSON file of tool kinds (or kind:title pairs) to always allow, and kinds or titles to always deny, as {\"allow\": [...], \"deny\": [...]}")
+	chatCmd.Flags().BoolVar(&enableTerminal, "enable-terminal", false, "Let the agent run commands in the working directory and show their output")
+	chatCmd.Flags().BoolVar(&saveImages, "save-images", false, "Save images the agent sends to temporary files and show their paths")
+	chatCmd.Flags().BoolVar(&simpleSpinner, "simple-spinner", false, "Use a plain ASCII spinner (automatic on ASCII-only terminals)")
+	chatCmd.Flags().DurationVar(&spinnerDelay, "spinner-delay", ui.DefaultSpinnerDelay, "How long a response must take before the spinner is shown")
+	chatCmd.Flags().Int64Var(&spinnerSeed, "spinner-seed", 0, "Seed the spinner animation for reproducible output (0 = random)")
+	chatCmd.Flags().BoolVar(&confirmPaste, "confirm-paste", true, "Ask before sending a paste that spans several lines (--confirm-paste=false sends on Enter)")
+	chatCmd.Flags().IntVar(&confirmTokens, "confirm-prompt-tokens", ui.DefaultConfirmPromptTokens, "Ask before sending a prompt estimated at more than this many tokens (0 = never ask)")
+	chatCmd.Flags().BoolVar(&sendOnPaste, "send-on-paste", false, "Send a pasted single line right away instead of waiting for Enter")
+	chatCmd.Flags().BoolVar(&timestamps, "timestamps", false, "Show the time each message was added")
+	chatCmd.Flags().BoolVar(&numberMessages, "number-messages", false, "Number messages in the transcript, as [#12], to show one again with /goto 12")
+	chatCmd.Flags().IntVar(&maxRenderLength, "max-message-render-length", ui.DefaultMaxMessageRenderLength, "Show at most this many bytes of a single message; /goto shows it in full (0 = no limit)")
+	chatCmd.Flags().IntVar(&grepLineWidth, "grep-line-width", 0, "Cut grep matches shown in the transcript to this many columns (0 = terminal width, -1 = whole lines)")
+	chatCmd.Flags().StringVar(&recordFile, "record", "", "Record the session to this asciicast v2 file, for playback with asciinema")
+	chatCmd.Flags().BoolVar(&syntaxHighlight, "syntax-highlight", false, "Color code in tool output, such as grep matches, by the language of its file")
+	chatCmd.Flags().IntVar(&grepWorkers, "grep-workers", 0, "Number of files scanned concurrently by grep (0 = number of CPUs)")
+	chatCmd.Flags().IntVar(&maxGrepResults, "max-grep-results", client.DefaultMaxGrepResults, "Hard cap on grep matches returned to the agent, whatever it requests")
+	chatCmd.Flags().Int64Var(&maxGrepFile, "max-grep-file-size", client.DefaultMaxGrepFileBytes, "Skip files larger than this many bytes when grepping")
+	chatCmd.Flags().Int64Var(&maxGrepLine, "max-grep-line-length", client.DefaultMaxGrepLineBytes, "Skip lines longer than this many bytes when grepping, such as minified code")
+	chatCmd.Flags().DurationVar(&grepFileTimeout, "grep-file-timeout", 0, "Skip a file that takes longer than this to grep (0 = no limit)")
+	chatCmd.Flags().Int64Var(&maxWriteSize, "max-write-size", client.DefaultMaxWriteBytes, "Reject agent file writes larger than this many bytes")
+	chatCmd.Flags().Int64Var(&maxReadSize, "max-read-size", client.DefaultMaxReadBytes, "Truncate agent file reads returning more than this many bytes")
+	chatCmd.Flags().BoolVar(&restrictToCwd, "restrict-to-cwd", false, "Reject agent file access outside the working directory, including via symlinks")
+	chatCmd.Flags().BoolVar(&absolutePaths, "absolute-paths", false, "Give the agent absolute paths in grep, list, find and stat results, rather than paths relative to the working directory")
+	chatCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follow symlinked directories when searching and listing (loops are skipped)")
 }
 
 func runChat(cmd *cobra.Command, args []string) {
+	// Settings given on the command line win over the config file
+	pinned := pinnedSettings(cmd)
+	if len(args) > 0 {
+		pinned["address"] = true
+	}
+
+	for _, level := range []string{fileLogLevel, tuiLogLevel, stderrLogLevel} {
+		if level == "" {
+			continue
+		}
+		if err := logger.CheckLevel(level); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if _, err := app.ParseFlushPolicy(flushPolicy); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fileCfg, err := readConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Use address from args if provided, otherwise use flag value
 	serverAddress := address
 	if len(args) > 0 {
 		serverAddress = args[0]
+	} else if fileCfg.Address != "" && !pinned["address"] {
+		serverAddress = fileCfg.Address
+	}
+
+	// A command replaces the address: the agent is spawned and spoken to over stdio
+	agentTransport := transport
+	var agentFields []string
+	if agentCommand != "" {
+		fields := strings.Fields(agentCommand)
+		if len(fields) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: --command is empty")
+			os.Exit(1)
+		}
+		agentTransport = client.TransportStdio
+		agentFields = fields
+		serverAddress = agentCommand
+	}
+
+	var mcpServers []acp.McpServer
+	if mcpConfig != "" {
+		servers, err := client.LoadMcpServers(mcpConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		mcpServers = servers
+	}
+
+	var policy *client.PermissionPolicy
+	if permissionPolicy != "" {
+		loaded, err := client.LoadPermissionPolicy(permissionPolicy)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		policy = loaded
+	}
+
+	// Resolve presentation settings before taking over the terminal,
+	// since background detection queries the terminal directly
+	preset := GetThemePreset()
+	if fileCfg.Theme != "" && !pinned["theme-preset"] {
+		preset = fileCfg.Theme
+	}
+	if preset == ui.PresetAuto {
+		preset = ui.DetectPreset()
+	}
+	if _, err := ui.PaletteForPreset(preset); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
 	// Build the application using the builder pattern
 	builder := NewApplicationBuilder(serverAddress)
+	builder.themePreset = preset
+	builder.pinned = pinned
+	builder.applyConfig(fileCfg)
+	if !cmd.Flags().Changed("session-file") {
+		builder.sessionFile = app.DefaultSessionFile(builder.cwd)
+	}
+	if !cmd.Flags().Changed("history-file") {
+		builder.historyFile = app.DefaultHistoryFile(builder.cwd, serverAddress)
+	}
+	builder.transport = agentTransport
+	if len(agentFields) > 0 {
+		builder.agentCommand = agentFields[0]
+		builder.agentArgs = agentFields[1:]
+	}
+	builder.mcpServers = mcpServers
+	builder.permPolicy = policy
 	defer builder.Cleanup()
 
 	// Build components
@@ -45,11 +237,21 @@ func runChat(cmd *cobra.Command, args []string) {
 	builder.BuildApp()
 	builder.StartLogConsumer()
 
+	if recordFile != "" {
+		if err := builder.StartRecording(recordFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			builder.Cleanup()
+			os.Exit(1)
+		}
+	}
+
 	// Create and run the program
 	program := builder.BuildProgram()
 
 	if _, err := program.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		// os.Exit skips the deferred Cleanup, which saves the recording and history
+		builder.Cleanup()
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ron/tui_acp/tui/logger"
+	"github.com/ron/tui_acp/tui/ui"
+	"github.com/spf13/cobra"
+)
+
+// fileConfig is the configuration read from --config, or else from
+// DefaultConfigFile when it exists. Flags given on the command line take
+// precedence over it, at startup and on /reload; it takes precedence over
+// the flags' defaults.
+type fileConfig struct {
+	Address string `json:"address"`
+	Theme   string `json:"theme"`
+	Debug   bool   `json:"debug"`
+	Trace   bool   `json:"trace"`
+	LogFile string `json:"logFile"`
+	Cwd     string `json:"cwd"`
+}
+
+// DefaultConfigFile returns where the config file is looked for without
+// --config, or "" when there is no user config directory
+func DefaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "tui_acp", "config.json")
+}
+
+// pinnedSettings returns the settings of the config file that were given as
+// flags, on the command line or through the environment, which the file can't
+// override
+func pinnedSettings(cmd *cobra.Command) map[string]bool {
+	pinned := make(map[string]bool)
+	for _, name := range []string{"address", "theme-preset", "debug", "trace", "log-file", "cwd"} {
+		if cmd.Flags().Changed(name) {
+			pinned[name] = true
+		}
+	}
+	return pinned
+}
+
+// readConfig loads the config file in use, giving an empty config when there is none
+func readConfig() (fileConfig, error) {
+	if GetConfigFile() == "" {
+		return fileConfig{}, nil
+	}
+	return loadFileConfig(GetConfigFile())
+}
+
+// applyConfig takes the logging settings and working directory from cfg,
+// except those pinned
+func (b *ApplicationBuilder) applyConfig(cfg fileConfig) {
+	if !b.pinned["debug"] && !b.pinned["trace"] {
+		b.debug = b.debug || cfg.Debug
+		b.trace = b.trace || cfg.Trace
+	}
+	if cfg.LogFile != "" && !b.pinned["log-file"] {
+		b.logFile = cfg.LogFile
+	}
+	if cfg.Cwd != "" && !b.pinned["cwd"] {
+		b.cwd = cfg.Cwd
+	}
+}
+
+// loadFileConfig reads and checks a config file
+func loadFileConfig(path string) (fileConfig, error) {
+	var cfg fileConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if cfg.Theme != "" && cfg.Theme != ui.PresetAuto {
+		if _, err := ui.PaletteForPreset(cfg.Theme); err != nil {
+			return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+		}
+	}
+	return cfg, nil
+}
+
+// ReloadConfig re-reads the config file and applies what it can to the running
+// application: the log level here, the theme through the returned palette.
+// The address can't change without reconnecting, so a change only warns.
+func (b *ApplicationBuilder) ReloadConfig() (ui.Reloaded, error) {
+	cfg, err := loadFileConfig(b.configFile)
+	if err != nil {
+		b.log.Warn("Keeping the current configuration: %v", err)
+		return ui.Reloaded{}, err
+	}
+
+	var reloaded ui.Reloaded
+
+	// Detecting the terminal background needs the terminal, which the TUI owns
+	// now, so auto keeps the theme in use
+	if cfg.Theme != "" && cfg.Theme != ui.PresetAuto && !b.pinned["theme-preset"] {
+		b.themePreset = cfg.Theme
+	}
+	reloaded.Palette, err = ui.PaletteForPreset(b.themePreset)
+	if err != nil {
+		return ui.Reloaded{}, err
+	}
+
+	if !b.pinned["debug"] && !b.pinned["trace"] {
+		if setter, ok := b.log.(logger.LevelSetter); ok {
+			setter.SetLevel(cfg.Debug, cfg.Trace)
+		}
+		if (cfg.Debug || cfg.Trace) && !b.debug && !b.trace {
+			reloaded.Warnings = append(reloaded.Warnings, "Debug logs go to the log file only; restart to show them here")
+		}
+	}
+
+	if cfg.Address != "" && cfg.Address != b.serverAddress && !b.pinned["address"] {
+		reloaded.Warnings = append(reloaded.Warnings, fmt.Sprintf("Address %s applies after a restart", cfg.Address))
+	}
+	if cfg.LogFile != "" && cfg.LogFile != b.logFile && !b.pinned["log-file"] {
+		reloaded.Warnings = append(reloaded.Warnings, fmt.Sprintf("Log file %s applies after a restart", cfg.LogFile))
+	}
+	if cfg.Cwd != "" && cfg.Cwd != b.cwd && !b.pinned["cwd"] {
+		reloaded.Warnings = append(reloaded.Warnings, fmt.Sprintf("Working directory %s applies after a restart", cfg.Cwd))
+	}
+
+	b.log.Info("Reloaded configuration from %s", b.configFile)
+	return reloaded, nil
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ron/tui_acp/tui/client/clienttest"
+	"github.com/spf13/cobra"
+)
+
+// writeConfig writes a config file holding content into dir and returns its path
+func writeConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "config.json")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    fileConfig
+		wantErr string
+	}{
+		{
+			name:    "all settings",
+			content: `{"address": "agent:9000", "theme": "dark", "debug": true, "trace": false, "logFile": "acp.log", "cwd": "/work"}`,
+			want:    fileConfig{Address: "agent:9000", Theme: "dark", Debug: true, LogFile: "acp.log", Cwd: "/work"},
+		},
+		{name: "empty", content: `{}`},
+		{name: "unknown keys", content: `{"address": "agent:9000", "fontSize": 12}`, want: fileConfig{Address: "agent:9000"}},
+		{name: "bad JSON", content: `address: agent:9000`, wantErr: "failed to parse config"},
+		{name: "bad theme", content: `{"theme": "neon"}`, wantErr: "invalid config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadFileConfig(writeConfig(t, t.TempDir(), tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg != tt.want {
+				t.Errorf("config = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	cfg := fileConfig{Debug: true, LogFile: "file.log", Cwd: "/from/file"}
+	tests := []struct {
+		name   string
+		pinned map[string]bool
+		want   ApplicationBuilder
+	}{
+		{"file fills in", nil, ApplicationBuilder{debug: true, logFile: "file.log", cwd: "/from/file"}},
+		// Flags given keep their values, even when they are the defaults
+		{"flags win", map[string]bool{"debug": true, "log-file": true, "cwd": true}, ApplicationBuilder{logFile: "flag.log", cwd: "/from/flag"}},
+		{"trace pins debug", map[string]bool{"trace": true}, ApplicationBuilder{logFile: "file.log", cwd: "/from/file"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &ApplicationBuilder{logFile: "flag.log", cwd: "/from/flag", pinned: tt.pinned}
+			b.applyConfig(cfg)
+			got := ApplicationBuilder{debug: b.debug, trace: b.trace, logFile: b.logFile, cwd: b.cwd}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("settings = debug %v, trace %v, log %q, cwd %q; want debug %v, trace %v, log %q, cwd %q",
+					got.debug, got.trace, got.logFile, got.cwd, tt.want.debug, tt.want.trace, tt.want.logFile, tt.want.cwd)
+			}
+		})
+	}
+}
+
+func TestPinnedSettings(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("address", "", "")
+	cmd.Flags().String("cwd", "", "")
+	cmd.Flags().Bool("debug", false, "")
+	if err := cmd.Flags().Parse([]string{"--address", "agent:1", "--debug=false"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{"address": true, "debug": true}
+	if got := pinnedSettings(cmd); !reflect.DeepEqual(got, want) {
+		t.Errorf("pinned = %v, want %v", got, want)
+	}
+}
+
+func TestConfigFileSettings(t *testing.T) {
+	agent := &clienttest.Agent{OnPrompt: answer("hello")}
+	address := agent.Listen(t)
+
+	t.Run("default location", func(t *testing.T) {
+		dir, config := t.TempDir(), t.TempDir()
+		os.Mkdir(filepath.Join(dir, "project"), 0o755)
+		logFile := filepath.Join(dir, "from-config.log")
+		writeConfig(t, filepath.Join(config, "tui_acp"), `{"address": "`+address+`", "cwd": "project", "logFile": "`+logFile+`"}`)
+
+		got := runCLIIn(t, dir, []string{"XDG_CONFIG_HOME=" + config}, "", "prompt", "hi")
+		if got.code != 0 || got.stdout != "hello\n" {
+			t.Fatalf("exit %d, stdout %q, stderr %q; want the agent from the config file", got.code, got.stdout, got.stderr)
+		}
+		news := agent.NewSessions()
+		if want := filepath.Join(dir, "project"); news[len(news)-1].Cwd != want {
+			t.Errorf("session in %s, want %s from the config file", news[len(news)-1].Cwd, want)
+		}
+		if _, err := os.Stat(logFile); err != nil {
+			t.Errorf("log file from the config file: %v", err)
+		}
+	})
+
+	t.Run("flag wins", func(t *testing.T) {
+		dir := t.TempDir()
+		path := writeConfig(t, dir, `{"address": "127.0.0.1:1"}`)
+
+		got := runCLIIn(t, dir, nil, "", "prompt", "hi", "--config", path, "--address", address)
+		if got.code != 0 || got.stdout != "hello\n" {
+			t.Errorf("exit %d, stdout %q, stderr %q; want --address used over the file", got.code, got.stdout, got.stderr)
+		}
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := writeConfig(t, dir, `{"theme": "neon"}`)
+
+		got := runCLIIn(t, dir, nil, "", "prompt", "hi", "--config", path, "--address", address)
+		if got.code == 0 || !strings.Contains(got.stderr, "invalid config") {
+			t.Errorf("exit %d, stderr %q; want the config rejected", got.code, got.stderr)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// envFlags are the environment variables read as fallbacks for flags, for
+// deployments where flags are awkward to pass
+var envFlags = []struct {
+	env  string
+	flag string
+}{
+	{"ACP_ADDRESS", "address"},
+	{"ACP_DEBUG", "debug"},
+	{"ACP_TRACE", "trace"},
+	{"ACP_LOG_FILE", "log-file"},
+	{"ACP_CWD", "cwd"},
+}
+
+// applyEnv sets each flag of cmd that wasn't given on the command line from its
+// environment variable, if set. A flag set this way counts as given, so the
+// environment also takes precedence over the config file.
+func applyEnv(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	for _, ef := range envFlags {
+		value, ok := os.LookupEnv(ef.env)
+		if !ok || flags.Lookup(ef.flag) == nil || flags.Changed(ef.flag) {
+			continue
+		}
+		if err := flags.Set(ef.flag, value); err != nil {
+			// Execute reports the error; usage wouldn't help with a bad variable
+			cmd.SilenceUsage, cmd.SilenceErrors = true, true
+			return fmt.Errorf("%s: %w", ef.env, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ron/tui_acp/tui/client/clienttest"
+	"github.com/spf13/cobra"
+)
+
+// envTestCommand returns a command with the flags the environment can set,
+// parsed from args
+func envTestCommand(t *testing.T, args ...string) *cobra.Command {
+	t.Helper()
+	cmd := &cobra.Command{}
+	cmd.Flags().String("address", "localhost:9090", "")
+	cmd.Flags().Bool("debug", false, "")
+	cmd.Flags().Bool("trace", false, "")
+	cmd.Flags().String("log-file", "tui.log", "")
+	cmd.Flags().String("cwd", "", "")
+	if err := cmd.Flags().Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want map[string]string
+	}{
+		{
+			name: "all set",
+			env:  map[string]string{"ACP_ADDRESS": "agent:9000", "ACP_DEBUG": "true", "ACP_TRACE": "1", "ACP_LOG_FILE": "/var/log/acp.log", "ACP_CWD": "/work"},
+			want: map[string]string{"address": "agent:9000", "debug": "true", "trace": "true", "log-file": "/var/log/acp.log", "cwd": "/work"},
+		},
+		{
+			name: "unset",
+			want: map[string]string{"address": "localhost:9090", "debug": "false", "trace": "false", "log-file": "tui.log", "cwd": ""},
+		},
+		// An empty variable is still set
+		{name: "empty", env: map[string]string{"ACP_CWD": ""}, want: map[string]string{"cwd": ""}},
+		{
+			name: "flags win",
+			env:  map[string]string{"ACP_ADDRESS": "agent:9000", "ACP_DEBUG": "true"},
+			args: []string{"--address", "flag:1", "--debug=false"},
+			want: map[string]string{"address": "flag:1", "debug": "false"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, ef := range envFlags {
+				if value, ok := tt.env[ef.env]; ok {
+					t.Setenv(ef.env, value)
+				} else {
+					// Setenv restores the variable when the test ends
+					t.Setenv(ef.env, "")
+					os.Unsetenv(ef.env)
+				}
+			}
+			cmd := envTestCommand(t, tt.args...)
+			if err := applyEnv(cmd, nil); err != nil {
+				t.Fatal(err)
+			}
+			for flag, want := range tt.want {
+				if got := cmd.Flags().Lookup(flag).Value.String(); got != want {
+					t.Errorf("--%s = %q, want %q", flag, got, want)
+				}
+			}
+
+			// Settings from the environment count as given, so the config
+			// file can't override them
+			pinned := pinnedSettings(cmd)
+			for _, ef := range envFlags {
+				_, fromEnv := tt.env[ef.env]
+				if fromEnv && !pinned[ef.flag] {
+					t.Errorf("--%s from %s not pinned", ef.flag, ef.env)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyEnvRejectsBadValue(t *testing.T) {
+	t.Setenv("ACP_DEBUG", "sometimes")
+	cmd := envTestCommand(t)
+	err := applyEnv(cmd, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "ACP_DEBUG:") {
+		t.Errorf("err = %v, want the variable named", err)
+	}
+}
+
+func TestEnvSettings(t *testing.T) {
+	agent := &clienttest.Agent{OnPrompt: answer("hello")}
+	address := agent.Listen(t)
+
+	tests := []struct {
+		name string
+		env  []string
+		args []string
+	}{
+		{"environment", []string{"ACP_ADDRESS=" + address}, nil},
+		{"environment over the config file", []string{"ACP_ADDRESS=" + address}, []string{"--config", "config.json"}},
+		{"flag over the environment", []string{"ACP_ADDRESS=127.0.0.1:1"}, []string{"--address", address}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeConfig(t, dir, `{"address": "127.0.0.1:1"}`)
+			args := append([]string{"prompt", "hi", "--timeout", "5s"}, tt.args...)
+
+			got := runCLIIn(t, dir, tt.env, "", args...)
+			if got.code != 0 || got.stdout != "hello\n" {
+				t.Errorf("exit %d, stdout %q, stderr %q; want the agent reached", got.code, got.stdout, got.stderr)
+			}
+		})
+	}
+
+	// A bad value is reported before anything runs
+	got := runCLIIn(t, t.TempDir(), []string{"ACP_DEBUG=sometimes"}, "", "prompt", "hi", "--address", address)
+	if got.code == 0 || !strings.Contains(got.stderr, "ACP_DEBUG") {
+		t.Errorf("exit %d, stderr %q; want ACP_DEBUG rejected", got.code, got.stderr)
+	}
+}
+
+func TestEnvCwd(t *testing.T) {
+	agent := &clienttest.Agent{OnPrompt: answer("ok")}
+	address := agent.Listen(t)
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "project"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A relative directory is resolved against where the command runs
+	got := runCLIIn(t, dir, []string{"ACP_CWD=project", "ACP_ADDRESS=" + address}, "", "prompt", "hi")
+	if got.code != 0 {
+		t.Fatalf("exit %d, stderr %q", got.code, got.stderr)
+	}
+	want := filepath.Join(dir, "project")
+	if news := agent.NewSessions(); len(news) != 1 || news[0].Cwd != want {
+		t.Errorf("new sessions = %+v, want one in %s", news, want)
+	}
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ron/tui_acp/tui/app"
+	"github.com/ron/tui_acp/tui/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	promptTimeout time.Duration
+	promptJSON    bool
+)
+
+// promptCmd represents the prompt command
+var promptCmd = &cobra.Command{
+	Use:   "prompt [text]",
+	Short: "Send one prompt to an ACP agent and print the response",
+	Long: `Send a single prompt to an ACP agent and print its response to stdout,
+without the interactive interface, for use in scripts.
+Without text the prompt is read from stdin. Permission requests the
+permission policy doesn't answer are denied. The exit code is nonzero
+when connecting or the prompt fails.
+With --json the result is printed as a JSON object instead, holding the
+response, the tool calls the agent made and any error.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runPrompt,
+}
+
+func init() {
+	rootCmd.AddCommand(promptCmd)
+
+	promptCmd.Flags().StringVarP(&address, "address", "a", "localhost:9090", "ACP server address (host:port)")
+	promptCmd.Flags().StringVar(&transport, "transport", client.TransportTCP, "How to reach the agent: tcp or unix (--command implies stdio)")
+	promptCmd.Flags().StringVar(&agentCommand, "command", "", "Launch the agent with this command line and talk to it over stdio, instead of connecting to --address")
+	promptCmd.Flags().StringVar(&workDir, "cwd", "", "Working directory for the agent session (default: the current directory)")
+	promptCmd.Flags().StringVar(&permissionPolicy, "permission-policy", "", "JSON file of rules answering tool permission requests; others are denied")
+	promptCmd.Flags().DurationVar(&promptTimeout, "timeout", 0, "Give up when the response takes longer than this (0 = no limit)")
+	promptCmd.Flags().BoolVar(&promptJSON, "json", false, "Print the response, tool calls and any error as a JSON object")
+}
+
+// promptResult is the outcome of a one-shot prompt, as printed by --json
+type promptResult struct {
+	Response  string           `json:"response"`
+	ToolCalls []promptToolCall `json:"toolCalls"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// promptToolCall is a tool call the agent made while answering
+type promptToolCall struct {
+	Method string                 `json:"method"`
+	Params map[string]interface{} `json:"params,omitempty"`
+	Result interface{}            `json:"result,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+
+	id   string // The agent's ID for a call it reported
+	done bool   // The result arrived
+}
+
+func runPrompt(cmd *cobra.Command, args []string) {
+	recorder := &headlessRecorder{}
+	response, err := sendOnePrompt(cmd, args, recorder)
+
+	if promptJSON {
+		result := promptResult{Response: response, ToolCalls: recorder.toolCalls()}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		data, marshalErr := json.MarshalIndent(result, "", "  ")
+		if marshalErr != nil {
+			// A tool result that can't be encoded mustn't cost the rest of the output
+			result.ToolCalls = nil
+			result.Error = errors.Join(err, fmt.Errorf("failed to encode tool calls: %w", marshalErr)).Error()
+			data, _ = json.MarshalIndent(result, "", "  ")
+		}
+		fmt.Println(string(data))
+	} else if err == nil {
+		fmt.Println(response)
+	}
+
+	if err != nil {
+		if !promptJSON {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// sendOnePrompt connects, sends the prompt and returns the agent's answer, or
+// as much of it as arrived before an error. The config file applies as it does
+// for chat.
+func sendOnePrompt(cmd *cobra.Command, args []string, recorder *headlessRecorder) (string, error) {
+	text, err := promptText(args)
+	if err != nil {
+		return "", err
+	}
+
+	pinned := pinnedSettings(cmd)
+	fileCfg, err := readConfig()
+	if err != nil {
+		return "", err
+	}
+
+	serverAddress := address
+	if fileCfg.Address != "" && !pinned["address"] {
+		serverAddress = fileCfg.Address
+	}
+	agentTransport := transport
+	var agentFields []string
+	if agentCommand != "" {
+		agentFields = strings.Fields(agentCommand)
+		if len(agentFields) == 0 {
+			return "", errors.New("--command is empty")
+		}
+		agentTransport = client.TransportStdio
+		serverAddress = agentCommand
+	}
+
+	builder := NewApplicationBuilder(serverAddress)
+	builder.pinned = pinned
+	builder.applyConfig(fileCfg)
+	builder.transport = agentTransport
+	if len(agentFields) > 0 {
+		builder.agentCommand = agentFields[0]
+		builder.agentArgs = agentFields[1:]
+	}
+	if permissionPolicy != "" {
+		policy, err := client.LoadPermissionPolicy(permissionPolicy)
+		if err != nil {
+			return "", err
+		}
+		builder.permPolicy = policy
+	}
+	// A one-off prompt stays out of the conversation and session kept for chat
+	builder.historyFile = ""
+	builder.sessionFile = ""
+	builder.onUpdate = recorder.onUpdate
+	defer builder.Cleanup()
+
+	builder.BuildLogger()
+	application := builder.BuildApp()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if promptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, promptTimeout)
+		defer cancel()
+	}
+
+	if err := application.Connect(ctx, serverAddress); err != nil {
+		return "", err
+	}
+	err = application.SendMessage(ctx, text)
+
+	messages, _ := application.Snapshot()
+	return responseText(messages), err
+}
+
+// promptText returns the prompt given as an argument, or else read from stdin
+func promptText(args []string) (string, error) {
+	text := ""
+	if len(args) > 0 {
+		text = args[0]
+	} else {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the prompt from stdin: %w", err)
+		}
+		text = string(data)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errors.New("the prompt is empty")
+	}
+	return text, nil
+}
+
+// headlessRecorder stands in for the TUI. With nobody to ask, permission
+// requests are denied, and noted on stderr so a missing tool call can be
+// explained. Tool calls are recorded for --json.
+type headlessRecorder struct {
+	mu    sync.Mutex
+	calls []promptToolCall
+}
+
+// onUpdate handles an update from the App
+func (r *headlessRecorder) onUpdate(event app.UpdateEvent) {
+	switch event.Kind {
+	case app.UpdatePermission:
+		if event.Prompt != nil {
+			fmt.Fprintf(os.Stderr, "Denied: %s\n", event.Prompt.Question)
+			event.Prompt.Answer(false)
+		}
+	case app.UpdateToolInput:
+		if event.Message == nil {
+			// Progress on a call already recorded
+			return
+		}
+		call := promptToolCall{Method: event.Method, id: event.ToolCallID}
+		call.Params, _ = event.Message.Data.(map[string]interface{})
+		r.mu.Lock()
+		r.calls = append(r.calls, call)
+		r.mu.Unlock()
+	case app.UpdateToolOutput:
+		r.finishCall(event)
+	}
+}
+
+// finishCall records the result on the call the agent gave the same ID, or
+// else the earliest unfinished call of the same method, or as a call of its
+// own if there is none
+func (r *headlessRecorder) finishCall(event app.UpdateEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	call := promptToolCall{Method: event.Method, id: event.ToolCallID}
+	index := -1
+	for i, c := range r.calls {
+		same := c.Method == event.Method
+		if event.ToolCallID != "" {
+			same = c.id == event.ToolCallID
+		}
+		if same && !c.done {
+			call, index = c, i
+			break
+		}
+	}
+	if event.Message != nil {
+		call.Result = event.Message.Data
+	}
+	if event.Err != nil {
+		call.Error = event.Err.Error()
+	}
+	call.done = true
+	if index < 0 {
+		r.calls = append(r.calls, call)
+		return
+	}
+	r.calls[index] = call
+}
+
+// toolCalls returns the tool calls recorded so far, never nil so they encode as []
+func (r *headlessRecorder) toolCalls() []promptToolCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]promptToolCall{}, r.calls...)
+}
+
+// responseText joins the agent's answer to the last user message, the text of
+// every assistant message after it
+func responseText(messages []app.Message) string {
+	start := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Type == app.MessageUser {
+			start = i + 1
+			break
+		}
+	}
+
+	var parts []string
+	for _, msg := range messages[start:] {
+		if msg.Type == app.MessageAssistant {
+			parts = append(parts, msg.Content)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	acp "github.com/coder/acp-go-sdk"
+	"github.com/ron/tui_acp/tui/client/clienttest"
+)
+
+// TestMain runs the command line in place of the tests when a test starts the
+// test binary again to try it, as it would run from a shell
+func TestMain(m *testing.M) {
+	if os.Getenv("TUI_ACP_TEST_CLI") == "1" {
+		rootCmd.SetArgs(os.Args[1:])
+		Execute()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// cliResult is what a run of the command line printed and how it exited
+type cliResult struct {
+	stdout, stderr string
+	code           int
+}
+
+// runCLI runs tui_acp with args and stdin in a fresh directory, away from the
+// user's config and environment
+func runCLI(t *testing.T, stdin string, args ...string) cliResult {
+	t.Helper()
+	return runCLIIn(t, t.TempDir(), nil, stdin, args...)
+}
+
+// runCLIIn runs tui_acp in dir like runCLI, with env added to its environment.
+// A variable in env replaces the one runCLI would set.
+func runCLIIn(t *testing.T, dir string, env []string, stdin string, args ...string) cliResult {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append([]string{
+		"TUI_ACP_TEST_CLI=1",
+		"HOME=" + t.TempDir(),
+		"XDG_CONFIG_HOME=" + t.TempDir(),
+		"PATH=" + os.Getenv("PATH"),
+	}, env...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if err != nil && !errors.As(err, &exit) {
+		t.Fatalf("run %v: %v", args, err)
+	}
+	return cliResult{stdout: stdout.String(), stderr: stderr.String(), code: cmd.ProcessState.ExitCode()}
+}
+
+// answer returns a prompt handler that streams the response in chunks
+func answer(chunks ...string) clienttest.PromptFunc {
+	return func(ctx context.Context, conn *acp.AgentSideConnection, p acp.PromptRequest) (acp.PromptResponse, error) {
+		for _, chunk := range chunks {
+			clienttest.Send(ctx, conn, p.SessionId, acp.UpdateAgentMessageText(chunk))
+		}
+		return acp.PromptResponse{StopReason: acp.StopReasonEndTurn}, nil
+	}
+}
+
+func TestPromptCommand(t *testing.T) {
+	agent := &clienttest.Agent{OnPrompt: answer("The answer ", "is 42.")}
+	address := agent.Listen(t)
+
+	tests := []struct {
+		name  string
+		stdin string
+		args  []string
+		sent  string
+	}{
+		{"argument", "", []string{"prompt", "What is the answer?", "--address", address}, "What is the answer?"},
+		{"stdin", "  What is the answer?\n", []string{"prompt", "--address", address}, "What is the answer?"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runCLI(t, tt.stdin, tt.args...)
+			if got.code != 0 || got.stdout != "The answer is 42.\n" {
+				t.Errorf("exit %d, stdout %q, stderr %q; want the answer", got.code, got.stdout, got.stderr)
+			}
+			prompts := agent.Prompts()
+			if len(prompts) != i+1 || prompts[i].Prompt[0].Text.Text != tt.sent {
+				t.Errorf("agent got %+v, want %q", prompts, tt.sent)
+			}
+		})
+	}
+}
+
+func TestPromptCommandFails(t *testing.T) {
+	failing := &clienttest.Agent{OnPrompt: func(ctx context.Context, conn *acp.AgentSideConnection, p acp.PromptRequest) (acp.PromptResponse, error) {
+		return acp.PromptResponse{}, errors.New("model overloaded")
+	}}
+	address := failing.Listen(t)
+
+	tests := []struct {
+		name   string
+		stdin  string
+		args   []string
+		stderr string
+	}{
+		{"agent error", "", []string{"prompt", "hi", "--address", address}, "model overloaded"},
+		{"empty prompt", " \n", []string{"prompt", "--address", address}, "the prompt is empty"},
+		// The timeout cuts the connection retries short
+		{"no agent", "", []string{"prompt", "hi", "--address", "127.0.0.1:1", "--timeout", "300ms"}, "failed to connect"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runCLI(t, tt.stdin, tt.args...)
+			if got.code == 0 || !strings.Contains(got.stderr, tt.stderr) {
+				t.Errorf("exit %d, stderr %q; want a failure mentioning %q", got.code, got.stderr, tt.stderr)
+			}
+			if got.stdout != "" {
+				t.Errorf("stdout %q, want nothing on failure", got.stdout)
+			}
+		})
+	}
+}
+
+// readFileTool returns a prompt handler that reports a tool call reading a
+// file, from start to finish, then answers with text. With fail set the turn
+// fails after the call instead.
+func readFileTool(fail bool) clienttest.PromptFunc {
+	return func(ctx context.Context, conn *acp.AgentSideConnection, p acp.PromptRequest) (acp.PromptResponse, error) {
+		send := func(update acp.SessionUpdate) { clienttest.Send(ctx, conn, p.SessionId, update) }
+		send(acp.StartToolCall("call-1", "Read main.go",
+			acp.WithStartKind(acp.ToolKindRead),
+			acp.WithStartStatus(acp.ToolCallStatusPending),
+			acp.WithStartRawInput(map[string]any{"path": "main.go"})))
+		send(acp.UpdateToolCall("call-1", acp.WithUpdateStatus(acp.ToolCallStatusInProgress)))
+		send(acp.UpdateToolCall("call-1",
+			acp.WithUpdateStatus(acp.ToolCallStatusCompleted),
+			acp.WithUpdateRawOutput(map[string]any{"lines": 3})))
+		if fail {
+			return acp.PromptResponse{}, errors.New("model overloaded")
+		}
+		send(acp.UpdateAgentMessageText("main.go has 3 lines."))
+		return acp.PromptResponse{StopReason: acp.StopReasonEndTurn}, nil
+	}
+}
+
+func TestPromptCommandJSON(t *testing.T) {
+	agent := &clienttest.Agent{OnPrompt: readFileTool(false)}
+	got := runCLI(t, "", "prompt", "How long is main.go?", "--json", "--address", agent.Listen(t))
+
+	want := `{
+  "response": "main.go has 3 lines.",
+  "toolCalls": [
+    {
+      "method": "Read main.go",
+      "params": {
+        "kind": "read",
+        "path": "main.go"
+      },
+      "result": {
+        "lines": 3
+      }
+    }
+  ]
+}
+`
+	if got.code != 0 || got.stdout != want {
+		t.Errorf("exit %d, stdout:\n%s\nwant:\n%s", got.code, got.stdout, want)
+	}
+}
+
+func TestPromptCommandJSONFailed(t *testing.T) {
+	agent := &clienttest.Agent{OnPrompt: readFileTool(true)}
+	got := runCLI(t, "", "prompt", "How long is main.go?", "--json", "--address", agent.Listen(t))
+	if got.code == 0 {
+		t.Error("exit 0 for a failed turn")
+	}
+
+	// A failed turn still prints valid JSON, with the calls made and the error
+	var result struct {
+		Response  string                   `json:"response"`
+		ToolCalls []map[string]interface{} `json:"toolCalls"`
+		Error     string                   `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(got.stdout), &result); err != nil {
+		t.Fatalf("stdout %q is not JSON: %v", got.stdout, err)
+	}
+	if len(result.ToolCalls) != 1 || result.ToolCalls[0]["result"] == nil {
+		t.Errorf("tool calls = %v, want the finished read", result.ToolCalls)
+	}
+	if result.Response != "" || !strings.Contains(result.Error, "model overloaded") {
+		t.Errorf("response %q, error %q; want no response and the agent's error", result.Response, result.Error)
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ron/tui_acp/tui/app"
+	"github.com/ron/tui_acp/tui/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayFormat string
+	replayWidth  int
+)
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay <file>",
+	Short: "Render a saved conversation without connecting to an agent",
+	Long: `Load a saved conversation (JSON array or JSONL) and print it to stdout.
+No agent connection is made, so past sessions can be reviewed offline.
+Use --format to choose between colored terminal output and Markdown.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runReplay,
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().StringVarP(&replayFormat, "format", "f", "text", "Output format (text, markdown)")
+	replayCmd.Flags().IntVarP(&replayWidth, "width", "w", 80, "Wrap width for text output")
+}
+
+func runReplay(cmd *cobra.Command, args []string) {
+	messages, err := app.LoadMessages(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	palette, err := ui.PaletteForPreset(GetThemePreset())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	renderer := ui.NewMessageRendererWithTheme(replayWidth, ui.NewMessageTheme(palette))
+
+	switch replayFormat {
+	case "text":
+		fmt.Print(renderer.RenderConversation(messages, ""))
+	case "markdown", "md":
+		fmt.Print(renderer.RenderConversationMarkdown(messages))
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (expected text or markdown)\n", replayFormat)
+		os.Exit(1)
+	}
+}
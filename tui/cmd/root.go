@@ -4,13 +4,21 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/ron/tui_acp/tui/ui"
 	"github.com/spf13/cobra"
 )
 
 var (
-	debug   bool
-	trace   bool
-	logFile string
+	debug       bool
+	trace       bool
+	logFile     string
+	logCompress bool
+	themePreset string
+	configFile  string
+
+	fileLogLevel   string
+	tuiLogLevel    string
+	stderrLogLevel string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -19,12 +27,18 @@ var rootCmd = &cobra.Command{
 	Short: "A TUI client for ACP (Agent Communication Protocol)",
 	Long: `A terminal user interface for communicating with ACP agents.
 This application provides an interactive chat interface to communicate
-with agents using the Agent Communication Protocol.`,
+with agents using the Agent Communication Protocol.
+
+Settings are taken from flags first, then the environment variables
+ACP_ADDRESS, ACP_DEBUG, ACP_TRACE, ACP_LOG_FILE and ACP_CWD, then the
+config file, then the defaults.`,
+	PersistentPreRunE: applyEnv,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	setVersions(rootCmd)
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -36,6 +50,12 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
 	rootCmd.PersistentFlags().BoolVarP(&trace, "trace", "t", false, "Enable trace logging (includes debug)")
 	rootCmd.PersistentFlags().StringVarP(&logFile, "log-file", "l", "tui.log", "Path to log file")
+	rootCmd.PersistentFlags().BoolVar(&logCompress, "log-compress", false, "Gzip rotated log files; the current log file is left uncompressed")
+	rootCmd.PersistentFlags().StringVar(&fileLogLevel, "file-log-level", "", "Level for the log file: trace, debug, info, warn or error (default: from --debug/--trace)")
+	rootCmd.PersistentFlags().StringVar(&tuiLogLevel, "tui-log-level", "", "Level for logs shown in the TUI; setting it shows logs without --debug (default: from --debug/--trace)")
+	rootCmd.PersistentFlags().StringVar(&stderrLogLevel, "stderr-log-level", "", "Also log to stderr at this level (default: no stderr logging)")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "JSON config file with address, theme, debug, trace, logFile and cwd; flags override it, /reload re-reads it (default: config.json in the tui_acp user config directory, if it exists)")
+	rootCmd.PersistentFlags().StringVar(&themePreset, "theme-preset", ui.PresetAuto, "Color theme preset (auto, dark, light, high-contrast); auto follows the terminal background")
 }
 
 // GetDebug returns the debug flag value
@@ -52,3 +72,32 @@ func GetTrace() bool {
 func GetLogFile() string {
 	return logFile
 }
+
+// GetLogCompress returns whether rotated log files are compressed
+func GetLogCompress() bool {
+	return logCompress
+}
+
+// GetLogLevels returns the per-output log levels for the file, the TUI and stderr
+func GetLogLevels() (file, tui, stderr string) {
+	return fileLogLevel, tuiLogLevel, stderrLogLevel
+}
+
+// GetThemePreset returns the theme preset name
+func GetThemePreset() string {
+	return themePreset
+}
+
+// GetConfigFile returns the config file path: the one given with --config, or
+// else DefaultConfigFile if it exists, or "" for none
+func GetConfigFile() string {
+	if configFile != "" {
+		return configFile
+	}
+	if path := DefaultConfigFile(); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
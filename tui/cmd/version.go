@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, set when building with
+//
+//	go build -ldflags "-X github.com/ron/tui_acp/tui/cmd.Version=v1.2.0 -X github.com/ron/tui_acp/tui/cmd.Commit=$(git rev-parse --short HEAD) -X github.com/ron/tui_acp/tui/cmd.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// and left at their defaults by go run
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version, commit and build date",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Fprintln(cmd.OutOrStdout(), cmd.Root().Name()+" "+versionString())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+
+	rootCmd.PersistentFlags().Bool("version", false, "Print the version and exit")
+	rootCmd.SetVersionTemplate("{{.Root.Name}} {{.Version}}\n")
+}
+
+// setVersions makes --version work after any command, as tui_acp chat
+// --version. Cobra answers it for every command with a Version, so it is set
+// once all commands have been added.
+func setVersions(root *cobra.Command) {
+	root.Version = versionString()
+	for _, c := range root.Commands() {
+		setVersions(c)
+	}
+}
+
+// versionString describes the build
+func versionString() string {
+	return fmt.Sprintf("%s (commit %s, built %s)", Version, Commit, BuildDate)
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import "testing"
+
+func TestVersionOutput(t *testing.T) {
+	// The test binary isn't built with -ldflags, so the defaults show
+	want := "tui_acp dev (commit unknown, built unknown)\n"
+
+	for _, args := range [][]string{
+		{"version"},
+		{"--version"},
+		{"chat", "--version"},
+		{"prompt", "--version"},
+	} {
+		got := runCLI(t, "", args...)
+		if got.code != 0 || got.stdout != want {
+			t.Errorf("%v: exit %d, stdout %q, stderr %q; want %q", args, got.code, got.stdout, got.stderr, want)
+		}
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	for _, v := range []*string{&Version, &Commit, &BuildDate} {
+		saved := *v
+		t.Cleanup(func() { *v = saved })
+	}
+	Version, Commit, BuildDate = "v1.2.0", "abc1234", "2025-03-04T09:30:00Z"
+
+	want := "v1.2.0 (commit abc1234, built 2025-03-04T09:30:00Z)"
+	if got := versionString(); got != want {
+		t.Errorf("versionString() = %q, want %q", got, want)
+	}
+}
+
+func TestVersionRejectsArgs(t *testing.T) {
+	got := runCLI(t, "", "version", "extra")
+	if got.code == 0 {
+		t.Errorf("exit 0, stdout %q; want the argument rejected", got.stdout)
+	}
+}
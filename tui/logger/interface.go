@@ -13,5 +13,11 @@ type LogMessage struct {
 type Logger interface {
 	Debug(format string, args ...interface{})
 	Info(format string, args ...interface{})
+	Warn(format string, args ...interface{})
 	Error(format string, args ...interface{})
 }
+
+// LevelSetter is implemented by loggers whose verbosity can change while running
+type LevelSetter interface {
+	SetLevel(debug, trace bool)
+}
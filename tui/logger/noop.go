@@ -10,4 +10,5 @@ func NewNoopLogger() Logger {
 
 func (l *NoopLogger) Debug(format string, args ...interface{}) {}
 func (l *NoopLogger) Info(format string, args ...interface{})  {}
+func (l *NoopLogger) Warn(format string, args ...interface{})  {}
 func (l *NoopLogger) Error(format string, args ...interface{}) {}
@@ -21,6 +21,10 @@ func (l *StderrLogger) Info(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, "[INFO] "+format+"\n", args...)
 }
 
+func (l *StderrLogger) Warn(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "[WARN] "+format+"\n", args...)
+}
+
 func (l *StderrLogger) Error(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, "[ERROR] "+format+"\n", args...)
 }
@@ -1,9 +1,11 @@
 package logger
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 
 	"github.com/rs/zerolog"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -15,25 +17,82 @@ type Config struct {
 	Trace      bool
 	LogFile    string
 	TUILogChan chan<- LogMessage // Optional channel for TUI output
+
+	// Compress gzips log files once they are rotated out. The file being
+	// written to is never compressed.
+	Compress bool
+
+	// Per-output levels (trace, debug, info, warn or error). Empty follows
+	// Debug and Trace, except for stderr, which is only written when set.
+	FileLevel   string
+	TUILevel    string
+	StderrLevel string
 }
 
 // ZerologAdapter adapts zerolog.Logger to the Logger interface
 type ZerologAdapter struct {
 	logger zerolog.Logger
+	level  atomic.Int32 // Lowest level any sink takes; events below it are dropped early
+	sinks  []*sink
+}
+
+// sink is one log output with its own level
+type sink struct {
+	out   io.Writer
+	fixed bool         // The level was configured for this output, so SetLevel leaves it alone
+	level atomic.Int32 // zerolog.Level below which events are dropped
+}
+
+// Write implements io.Writer for events without a level
+func (s *sink) Write(p []byte) (int, error) {
+	return s.out.Write(p)
+}
+
+// WriteLevel implements zerolog.LevelWriter, dropping events below the sink's level
+func (s *sink) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level < zerolog.Level(s.level.Load()) {
+		return len(p), nil
+	}
+	return s.out.Write(p)
+}
+
+// CheckLevel returns an error if name isn't a level an output can be set to
+func CheckLevel(name string) error {
+	_, err := parseLevel(name)
+	return err
+}
+
+// parseLevel parses a per-output level name
+func parseLevel(name string) (zerolog.Level, error) {
+	switch name {
+	case "trace", "debug", "info", "warn", "error":
+		return zerolog.ParseLevel(name)
+	}
+	return zerolog.NoLevel, fmt.Errorf("unknown log level %q: use trace, debug, info, warn or error", name)
+}
+
+// levelFor maps the debug and trace switches to a log level
+func levelFor(debug, trace bool) zerolog.Level {
+	if trace {
+		return zerolog.TraceLevel
+	} else if debug {
+		return zerolog.DebugLevel
+	}
+	return zerolog.InfoLevel
 }
 
 // NewZerologLogger creates a new zerolog-based logger with multiple transports
 func NewZerologLogger(cfg Config) Logger {
-	var logLevel zerolog.Level
-	if cfg.Trace {
-		logLevel = zerolog.TraceLevel
-	} else if cfg.Debug {
-		logLevel = zerolog.DebugLevel
-	} else {
-		logLevel = zerolog.InfoLevel
-	}
 
-	var writers []io.Writer
+	adapter := &ZerologAdapter{}
+	addSink := func(out io.Writer, level string) {
+		s := &sink{out: out}
+		if parsed, err := parseLevel(level); err == nil {
+			s.fixed = true
+			s.level.Store(int32(parsed))
+		}
+		adapter.sinks = append(adapter.sinks, s)
+	}
 
 	if cfg.LogFile != "" {
 		logPath := cfg.LogFile
@@ -50,8 +109,9 @@ func NewZerologLogger(cfg Config) Logger {
 			MaxSize:    10, // megabytes
 			MaxBackups: 3,
 			MaxAge:     28, // days
+			Compress:   cfg.Compress,
 		}
-		writers = append(writers, fileLogger)
+		addSink(fileLogger, cfg.FileLevel)
 	}
 
 	if cfg.TUILogChan != nil {
@@ -66,32 +126,78 @@ func NewZerologLogger(cfg Config) Logger {
 				zerolog.MessageFieldName,
 			},
 		}
-		writers = append(writers, consoleWriter)
+		addSink(consoleWriter, cfg.TUILevel)
+	}
+
+	if cfg.StderrLevel != "" {
+		addSink(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: "15:04:05"}, cfg.StderrLevel)
 	}
 
+	writers := make([]io.Writer, 0, len(adapter.sinks))
+	for _, s := range adapter.sinks {
+		writers = append(writers, s)
+	}
 	if len(writers) == 0 {
 		writers = append(writers, io.Discard)
 	}
 
-	multi := io.MultiWriter(writers...)
-
-	logger := zerolog.New(multi).
+	// Each sink filters by its own level; the adapter drops events no sink takes
+	// before formatting them, so SetLevel can change both while logging
+	adapter.logger = zerolog.New(zerolog.MultiLevelWriter(writers...)).
 		With().
 		Timestamp().
-		Logger().
-		Level(logLevel)
+		Logger()
+
+	adapter.SetLevel(cfg.Debug, cfg.Trace)
+	return adapter
+}
+
+// SetLevel changes the verbosity of a running logger. Outputs given their own
+// level keep it.
+func (z *ZerologAdapter) SetLevel(debug, trace bool) {
+	level := levelFor(debug, trace)
+	lowest := zerolog.Disabled
+	for _, s := range z.sinks {
+		if !s.fixed {
+			s.level.Store(int32(level))
+		}
+		lowest = min(lowest, zerolog.Level(s.level.Load()))
+	}
+	if len(z.sinks) == 0 {
+		lowest = level
+	}
+	z.level.Store(int32(lowest))
+}
 
-	return &ZerologAdapter{logger: logger}
+// enabled reports whether events at level are logged
+func (z *ZerologAdapter) enabled(level zerolog.Level) bool {
+	return level >= zerolog.Level(z.level.Load())
 }
 
 func (z *ZerologAdapter) Debug(format string, args ...interface{}) {
+	if !z.enabled(zerolog.DebugLevel) {
+		return
+	}
 	z.logger.Debug().Msgf(format, args...)
 }
 
 func (z *ZerologAdapter) Info(format string, args ...interface{}) {
+	if !z.enabled(zerolog.InfoLevel) {
+		return
+	}
 	z.logger.Info().Msgf(format, args...)
 }
 
+func (z *ZerologAdapter) Warn(format string, args ...interface{}) {
+	if !z.enabled(zerolog.WarnLevel) {
+		return
+	}
+	z.logger.Warn().Msgf(format, args...)
+}
+
 func (z *ZerologAdapter) Error(format string, args ...interface{}) {
+	if !z.enabled(zerolog.ErrorLevel) {
+		return
+	}
 	z.logger.Error().Msgf(format, args...)
 }
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editorFinishedMsg carries the text saved in the external editor
+type editorFinishedMsg struct {
+	text string
+	err  error
+}
+
+// editorCommand returns the user's editor command line: $VISUAL, then $EDITOR,
+// then vi
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
+
+// openEditor suspends the TUI and edits text in the external editor, delivering
+// the saved text as an editorFinishedMsg once the editor exits
+func openEditor(text string) tea.Cmd {
+	file, err := os.CreateTemp("", "tui_acp-prompt-*.md")
+	if err != nil {
+		return func() tea.Msg {
+			return editorFinishedMsg{err: fmt.Errorf("failed to create prompt file: %w", err)}
+		}
+	}
+	_, err = file.WriteString(text)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return func() tea.Msg {
+			return editorFinishedMsg{err: fmt.Errorf("failed to write prompt file: %w", err)}
+		}
+	}
+
+	args := editorCommand()
+	cmd := exec.Command(args[0], append(args[1:], file.Name())...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(file.Name())
+		if err != nil {
+			return editorFinishedMsg{err: fmt.Errorf("editor %s failed: %w", args[0], err)}
+		}
+		data, err := os.ReadFile(file.Name())
+		if err != nil {
+			return editorFinishedMsg{err: fmt.Errorf("failed to read prompt file: %w", err)}
+		}
+		// Editors end the file with a newline that isn't part of the prompt
+		return editorFinishedMsg{text: strings.TrimRight(string(data), "\r\n")}
+	})
+}
@@ -0,0 +1,71 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+	"github.com/ron/tui_acp/tui/app"
+)
+
+// maxGrepMatchesShown caps how many matches of one grep are listed in the transcript
+const maxGrepMatchesShown = 20
+
+// grepMatches returns the matches of a grep tool output, or nil for other messages
+func grepMatches(msg app.Message) []map[string]interface{} {
+	if msg.Type != app.MessageToolOutput {
+		return nil
+	}
+	res, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	matches, _ := res["matches"].([]map[string]interface{})
+	return matches
+}
+
+// renderGrepMatches lists grep matches beneath their summary, one per line as
+// path:line: text. Lines are cut to the grep line width with "…" so long
+// matches don't wrap, unless the width is negative. With highlighting on, the
+// matched line is colored by the language of its file.
+func (r MessageRenderer) renderGrepMatches(style lipgloss.Style, matches []map[string]interface{}) string {
+	width := r.grepLineWidth
+	if width == 0 {
+		width = r.getWrapWidth()
+	}
+
+	var b strings.Builder
+	for i, match := range matches {
+		if i == maxGrepMatchesShown {
+			b.WriteString(style.Render(fmt.Sprintf("   … %d more", len(matches)-i)) + "\n")
+			break
+		}
+		line, _ := match["line"].(string)
+		path, _ := match["path"].(string)
+		prefix := fmt.Sprintf("   %s:%v: ", path, match["lineNumber"])
+		text := prefix + strings.TrimSpace(strings.ReplaceAll(line, "\t", " "))
+		if width > 0 {
+			text = runewidth.Truncate(text, width, "…")
+		}
+
+		lang := syntaxForPath(path)
+		if !r.highlight || lang == nil || !strings.HasPrefix(text, prefix) {
+			if width <= 0 {
+				text = wrapText(text, r.getWrapWidth())
+			}
+			b.WriteString(style.Render(text) + "\n")
+			continue
+		}
+
+		// Color each part on its own and add the margin around them all, as
+		// rendering colored text in style would reset its color
+		plain := style.UnsetMargins()
+		text = plain.Render(prefix) + r.theme.syntax.highlight(text[len(prefix):], lang, plain)
+		if width <= 0 {
+			text = wrapText(text, r.getWrapWidth())
+		}
+		b.WriteString(lipgloss.NewStyle().MarginLeft(style.GetMarginLeft()).Render(text) + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,236 @@
+package ui
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// syntaxKind classifies a span of source code for highlighting
+type syntaxKind int
+
+const (
+	syntaxPlain syntaxKind = iota
+	syntaxKeyword
+	syntaxString
+	syntaxComment
+	syntaxNumber
+)
+
+// syntaxSpan is a run of source text of one kind
+type syntaxSpan struct {
+	text string
+	kind syntaxKind
+}
+
+// syntaxLanguage describes just enough of a language to color single lines of it
+type syntaxLanguage struct {
+	keywords     map[string]bool
+	lineComments []string  // Markers that comment out the rest of the line
+	blockComment [2]string // Start and end of a block comment, if the language has one
+	quotes       string    // Characters that open and close a string
+}
+
+// words builds a keyword set
+func words(list string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(list) {
+		set[w] = true
+	}
+	return set
+}
+
+var (
+	langGo = &syntaxLanguage{
+		keywords: words(`break case chan const continue default defer else fallthrough for func go goto if
+			import interface map package range return select struct switch type var nil true false`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	}
+	langPython = &syntaxLanguage{
+		keywords: words(`and as assert async await break class continue def del elif else except finally for
+			from global if import in is lambda nonlocal not or pass raise return try while with yield None True False`),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+	langJavaScript = &syntaxLanguage{
+		keywords: words(`async await break case catch class const continue default delete do else export extends
+			finally for from function if import in instanceof interface let new of return switch this throw try type
+			typeof var void while yield null undefined true false`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	}
+	langRust = &syntaxLanguage{
+		keywords: words(`as async await break const continue crate else enum extern fn for if impl in let loop
+			match mod move mut pub ref return self Self static struct super trait type unsafe use where while true false`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"",
+	}
+	langC = &syntaxLanguage{
+		keywords: words(`abstract auto bool break case catch char class const continue default delete do double
+			else enum extends extern final float for goto if implements import int long namespace new package private
+			protected public return short signed sizeof static struct switch template this throw try typedef union
+			unsigned using var virtual void volatile while null nullptr true false`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	}
+	langShell = &syntaxLanguage{
+		keywords:     words(`case do done elif else esac export fi for function if in local return then until while`),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+)
+
+// syntaxByExtension maps file extensions to the language used to highlight them
+var syntaxByExtension = map[string]*syntaxLanguage{
+	".go":   langGo,
+	".py":   langPython,
+	".js":   langJavaScript,
+	".jsx":  langJavaScript,
+	".mjs":  langJavaScript,
+	".ts":   langJavaScript,
+	".tsx":  langJavaScript,
+	".rs":   langRust,
+	".c":    langC,
+	".h":    langC,
+	".cc":   langC,
+	".cpp":  langC,
+	".hpp":  langC,
+	".java": langC,
+	".cs":   langC,
+	".kt":   langC,
+	".sh":   langShell,
+	".bash": langShell,
+	".zsh":  langShell,
+}
+
+// syntaxForPath returns the language of a file by its extension, or nil when
+// it isn't one the highlighter knows
+func syntaxForPath(path string) *syntaxLanguage {
+	return syntaxByExtension[strings.ToLower(filepath.Ext(path))]
+}
+
+// spans splits a line of source into spans. Strings and block comments that
+// don't close on the line run to its end.
+func (l *syntaxLanguage) spans(line string) []syntaxSpan {
+	var spans []syntaxSpan
+	add := func(text string, kind syntaxKind) {
+		if n := len(spans); n > 0 && spans[n-1].kind == kind {
+			spans[n-1].text += text
+			return
+		}
+		spans = append(spans, syntaxSpan{text: text, kind: kind})
+	}
+
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		if l.isLineComment(rest) {
+			add(rest, syntaxComment)
+			break
+		}
+		if start := l.blockComment[0]; start != "" && strings.HasPrefix(rest, start) {
+			end := strings.Index(rest[len(start):], l.blockComment[1])
+			n := len(rest)
+			if end >= 0 {
+				n = len(start) + end + len(l.blockComment[1])
+			}
+			add(rest[:n], syntaxComment)
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		switch {
+		case strings.ContainsRune(l.quotes, r):
+			n := closingQuote(rest, r)
+			add(rest[:n], syntaxString)
+			i += n
+		case unicode.IsDigit(r):
+			n := len(rest) - len(strings.TrimLeftFunc(rest, isWordRune))
+			add(rest[:n], syntaxNumber)
+			i += n
+		case isWordRune(r):
+			n := len(rest) - len(strings.TrimLeftFunc(rest, isWordRune))
+			kind := syntaxPlain
+			if l.keywords[rest[:n]] {
+				kind = syntaxKeyword
+			}
+			add(rest[:n], kind)
+			i += n
+		default:
+			add(rest[:size], syntaxPlain)
+			i += size
+		}
+	}
+	return spans
+}
+
+// isLineComment reports whether s starts with one of the language's line comments
+func (l *syntaxLanguage) isLineComment(s string) bool {
+	for _, marker := range l.lineComments {
+		if strings.HasPrefix(s, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// closingQuote returns the length of the string literal s starts with, up to
+// and including its unescaped closing quote, or all of s if it never closes
+func closingQuote(s string, quote rune) int {
+	escaped := false
+	for i, r := range s {
+		switch {
+		case i == 0:
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == quote:
+			return i + utf8.RuneLen(r)
+		}
+	}
+	return len(s)
+}
+
+// isWordRune reports whether r can be part of an identifier or number
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// syntaxStyles colors each kind of span. Plain text keeps the message's own style.
+type syntaxStyles map[syntaxKind]lipgloss.Style
+
+// newSyntaxStyles builds the highlighting styles from a palette
+func newSyntaxStyles(p Palette) syntaxStyles {
+	return syntaxStyles{
+		syntaxKeyword: lipgloss.NewStyle().Foreground(lipgloss.Color(p.Keyword)).Bold(true),
+		syntaxString:  lipgloss.NewStyle().Foreground(lipgloss.Color(p.String)),
+		syntaxComment: lipgloss.NewStyle().Foreground(lipgloss.Color(p.Comment)).Italic(true),
+		syntaxNumber:  lipgloss.NewStyle().Foreground(lipgloss.Color(p.Number)),
+	}
+}
+
+// highlight renders a line of code in lang, with plain text in base.
+// Without a language the line is rendered in base alone.
+func (s syntaxStyles) highlight(line string, lang *syntaxLanguage, base lipgloss.Style) string {
+	if lang == nil {
+		return base.Render(line)
+	}
+	var b strings.Builder
+	for _, span := range lang.spans(line) {
+		style, ok := s[span.kind]
+		if !ok {
+			style = base
+		}
+		b.WriteString(style.Render(span.text))
+	}
+	return b.String()
+}
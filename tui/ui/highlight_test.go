@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/ron/tui_acp/tui/app"
+)
+
+// withColor renders in color for the rest of the test, as on a terminal that
+// supports it, rather than as plain text like the test's output
+func withColor(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+}
+
+// grepOutput returns a grep tool output with one match in path
+func grepOutput(path, line string) app.Message {
+	return app.Message{
+		Type:    app.MessageToolOutput,
+		Content: "1 match",
+		Data: map[string]interface{}{
+			"matches": []map[string]interface{}{{"path": path, "lineNumber": 3, "line": line}},
+		},
+	}
+}
+
+func TestGrepMatchesHighlighted(t *testing.T) {
+	withColor(t)
+	const line = `func main() { fmt.Println("hi", 42) } // greet`
+
+	tests := []struct {
+		name      string
+		path      string
+		highlight bool
+		colored   bool
+	}{
+		{"go", "main.go", true, true},
+		{"disabled", "main.go", false, false},
+		{"unknown language", "notes.xyz", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewMessageRenderer(120)
+			r.SetSyntaxHighlight(tt.highlight)
+			out := r.RenderMessage(grepOutput(tt.path, line))
+
+			syntax := r.theme.syntax
+			parts := map[string]string{
+				"keyword": syntax[syntaxKeyword].Render("func"),
+				"string":  syntax[syntaxString].Render(`"hi"`),
+				"number":  syntax[syntaxNumber].Render("42"),
+				"comment": syntax[syntaxComment].Render("// greet"),
+			}
+			for kind, styled := range parts {
+				if got := strings.Contains(out, styled); got != tt.colored {
+					t.Errorf("%s colored = %v, want %v in %q", kind, got, tt.colored, out)
+				}
+			}
+			if !strings.Contains(out, "\x1b[") {
+				t.Errorf("output %q has no styling at all", out)
+			}
+			// Uncolored, the line is rendered whole in the message's style
+			if tt.colored == strings.Contains(out, line) {
+				t.Errorf("line kept whole = %v, want %v in %q", !tt.colored, !tt.colored, out)
+			}
+		})
+	}
+}
+
+func TestSyntaxSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		lang *syntaxLanguage
+		line string
+		want []syntaxSpan
+	}{
+		{"go", langGo, `if x := "a\"b"; x != "" {`, []syntaxSpan{
+			{"if", syntaxKeyword}, {" x := ", syntaxPlain}, {`"a\"b"`, syntaxString},
+			{"; x != ", syntaxPlain}, {`""`, syntaxString}, {" {", syntaxPlain},
+		}},
+		{"block comment", langGo, "x /* note */ y", []syntaxSpan{
+			{"x ", syntaxPlain}, {"/* note */", syntaxComment}, {" y", syntaxPlain},
+		}},
+		{"unclosed string", langPython, `print('open`, []syntaxSpan{
+			{"print(", syntaxPlain}, {"'open", syntaxString},
+		}},
+		{"shell comment", langShell, "echo $x # done", []syntaxSpan{
+			{"echo $x ", syntaxPlain}, {"# done", syntaxComment},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.lang.spans(tt.line)
+			if len(got) != len(tt.want) {
+				t.Fatalf("spans(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("span %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -1,41 +1,65 @@
 package ui
 
 import (
+	"strings"
+	"unicode"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
 )
 
-var (
-	caretStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color(ColorCaret)).
-			Bold(true)
-
-	placeholderStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color(ColorPlaceholder))
-)
-
-// InputBox handles all input box logic and rendering
+// InputBox handles all input box logic and rendering.
+// The value is kept as runes so the cursor never lands inside a multi-byte character.
 type InputBox struct {
-	value       string
-	cursor      int
+	value       []rune
+	cursor      int // Rune index into value
 	placeholder string
+
+	caretStyle       lipgloss.Style
+	placeholderStyle lipgloss.Style
 }
 
 // NewInputBox creates a new input box
 func NewInputBox(placeholder string) InputBox {
-	return InputBox{
-		value:       "",
+	input := InputBox{
+		value:       nil,
 		cursor:      0,
 		placeholder: placeholder,
 	}
+	input.SetPalette(DarkPalette())
+	return input
+}
+
+// SetPalette updates the caret and placeholder colors
+func (i *InputBox) SetPalette(p Palette) {
+	i.caretStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(p.Caret)).
+		Bold(true)
+	i.placeholderStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(p.Placeholder))
 }
 
 // Update handles keyboard input for the input box
 func (i *InputBox) Update(msg tea.KeyMsg) (bool, string) {
+	// Pasted text arrives in one message and may span several lines
+	if msg.Type == tea.KeyRunes && msg.Paste {
+		text := strings.ReplaceAll(string(msg.Runes), "\r\n", "\n")
+		text = strings.ReplaceAll(text, "\r", "\n")
+		i.insert([]rune(text))
+		return false, ""
+	}
+
 	switch msg.String() {
+	case "alt+enter", "ctrl+j":
+		// Terminals send Shift+Enter as plain Enter, so Alt+Enter and Ctrl+J
+		// (a bare line feed) insert the newline instead
+		i.insert([]rune{'\n'})
+		return false, ""
+
 	case "enter":
-		if i.value != "" {
-			submitted := i.value
+		if len(i.value) > 0 {
+			submitted := string(i.value)
 			i.Clear()
 			return true, submitted
 		}
@@ -43,7 +67,7 @@ func (i *InputBox) Update(msg tea.KeyMsg) (bool, string) {
 
 	case "backspace":
 		if i.cursor > 0 {
-			i.value = i.value[:i.cursor-1] + i.value[i.cursor:]
+			i.value = append(i.value[:i.cursor-1], i.value[i.cursor:]...)
 			i.cursor--
 		}
 		return false, ""
@@ -60,55 +84,188 @@ func (i *InputBox) Update(msg tea.KeyMsg) (bool, string) {
 		}
 		return false, ""
 
+	case "ctrl+left":
+		i.cursor = i.wordStart(i.cursor)
+		return false, ""
+
+	case "ctrl+right":
+		i.cursor = i.wordEnd(i.cursor)
+		return false, ""
+
+	case "ctrl+w":
+		i.deleteTo(i.wordStart(i.cursor))
+		return false, ""
+
+	case "ctrl+u":
+		i.deleteTo(i.lineStart(i.cursor))
+		return false, ""
+
+	case "ctrl+k":
+		i.deleteTo(i.lineEnd(i.cursor))
+		return false, ""
+
+	case "up":
+		i.moveLine(-1)
+		return false, ""
+
+	case "down":
+		i.moveLine(1)
+		return false, ""
+
 	case "home":
-		i.cursor = 0
+		i.cursor = i.lineStart(i.cursor)
 		return false, ""
 
 	case "end":
-		i.cursor = len(i.value)
+		i.cursor = i.lineEnd(i.cursor)
 		return false, ""
 
 	default:
-		// Handle regular character input
-		if len(msg.String()) == 1 {
-			i.value = i.value[:i.cursor] + msg.String() + i.value[i.cursor:]
-			i.cursor++
+		// Handle regular character input, including non-ASCII runes. Alt
+		// combinations are key bindings, not text.
+		if msg.Alt {
+			return false, ""
+		}
+		switch msg.Type {
+		case tea.KeyRunes:
+			i.insert(msg.Runes)
+		case tea.KeySpace:
+			i.insert([]rune{' '})
 		}
 		return false, ""
 	}
 }
 
+// insert adds runes at the cursor and moves the cursor past them
+func (i *InputBox) insert(runes []rune) {
+	value := make([]rune, 0, len(i.value)+len(runes))
+	value = append(value, i.value[:i.cursor]...)
+	value = append(value, runes...)
+	value = append(value, i.value[i.cursor:]...)
+	i.value = value
+	i.cursor += len(runes)
+}
+
+// wordStart returns the start of the word before pos, skipping the spaces
+// directly before it first
+func (i InputBox) wordStart(pos int) int {
+	for pos > 0 && unicode.IsSpace(i.value[pos-1]) {
+		pos--
+	}
+	for pos > 0 && !unicode.IsSpace(i.value[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// wordEnd returns the start of the word after pos: the rest of the word at pos
+// is skipped, then the spaces after it
+func (i InputBox) wordEnd(pos int) int {
+	for pos < len(i.value) && !unicode.IsSpace(i.value[pos]) {
+		pos++
+	}
+	for pos < len(i.value) && unicode.IsSpace(i.value[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// deleteTo deletes the runes between the cursor and pos, leaving the cursor at
+// the start of the deleted range
+func (i *InputBox) deleteTo(pos int) {
+	from, to := min(pos, i.cursor), max(pos, i.cursor)
+	i.value = append(i.value[:from], i.value[to:]...)
+	i.cursor = from
+}
+
+// lineStart returns the index of the first rune on the line holding pos
+func (i InputBox) lineStart(pos int) int {
+	for pos > 0 && i.value[pos-1] != '\n' {
+		pos--
+	}
+	return pos
+}
+
+// lineEnd returns the index of the newline ending the line holding pos, or the
+// end of the input on the last line
+func (i InputBox) lineEnd(pos int) int {
+	for pos < len(i.value) && i.value[pos] != '\n' {
+		pos++
+	}
+	return pos
+}
+
+// moveLine moves the cursor delta lines up or down, keeping its display column
+// where the line is long enough. It stays put on the first or last line.
+func (i *InputBox) moveLine(delta int) {
+	start := i.lineStart(i.cursor)
+	column := runewidth.StringWidth(string(i.value[start:i.cursor]))
+
+	target := start
+	switch {
+	case delta < 0:
+		if start == 0 {
+			return
+		}
+		target = i.lineStart(start - 1)
+	case delta > 0:
+		end := i.lineEnd(i.cursor)
+		if end == len(i.value) {
+			return
+		}
+		target = end + 1
+	}
+
+	pos, width := target, 0
+	end := i.lineEnd(target)
+	for pos < end && width+runewidth.RuneWidth(i.value[pos]) <= column {
+		width += runewidth.RuneWidth(i.value[pos])
+		pos++
+	}
+	i.cursor = pos
+}
+
 // View renders the input box
 func (i InputBox) View() string {
-	caret := caretStyle.Render(">")
+	caret := i.caretStyle.Render(">")
 
 	var inputText string
-	if i.value == "" {
-		inputText = placeholderStyle.Render(i.placeholder)
+	if len(i.value) == 0 {
+		inputText = i.placeholderStyle.Render(i.placeholder)
 	} else {
-		// Show cursor as █ block
-		if i.cursor < len(i.value) {
-			inputText = i.value[:i.cursor] + "█" + i.value[i.cursor:]
-		} else {
-			inputText = i.value + "█"
-		}
+		// Show cursor as █ block between characters, so it never splits a
+		// multi-byte or double-width character
+		inputText = string(i.value[:i.cursor]) + "█" + string(i.value[i.cursor:])
+		// Continuation lines are indented to line up under the first
+		inputText = strings.ReplaceAll(inputText, "\n", "\n  ")
 	}
 
 	return caret + " " + inputText
 }
 
+// SetValue replaces the input, moving the cursor to the end
+func (i *InputBox) SetValue(value string) {
+	i.value = []rune(value)
+	i.cursor = len(i.value)
+}
+
 // Clear resets the input box
 func (i *InputBox) Clear() {
-	i.value = ""
+	i.value = nil
 	i.cursor = 0
 }
 
 // Value returns the current input value
 func (i InputBox) Value() string {
-	return i.value
+	return string(i.value)
+}
+
+// LineCount returns how many lines the input spans
+func (i InputBox) LineCount() int {
+	return strings.Count(string(i.value), "\n") + 1
 }
 
 // IsEmpty returns whether the input is empty
 func (i InputBox) IsEmpty() bool {
-	return i.value == ""
+	return len(i.value) == 0
 }
@@ -0,0 +1,259 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// press sends each key to the input box in turn. Keys are given as
+// tea.KeyMsg.String would name them; any other string is typed as text.
+func press(i *InputBox, keys ...string) {
+	for _, key := range keys {
+		i.Update(keyMsg(key))
+	}
+}
+
+// keyMsg returns the key message a terminal sends for key
+func keyMsg(key string) tea.KeyMsg {
+	names := map[string]tea.KeyType{
+		"enter":      tea.KeyEnter,
+		"backspace":  tea.KeyBackspace,
+		"left":       tea.KeyLeft,
+		"right":      tea.KeyRight,
+		"up":         tea.KeyUp,
+		"down":       tea.KeyDown,
+		"home":       tea.KeyHome,
+		"end":        tea.KeyEnd,
+		"ctrl+left":  tea.KeyCtrlLeft,
+		"ctrl+right": tea.KeyCtrlRight,
+		"ctrl+w":     tea.KeyCtrlW,
+		"ctrl+u":     tea.KeyCtrlU,
+		"ctrl+k":     tea.KeyCtrlK,
+		"ctrl+j":     tea.KeyCtrlJ,
+		" ":          tea.KeySpace,
+	}
+	if key == "alt+enter" {
+		return tea.KeyMsg{Type: tea.KeyEnter, Alt: true}
+	}
+	if t, ok := names[key]; ok {
+		return tea.KeyMsg{Type: t}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+// cursorView renders the value with the cursor shown as |
+func cursorView(i InputBox) string {
+	return string(i.value[:i.cursor]) + "|" + string(i.value[i.cursor:])
+}
+
+func TestInputMoveLineKeepsDisplayColumn(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		keys  []string
+		want  string
+	}{
+		// Two wide runes are four columns, over the fourth narrow one
+		{"wide to narrow", "日本語\nabcdef", []string{"up", "end", "left", "down"}, "日本語\nabcd|ef"},
+		// Three columns lands after the first wide rune rather than inside the second
+		{"narrow to wide", "日本語\nabcdef", []string{"home", "right", "right", "right", "up"}, "日|本語\nabcdef"},
+		{"emoji", "😀😀x\n12345", []string{"left", "up"}, "😀😀|x\n12345"},
+		{"short line", "日本語\nab", []string{"up", "end", "down"}, "日本語\nab|"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := NewInputBox("")
+			input.SetValue(tt.value)
+			press(&input, tt.keys...)
+			if got := cursorView(input); got != tt.want {
+				t.Errorf("after %v: %q, want %q", tt.keys, got, tt.want)
+			}
+		})
+	}
+}
+
+// typeText types s into the input one key at a time, as a terminal sends it
+func typeText(i *InputBox, s string) {
+	for _, r := range s {
+		if r == ' ' {
+			press(i, " ")
+		} else {
+			i.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+}
+
+func TestInputMultiline(t *testing.T) {
+	for _, newline := range []string{"alt+enter", "ctrl+j"} {
+		t.Run(newline, func(t *testing.T) {
+			input := NewInputBox("")
+			typeText(&input, "first line")
+			press(&input, newline)
+			typeText(&input, "second")
+
+			if got := input.LineCount(); got != 2 {
+				t.Errorf("LineCount = %d, want 2", got)
+			}
+			submitted, text := input.Update(keyMsg("enter"))
+			if !submitted || text != "first line\nsecond" {
+				t.Errorf("enter = %v, %q; want the text submitted with its newline", submitted, text)
+			}
+			if !input.IsEmpty() {
+				t.Errorf("input = %q after submitting, want it cleared", input.Value())
+			}
+		})
+	}
+}
+
+func TestInputEnterOnEmptyDoesNotSubmit(t *testing.T) {
+	input := NewInputBox("")
+	if submitted, _ := input.Update(keyMsg("enter")); submitted {
+		t.Error("empty input submitted")
+	}
+}
+
+func TestInputPasteKeepsLines(t *testing.T) {
+	input := NewInputBox("")
+	input.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a\r\nb\rc"), Paste: true})
+
+	// A pasted newline doesn't submit, and line endings become \n
+	if got := input.Value(); got != "a\nb\nc" {
+		t.Errorf("value = %q, want %q", got, "a\nb\nc")
+	}
+}
+
+func TestInputCursorAcrossLines(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		want string
+	}{
+		{"up keeps column", []string{"left", "left", "up"}, "abcd\nxy|z\nabcd"},
+		{"up to a shorter line", []string{"up"}, "abcd\nxyz|\nabcd"},
+		{"up on the first line stays", []string{"up", "up", "up"}, "abc|d\nxyz\nabcd"},
+		{"down on the last line stays", []string{"down"}, "abcd\nxyz\nabcd|"},
+		{"left crosses the newline", []string{"home", "left"}, "abcd\nxyz|\nabcd"},
+		{"right crosses the newline", []string{"up", "right"}, "abcd\nxyz\n|abcd"},
+		{"home and end stay on the line", []string{"up", "home"}, "abcd\n|xyz\nabcd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := NewInputBox("")
+			input.SetValue("abcd\nxyz\nabcd")
+			press(&input, tt.keys...)
+			if got := cursorView(input); got != tt.want {
+				t.Errorf("after %v: %q, want %q", tt.keys, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInputViewShowsCursorOnItsLine(t *testing.T) {
+	input := NewInputBox("")
+	input.SetValue("one\ntwo")
+	press(&input, "up")
+
+	// Only the > caret is styled, so the text after it is compared as is
+	lines := strings.Split(strings.TrimPrefix(input.View(), input.caretStyle.Render(">")), "\n")
+	if len(lines) != 2 || lines[0] != " one█" || lines[1] != "  two" {
+		t.Errorf("view lines = %q, want the block cursor after one", lines)
+	}
+}
+
+// withCursor returns an input box holding s, with the cursor where s has a |
+func withCursor(s string) InputBox {
+	before, after, _ := strings.Cut(s, "|")
+	input := NewInputBox("")
+	input.SetValue(before + after)
+	input.cursor = len([]rune(before))
+	return input
+}
+
+func TestInputWordAndLineKeys(t *testing.T) {
+	tests := []struct {
+		key   string
+		start string
+		want  string
+	}{
+		{"ctrl+left", "hello wor|ld", "hello |world"},
+		{"ctrl+left", "hello |world", "|hello world"},
+		{"ctrl+left", "hello   |world", "|hello   world"},
+		{"ctrl+left", "|hello world", "|hello world"},
+		{"ctrl+left", "one\n|two", "|one\ntwo"},
+
+		{"ctrl+right", "hel|lo world", "hello |world"},
+		{"ctrl+right", "hello|   world", "hello   |world"},
+		{"ctrl+right", "hello wo|rld", "hello world|"},
+		{"ctrl+right", "hello world|", "hello world|"},
+		{"ctrl+right", "one|\ntwo", "one\n|two"},
+
+		{"ctrl+w", "hello world|", "hello |"},
+		{"ctrl+w", "hello world  |", "hello |"},
+		{"ctrl+w", "hello wo|rld", "hello |rld"},
+		{"ctrl+w", "|hello", "|hello"},
+		{"ctrl+w", "one\n|two", "|two"},
+
+		{"ctrl+u", "hello wo|rld", "|rld"},
+		{"ctrl+u", "|hello", "|hello"},
+		{"ctrl+u", "one\ntw|o", "one\n|o"},
+		{"ctrl+u", "one\n|two", "one\n|two"},
+
+		{"ctrl+k", "hello wo|rld", "hello wo|"},
+		{"ctrl+k", "hello|", "hello|"},
+		{"ctrl+k", "o|ne\ntwo", "o|\ntwo"},
+		{"ctrl+k", "one|\ntwo", "one|\ntwo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+" "+tt.start, func(t *testing.T) {
+			input := withCursor(tt.start)
+			press(&input, tt.key)
+			if got := cursorView(input); got != tt.want {
+				t.Errorf("%s on %q = %q, want %q", tt.key, tt.start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInputEditsMultibyteText(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		want string
+	}{
+		{"typed", nil, "café 🎉|"},
+		{"backspace emoji", []string{"backspace"}, "café |"},
+		{"backspace accent", []string{"left", "left", "backspace"}, "caf| 🎉"},
+		{"insert before emoji", []string{"left", "x"}, "café x|🎉"},
+		{"insert after accent", []string{"left", "left", "-"}, "café-| 🎉"},
+		{"insert before accent", []string{"left", "left", "left", "e"}, "cafe|é 🎉"},
+		{"home then right", []string{"home", "right", "right", "right", "right"}, "café| 🎉"},
+		{"word back over emoji", []string{"ctrl+left", "ctrl+left"}, "|café 🎉"},
+		{"delete word with accent", []string{"left", "left", "ctrl+w"}, "| 🎉"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := NewInputBox("")
+			typeText(&input, "café 🎉")
+			press(&input, tt.keys...)
+
+			got := cursorView(input)
+			if got != tt.want {
+				t.Errorf("after %v: %q, want %q", tt.keys, got, tt.want)
+			}
+			if !utf8.ValidString(input.Value()) {
+				t.Errorf("value %q is not valid UTF-8", input.Value())
+			}
+			// The block cursor sits between whole characters
+			if view := input.View(); !strings.Contains(view, strings.Replace(got, "|", "█", 1)) {
+				t.Errorf("view %q, want the cursor drawn as in %q", view, got)
+			}
+		})
+	}
+}
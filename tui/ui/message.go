@@ -1,14 +1,19 @@
 package ui
 
 import (
-	"github.com/muesli/reflow/wordwrap"
+	"strings"
+	"time"
+
 	"github.com/ron/tui_acp/tui/app"
 )
 
 // MessageRenderer handles rendering of conversation messages
 type MessageRenderer struct {
-	width int
-	theme *MessageTheme
+	width         int
+	theme         *MessageTheme
+	grepLineWidth int  // Width grep match lines are cut to (0 = wrap width, negative = wrap instead)
+	highlight     bool // Color code in tool output by its language
+	timestamps    bool // Prefix messages with the time they were added
 }
 
 // NewMessageRenderer creates a new message renderer with the default theme
@@ -32,6 +37,22 @@ func (r *MessageRenderer) SetWidth(width int) {
 	r.width = width
 }
 
+// SetGrepLineWidth sets the width grep match lines are cut to. 0 follows the
+// wrap width; a negative width shows whole lines, wrapped.
+func (r *MessageRenderer) SetGrepLineWidth(width int) {
+	r.grepLineWidth = width
+}
+
+// SetSyntaxHighlight turns syntax highlighting of code in tool output on or off
+func (r *MessageRenderer) SetSyntaxHighlight(on bool) {
+	r.highlight = on
+}
+
+// SetTimestamps controls whether messages are prefixed with the time they were added
+func (r *MessageRenderer) SetTimestamps(on bool) {
+	r.timestamps = on
+}
+
 // RenderConversation renders all messages in the conversation
 func (r MessageRenderer) RenderConversation(messages []app.Message, currentResponse string) string {
 	var output string
@@ -51,13 +72,34 @@ func (r MessageRenderer) RenderConversation(messages []app.Message, currentRespo
 // RenderMessage renders a single message based on its type
 func (r MessageRenderer) RenderMessage(msg app.Message) string {
 	style, label := r.theme.GetConfig(msg.Type)
-	return r.renderWithStyle(style, label, msg.Content)
+	return r.renderTime(msg) + r.renderWithStyle(style, label, msg.Content) + r.renderGrepMatches(style, grepMatches(msg))
+}
+
+// renderTime renders the time prefix of a message, or nothing when timestamps
+// are off or the message has no time
+func (r MessageRenderer) renderTime(msg app.Message) string {
+	if !r.timestamps || msg.Time.IsZero() {
+		return ""
+	}
+	return r.theme.time.Render(msg.Time.Format(time.TimeOnly))
+}
+
+// RenderToolResult renders tool output as a continuation of the tool call above it
+func (r MessageRenderer) RenderToolResult(msg app.Message) string {
+	style, _ := r.theme.GetConfig(msg.Type)
+	return r.renderWithStyle(style, "└─ ", msg.Content) + r.renderGrepMatches(style, grepMatches(msg))
+}
+
+// RenderContinuation renders a later part of a message without its label
+func (r MessageRenderer) RenderContinuation(msg app.Message) string {
+	style, _ := r.theme.GetConfig(msg.Type)
+	return r.renderWithStyle(style, "", msg.Content)
 }
 
 // renderWithStyle is a helper that renders content with a given style and label
 func (r MessageRenderer) renderWithStyle(style interface{ Render(...string) string }, label, content string) string {
 	wrapWidth := r.getWrapWidth()
-	wrapped := wordwrap.String(content, wrapWidth)
+	wrapped := wrapText(content, wrapWidth)
 	return style.Render(label) + wrapped + "\n"
 }
 
@@ -67,6 +109,14 @@ func (r MessageRenderer) renderAssistantMessage(content string) string {
 	return r.renderWithStyle(style, label, content)
 }
 
+// RenderStreaming renders a growing assistant response, reusing the wrapping
+// cache holds for the part rendered before. Only the last limit bytes of the
+// wrapped response are shown (0 = all of it).
+func (r MessageRenderer) RenderStreaming(content string, cache *wrapCache, limit int) string {
+	style, label := r.theme.GetConfig(app.MessageAssistant)
+	return style.Render(label) + cache.wrapTail(content, r.getWrapWidth(), limit) + "\n"
+}
+
 // getWrapWidth calculates the appropriate width for word wrapping
 func (r MessageRenderer) getWrapWidth() int {
 	wrapWidth := r.width - 4
@@ -75,3 +125,20 @@ func (r MessageRenderer) getWrapWidth() int {
 	}
 	return wrapWidth
 }
+
+// RenderMarkdown renders a single message as a Markdown section labelled by role
+func (r MessageRenderer) RenderMarkdown(msg app.Message) string {
+	_, label := r.theme.GetConfig(msg.Type)
+	label = strings.TrimSuffix(strings.TrimSpace(label), ":")
+	return "**" + label + ":**\n\n" + msg.Content + "\n"
+}
+
+// RenderConversationMarkdown renders all messages as a Markdown document
+func (r MessageRenderer) RenderConversationMarkdown(messages []app.Message) string {
+	var output strings.Builder
+	for _, msg := range messages {
+		output.WriteString(r.RenderMarkdown(msg))
+		output.WriteString("\n")
+	}
+	return output.String()
+}
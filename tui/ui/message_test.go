@@ -0,0 +1,39 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ron/tui_acp/tui/app"
+)
+
+func TestRenderMessageTimestamp(t *testing.T) {
+	at := time.Date(2025, 3, 4, 9, 30, 5, 0, time.Local)
+	tests := []struct {
+		name string
+		on   bool
+		at   time.Time
+		want bool
+	}{
+		{"enabled", true, at, true},
+		{"disabled", false, at, false},
+		{"message without a time", true, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewMessageRenderer(80)
+			r.SetTimestamps(tt.on)
+			out := r.RenderMessage(app.Message{Type: app.MessageUser, Content: "hello", Time: tt.at})
+
+			if got := strings.Contains(out, "09:30:05"); got != tt.want {
+				t.Errorf("time shown = %v, want %v in %q", got, tt.want, out)
+			}
+			// The time comes before the label
+			if tt.want && strings.Index(out, "09:30:05") > strings.Index(out, "You:") {
+				t.Errorf("time after the label in %q", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ron/tui_acp/tui/client"
+)
+
+// modeSetMsg reports the outcome of switching the session mode
+type modeSetMsg struct {
+	name string
+	err  error
+}
+
+// handleMode lists the agent's session modes, or switches to the one named, as
+// /mode code. The switch is a request to the agent, so it runs off the UI loop.
+func (m Model) handleMode(args []string) (tea.Model, tea.Cmd) {
+	modes, current := m.app.Modes()
+	if len(modes) == 0 {
+		return m, tea.Println(m.view.RenderNotice("The agent has no session modes"))
+	}
+	if len(args) == 0 {
+		return m, tea.Println(m.view.RenderModes(modes, current))
+	}
+
+	name := strings.Join(args, " ")
+	application := m.app
+	return m, func() tea.Msg {
+		return modeSetMsg{name: name, err: application.SetMode(context.Background(), name)}
+	}
+}
+
+// handleModeSet reports a failed mode switch. A successful one shows in the
+// status line through the mode update it causes.
+func (m Model) handleModeSet(msg modeSetMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		return m, tea.Println(m.view.RenderError(fmt.Errorf("mode not changed: %w", msg.err)))
+	}
+	return m, nil
+}
+
+// modeName returns the name of the mode with the given ID, or "" if none has it
+func modeName(modes []client.SessionMode, id string) string {
+	for _, mode := range modes {
+		if mode.ID == id {
+			return mode.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,167 @@
+package ui
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// CastRecorder records what the TUI writes to the terminal as an asciicast v2
+// file, which asciinema can play back. It passes everything through to the
+// terminal it wraps and stands in for it as the program's output, keeping its
+// file descriptor so the terminal size is still detected.
+//
+// Events are buffered and only reach the file in large writes, so recording
+// adds little to each frame. A recording error stops the recording, not the
+// TUI; Close reports it.
+type CastRecorder struct {
+	out *os.File
+
+	mu    sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+	start time.Time
+	err   error
+
+	// pending is the start of a character split across writes, held back so
+	// its event isn't recorded with the bytes mangled
+	pending []byte
+}
+
+// castHeader is the first line of an asciicast v2 file
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// NewCastRecorder creates the cast file at path and starts recording output
+// written through it to out
+func NewCastRecorder(path string, out *os.File) (*CastRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	width, height, err := term.GetSize(out.Fd())
+	if err != nil {
+		width, height = 80, 24
+	}
+	r := &CastRecorder{
+		out:   out,
+		file:  file,
+		w:     bufio.NewWriterSize(file, 64*1024),
+		start: time.Now(),
+	}
+
+	header, _ := json.Marshal(castHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: r.start.Unix(),
+		Env:       map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+	})
+	r.w.Write(header)
+	if err := r.w.WriteByte('\n'); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write recording: %w", err)
+	}
+	return r, nil
+}
+
+// Write writes p to the terminal and records it as an output event
+func (r *CastRecorder) Write(p []byte) (int, error) {
+	n, err := r.out.Write(p)
+	if n > 0 {
+		r.record(p[:n])
+	}
+	return n, err
+}
+
+// record appends an output event for data (one [time, "o", data] line). An
+// incomplete UTF-8 sequence at its end waits for the rest in the next write.
+func (r *CastRecorder) record(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+
+	data = append(r.pending, data...)
+	cut := incompleteTail(data)
+	r.pending = append([]byte(nil), data[cut:]...)
+	if cut > 0 {
+		r.writeEvent(data[:cut])
+	}
+}
+
+// incompleteTail returns where an incomplete UTF-8 sequence ending data
+// starts, or len(data) when it ends on a complete character
+func incompleteTail(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
+
+// writeEvent writes an output event for data to the cast file
+func (r *CastRecorder) writeEvent(data []byte) {
+	text, _ := json.Marshal(string(data))
+	line := make([]byte, 0, len(text)+24)
+	line = append(line, '[')
+	line = strconv.AppendFloat(line, time.Since(r.start).Seconds(), 'f', 6, 64)
+	line = append(line, `, "o", `...)
+	line = append(line, text...)
+	line = append(line, "]\n"...)
+	if _, err := r.w.Write(line); err != nil {
+		r.err = fmt.Errorf("failed to write recording: %w", err)
+	}
+}
+
+// Read reads from the terminal, which the program may use as its output
+func (r *CastRecorder) Read(p []byte) (int, error) {
+	return r.out.Read(p)
+}
+
+// Fd returns the terminal's file descriptor, so the program treats the
+// recorder as the terminal
+func (r *CastRecorder) Fd() uintptr {
+	return r.out.Fd()
+}
+
+// Close finishes the recording and closes the cast file. The terminal is left open.
+func (r *CastRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return r.err
+	}
+
+	// A character still incomplete at the end is recorded as it is
+	if len(r.pending) > 0 && r.err == nil {
+		r.writeEvent(r.pending)
+		r.pending = nil
+	}
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to write recording: %w", err)
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to close recording: %w", err)
+	}
+	r.file = nil
+	return r.err
+}
@@ -0,0 +1,174 @@
+package ui
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// castEvent is an output event line of an asciicast file
+type castEvent struct {
+	time float64
+	data string
+}
+
+// readCast parses the cast file at path into its header and output events
+func readCast(t *testing.T, path string) (castHeader, []castEvent) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		t.Fatal("recording has no header")
+	}
+	var header castHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatalf("header %q: %v", scanner.Text(), err)
+	}
+	var events []castEvent
+	for scanner.Scan() {
+		var fields []any
+		if err := json.Unmarshal(scanner.Bytes(), &fields); err != nil {
+			t.Fatalf("event %q: %v", scanner.Text(), err)
+		}
+		if len(fields) != 3 || fields[1] != "o" {
+			t.Fatalf("event %q, want [time, \"o\", data]", scanner.Text())
+		}
+		events = append(events, castEvent{time: fields[0].(float64), data: fields[2].(string)})
+	}
+	return header, events
+}
+
+// newTestRecorder records to a cast file in a temporary directory, with a
+// plain file standing in for the terminal
+func newTestRecorder(t *testing.T) (r *CastRecorder, castPath string, out *os.File) {
+	t.Helper()
+	dir := t.TempDir()
+	out, err := os.Create(filepath.Join(dir, "terminal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { out.Close() })
+	castPath = filepath.Join(dir, "session.cast")
+	r, err = NewCastRecorder(castPath, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, castPath, out
+}
+
+func TestCastRecorder(t *testing.T) {
+	r, castPath, out := newTestRecorder(t)
+	writes := []string{"\x1b[2J", "hello ", "world\r\n"}
+	for _, w := range writes {
+		if n, err := r.Write([]byte(w)); n != len(w) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", w, n, err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Everything reaches the terminal unchanged
+	if shown, _ := os.ReadFile(out.Name()); string(shown) != strings.Join(writes, "") {
+		t.Errorf("terminal got %q, want %q", shown, strings.Join(writes, ""))
+	}
+
+	// Without a terminal to ask, the size falls back to 80x24
+	header, events := readCast(t, castPath)
+	if header.Version != 2 || header.Width != 80 || header.Height != 24 || header.Timestamp == 0 {
+		t.Errorf("header = %+v, want version 2 at 80x24 with a start time", header)
+	}
+	if len(events) != len(writes) {
+		t.Fatalf("recorded %d events, want one per write: %+v", len(events), events)
+	}
+	for i, event := range events {
+		if event.data != writes[i] {
+			t.Errorf("event %d = %q, want %q", i, event.data, writes[i])
+		}
+		if i > 0 && event.time < events[i-1].time {
+			t.Errorf("event %d at %v, before the one at %v", i, event.time, events[i-1].time)
+		}
+	}
+}
+
+func TestCastRecorderHoldsSplitCharacters(t *testing.T) {
+	r, castPath, _ := newTestRecorder(t)
+	text := "café 🎉 日本"
+	// Split inside é, inside the emoji and inside 日
+	writes := []string{text[:4], text[4:8], text[8:10], text[10:14], text[14:]}
+	for _, w := range writes {
+		r.Write([]byte(w))
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	_, events := readCast(t, castPath)
+	var played strings.Builder
+	for _, event := range events {
+		if strings.ContainsRune(event.data, utf8.RuneError) {
+			t.Errorf("event %q has a mangled character", event.data)
+		}
+		played.WriteString(event.data)
+	}
+	if played.String() != text {
+		t.Errorf("recording plays back %q, want %q", played.String(), text)
+	}
+}
+
+func TestCastRecorderCloseFlushesIncompleteTail(t *testing.T) {
+	r, castPath, _ := newTestRecorder(t)
+	r.Write([]byte("ok\xe6\x97"))
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// Closing again is harmless
+	if err := r.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+
+	// The broken character is still recorded, as JSON can carry it
+	_, events := readCast(t, castPath)
+	if len(events) != 2 || events[0].data != "ok" || !strings.HasPrefix(events[1].data, string(utf8.RuneError)) {
+		t.Errorf("events = %+v, want ok then the incomplete character", events)
+	}
+}
+
+func TestNewCastRecorderBadPath(t *testing.T) {
+	_, err := NewCastRecorder(filepath.Join(t.TempDir(), "missing", "session.cast"), os.Stdout)
+	if err == nil || !strings.Contains(err.Error(), "failed to create recording") {
+		t.Errorf("err = %v, want the file not created", err)
+	}
+}
+
+func TestIncompleteTail(t *testing.T) {
+	tests := []struct {
+		data string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"caf\xc3", 3},
+		{"café", 5},
+		{"x\xf0\x9f\x8e", 1},
+		{"x🎉", 5},
+		{"\xe6\x97", 0},
+		// A stray continuation byte isn't the start of anything to wait for
+		{"a\x80", 2},
+	}
+
+	for _, tt := range tests {
+		if got := incompleteTail([]byte(tt.data)); got != tt.want {
+			t.Errorf("incompleteTail(%q) = %d, want %d", tt.data, got, tt.want)
+		}
+	}
+}
@@ -18,11 +18,21 @@ var spinnerChars = []rune{
 	'+', '.', '*', '&', '%', '#', '!',
 }
 
+// Spinner is a loading indicator animated by TickMsg, allowing the TUI
+// to swap implementations based on terminal capabilities
+type Spinner interface {
+	Init() tea.Cmd
+	Update(msg tea.Msg) (Spinner, tea.Cmd)
+	View() string
+}
+
 // HexSpinner represents a hexadecimal loading indicator
 type HexSpinner struct {
 	positions [16]int  // Each position is an index into the character set
 	frame     int
 	chars     []rune   // Character set to randomly choose from
+	color     string   // Foreground color
+	rng       *rand.Rand // Source of randomness; nil uses the global RNG
 }
 
 // TickMsg is sent on each spinner animation frame
@@ -34,17 +44,41 @@ func NewHexSpinner() HexSpinner {
 		positions: [16]int{},
 		frame:     0,
 		chars:     spinnerChars,
+		color:     ColorSpinner,
 	}
 }
 
+// NewHexSpinnerWithColor creates a new hexadecimal spinner with a custom color
+func NewHexSpinnerWithColor(color string) HexSpinner {
+	s := NewHexSpinner()
+	s.color = color
+	return s
+}
+
+// NewHexSpinnerWithRand creates a hexadecimal spinner that draws characters from rng.
+// A seeded rng makes the animation reproducible, e.g. for golden tests and recordings.
+func NewHexSpinnerWithRand(color string, rng *rand.Rand) HexSpinner {
+	s := NewHexSpinnerWithColor(color)
+	s.rng = rng
+	return s
+}
+
+// randIndex picks a random index into the character set
+func (s HexSpinner) randIndex() int {
+	if s.rng != nil {
+		return s.rng.Intn(len(s.chars))
+	}
+	return rand.Intn(len(s.chars))
+}
+
 // Update updates the spinner state
-func (s HexSpinner) Update(msg tea.Msg) (HexSpinner, tea.Cmd) {
+func (s HexSpinner) Update(msg tea.Msg) (Spinner, tea.Cmd) {
 	switch msg.(type) {
 	case TickMsg:
 		s.frame++
 		// Randomly select a character for each position
 		for i := range s.positions {
-			s.positions[i] = rand.Intn(len(s.chars))
+			s.positions[i] = s.randIndex()
 		}
 		return s, tick()
 	}
@@ -60,9 +94,9 @@ func (s HexSpinner) View() string {
 		hexChars.WriteRune(s.chars[idx])
 	}
 
-	// Style with cyber/hacker aesthetic - green color
+	// Style with cyber/hacker aesthetic - bright green by default
 	style := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("46")). // Bright green
+		Foreground(lipgloss.Color(s.color)).
 		Bold(true)
 
 	// Add brackets for tech feel
@@ -71,7 +105,12 @@ func (s HexSpinner) View() string {
 
 // tick returns a command that sends a TickMsg after a short interval
 func tick() tea.Cmd {
-	return tea.Tick(70*time.Millisecond, func(t time.Time) tea.Msg {
+	return tickEvery(70 * time.Millisecond)
+}
+
+// tickEvery returns a command that sends a TickMsg after the given interval
+func tickEvery(interval time.Duration) tea.Cmd {
+	return tea.Tick(interval, func(t time.Time) tea.Msg {
 		return TickMsg(t)
 	})
 }
@@ -80,3 +119,40 @@ func tick() tea.Cmd {
 func (s HexSpinner) Init() tea.Cmd {
 	return tick()
 }
+
+// asciiSpinnerFrames defines the classic rotating bar animation
+var asciiSpinnerFrames = []string{"|", "/", "-", "\\"}
+
+// asciiSpinnerInterval is slower than the hex spinner to keep output
+// readable over slow links and in CI logs
+const asciiSpinnerInterval = 250 * time.Millisecond
+
+// ASCIISpinner is a plain, unstyled spinner for limited terminals
+type ASCIISpinner struct {
+	frame int
+}
+
+// NewASCIISpinner creates a new ASCII spinner
+func NewASCIISpinner() ASCIISpinner {
+	return ASCIISpinner{}
+}
+
+// Update advances the spinner on each tick
+func (s ASCIISpinner) Update(msg tea.Msg) (Spinner, tea.Cmd) {
+	switch msg.(type) {
+	case TickMsg:
+		s.frame = (s.frame + 1) % len(asciiSpinnerFrames)
+		return s, tickEvery(asciiSpinnerInterval)
+	}
+	return s, nil
+}
+
+// View renders the spinner
+func (s ASCIISpinner) View() string {
+	return "[" + asciiSpinnerFrames[s.frame] + "]"
+}
+
+// Init initializes the spinner and starts the tick
+func (s ASCIISpinner) Init() tea.Cmd {
+	return tickEvery(asciiSpinnerInterval)
+}
@@ -1,6 +1,12 @@
 package ui
 
-import "github.com/ron/tui_acp/tui/app"
+import (
+	"time"
+
+	"github.com/ron/tui_acp/tui/app"
+	"github.com/ron/tui_acp/tui/client"
+	"github.com/ron/tui_acp/tui/clock"
+)
 
 // ChatState holds the pure state of the chat UI, separate from rendering and input handling.
 // This makes state changes explicit and testable.
@@ -9,24 +15,135 @@ type ChatState struct {
 	Connecting bool
 	Connected  bool
 	Error      error
+	Health     client.ConnectionHealth // Last heartbeat snapshot
+	Watches    int                     // File watches the agent has active
+	Mode       string                  // Name of the agent's session mode, if it has modes
 
-	// Message tracking
-	PrintedMsgCount int
+	// Message tracking: the ID of the last message printed. IDs rather than a
+	// count, as the conversation may drop its oldest messages.
+	LastPrintedID int64
 
 	// Loading state
-	Loading bool
+	Loading      bool
+	LoadingSince time.Time // When the current loading period started
+	ActiveTool   string    // Tool method currently running, if any
+	Cancelled    bool      // The user cancelled the response; cleared when it completes
+	Streaming    bool      // The first chunk of the response has arrived
+
+	// Prompt is a permission question awaiting an answer, if any
+	Prompt *app.PermissionPrompt
+	// PromptIndex is the highlighted option of Prompt
+	PromptIndex int
+	// queuedPrompts arrived while Prompt was pending and are shown after it
+	queuedPrompts []*app.PermissionPrompt
+
+	// PasteLines is the line count of a multi-line paste awaiting confirmation
+	// before it can be sent (0 = none)
+	PasteLines int
+
+	// LargePromptTokens is the estimated size of a prompt awaiting confirmation
+	// before it can be sent (0 = none). LargePromptConfirmed lets the next
+	// submit through.
+	LargePromptTokens    int
+	LargePromptConfirmed bool
+
+	// CtrlXPending is set after Ctrl+X, the first key of the Ctrl+X Ctrl+E chord
+	CtrlXPending bool
+
+	clock clock.Clock // Time source for loading durations
 }
 
 // NewChatState creates a new chat state in connecting mode
 func NewChatState() ChatState {
+	return NewChatStateWithClock(clock.NewRealClock())
+}
+
+// NewChatStateWithClock creates a new chat state that reads time from clk
+func NewChatStateWithClock(clk clock.Clock) ChatState {
 	return ChatState{
-		Connecting:      true,
-		Connected:       false,
-		PrintedMsgCount: 0,
-		Loading:         false,
+		Connecting:    true,
+		Connected:     false,
+		LastPrintedID: 0,
+		Loading:       false,
+		clock:         clk,
+	}
+}
+
+// getClock returns the state's clock, falling back to the real clock for zero values
+func (s ChatState) getClock() clock.Clock {
+	if s.clock == nil {
+		return clock.NewRealClock()
+	}
+	return s.clock
+}
+
+// SetPrompt shows a permission prompt with its first option highlighted, or
+// queues it behind the one already pending
+func (s *ChatState) SetPrompt(prompt *app.PermissionPrompt) {
+	if s.Prompt != nil {
+		s.queuedPrompts = append(s.queuedPrompts, prompt)
+		return
+	}
+	s.Prompt = prompt
+	s.PromptIndex = 0
+}
+
+// DropPrompt removes a prompt that stopped waiting from the queue. It returns
+// false when prompt is the one shown, which must be dismissed with ChoosePrompt.
+func (s *ChatState) DropPrompt(prompt *app.PermissionPrompt) bool {
+	for i, queued := range s.queuedPrompts {
+		if queued == prompt {
+			s.queuedPrompts = append(s.queuedPrompts[:i], s.queuedPrompts[i+1:]...)
+			return true
+		}
+	}
+	return s.Prompt != prompt
+}
+
+// nextPrompt shows the next queued prompt, if any
+func (s *ChatState) nextPrompt() {
+	s.Prompt = nil
+	s.PromptIndex = 0
+	if len(s.queuedPrompts) > 0 {
+		s.Prompt = s.queuedPrompts[0]
+		s.queuedPrompts = s.queuedPrompts[1:]
 	}
 }
 
+// MovePromptIndex moves the highlighted option by delta, stopping at either end
+func (s *ChatState) MovePromptIndex(delta int) {
+	if s.Prompt == nil {
+		return
+	}
+	s.PromptIndex = max(0, min(len(s.Prompt.Options)-1, s.PromptIndex+delta))
+}
+
+// ChoosePrompt answers the pending permission prompt with the option at index,
+// or dismisses it when index is out of range, and clears it. It returns the
+// question and the chosen option's name, which is "" when the prompt was
+// dismissed; both are "" when nothing was pending.
+func (s *ChatState) ChoosePrompt(index int) (question, answer string) {
+	if s.Prompt == nil {
+		return "", ""
+	}
+	question = s.Prompt.Question
+	if index >= 0 && index < len(s.Prompt.Options) {
+		answer = s.Prompt.Options[index].Name
+	}
+	s.Prompt.Choose(index)
+	s.nextPrompt()
+	return question, answer
+}
+
+// AnswerPrompt answers the pending permission prompt with its first option that
+// allows or denies. See ChoosePrompt for the return values.
+func (s *ChatState) AnswerPrompt(allow bool) (question, answer string) {
+	if s.Prompt == nil {
+		return "", ""
+	}
+	return s.ChoosePrompt(s.Prompt.FirstOption(allow))
+}
+
 // SetConnected updates state after successful connection
 func (s *ChatState) SetConnected() {
 	s.Connecting = false
@@ -44,6 +161,7 @@ func (s *ChatState) SetConnectionError(err error) {
 func (s *ChatState) SetError(err error) {
 	s.Error = err
 	s.Loading = false
+	s.ActiveTool = ""
 }
 
 // ClearError clears the error state
@@ -51,17 +169,42 @@ func (s *ChatState) ClearError() {
 	s.Error = nil
 }
 
-// SetLoading sets the loading state
+// SetLoading sets the loading state, recording when loading started
 func (s *ChatState) SetLoading(loading bool) {
+	if loading && !s.Loading {
+		s.LoadingSince = s.getClock().Now()
+	}
+	if loading != s.Loading {
+		s.Streaming = false
+	}
+	if !loading {
+		s.ActiveTool = ""
+	}
 	s.Loading = loading
 }
 
-// UpdatePrintedCount updates the count of printed messages and returns the new messages to print
-func (s *ChatState) UpdatePrintedCount(messages []app.Message) []app.Message {
-	if s.PrintedMsgCount >= len(messages) {
-		return nil
+// SetActiveTool records the tool currently running (empty when none)
+func (s *ChatState) SetActiveTool(method string) {
+	s.ActiveTool = method
+}
+
+// LoadingFor returns how long the current loading period has lasted
+func (s ChatState) LoadingFor() time.Duration {
+	if !s.Loading {
+		return 0
+	}
+	return s.getClock().Since(s.LoadingSince)
+}
+
+// UpdatePrinted marks all messages as printed and returns the index of the first
+// one not printed before, len(messages) when there is none
+func (s *ChatState) UpdatePrinted(messages []app.Message) int {
+	start := len(messages)
+	for start > 0 && messages[start-1].ID > s.LastPrintedID {
+		start--
+	}
+	if start < len(messages) {
+		s.LastPrintedID = messages[len(messages)-1].ID
 	}
-	newMessages := messages[s.PrintedMsgCount:]
-	s.PrintedMsgCount = len(messages)
-	return newMessages
+	return start
 }
@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ron/tui_acp/tui/app"
+)
+
+// withIDs returns messages with the given IDs, as the conversation numbers them
+func withIDs(ids ...int64) []app.Message {
+	messages := make([]app.Message, len(ids))
+	for i, id := range ids {
+		messages[i] = app.Message{ID: id, Type: app.MessageInfo}
+	}
+	return messages
+}
+
+func TestUpdatePrinted(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastPrinted int64
+		messages    []app.Message
+		wantStart   int
+		wantLast    int64
+	}{
+		{"first print", 0, withIDs(1, 2, 3), 0, 3},
+		{"new messages", 3, withIDs(1, 2, 3, 4, 5), 3, 5},
+		{"nothing new", 5, withIDs(1, 2, 3, 4, 5), 5, 5},
+		// The oldest messages were dropped: only those after the last printed are new
+		{"trimmed", 3, withIDs(3, 4, 5), 1, 5},
+		{"trimmed with nothing new", 5, withIDs(4, 5), 2, 5},
+		{"trimmed past everything printed", 3, withIDs(6, 7), 0, 7},
+		{"empty", 3, nil, 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewChatState()
+			s.LastPrintedID = tt.lastPrinted
+			start := s.UpdatePrinted(tt.messages)
+			if start != tt.wantStart || s.LastPrintedID != tt.wantLast {
+				t.Errorf("UpdatePrinted = %d, last printed %d; want %d, %d", start, s.LastPrintedID, tt.wantStart, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestPrintNewMessagesAfterTrim(t *testing.T) {
+	a := app.New(app.Config{MaxMessages: 3})
+	m := newTestModel(a, time.Now())
+	add := func(texts ...string) {
+		for _, text := range texts {
+			a.AddMessage(string(app.MessageInfo), text)
+		}
+	}
+
+	add("one", "two")
+	messages, _ := a.Snapshot()
+	if cmds := m.printNewMessages(messages); len(cmds) != 2 {
+		t.Fatalf("printed %d messages, want 2", len(cmds))
+	}
+
+	// Three more push the printed ones out; each new one is printed once
+	add("three", "four", "five")
+	messages, _ = a.Snapshot()
+	if cmds := m.printNewMessages(messages); len(cmds) != 3 {
+		t.Errorf("printed %d messages after trimming, want 3", len(cmds))
+	}
+	if cmds := m.printNewMessages(messages); len(cmds) != 0 {
+		t.Errorf("printed %d messages again", len(cmds))
+	}
+}
@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"fmt"
+
 	"github.com/charmbracelet/lipgloss"
 	"github.com/ron/tui_acp/tui/app"
 )
@@ -15,14 +17,148 @@ const (
 	ColorError       = "196"
 	ColorDebug       = "240"
 	ColorInfo        = "39"
+	ColorThought     = "245"
 	ColorCaret       = "62"
 	ColorPlaceholder = "240"
 	ColorGray        = "240"
+	ColorSpinner     = "46"
+)
+
+// Theme preset names accepted by PaletteForPreset
+const (
+	PresetAuto         = "auto"
+	PresetDark         = "dark"
+	PresetLight        = "light"
+	PresetHighContrast = "high-contrast"
 )
 
+// Palette holds the colors used across the TUI for a single theme preset
+type Palette struct {
+	User        string
+	Assistant   string
+	ToolInput   string
+	ToolOutput  string
+	System      string
+	Error       string
+	Debug       string
+	Info        string
+	Thought     string
+	Caret       string
+	Placeholder string
+	Gray        string
+	Spinner     string
+	Keyword     string // Syntax highlighting of code in tool output
+	String      string
+	Comment     string
+	Number      string
+	Bold        bool // Render every message label in bold
+}
+
+// DarkPalette returns the default palette, tuned for dark terminal backgrounds
+func DarkPalette() Palette {
+	return Palette{
+		User:        ColorUser,
+		Assistant:   ColorAssistant,
+		ToolInput:   ColorToolInput,
+		ToolOutput:  ColorToolOutput,
+		System:      ColorSystem,
+		Error:       ColorError,
+		Debug:       ColorDebug,
+		Info:        ColorInfo,
+		Thought:     ColorThought,
+		Caret:       ColorCaret,
+		Placeholder: ColorPlaceholder,
+		Gray:        ColorGray,
+		Spinner:     ColorSpinner,
+		Keyword:     "204",
+		String:      "150",
+		Comment:     "244",
+		Number:      "179",
+	}
+}
+
+// LightPalette returns a palette with darker foregrounds readable on white backgrounds
+func LightPalette() Palette {
+	return Palette{
+		User:        "25",
+		Assistant:   "29",
+		ToolInput:   "91",
+		ToolOutput:  "55",
+		System:      "130",
+		Error:       "160",
+		Debug:       "243",
+		Info:        "31",
+		Thought:     "244",
+		Caret:       "57",
+		Placeholder: "245",
+		Gray:        "243",
+		Spinner:     "28",
+		Keyword:     "161",
+		String:      "28",
+		Comment:     "245",
+		Number:      "130",
+	}
+}
+
+// HighContrastPalette returns a palette of bright, saturated colors for maximum legibility
+func HighContrastPalette() Palette {
+	return Palette{
+		User:        "51",
+		Assistant:   "15",
+		ToolInput:   "213",
+		ToolOutput:  "219",
+		System:      "226",
+		Error:       "9",
+		Debug:       "252",
+		Info:        "14",
+		Thought:     "250",
+		Caret:       "15",
+		Placeholder: "252",
+		Gray:        "252",
+		Spinner:     "10",
+		Keyword:     "201",
+		String:      "118",
+		Comment:     "250",
+		Number:      "226",
+		Bold:        true,
+	}
+}
+
+// PaletteForPreset returns the palette for a named preset.
+// PresetAuto picks dark or light based on the detected terminal background.
+func PaletteForPreset(name string) (Palette, error) {
+	if name == PresetAuto {
+		name = DetectPreset()
+	}
+
+	switch name {
+	case "", PresetDark:
+		return DarkPalette(), nil
+	case PresetLight:
+		return LightPalette(), nil
+	case PresetHighContrast:
+		return HighContrastPalette(), nil
+	default:
+		return Palette{}, fmt.Errorf("unknown theme preset %q (expected %s, %s, %s or %s)",
+			name, PresetAuto, PresetDark, PresetLight, PresetHighContrast)
+	}
+}
+
+// DetectPreset queries the terminal background color and returns the matching preset.
+// Terminals that don't report a background are assumed to be dark.
+// This must run before the Bubble Tea program takes over stdin.
+func DetectPreset() string {
+	if lipgloss.HasDarkBackground() {
+		return PresetDark
+	}
+	return PresetLight
+}
+
 // MessageTheme defines the visual styling for different message types
 type MessageTheme struct {
 	configs map[app.MessageType]messageConfig
+	syntax  syntaxStyles // Colors for code in tool output, when highlighting is on
+	time    lipgloss.Style
 }
 
 // messageConfig defines the style and label for a message type
@@ -33,17 +169,26 @@ type messageConfig struct {
 
 // DefaultMessageTheme creates the default message theme
 func DefaultMessageTheme() *MessageTheme {
+	return NewMessageTheme(DarkPalette())
+}
+
+// NewMessageTheme creates a message theme from a palette
+func NewMessageTheme(p Palette) *MessageTheme {
 	return &MessageTheme{
 		configs: map[app.MessageType]messageConfig{
-			app.MessageUser:       {style: createMessageStyle(ColorUser, true, false), label: "You: "},
-			app.MessageAssistant:  {style: createMessageStyle(ColorAssistant, false, false), label: "Agent: "},
-			app.MessageToolInput:  {style: createMessageStyle(ColorToolInput, false, false), label: "Tool Input: "},
-			app.MessageToolOutput: {style: createMessageStyle(ColorToolOutput, false, false), label: "Tool Output: "},
-			app.MessageSystem:     {style: createMessageStyle(ColorSystem, false, true), label: "System: "},
-			app.MessageError:      {style: createMessageStyle(ColorError, true, false), label: "Error: "},
-			app.MessageDebug:      {style: createMessageStyle(ColorDebug, false, true), label: "Debug: "},
-			app.MessageInfo:       {style: createMessageStyle(ColorInfo, false, false), label: "Info: "},
+			app.MessageUser:       {style: createMessageStyle(p.User, true, false), label: "You: "},
+			app.MessageAssistant:  {style: createMessageStyle(p.Assistant, p.Bold, false), label: "Agent: "},
+			app.MessageToolInput:  {style: createMessageStyle(p.ToolInput, p.Bold, false), label: "Tool Input: "},
+			app.MessageToolOutput: {style: createMessageStyle(p.ToolOutput, p.Bold, false), label: "Tool Output: "},
+			app.MessageSystem:     {style: createMessageStyle(p.System, p.Bold, true), label: "System: "},
+			app.MessageError:      {style: createMessageStyle(p.Error, true, false), label: "Error: "},
+			app.MessageDebug:      {style: createMessageStyle(p.Debug, p.Bold, true), label: "Debug: "},
+			app.MessageInfo:       {style: createMessageStyle(p.Info, p.Bold, false), label: "Info: "},
+			app.MessageThought:    {style: createMessageStyle(p.Thought, p.Bold, true), label: "Thinking: "},
+			app.MessagePlan:       {style: createMessageStyle(p.Info, true, false), label: "Plan:\n"},
 		},
+		syntax: newSyntaxStyles(p),
+		time:   lipgloss.NewStyle().Foreground(lipgloss.Color(p.Gray)),
 	}
 }
 
@@ -51,8 +196,11 @@ func DefaultMessageTheme() *MessageTheme {
 func (t *MessageTheme) GetConfig(msgType app.MessageType) (lipgloss.Style, string) {
 	cfg, ok := t.configs[msgType]
 	if !ok {
-		// Default to assistant style for unknown types
+		// Default to assistant style for unknown types, labelled with the raw type
 		cfg = t.configs[app.MessageAssistant]
+		if msgType != "" {
+			cfg.label = string(msgType) + ": "
+		}
 	}
 	return cfg.style, cfg.label
 }
@@ -0,0 +1,15 @@
+package ui
+
+// DefaultConfirmPromptTokens is the estimated prompt size above which sending
+// asks for confirmation by default
+const DefaultConfirmPromptTokens = 25000
+
+// bytesPerToken is the rough ratio of text to tokens used for estimates. Real
+// tokenizers differ by model and language; this is close enough for English
+// text and code to tell a question from a pasted file.
+const bytesPerToken = 4
+
+// estimateTokens returns roughly how many tokens text takes up
+func estimateTokens(text string) int {
+	return (len(text) + bytesPerToken - 1) / bytesPerToken
+}
@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// DefaultMaxMessageRenderLength is how many bytes of a message are rendered
+// before the rest is left out of the display
+const DefaultMaxMessageRenderLength = 100 * 1024
+
+// truncateHead keeps the first limit bytes of a message for display, noting how
+// much was left out and how to see it all. A limit of 0 or less keeps everything.
+func truncateHead(content string, limit int, number int64) string {
+	if limit <= 0 || len(content) <= limit {
+		return content
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s\n…[truncated, %d bytes; %s %d shows it in full]", content[:cut], len(content), gotoCommand, number)
+}
+
+// truncateTail keeps the last limit bytes of a streaming response for display,
+// so printing each frame stays cheap. A limit of 0 or less keeps everything.
+func truncateTail(content string, limit int) string {
+	return truncateJoinedTail(content, "", limit)
+}
+
+// truncateJoinedTail is truncateTail of head+tail, without joining all of
+// head first when most of it is cut
+func truncateJoinedTail(head, tail string, limit int) string {
+	total := len(head) + len(tail)
+	if limit <= 0 || total <= limit {
+		return head + tail
+	}
+	at := func(i int) byte {
+		if i < len(head) {
+			return head[i]
+		}
+		return tail[i-len(head)]
+	}
+	cut := total - limit
+	for cut < total && !utf8.RuneStart(at(cut)) {
+		cut++
+	}
+
+	notice := fmt.Sprintf("…[%d earlier bytes not shown]\n", cut)
+	if cut >= len(head) {
+		return notice + tail[cut-len(head):]
+	}
+	return notice + head[cut:] + tail
+}
@@ -2,16 +2,24 @@ package ui
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 	"github.com/ron/tui_acp/tui/app"
+	"github.com/ron/tui_acp/tui/clock"
 )
 
 // Message types for tea.Model communication
 type (
-	acpUpdateMsg struct{ text string }
-	acpErrorMsg  struct{ err error }
-	connectMsg   struct{ err error }
+	acpUpdateMsg  struct{ event app.UpdateEvent }
+	connectMsg    struct{ err error }
+	healthTickMsg struct{}
 )
 
 // Model represents the TUI state - a thin coordinator that composes
@@ -23,35 +31,179 @@ type Model struct {
 	// Components
 	inputBox InputBox
 	view     ViewRenderer
-	spinner  HexSpinner
+	spinner  Spinner
 
 	// External dependencies
 	app        *app.App
-	updateChan chan string
-	errChan    chan error
+	updateChan chan app.UpdateEvent
 	address    string
+
+	healthInterval time.Duration // How often connection health is refreshed (0 = never)
+	confirmPaste   bool          // Ask before sending a multi-line paste
+	confirmTokens  int           // Ask before sending a prompt estimated above this many tokens (0 = never)
+	sendOnPaste    bool          // Send a single-line paste right away
+	numberMessages bool          // Prefix printed messages with their number
+	maxRender      int           // Bytes of a message rendered before the rest is left out (0 = all)
+	reload         func() (Reloaded, error)
+}
+
+// Options contains optional presentation settings for the TUI model
+type Options struct {
+	Palette Palette
+
+	// SimpleSpinner forces the plain ASCII spinner. It is also used
+	// automatically when the terminal only supports ASCII output.
+	SimpleSpinner bool
+
+	// SpinnerDelay is how long a request must be pending before the spinner appears
+	SpinnerDelay time.Duration
+
+	// HeartbeatInterval is how often the connection health indicator refreshes.
+	// It should match the client heartbeat; 0 hides the indicator.
+	HeartbeatInterval time.Duration
+
+	// SpinnerSeed makes the spinner animation deterministic when non-zero,
+	// so demos and recordings are reproducible
+	SpinnerSeed int64
+
+	// Clock is the time source for elapsed-time display (nil = system clock)
+	Clock clock.Clock
+
+	// Reload re-reads the configuration for the /reload command (nil = unavailable)
+	Reload func() (Reloaded, error)
+
+	// ConfirmMultilinePaste asks before a paste spanning several lines can be
+	// sent, so an accidental paste doesn't fire off a prompt
+	ConfirmMultilinePaste bool
+
+	// SendSingleLinePaste sends the input as soon as a single line is pasted into
+	// it, instead of waiting for Enter. A trailing newline still counts as a single
+	// line and is dropped. Multi-line pastes are never sent on their own; they wait
+	// for Enter, or for confirmation with ConfirmMultilinePaste.
+	//
+	// Both options rely on bracketed paste, which delivers a paste as one message.
+	// Terminals without it deliver pasted text as keystrokes, each newline acting
+	// as Enter, so neither option applies there.
+	SendSingleLinePaste bool
+
+	// ConfirmPromptTokens asks before sending a prompt estimated at more tokens
+	// than this, which may not fit the agent's context or may cost a lot
+	// (0 = never ask)
+	ConfirmPromptTokens int
+
+	// GrepLineWidth cuts grep match lines in the transcript to this many columns
+	// with "…" (0 = the terminal width, negative = show whole lines, wrapped)
+	GrepLineWidth int
+
+	// Timestamps prefixes each printed message with the time it was added
+	Timestamps bool
+
+	// SyntaxHighlight colors code in tool output, such as grep matches, by the
+	// language of the file it came from, in the palette's syntax colors. Files in
+	// languages the highlighter doesn't know are shown uncolored.
+	SyntaxHighlight bool
+
+	// MaxMessageRenderLength caps how many bytes of a single message are rendered,
+	// so a pathological response can't stall the display. The conversation keeps
+	// the full text; /goto prints a truncated message in full. 0 = no cap.
+	MaxMessageRenderLength int
+
+	// NumberMessages prefixes each printed message with its number, as [#12],
+	// so it can be referred to and shown again with /goto
+	NumberMessages bool
+}
+
+// Reloaded is the part of a re-read configuration that applies to a running TUI
+type Reloaded struct {
+	Palette  Palette
+	Warnings []string // Changes that need a restart to take effect
+}
+
+// reloadCommand re-reads the configuration instead of being sent to the agent
+const reloadCommand = "/reload"
+
+// gotoCommand reprints a numbered message, as /goto 12, instead of being sent to the agent
+const gotoCommand = "/goto"
+
+// saveCommand writes the conversation to a file, as /save notes.md
+const saveCommand = "/save"
+
+// modeCommand lists the agent's session modes, or switches mode as /mode code
+const modeCommand = "/mode"
+
+// infoCommand shows what was negotiated with the agent, for diagnostics
+const infoCommand = "/info"
+
+// findCommand searches the conversation, as /find some words
+const findCommand = "/find"
+
+// retryCommand drops the answer to the last message and sends that message again
+const retryCommand = "/retry"
+
+// DefaultSpinnerDelay hides the spinner for responses faster than this
+const DefaultSpinnerDelay = 200 * time.Millisecond
+
+// DefaultOptions returns the default TUI options
+func DefaultOptions() Options {
+	return Options{
+		Palette:                DarkPalette(),
+		SpinnerDelay:           DefaultSpinnerDelay,
+		MaxMessageRenderLength: DefaultMaxMessageRenderLength,
+		ConfirmPromptTokens:    DefaultConfirmPromptTokens,
+		Clock:                  clock.NewRealClock(),
+	}
 }
 
 // NewModel creates a new TUI model
-func NewModel(application *app.App, updateChan chan string, address string) Model {
+func NewModel(application *app.App, updateChan chan app.UpdateEvent, address string, opts Options) Model {
+	inputBox := NewInputBox("Type a message...")
+	inputBox.SetPalette(opts.Palette)
+
+	view := NewViewRendererWithPalette(80, opts.Palette)
+	view.SetSpinnerDelay(opts.SpinnerDelay)
+	view.SetGrepLineWidth(opts.GrepLineWidth)
+	view.SetSyntaxHighlight(opts.SyntaxHighlight)
+	view.SetTimestamps(opts.Timestamps)
+	view.SetMaxRenderLength(opts.MaxMessageRenderLength)
+
+	state := NewChatState()
+	if opts.Clock != nil {
+		state = NewChatStateWithClock(opts.Clock)
+	}
+
 	return Model{
-		state:      NewChatState(),
-		inputBox:   NewInputBox("Type a message..."),
-		view:       NewViewRenderer(80),
-		spinner:    NewHexSpinner(),
+		state:      state,
+		inputBox:   inputBox,
+		view:       view,
+		spinner:    newSpinner(opts),
 		app:        application,
 		updateChan: updateChan,
-		errChan:    make(chan error, 10),
 		address:    address,
+
+		healthInterval: opts.HeartbeatInterval,
+		confirmPaste:   opts.ConfirmMultilinePaste,
+		sendOnPaste:    opts.SendSingleLinePaste,
+		confirmTokens:  opts.ConfirmPromptTokens,
+		numberMessages: opts.NumberMessages,
+		maxRender:      opts.MaxMessageRenderLength,
+		reload:         opts.Reload,
+	}
+}
+
+// newSpinner selects the spinner implementation for the terminal
+func newSpinner(opts Options) Spinner {
+	if opts.SimpleSpinner || lipgloss.ColorProfile() == termenv.Ascii {
+		return NewASCIISpinner()
+	}
+	if opts.SpinnerSeed != 0 {
+		return NewHexSpinnerWithRand(opts.Palette.Spinner, rand.New(rand.NewSource(opts.SpinnerSeed)))
 	}
+	return NewHexSpinnerWithColor(opts.Palette.Spinner)
 }
 
 // Init initializes the TUI
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(
-		Connect(m.address, m.updateChan, m.app),
-		waitForError(m.errChan),
-	)
+	return Connect(m.address, m.updateChan, m.app)
 }
 
 // Update handles messages and updates the model
@@ -61,12 +213,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleConnect(msg)
 	case acpUpdateMsg:
 		return m.handleACPUpdate(msg)
-	case acpErrorMsg:
-		return m.handleACPError(msg)
 	case TickMsg:
 		return m.handleTick(msg)
+	case healthTickMsg:
+		return m.handleHealthTick()
 	case tea.KeyMsg:
 		return m.handleKeyMsg(msg)
+	case editorFinishedMsg:
+		return m.handleEditorFinished(msg)
+	case modeSetMsg:
+		return m.handleModeSet(msg)
 	case tea.WindowSizeMsg:
 		m.view.SetWidth(msg.Width)
 	}
@@ -99,46 +255,91 @@ func (m Model) handleConnect(msg connectMsg) (tea.Model, tea.Cmd) {
 	}
 
 	m.state.SetConnected()
+	m.state.Health = m.app.ConnectionHealth()
+	modes, current := m.app.Modes()
+	m.state.Mode = modeName(modes, current)
 
-	// Print welcome header
+	// Print welcome header, then any conversation restored from an earlier run
 	header, separator, welcome := m.view.RenderWelcome(m.address)
-	return m, tea.Batch(
+	prints := []tea.Cmd{
 		tea.Println(header),
 		tea.Println(separator),
 		tea.Println(welcome),
 		tea.Println(""),
+	}
+	messages, _ := m.app.Snapshot()
+	prints = append(prints, m.printNewMessages(messages)...)
+	return m, tea.Batch(
+		tea.Sequence(prints...),
 		waitForUpdate(m.updateChan),
+		m.healthTick(),
 	)
 }
 
+// handleHealthTick refreshes the connection health indicator
+func (m Model) handleHealthTick() (tea.Model, tea.Cmd) {
+	m.state.Health = m.app.ConnectionHealth()
+	m.state.Watches = m.app.WatchCount()
+	if !m.state.Health.Connected {
+		return m, nil
+	}
+	return m, m.healthTick()
+}
+
+// healthTick schedules the next health refresh, or nil when monitoring is off
+func (m Model) healthTick() tea.Cmd {
+	if m.healthInterval <= 0 {
+		return nil
+	}
+	return tea.Tick(m.healthInterval, func(time.Time) tea.Msg {
+		return healthTickMsg{}
+	})
+}
+
 // handleACPUpdate handles update messages from the ACP layer
 func (m Model) handleACPUpdate(msg acpUpdateMsg) (tea.Model, tea.Cmd) {
-	messages, _ := m.app.GetState()
-	var cmds []tea.Cmd
+	messages, _ := m.app.Snapshot()
 
 	// Print any new completed messages
-	newMessages := m.state.UpdatePrintedCount(messages)
-	for _, newMsg := range newMessages {
-		rendered := m.view.RenderMessage(newMsg)
-		cmds = append(cmds, tea.Println(rendered))
-	}
-
-	// OnMessageComplete sends empty string as explicit completion signal
-	// This is the most reliable way to detect when the response is done
-	if msg.text == "" {
+	cmds := m.printNewMessages(messages)
+
+	switch msg.event.Kind {
+	case app.UpdateChunk:
+		m.state.Streaming = m.state.Loading
+	case app.UpdateToolInput:
+		m.state.SetActiveTool(msg.event.Method)
+	case app.UpdateToolOutput:
+		m.state.SetActiveTool("")
+		m.state.Watches = m.app.WatchCount()
+	case app.UpdateComplete:
+		// OnMessageComplete sends an explicit completion event when the response is done
 		m.state.SetLoading(false)
+		if m.state.Cancelled {
+			m.state.Cancelled = false
+			cmds = append(cmds, tea.Println(m.view.RenderCancelled()))
+		}
+	case app.UpdateError:
+		m.state.SetError(msg.event.Err)
+	case app.UpdateMode:
+		m.state.Mode = modeName(msg.event.Modes, msg.event.Text)
+	case app.UpdateConnection:
+		m.state.Health = m.app.ConnectionHealth()
+		if msg.event.Err != nil {
+			cmds = append(cmds, tea.Println(m.view.RenderError(msg.event.Err)))
+		}
+	case app.UpdatePermission:
+		m.state.SetPrompt(msg.event.Prompt)
+	case app.UpdatePromptDone:
+		if !m.state.DropPrompt(msg.event.Prompt) {
+			question, _ := m.state.ChoosePrompt(-1)
+			cmds = append(cmds, tea.Println(m.view.RenderPromptExpired(question)))
+		}
 	}
 
 	cmds = append(cmds, waitForUpdate(m.updateChan))
 	return m, tea.Batch(cmds...)
 }
 
-// handleACPError handles error messages from async operations
-func (m Model) handleACPError(msg acpErrorMsg) (tea.Model, tea.Cmd) {
-	m.state.SetError(msg.err)
-	return m, waitForError(m.errChan)
-}
-
 // handleTick handles spinner animation tick messages
 func (m Model) handleTick(msg TickMsg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
@@ -148,75 +349,334 @@ func (m Model) handleTick(msg TickMsg) (tea.Model, tea.Cmd) {
 
 // handleKeyMsg handles keyboard input messages
 func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.state.Prompt != nil {
+		return m.handlePromptKey(msg)
+	}
+	if m.state.PasteLines > 0 {
+		return m.handlePasteConfirmKey(msg)
+	}
+	if m.state.LargePromptTokens > 0 {
+		return m.handleLargePromptKey(msg)
+	}
+
+	// Ctrl+X Ctrl+E opens the input in $EDITOR, as in bash
+	if m.state.CtrlXPending {
+		m.state.CtrlXPending = false
+		if msg.String() == "ctrl+e" {
+			return m, openEditor(m.inputBox.Value())
+		}
+	}
+
 	switch msg.String() {
-	case "ctrl+c", "esc":
+	case "ctrl+x":
+		m.state.CtrlXPending = true
+		return m, nil
+	case "esc":
+		// Esc stops a response in progress; otherwise it quits
+		if m.state.Loading {
+			return m.handleCancel()
+		}
+		return m, tea.Quit
+	case "ctrl+c":
 		return m, tea.Quit
 	default:
 		return m.handleTextInput(msg)
 	}
 }
 
+// handlePromptKey answers a pending permission prompt. Up and down pick an
+// option and Enter chooses it; a digit chooses that option directly, and y or n
+// the first option that allows or denies. Other keys are ignored so typing can't
+// slip past the question.
+func (m Model) handlePromptKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var question, answer string
+	switch key := msg.String(); key {
+	case "up", "shift+tab":
+		m.state.MovePromptIndex(-1)
+		return m, nil
+	case "down", "tab":
+		m.state.MovePromptIndex(1)
+		return m, nil
+	case "enter":
+		question, answer = m.state.ChoosePrompt(m.state.PromptIndex)
+	case "y", "Y":
+		question, answer = m.state.AnswerPrompt(true)
+	case "n", "N", "esc":
+		question, answer = m.state.AnswerPrompt(false)
+	case "ctrl+c":
+		// Release the waiting agent requests before quitting
+		for m.state.Prompt != nil {
+			m.state.ChoosePrompt(-1)
+		}
+		return m, tea.Quit
+	default:
+		if len(key) != 1 || key[0] < '1' || key[0] > '9' {
+			return m, nil
+		}
+		index := int(key[0] - '1')
+		if index >= len(m.state.Prompt.Options) {
+			return m, nil
+		}
+		question, answer = m.state.ChoosePrompt(index)
+	}
+	return m, tea.Println(m.view.RenderPromptAnswer(question, answer))
+}
+
+// handlePasteConfirmKey answers the question shown after a multi-line paste.
+// Enter sends; any other key returns to editing, and is applied unless it is Esc.
+func (m Model) handlePasteConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.state.PasteLines = 0
+	switch msg.String() {
+	case "esc":
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	return m.handleTextInput(msg)
+}
+
+// handleLargePromptKey answers the question shown before sending a large prompt.
+// Enter sends; any other key returns to editing, and is applied unless it is Esc.
+func (m Model) handleLargePromptKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.state.LargePromptTokens = 0
+	switch msg.String() {
+	case "enter":
+		m.state.LargePromptConfirmed = true
+	case "esc":
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	return m.handleTextInput(msg)
+}
+
+// handleEditorFinished puts the text saved in the external editor into the input
+// box. If the editor failed or the text was left empty, the input is kept as it was.
+func (m Model) handleEditorFinished(msg editorFinishedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		return m, tea.Println(m.view.RenderError(msg.err))
+	}
+	if strings.TrimSpace(msg.text) == "" {
+		return m, tea.Println(m.view.RenderNotice("Editor closed without text; input kept"))
+	}
+	m.inputBox.SetValue(msg.text)
+	return m, nil
+}
+
+// handleReload applies a re-read configuration. A configuration that fails to
+// load leaves the current one in place.
+func (m Model) handleReload() (tea.Model, tea.Cmd) {
+	if m.reload == nil {
+		return m, tea.Println(m.view.RenderNotice("Nothing to reload: no config file was given"))
+	}
+
+	reloaded, err := m.reload()
+	if err != nil {
+		return m, tea.Println(m.view.RenderError(fmt.Errorf("configuration not reloaded: %w", err)))
+	}
+
+	m.inputBox.SetPalette(reloaded.Palette)
+	m.view.SetPalette(reloaded.Palette)
+
+	cmds := []tea.Cmd{tea.Println(m.view.RenderNotice("Configuration reloaded"))}
+	for _, warning := range reloaded.Warnings {
+		cmds = append(cmds, tea.Println(m.view.RenderNotice("  "+warning)))
+	}
+	return m, tea.Sequence(cmds...)
+}
+
+// handleCancel cancels the prompt in flight and stops loading. The notice is
+// printed on completion so it follows any partial response.
+func (m Model) handleCancel() (tea.Model, tea.Cmd) {
+	m.state.Cancelled = m.app.CancelPrompt()
+	m.state.SetLoading(false)
+	m.state.SetActiveTool("")
+	return m, nil
+}
+
 // handleTextInput handles regular text input and submission
 func (m Model) handleTextInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	submitted, userMessage := m.inputBox.Update(msg)
+	if !submitted && msg.Paste {
+		multiline := strings.ContainsAny(strings.TrimRight(string(msg.Runes), "\r\n"), "\r\n")
+		switch {
+		case multiline && m.confirmPaste:
+			m.state.PasteLines = m.inputBox.LineCount()
+		case !multiline && m.sendOnPaste:
+			userMessage = strings.TrimRight(m.inputBox.Value(), "\n")
+			m.inputBox.Clear()
+			submitted = userMessage != ""
+		}
+	}
 	if !submitted {
 		return m, nil
 	}
+	if strings.TrimSpace(userMessage) == reloadCommand {
+		return m.handleReload()
+	}
+	if strings.TrimSpace(userMessage) == infoCommand {
+		return m.handleInfo()
+	}
+	if strings.TrimSpace(userMessage) == retryCommand {
+		return m.handleRetry()
+	}
+	if fields := strings.Fields(userMessage); len(fields) > 0 && fields[0] == gotoCommand {
+		return m.handleGoto(fields[1:])
+	}
+	if fields := strings.Fields(userMessage); len(fields) > 0 && fields[0] == saveCommand {
+		return m.handleSave(fields[1:])
+	}
+	if fields := strings.Fields(userMessage); len(fields) > 0 && fields[0] == modeCommand {
+		return m.handleMode(fields[1:])
+	}
+	if fields := strings.Fields(userMessage); len(fields) > 0 && fields[0] == findCommand {
+		// The query is the text after the command, with its own spacing kept
+		return m.handleFind(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(userMessage), findCommand)))
+	}
+
+	// Ask before sending a prompt large enough to be a mistake, keeping it in
+	// the input box meanwhile
+	confirmed := m.state.LargePromptConfirmed
+	m.state.LargePromptConfirmed = false
+	if tokens := estimateTokens(userMessage); m.confirmTokens > 0 && tokens > m.confirmTokens && !confirmed {
+		m.inputBox.SetValue(userMessage)
+		m.state.LargePromptTokens = tokens
+		return m, nil
+	}
 
 	// Add message to conversation
 	m.app.AddUserMessage(userMessage)
 
 	// Print new messages
-	messages := m.app.GetMessages()
-	var cmds []tea.Cmd
-	newMessages := m.state.UpdatePrintedCount(messages)
-	for _, msg := range newMessages {
-		rendered := m.view.RenderMessage(msg)
-		cmds = append(cmds, tea.Println(rendered))
-	}
+	messages, _ := m.app.Snapshot()
+	cmds := m.printNewMessages(messages)
 
 	// Start loading
 	m.state.SetLoading(true)
 
-	// Send to server asynchronously
-	errChan := m.errChan
+	// Send to server asynchronously; failures arrive as UpdateError events
+	application := m.app
 	go func() {
-		if err := m.app.SendPromptToAgent(context.Background(), userMessage); err != nil {
-			select {
-			case errChan <- err:
-			default:
-			}
-		}
+		application.SendPromptToAgent(context.Background(), userMessage)
 	}()
 
 	cmds = append(cmds, m.spinner.Init())
 	return m, tea.Batch(cmds...)
 }
 
-// Channel monitoring commands
+// printNewMessages returns print commands for messages not yet printed.
+// Tool output directly following its tool call is rendered as part of that call.
+func (m *Model) printNewMessages(messages []app.Message) []tea.Cmd {
+	start := m.state.UpdatePrinted(messages)
+	newMessages := messages[start:]
+
+	cmds := make([]tea.Cmd, 0, len(newMessages))
+	for i, msg := range newMessages {
+		var prev *app.Message
+		if idx := start + i - 1; idx >= 0 {
+			prev = &messages[idx]
+		}
+		msg.Content = truncateHead(msg.Content, m.maxRender, msg.ID)
+		rendered := m.view.RenderMessageAfter(prev, msg)
+		if m.numberMessages {
+			rendered = m.view.RenderMessageNumber(msg.ID) + rendered
+		}
+		cmds = append(cmds, tea.Println(rendered))
+	}
+	return cmds
+}
 
-func waitForUpdate(updateChan chan string) tea.Cmd {
-	return func() tea.Msg {
-		text, ok := <-updateChan
-		if !ok {
-			return nil
+// handleGoto prints a message again below the conversation. The transcript lives
+// in the terminal's scrollback, which the TUI can't scroll, so the message is
+// brought to the bottom instead.
+func (m Model) handleGoto(args []string) (tea.Model, tea.Cmd) {
+	messages, _ := m.app.Snapshot()
+	if len(messages) == 0 {
+		return m, tea.Println(m.view.RenderNotice("No messages yet"))
+	}
+	var n int64
+	if len(args) == 1 {
+		n, _ = strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	}
+	for _, msg := range messages {
+		if msg.ID == n {
+			return m, tea.Println(m.view.RenderMessageNumber(n) + m.view.RenderMessage(msg))
 		}
-		return acpUpdateMsg{text: text}
 	}
+	return m, tea.Println(m.view.RenderNotice(fmt.Sprintf("Usage: %s N, where N is a message number from %d to %d", gotoCommand, messages[0].ID, messages[len(messages)-1].ID)))
+}
+
+// handleInfo prints the negotiated protocol, capabilities and session
+func (m Model) handleInfo() (tea.Model, tea.Cmd) {
+	info, ok := m.app.ConnectionInfo()
+	if !ok {
+		return m, tea.Println(m.view.RenderNotice("Not connected"))
+	}
+	return m, tea.Println(m.view.RenderInfo(info))
+}
+
+// handleFind lists the messages containing query. The search ignores case
+// unless the query has an upper case letter.
+func (m Model) handleFind(query string) (tea.Model, tea.Cmd) {
+	if query == "" {
+		return m, tea.Println(m.view.RenderNotice(fmt.Sprintf("Usage: %s TEXT", findCommand)))
+	}
+	caseSensitive := strings.ToLower(query) != query
+	return m, tea.Println(m.view.RenderSearchHits(query, m.app.Search(query, caseSensitive)))
 }
 
-func waitForError(errChan chan error) tea.Cmd {
+// handleRetry drops everything after the last user message and sends it again.
+// The dropped messages stay in the scrollback above a notice of the retry.
+func (m Model) handleRetry() (tea.Model, tea.Cmd) {
+	if m.state.Loading {
+		return m, tea.Println(m.view.RenderNotice("Wait for the response to finish, or press Esc to stop it, before retrying"))
+	}
+	msg, err := m.app.RetryLastUserMessage(context.Background())
+	if err != nil {
+		return m, tea.Println(m.view.RenderError(err))
+	}
+
+	m.state.LastPrintedID = msg.ID
+	m.state.SetLoading(true)
+
+	return m, tea.Batch(
+		tea.Println(m.view.RenderNotice(fmt.Sprintf("Retrying message %d", msg.ID))),
+		m.spinner.Init(),
+	)
+}
+
+// handleSave exports the conversation to the file given, as JSON for .json files
+// and Markdown otherwise. Without a file it is saved as Markdown under a
+// timestamped name in the working directory.
+func (m Model) handleSave(args []string) (tea.Model, tea.Cmd) {
+	if len(args) > 1 {
+		return m, tea.Println(m.view.RenderNotice(fmt.Sprintf("Usage: %s [file.md|file.json]", saveCommand)))
+	}
+
+	path := app.DefaultExportPath(app.ExportMarkdown, m.state.clock.Now())
+	if len(args) == 1 {
+		path = args[0]
+	}
+	if err := m.app.ExportConversation(path, app.ExportFormatForPath(path)); err != nil {
+		return m, tea.Println(m.view.RenderError(err))
+	}
+	return m, tea.Println(m.view.RenderNotice(fmt.Sprintf("Conversation saved to %s", path)))
+}
+
+// Channel monitoring commands
+
+func waitForUpdate(updateChan chan app.UpdateEvent) tea.Cmd {
 	return func() tea.Msg {
-		err, ok := <-errChan
+		event, ok := <-updateChan
 		if !ok {
 			return nil
 		}
-		return acpErrorMsg{err: err}
+		return acpUpdateMsg{event: event}
 	}
 }
 
 // Connect initiates connection to the server
-func Connect(address string, updateChan chan string, application *app.App) tea.Cmd {
+func Connect(address string, updateChan chan app.UpdateEvent, application *app.App) tea.Cmd {
 	return func() tea.Msg {
 		err := application.Connect(context.Background(), address)
 		return connectMsg{err: err}
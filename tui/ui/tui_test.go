@@ -0,0 +1,62 @@
+package ui
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ron/tui_acp/tui/app"
+	"github.com/ron/tui_acp/tui/clock"
+)
+
+// newTestModel returns a model over an app that isn't connected, with its
+// clock stopped at now
+func newTestModel(a *app.App, now time.Time) Model {
+	return NewModel(a, make(chan app.UpdateEvent), "", Options{Clock: clock.NewFakeClock(now)})
+}
+
+// submit types text into the model's input and presses enter
+func submit(m Model, text string) Model {
+	m.inputBox.SetValue(text)
+	updated, _ := m.handleTextInput(tea.KeyMsg{Type: tea.KeyEnter})
+	return updated.(Model)
+}
+
+func TestSaveCommand(t *testing.T) {
+	t.Chdir(t.TempDir())
+	a := app.New(app.Config{RenderMarkdown: NewMessageRenderer(0).RenderConversationMarkdown})
+	a.AddUserMessage("hello")
+	a.AddMessage(string(app.MessageAssistant), "hi there")
+	m := newTestModel(a, time.Date(2025, 3, 4, 9, 30, 0, 0, time.UTC))
+
+	tests := []struct {
+		command string
+		path    string
+		want    string
+	}{
+		// Without a file the export is Markdown named after the time
+		{"/save", "conversation-20250304-093000.md", "**You:**\n\nhello\n\n**Agent:**\n\nhi there\n"},
+		{"/save notes.md", "notes.md", "**You:**\n\nhello\n"},
+		{"/save chat.json", "chat.json", `"content": "hi there"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			submit(m, tt.command)
+			data, err := os.ReadFile(tt.path)
+			if err != nil {
+				t.Fatalf("%s didn't write %s: %v", tt.command, tt.path, err)
+			}
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("%s wrote %q, want it to contain %q", tt.path, data, tt.want)
+			}
+		})
+	}
+
+	// The command itself isn't sent or added to the conversation
+	if messages := a.GetMessages(); len(messages) != 2 {
+		t.Errorf("conversation has %d messages after saving, want 2", len(messages))
+	}
+}
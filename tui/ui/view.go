@@ -2,9 +2,12 @@ package ui
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/ron/tui_acp/tui/app"
+	"github.com/ron/tui_acp/tui/client"
 )
 
 // TUIStyles holds all the styles used by the TUI view
@@ -13,21 +16,45 @@ type TUIStyles struct {
 	Separator lipgloss.Style
 	Error     lipgloss.Style
 	Help      lipgloss.Style
+	Prompt    lipgloss.Style
+	Match     lipgloss.Style // Text matching a /find search
+
+	// Connection health indicator
+	HealthGood     lipgloss.Style
+	HealthDegraded lipgloss.Style
+	HealthDown     lipgloss.Style
 }
 
 // DefaultTUIStyles returns the default TUI styles
 func DefaultTUIStyles() TUIStyles {
+	return NewTUIStyles(DarkPalette())
+}
+
+// NewTUIStyles returns the TUI styles for a palette
+func NewTUIStyles(p Palette) TUIStyles {
 	return TUIStyles{
 		Header: lipgloss.NewStyle().
-			Foreground(lipgloss.Color(ColorAssistant)).
+			Foreground(lipgloss.Color(p.Assistant)).
 			Bold(true),
 		Separator: lipgloss.NewStyle().
-			Foreground(lipgloss.Color(ColorGray)),
+			Foreground(lipgloss.Color(p.Gray)),
 		Error: lipgloss.NewStyle().
-			Foreground(lipgloss.Color(ColorError)).
+			Foreground(lipgloss.Color(p.Error)).
 			Bold(true),
 		Help: lipgloss.NewStyle().
-			Foreground(lipgloss.Color(ColorGray)),
+			Foreground(lipgloss.Color(p.Gray)),
+		Prompt: lipgloss.NewStyle().
+			Foreground(lipgloss.Color(p.System)).
+			Bold(true),
+		Match: lipgloss.NewStyle().
+			Foreground(lipgloss.Color(p.System)).
+			Reverse(true),
+		HealthGood: lipgloss.NewStyle().
+			Foreground(lipgloss.Color(p.Spinner)),
+		HealthDegraded: lipgloss.NewStyle().
+			Foreground(lipgloss.Color(p.System)),
+		HealthDown: lipgloss.NewStyle().
+			Foreground(lipgloss.Color(p.Error)),
 	}
 }
 
@@ -36,16 +63,59 @@ func DefaultTUIStyles() TUIStyles {
 type ViewRenderer struct {
 	styles          TUIStyles
 	messageRenderer MessageRenderer
+	spinnerDelay    time.Duration // How long loading must last before the spinner shows
+	maxRenderLength int           // Bytes of the streaming response rendered each frame (0 = all)
+	stream          *wrapCache    // Wrapping of the streaming response, kept between frames
 }
 
 // NewViewRenderer creates a new view renderer
 func NewViewRenderer(width int) ViewRenderer {
+	return NewViewRendererWithPalette(width, DarkPalette())
+}
+
+// NewViewRendererWithPalette creates a new view renderer styled with a palette
+func NewViewRendererWithPalette(width int, p Palette) ViewRenderer {
 	return ViewRenderer{
-		styles:          DefaultTUIStyles(),
-		messageRenderer: NewMessageRenderer(width),
+		styles:          NewTUIStyles(p),
+		messageRenderer: NewMessageRendererWithTheme(width, NewMessageTheme(p)),
+		stream:          &wrapCache{},
 	}
 }
 
+// SetPalette restyles the view and its messages, keeping the wrap width
+func (v *ViewRenderer) SetPalette(p Palette) {
+	v.styles = NewTUIStyles(p)
+	v.messageRenderer.theme = NewMessageTheme(p)
+}
+
+// SetGrepLineWidth sets the width grep match lines are cut to (0 = wrap width,
+// negative = whole lines)
+func (v *ViewRenderer) SetGrepLineWidth(width int) {
+	v.messageRenderer.SetGrepLineWidth(width)
+}
+
+// SetTimestamps controls whether printed messages show the time they were added
+func (v *ViewRenderer) SetTimestamps(on bool) {
+	v.messageRenderer.SetTimestamps(on)
+}
+
+// SetSyntaxHighlight turns syntax highlighting of code in tool output on or off
+func (v *ViewRenderer) SetSyntaxHighlight(on bool) {
+	v.messageRenderer.SetSyntaxHighlight(on)
+}
+
+// SetMaxRenderLength caps how many bytes of the streaming response are rendered,
+// showing only its end (0 = no cap)
+func (v *ViewRenderer) SetMaxRenderLength(limit int) {
+	v.maxRenderLength = limit
+}
+
+// SetSpinnerDelay sets how long loading must last before the spinner is shown,
+// so quick responses don't flash it
+func (v *ViewRenderer) SetSpinnerDelay(delay time.Duration) {
+	v.spinnerDelay = delay
+}
+
 // SetWidth updates the width for the message renderer
 func (v *ViewRenderer) SetWidth(width int) {
 	v.messageRenderer.SetWidth(width)
@@ -74,15 +144,37 @@ func (v ViewRenderer) RenderMessage(msg app.Message) string {
 	return v.messageRenderer.RenderMessage(msg)
 }
 
-// RenderStreamingResponse renders the current streaming response
+// RenderMessageAfter renders a message in the context of the message printed before it.
+// Tool calls are printed without a trailing blank line so their output can follow
+// directly beneath them as a group. Later parts of a response flushed in parts
+// carry on without a label of their own.
+func (v ViewRenderer) RenderMessageAfter(prev *app.Message, msg app.Message) string {
+	switch {
+	case msg.Continued && prev != nil && prev.Type == msg.Type:
+		return v.messageRenderer.RenderContinuation(msg)
+	case msg.Type == app.MessageToolInput:
+		return strings.TrimSuffix(v.messageRenderer.RenderMessage(msg), "\n")
+	case msg.Type == app.MessageToolOutput && prev != nil && prev.Type == app.MessageToolInput:
+		return v.messageRenderer.RenderToolResult(msg)
+	default:
+		return v.messageRenderer.RenderMessage(msg)
+	}
+}
+
+// RenderMessageNumber renders the subtle [#n] prefix of a numbered message
+func (v ViewRenderer) RenderMessageNumber(n int64) string {
+	return v.styles.Help.Render(fmt.Sprintf("[#%d] ", n))
+}
+
+// RenderStreamingResponse renders the current streaming response. Only the
+// text added since the last frame is wrapped again.
 func (v ViewRenderer) RenderStreamingResponse(response string) string {
 	if response == "" {
 		return ""
 	}
-	return v.messageRenderer.RenderMessage(app.Message{
-		Type:    app.MessageAssistant,
-		Content: response,
-	}) + "\n"
+	// The cache holds the whole response, wrapped; cutting the raw response
+	// instead would shift its start every frame and defeat the cache
+	return v.messageRenderer.RenderStreaming(response, v.stream, v.maxRenderLength) + "\n"
 }
 
 // RenderError renders an error message
@@ -93,21 +185,205 @@ func (v ViewRenderer) RenderError(err error) string {
 	return v.styles.Error.Render(fmt.Sprintf("Error: %v\n", err))
 }
 
-// RenderSpinner renders the loading spinner
-func (v ViewRenderer) RenderSpinner(spinner HexSpinner) string {
+// RenderNotice renders a line of client feedback, such as the outcome of a command
+func (v ViewRenderer) RenderNotice(text string) string {
+	return v.styles.Help.Render(text)
+}
+
+// RenderSpinner renders the loading spinner, naming the running tool if there is one
+func (v ViewRenderer) RenderSpinner(spinner Spinner, activeTool string) string {
+	if activeTool != "" {
+		return spinner.View() + " Running " + activeTool + "...\n"
+	}
 	return spinner.View() + " Processing...\n"
 }
 
+// RenderResponding renders the quiet indicator shown once the response is streaming
+func (v ViewRenderer) RenderResponding() string {
+	return v.styles.Help.Render("• Agent is responding...") + "\n"
+}
+
 // RenderHelp renders the help text
 func (v ViewRenderer) RenderHelp() string {
-	return v.styles.Help.Render("Enter: send • Ctrl+C: quit")
+	return v.styles.Help.Render("Enter: send • Alt+Enter: newline • Ctrl+X Ctrl+E: editor • Ctrl+C: quit")
+}
+
+// RenderLoadingHelp renders the help text shown while a response is in progress
+func (v ViewRenderer) RenderLoadingHelp() string {
+	return v.styles.Help.Render("Esc: cancel • Ctrl+C: quit")
+}
+
+// RenderCancelled renders the notice printed when the user cancels a response
+func (v ViewRenderer) RenderCancelled() string {
+	return v.styles.Help.Render("Response cancelled")
+}
+
+// RenderPrompt renders a pending permission question and its numbered options
+// in place of the input box, marking the highlighted one
+func (v ViewRenderer) RenderPrompt(prompt *app.PermissionPrompt, selected int) string {
+	var b strings.Builder
+	b.WriteString(v.styles.Prompt.Render("? " + prompt.Question))
+	for i, opt := range prompt.Options {
+		line := fmt.Sprintf("%d. %s", i+1, opt.Name)
+		if i == selected {
+			b.WriteString("\n" + v.styles.Prompt.Render("› "+line))
+		} else {
+			b.WriteString("\n" + v.styles.Help.Render("  "+line))
+		}
+	}
+	return b.String()
+}
+
+// RenderPromptAnswer renders an answered permission question for the scrollback.
+// An empty answer means the question was dismissed.
+func (v ViewRenderer) RenderPromptAnswer(question, answer string) string {
+	if answer == "" {
+		answer = "dismissed"
+	}
+	return v.styles.Help.Render("? " + question + " " + answer)
+}
+
+// RenderPromptExpired renders a permission question that stopped waiting for an answer
+func (v ViewRenderer) RenderPromptExpired(question string) string {
+	return v.styles.Help.Render("? " + question + " no answer in time")
+}
+
+// RenderPromptHelp renders the help text shown while a prompt is pending
+func (v ViewRenderer) RenderPromptHelp() string {
+	return v.styles.Help.Render("↑/↓: select • Enter/1-9: choose • y: allow • n/Esc: deny • Ctrl+C: quit")
+}
+
+// RenderPasteConfirm renders the question shown after a multi-line paste
+func (v ViewRenderer) RenderPasteConfirm(lines int) string {
+	return v.styles.Prompt.Render(fmt.Sprintf("Send %d lines?", lines)) +
+		v.styles.Help.Render(" Enter: confirm • Esc: keep editing")
+}
+
+// RenderLargePromptConfirm renders the question asked before sending a large prompt
+func (v ViewRenderer) RenderLargePromptConfirm(tokens int) string {
+	return v.styles.Prompt.Render(fmt.Sprintf("This prompt is ~%d tokens, send anyway?", tokens)) +
+		v.styles.Help.Render(" Enter: send • Esc: keep editing")
+}
+
+// healthDownFailures is how many failed pings in a row mark the connection as down
+const healthDownFailures = 3
+
+// RenderHealth renders a colored dot for the connection health, with the last ping latency.
+// Nothing is rendered when the connection isn't monitored by a heartbeat.
+func (v ViewRenderer) RenderHealth(h client.ConnectionHealth) string {
+	if !h.Monitored {
+		return ""
+	}
+
+	switch {
+	case !h.Connected || h.ConsecutiveFailures >= healthDownFailures:
+		return v.styles.HealthDown.Render("●") + v.styles.Help.Render(" disconnected • ")
+	case h.ConsecutiveFailures > 0:
+		return v.styles.HealthDegraded.Render("●") +
+			v.styles.Help.Render(fmt.Sprintf(" %d missed pings • ", h.ConsecutiveFailures))
+	case h.LastPing.IsZero():
+		return v.styles.HealthGood.Render("●") + v.styles.Help.Render(" connected • ")
+	default:
+		return v.styles.HealthGood.Render("●") +
+			v.styles.Help.Render(fmt.Sprintf(" %v • ", h.Latency.Round(time.Millisecond)))
+	}
+}
+
+// RenderWatches renders how many file watches are active, or nothing when there are none
+func (v ViewRenderer) RenderWatches(count int) string {
+	switch count {
+	case 0:
+		return ""
+	case 1:
+		return v.styles.Help.Render("1 watch • ")
+	default:
+		return v.styles.Help.Render(fmt.Sprintf("%d watches • ", count))
+	}
+}
+
+// RenderInfo renders connection diagnostics for /info as an aligned key/value
+// block in the system message style
+func (v ViewRenderer) RenderInfo(info client.ConnectionInfo) string {
+	list := func(items []string) string {
+		if len(items) == 0 {
+			return "none"
+		}
+		return strings.Join(items, ", ")
+	}
+	agent := info.Agent
+	if agent == "" {
+		agent = "not reported"
+	}
+
+	rows := [][2]string{
+		{"Address", info.Address},
+		{"Transport", info.Transport},
+		{"Protocol", fmt.Sprintf("ACP v%d", info.ProtocolVersion)},
+		{"Agent", agent},
+		{"Agent supports", list(info.AgentCapabilities)},
+		{"Client offers", list(info.ClientCapabilities)},
+		{"Auth methods", list(info.AuthMethods)},
+		{"Session", info.SessionID},
+		{"Working dir", info.Cwd},
+	}
+	var b strings.Builder
+	b.WriteString("Connection info")
+	for _, row := range rows {
+		fmt.Fprintf(&b, "\n  %-15s %s", row[0], row[1])
+	}
+	return v.messageRenderer.RenderMessage(app.Message{Type: app.MessageSystem, Content: b.String()})
+}
+
+// RenderSearchHits lists the messages matching a /find search, one per line
+// with the match highlighted in its snippet
+func (v ViewRenderer) RenderSearchHits(query string, hits []app.SearchHit) string {
+	if len(hits) == 0 {
+		return v.styles.Help.Render(fmt.Sprintf("No messages match %q", query))
+	}
+
+	lines := make([]string, 0, len(hits)+1)
+	lines = append(lines, v.styles.Help.Render(fmt.Sprintf("%d messages match %q (show one with %s N):", len(hits), query, gotoCommand)))
+	for _, hit := range hits {
+		_, label := v.messageRenderer.theme.GetConfig(hit.Type)
+		label = strings.TrimSuffix(strings.TrimSpace(label), ":")
+		lines = append(lines, v.styles.Help.Render(fmt.Sprintf("  [#%d] %s: %s", hit.ID, label, hit.Snippet[:hit.Start]))+
+			v.styles.Match.Render(hit.Snippet[hit.Start:hit.End])+
+			v.styles.Help.Render(hit.Snippet[hit.End:]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RenderMode renders the agent's session mode, or nothing when it has no modes
+func (v ViewRenderer) RenderMode(name string) string {
+	if name == "" {
+		return ""
+	}
+	return v.styles.Help.Render(fmt.Sprintf("mode: %s • ", name))
+}
+
+// RenderModes lists the agent's session modes for /mode, marking the current one
+func (v ViewRenderer) RenderModes(modes []client.SessionMode, current string) string {
+	lines := make([]string, 0, len(modes)+1)
+	lines = append(lines, "Session modes (switch with /mode <name>):")
+	for _, mode := range modes {
+		mark := "  "
+		if mode.ID == current {
+			mark = "* "
+		}
+		line := mark + mode.Name
+		if mode.Description != "" {
+			line += " - " + mode.Description
+		}
+		lines = append(lines, line)
+	}
+	return v.styles.Help.Render(strings.Join(lines, "\n"))
 }
 
 // RenderMainView composes the main chat view from all components
 func (v ViewRenderer) RenderMainView(
 	state ChatState,
 	currentResponse string,
-	spinner HexSpinner,
+	spinner Spinner,
 	inputView string,
 ) string {
 	streamingView := v.RenderStreamingResponse(currentResponse)
@@ -118,11 +394,28 @@ func (v ViewRenderer) RenderMainView(
 	}
 
 	var spinnerView string
-	if state.Loading {
-		spinnerView = v.RenderSpinner(spinner)
+	switch {
+	case state.Loading && state.Streaming && state.ActiveTool == "":
+		spinnerView = v.RenderResponding()
+	case state.Loading && state.LoadingFor() >= v.spinnerDelay:
+		spinnerView = v.RenderSpinner(spinner, state.ActiveTool)
 	}
 
-	help := v.RenderHelp()
+	status := v.RenderHealth(state.Health) + v.RenderMode(state.Mode) + v.RenderWatches(state.Watches)
+	help := status + v.RenderHelp()
+	if state.Loading {
+		help = status + v.RenderLoadingHelp()
+	}
+	if state.PasteLines > 0 {
+		help = status + v.RenderPasteConfirm(state.PasteLines)
+	}
+	if state.LargePromptTokens > 0 {
+		help = status + v.RenderLargePromptConfirm(state.LargePromptTokens)
+	}
+	if state.Prompt != nil {
+		inputView = v.RenderPrompt(state.Prompt, state.PromptIndex)
+		help = status + v.RenderPromptHelp()
+	}
 
 	return streamingView + errorView + spinnerView + inputView + "\n" + help
 }
@@ -0,0 +1,152 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ron/tui_acp/tui/app"
+)
+
+// streamedResponse builds a response of about size bytes of prose, with a
+// paragraph break every few lines, as an agent would stream it
+func streamedResponse(size int) string {
+	var b strings.Builder
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "Line %d of the response says something about the code it looks at. ", i)
+		if i%3 == 2 {
+			b.WriteString("\n")
+		}
+		if i%12 == 11 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// chunks splits content into pieces of size bytes, as they arrive while streaming
+func chunks(content string, size int) []string {
+	var parts []string
+	for len(content) > size {
+		parts = append(parts, content[:size])
+		content = content[size:]
+	}
+	return append(parts, content)
+}
+
+func TestRenderStreamingResponseMatchesUncached(t *testing.T) {
+	for _, limit := range []int{0, 2000} {
+		t.Run(fmt.Sprintf("limit=%d", limit), func(t *testing.T) {
+			v := NewViewRenderer(80)
+			v.SetMaxRenderLength(limit)
+
+			response := ""
+			for i, chunk := range chunks(streamedResponse(8000), 37) {
+				response += chunk
+				got := v.RenderStreamingResponse(response)
+				want := v.messageRenderer.RenderStreaming(response, nil, limit) + "\n"
+				if got != want {
+					t.Fatalf("frame %d: cached render differs from a full render\ngot:\n%s\nwant:\n%s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderStreamingResponseKeepsCacheOverLimit(t *testing.T) {
+	v := NewViewRenderer(80)
+	v.SetMaxRenderLength(1000)
+
+	response := streamedResponse(5000)
+	v.RenderStreamingResponse(response)
+	done := v.stream.done
+	if done == "" {
+		t.Fatal("nothing was cached")
+	}
+
+	// Past the limit the shown part moves each frame, but the cache must still
+	// extend what it has rather than start over
+	v.RenderStreamingResponse(response + "more")
+	if !strings.HasPrefix(v.stream.done, done) {
+		t.Fatal("cache was rebuilt after the response grew past the limit")
+	}
+}
+
+func TestTruncateJoinedTail(t *testing.T) {
+	tests := []struct {
+		head, tail string
+		limit      int
+		want       string
+	}{
+		{"abc", "def", 0, "abcdef"},
+		{"abc", "def", 6, "abcdef"},
+		{"abc", "def", 4, "…[2 earlier bytes not shown]\ncdef"},
+		{"abc", "def", 2, "…[4 earlier bytes not shown]\nef"},
+		// The cut moves forward to a rune start
+		{"aé", "b", 2, "…[3 earlier bytes not shown]\nb"},
+	}
+	for _, tt := range tests {
+		if got := truncateJoinedTail(tt.head, tt.tail, tt.limit); got != tt.want {
+			t.Errorf("truncateJoinedTail(%q, %q, %d) = %q, want %q", tt.head, tt.tail, tt.limit, got, tt.want)
+		}
+		if got, want := truncateJoinedTail(tt.head, tt.tail, tt.limit), truncateTail(tt.head+tt.tail, tt.limit); got != want {
+			t.Errorf("truncateJoinedTail(%q, %q, %d) = %q, truncateTail gives %q", tt.head, tt.tail, tt.limit, got, want)
+		}
+	}
+}
+
+// BenchmarkRenderStreamingResponse renders every frame of a response streamed
+// in 256-byte chunks, for a response under the render limit and one well past
+// it. Uncached wraps the shown part of the response from scratch each frame.
+func BenchmarkRenderStreamingResponse(b *testing.B) {
+	for _, size := range []int{32 * 1024, 256 * 1024} {
+		parts := chunks(streamedResponse(size), 256)
+
+		b.Run(fmt.Sprintf("size=%dKB/cached", size/1024), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				v := NewViewRenderer(100)
+				v.SetMaxRenderLength(DefaultMaxMessageRenderLength)
+				response := ""
+				for _, part := range parts {
+					response += part
+					v.RenderStreamingResponse(response)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("size=%dKB/uncached", size/1024), func(b *testing.B) {
+			r := NewMessageRenderer(100)
+			for i := 0; i < b.N; i++ {
+				response := ""
+				for _, part := range parts {
+					response += part
+					r.RenderStreaming(truncateTail(response, DefaultMaxMessageRenderLength), nil, 0)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderSearchHits(t *testing.T) {
+	withColor(t)
+	v := NewViewRenderer(80)
+
+	if got := v.RenderSearchHits("toml", nil); !strings.Contains(got, `No messages match "toml"`) {
+		t.Errorf("no hits rendered as %q", got)
+	}
+
+	hits := []app.SearchHit{
+		{ID: 2, Type: app.MessageAssistant, Snippet: "Call json.Unmarshal(data)", Start: 10, End: 19},
+		{ID: 7, Type: app.MessageUser, Snippet: "unmarshal it", Start: 0, End: 9},
+	}
+	lines := strings.Split(v.RenderSearchHits("unmarshal", hits), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `2 messages match "unmarshal"`) {
+		t.Fatalf("rendered %q, want a heading and a line per hit", lines)
+	}
+	// Each line names its message and highlights the match alone
+	for i, want := range []string{"[#2] Agent: Call json.", "[#7] You: "} {
+		if line := lines[i+1]; !strings.Contains(line, want) || !strings.Contains(line, v.styles.Match.Render(hits[i].Snippet[hits[i].Start:hits[i].End])) {
+			t.Errorf("hit line %q, want %q and the match highlighted", line, want)
+		}
+	}
+}
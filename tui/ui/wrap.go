@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+// wrapText wraps content to width on word boundaries. CJK text has no spaces to
+// break on, so lines still too long after that are broken between CJK characters.
+func wrapText(content string, width int) string {
+	wrapped := wordwrap.String(content, width)
+
+	lines := strings.Split(wrapped, "\n")
+	for i, line := range lines {
+		if ansi.PrintableRuneWidth(line) > width {
+			lines[i] = breakCJK(line, width)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// breakCJK breaks a line before any CJK character that would overflow width.
+// Other text is left as it is, so Latin words are never split.
+func breakCJK(line string, width int) string {
+	var b strings.Builder
+	col := 0
+	inEscape := false
+	for _, r := range line {
+		// Escape sequences take no columns
+		if r == ansi.Marker {
+			inEscape = true
+		}
+		if inEscape {
+			b.WriteRune(r)
+			inEscape = !ansi.IsTerminator(r)
+			continue
+		}
+
+		w := runewidth.RuneWidth(r)
+		if col > 0 && col+w > width && isCJK(r) {
+			b.WriteByte('\n')
+			col = 0
+		}
+		b.WriteRune(r)
+		col += w
+	}
+	return b.String()
+}
+
+// isCJK reports whether r is a Chinese, Japanese or Korean character, including
+// CJK punctuation and full-width forms
+func isCJK(r rune) bool {
+	switch {
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+		return true
+	case r >= 0x3000 && r <= 0x303F: // CJK symbols and punctuation
+		return true
+	case r >= 0xFF00 && r <= 0xFFEF: // Half-width and full-width forms
+		return true
+	}
+	return false
+}
+
+// wrapCache wraps text that only ever grows at its end, such as a streaming
+// response. Lines are wrapped on their own, so complete lines are wrapped once
+// and kept; each call only wraps what was added since, plus the last, still open
+// line. Text that doesn't extend the cached text, or a new width, starts over.
+type wrapCache struct {
+	width   int
+	done    string          // Complete lines wrapped so far, newline included
+	wrapped strings.Builder // done, wrapped
+}
+
+// wrap returns content wrapped to width, as wrapText would. A nil cache wraps
+// everything each time.
+func (c *wrapCache) wrap(content string, width int) string {
+	return c.wrapTail(content, width, 0)
+}
+
+// wrapTail is wrap keeping only the last limit bytes of the result, as
+// truncateTail would (0 = all of it). The whole content is wrapped and cached,
+// so the cut can move as the content grows without wrapping it all again.
+func (c *wrapCache) wrapTail(content string, width int, limit int) string {
+	if c == nil {
+		return truncateTail(wrapText(content, width), limit)
+	}
+	if width != c.width || !strings.HasPrefix(content, c.done) {
+		c.width = width
+		c.done = ""
+		c.wrapped.Reset()
+	}
+
+	cut := strings.LastIndexByte(content, '\n') + 1
+	if cut > len(c.done) {
+		// Wrapped with its newline, which keeps any spaces before it as a
+		// full wrap would
+		c.wrapped.WriteString(wrapText(content[len(c.done):cut], width))
+		c.done = content[:cut]
+	}
+	return truncateJoinedTail(c.wrapped.String(), wrapText(content[cut:], width), limit)
+}
@@ -0,0 +1,127 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/muesli/reflow/ansi"
+	"github.com/ron/tui_acp/tui/app"
+)
+
+// checkWidth fails the test if a line of wrapped is wider than width columns
+func checkWidth(t *testing.T, wrapped string, width int) {
+	t.Helper()
+	for _, line := range strings.Split(wrapped, "\n") {
+		if w := ansi.PrintableRuneWidth(line); w > width {
+			t.Errorf("line %q is %d columns, over %d", line, w, width)
+		}
+	}
+}
+
+func TestWrapTextBreaksCJK(t *testing.T) {
+	line := strings.Repeat("日本語のテキストは空白なしで続きます。", 5)
+	wrapped := wrapText(line, 20)
+
+	checkWidth(t, wrapped, 20)
+	if lines := strings.Count(wrapped, "\n") + 1; lines < 9 {
+		t.Errorf("wrapped into %d lines, want the text spread over at least 9", lines)
+	}
+	if joined := strings.ReplaceAll(wrapped, "\n", ""); joined != line {
+		t.Errorf("wrapping changed the text: %q", joined)
+	}
+}
+
+func TestWrapTextKeepsLatinWords(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  string
+	}{
+		{"words", "the quick brown fox jumps", 10, "the quick\nbrown fox\njumps"},
+		// A word longer than the width overflows rather than being split
+		{"long word", "a supercalifragilistic word", 10, "a\nsupercalifragilistic\nword"},
+		{"Korean with spaces", "안녕하세요 세계 여러분", 12, "안녕하세요\n세계 여러분"},
+		// Spaces are broken on first, then the CJK run that is still too long
+		{"mixed", "see 日本語のテキスト here", 12, "see\n日本語のテキ\nスト\nhere"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapText(tt.text, tt.width); got != tt.want {
+				t.Errorf("wrapText(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapTextCJKKeepsEscapes(t *testing.T) {
+	line := "\x1b[1m" + strings.Repeat("漢字", 10) + "\x1b[0m"
+	wrapped := wrapText(line, 8)
+
+	checkWidth(t, wrapped, 8)
+	if !strings.HasPrefix(wrapped, "\x1b[1m漢字漢字\n") || !strings.HasSuffix(wrapped, "漢字\x1b[0m") {
+		t.Errorf("wrapText = %q, want the styling kept around the broken text", wrapped)
+	}
+}
+
+func TestIsCJK(t *testing.T) {
+	tests := []struct {
+		r    rune
+		want bool
+	}{
+		{'漢', true},
+		{'ひ', true},
+		{'カ', true},
+		{'한', true},
+		{'。', true},
+		{'Ａ', true},
+		{'a', false},
+		{'é', false},
+		{'😀', false},
+	}
+
+	for _, tt := range tests {
+		if got := isCJK(tt.r); got != tt.want {
+			t.Errorf("isCJK(%q) = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestWrapTextMeasuresWideRunes(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  string
+	}{
+		// Each emoji is two columns, so four of them fill a line of eight
+		{"emoji", "😀😀 😀😀 😀😀", 9, "😀😀 😀😀\n😀😀"},
+		{"full-width words", "全角 全角 全角", 10, "全角 全角\n全角"},
+		{"mixed", "go 漢字 test 😀 ok", 10, "go 漢字\ntest 😀 ok"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapText(tt.text, tt.width)
+			if got != tt.want {
+				t.Errorf("wrapText(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+			}
+			checkWidth(t, got, tt.width)
+		})
+	}
+}
+
+func TestRenderMessageWideRunesFitWidth(t *testing.T) {
+	// The text wraps at 40 columns, leaving room for the style's indent
+	r := NewMessageRenderer(44)
+	msg := app.Message{Type: app.MessageAssistant, Content: strings.Repeat("字", 50) + " " + strings.Repeat("😀😀 ", 15)}
+
+	rendered := strings.TrimSuffix(r.RenderContinuation(msg), "\n")
+	checkWidth(t, rendered, 44)
+	// 100 columns of CJK, then 15 emoji pairs of 5 columns with their space,
+	// 8 to a line
+	if lines := strings.Split(rendered, "\n"); len(lines) != 5 {
+		t.Errorf("rendered %d lines, want 5: %q", len(lines), lines)
+	}
+}